@@ -0,0 +1,207 @@
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+// reader decodes Avro binary encoding from a byte slice.
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func (r *reader) long() (int64, error) {
+	u, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errShortBuffer
+	}
+	r.pos += n
+	return int64(u>>1) ^ -int64(u&1), nil // zig-zag
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, errShortBuffer
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	n, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(len(r.buf)-r.pos) {
+		return nil, errShortBuffer
+	}
+	return r.next(int(n))
+}
+
+// checkCount rejects a count of n items of at least size bytes each that
+// cannot fit in the remaining data, so a crafted count cannot make the
+// decoder loop or allocate without bound. Every item is taken to need at
+// least one byte.
+func (r *reader) checkCount(n int64, size int) error {
+	if n < 0 {
+		return fmt.Errorf("negative count %d", n)
+	}
+	size = max(size, 1)
+	if n > int64((len(r.buf)-r.pos)/size) {
+		return fmt.Errorf("count %d exceeds the remaining %d bytes", n, len(r.buf)-r.pos)
+	}
+	return nil
+}
+
+// minSize returns the fewest bytes a value of schema s can be encoded in.
+func minSize(s *schema, seen map[*schema]bool) int {
+	switch s.kind {
+	case "null":
+		return 0
+	case "float":
+		return 4
+	case "double":
+		return 8
+	case "fixed":
+		return s.size
+	case "record":
+		if seen[s] {
+			return 0
+		}
+		if seen == nil {
+			seen = make(map[*schema]bool)
+		}
+		seen[s] = true
+		n := 0
+		for _, f := range s.fields {
+			n += minSize(f.typ, seen)
+		}
+		delete(seen, s)
+		return n
+	default:
+		// boolean, int, long, bytes, string, enum and union take at least
+		// one byte, arrays and maps their terminating zero count.
+		return 1
+	}
+}
+
+// value decodes one value of schema s into its generic Go form: records
+// and maps become map[string]any, arrays []any, enums string, bytes and
+// fixed []byte.
+func (r *reader) value(s *schema) (any, error) {
+	switch s.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		return r.long()
+	case "float":
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		return r.bytes()
+	case "string":
+		b, err := r.bytes()
+		return string(b), err
+	case "fixed":
+		return r.next(s.size)
+	case "enum":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.symbols) {
+			return nil, fmt.Errorf("enum index %d out of range", i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.union) {
+			return nil, fmt.Errorf("union index %d out of range", i)
+		}
+		return r.value(s.union[i])
+	case "record":
+		out := make(map[string]any, len(s.fields))
+		for _, f := range s.fields {
+			v, err := r.value(f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", f.name, err)
+			}
+			out[f.name] = v
+		}
+		return out, nil
+	case "array":
+		var out []any
+		err := r.blocks(minSize(s.items, nil), func() error {
+			v, err := r.value(s.items)
+			out = append(out, v)
+			return err
+		})
+		return out, err
+	case "map":
+		out := make(map[string]any)
+		err := r.blocks(1+minSize(s.items, nil), func() error { // key and value
+			k, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			v, err := r.value(s.items)
+			out[string(k)] = v
+			return err
+		})
+		return out, err
+	default:
+		return nil, fmt.Errorf("unsupported type %q", s.kind)
+	}
+}
+
+// blocks reads the block-encoded items of an array or map, each taking at
+// least size bytes.
+func (r *reader) blocks(size int, item func() error) error {
+	for {
+		n, err := r.long()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+			if _, err := r.long(); err != nil { // block size in bytes
+				return err
+			}
+		}
+		if err := r.checkCount(n, size); err != nil {
+			return err
+		}
+		for ; n > 0; n-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
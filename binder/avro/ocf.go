@@ -0,0 +1,142 @@
+// Package avro provides core.Binder implementations for Avro payloads.
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// ocfMagic starts every Avro Object Container File.
+var ocfMagic = []byte{'O', 'b', 'j', 1}
+
+// ErrMalformedOCF is returned when a payload is not a valid Object
+// Container File.
+var ErrMalformedOCF = errors.New("eventmux/avro: malformed object container file")
+
+// OCFBinder decodes payloads that are Avro Object Container Files, where the
+// writer schema is embedded in the file header. This is distinct from the
+// Confluent wire format, which carries only a schema registry ID.
+//
+// Records are decoded against the embedded schema and then bound to v with
+// JSON, so struct fields map to Avro field names through json tags (or the
+// JSON binder's SnakeCase). If v points to a slice, every record in the file
+// is bound; otherwise the file must contain exactly one record.
+//
+// The "null" and "deflate" codecs are supported.
+type OCFBinder struct {
+	// JSON controls how decoded records are mapped onto v.
+	JSON core.JSONBinder
+}
+
+// Bind implements core.Binder.
+func (b OCFBinder) Bind(msg core.Message, v any) error {
+	records, err := DecodeOCF(msg.Value())
+	if err != nil {
+		return err
+	}
+
+	var out any = records
+	if t := reflect.TypeOf(v); t == nil || t.Kind() != reflect.Pointer ||
+		(t.Elem().Kind() != reflect.Slice && t.Elem().Kind() != reflect.Array) {
+		if len(records) != 1 {
+			return fmt.Errorf("eventmux/avro: bind %d records into %T; use a slice", len(records), v)
+		}
+		out = records[0]
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("eventmux/avro: bind: %w", err)
+	}
+	return b.JSON.Bind(&jsonMessage{Message: msg, value: data}, v)
+}
+
+// jsonMessage presents decoded records as a JSON payload.
+type jsonMessage struct {
+	core.Message
+	value []byte
+}
+
+func (m *jsonMessage) Value() []byte { return m.value }
+
+// DecodeOCF decodes every record in an Object Container File into its
+// generic form (records as map[string]any).
+func DecodeOCF(data []byte) ([]any, error) {
+	if !bytes.HasPrefix(data, ocfMagic) {
+		return nil, fmt.Errorf("%w: missing magic bytes", ErrMalformedOCF)
+	}
+	r := &reader{buf: data, pos: len(ocfMagic)}
+
+	meta, err := r.value(&schema{kind: "map", items: &schema{kind: "bytes"}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformedOCF, err)
+	}
+	header := meta.(map[string]any)
+	rawSchema, _ := header["avro.schema"].([]byte)
+	if len(rawSchema) == 0 {
+		return nil, fmt.Errorf("%w: header has no avro.schema", ErrMalformedOCF)
+	}
+	s, err := parseSchema(rawSchema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedOCF, err)
+	}
+	codec, _ := header["avro.codec"].([]byte)
+
+	sync, err := r.next(16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformedOCF, err)
+	}
+
+	var records []any
+	for r.pos < len(r.buf) {
+		count, err := r.long()
+		if err != nil {
+			return nil, fmt.Errorf("%w: block: %v", ErrMalformedOCF, err)
+		}
+		block, err := r.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("%w: block: %v", ErrMalformedOCF, err)
+		}
+		if block, err = decompress(string(codec), block); err != nil {
+			return nil, err
+		}
+		br := &reader{buf: block}
+		if err := br.checkCount(count, minSize(s, nil)); err != nil {
+			return nil, fmt.Errorf("%w: block: %v", ErrMalformedOCF, err)
+		}
+		for i := int64(0); i < count; i++ {
+			rec, err := br.value(s)
+			if err != nil {
+				return nil, fmt.Errorf("eventmux/avro: record %d: %w", len(records), err)
+			}
+			records = append(records, rec)
+		}
+		marker, err := r.next(16)
+		if err != nil || !bytes.Equal(marker, sync) {
+			return nil, fmt.Errorf("%w: bad sync marker", ErrMalformedOCF)
+		}
+	}
+	return records, nil
+}
+
+func decompress(codec string, block []byte) ([]byte, error) {
+	switch codec {
+	case "", "null":
+		return block, nil
+	case "deflate":
+		out, err := io.ReadAll(flate.NewReader(bytes.NewReader(block)))
+		if err != nil {
+			return nil, fmt.Errorf("eventmux/avro: deflate: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("eventmux/avro: unsupported codec %q", codec)
+	}
+}
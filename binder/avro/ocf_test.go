@@ -0,0 +1,212 @@
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+const orderSchema = `{
+	"type": "record", "name": "Order", "namespace": "shop",
+	"fields": [
+		{"name": "order_id", "type": "string"},
+		{"name": "amount", "type": "long"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "note", "type": ["null", "string"]}
+	]
+}`
+
+type testOrder struct {
+	OrderID string   `json:"order_id"`
+	Amount  int64    `json:"amount"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+	Note    *string  `json:"note"`
+}
+
+// encoder writes Avro binary encoding for building test fixtures.
+type encoder struct{ bytes.Buffer }
+
+func (e *encoder) long(v int64) {
+	e.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (e *encoder) str(s string) {
+	e.long(int64(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) order(id string, amount int64, tags []string, status int64, note *string) {
+	e.str(id)
+	e.long(amount)
+	if len(tags) > 0 {
+		e.long(int64(len(tags)))
+		for _, t := range tags {
+			e.str(t)
+		}
+	}
+	e.long(0)
+	e.long(status)
+	if note == nil {
+		e.long(0)
+	} else {
+		e.long(1)
+		e.str(*note)
+	}
+}
+
+// ocf wraps encoded records in an Object Container File with one block.
+func ocf(codec string, count int64, records []byte) []byte {
+	var f encoder
+	f.Write(ocfMagic)
+	f.long(2)
+	f.str("avro.schema")
+	f.str(orderSchema)
+	f.str("avro.codec")
+	f.str(codec)
+	f.long(0)
+	sync := bytes.Repeat([]byte{0xAB}, 16)
+	f.Write(sync)
+
+	if codec == "deflate" {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write(records)
+		w.Close()
+		records = buf.Bytes()
+	}
+	f.long(count)
+	f.long(int64(len(records)))
+	f.Write(records)
+	f.Write(sync)
+	return f.Bytes()
+}
+
+func TestOCFBinder_SingleRecord(t *testing.T) {
+	note := "gift"
+	var recs encoder
+	recs.order("o-1", 4200, []string{"a", "b"}, 1, &note)
+
+	msg := &mock.Message{V: ocf("null", 1, recs.Bytes())}
+	var got testOrder
+	if err := (OCFBinder{}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+
+	if got.OrderID != "o-1" || got.Amount != 4200 || got.Status != "PAID" {
+		t.Errorf("got %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "b" {
+		t.Errorf("tags = %v, want [a b]", got.Tags)
+	}
+	if got.Note == nil || *got.Note != "gift" {
+		t.Errorf("note = %v, want gift", got.Note)
+	}
+}
+
+func TestOCFBinder_MultipleRecords(t *testing.T) {
+	for _, codec := range []string{"null", "deflate"} {
+		t.Run(codec, func(t *testing.T) {
+			var recs encoder
+			recs.order("o-1", 1, nil, 0, nil)
+			recs.order("o-2", 2, []string{"x"}, 1, nil)
+			recs.order("o-3", 3, nil, 0, nil)
+
+			msg := &mock.Message{V: ocf(codec, 3, recs.Bytes())}
+			var got []testOrder
+			if err := (OCFBinder{}).Bind(msg, &got); err != nil {
+				t.Fatalf("bind: %v", err)
+			}
+			if len(got) != 3 {
+				t.Fatalf("got %d records, want 3", len(got))
+			}
+			for i, want := range []string{"o-1", "o-2", "o-3"} {
+				if got[i].OrderID != want || got[i].Amount != int64(i+1) {
+					t.Errorf("record %d = %+v", i, got[i])
+				}
+			}
+			if got[0].Note != nil {
+				t.Errorf("null union should bind to nil, got %v", *got[0].Note)
+			}
+
+			var single testOrder
+			if err := (OCFBinder{}).Bind(msg, &single); err == nil {
+				t.Error("binding multiple records into a struct should fail")
+			}
+		})
+	}
+}
+
+func TestOCFBinder_Malformed(t *testing.T) {
+	var recs encoder
+	recs.order("o-1", 1, nil, 0, nil)
+	valid := ocf("null", 1, recs.Bytes())
+
+	tests := map[string][]byte{
+		"not ocf":        []byte(`{"order_id":"o-1"}`),
+		"truncated":      valid[:10],
+		"bad sync":       append(valid[:len(valid)-1:len(valid)-1], 0x00),
+		"missing schema": append(append([]byte{}, ocfMagic...), 0),
+	}
+	for name, payload := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got testOrder
+			err := (OCFBinder{}).Bind(&mock.Message{V: payload}, &got)
+			if !errors.Is(err, ErrMalformedOCF) {
+				t.Errorf("expected ErrMalformedOCF, got %v", err)
+			}
+		})
+	}
+}
+
+// TestDecode_CraftedCounts covers lengths and counts near the int64 range,
+// which must be rejected rather than overflow a bounds check or drive an
+// unbounded allocation.
+func TestDecode_CraftedCounts(t *testing.T) {
+	const huge = int64(1) << 62
+
+	var longString encoder
+	longString.long(math.MaxInt64) // order_id length, overflowing pos+n
+	longString.WriteString("o-1")
+
+	var longArray encoder
+	longArray.str("o-1")
+	longArray.long(1)
+	longArray.long(huge) // tags count
+
+	var negBlock encoder
+	negBlock.str("o-1")
+	negBlock.long(1)
+	negBlock.long(-huge) // tags count, with a block size
+	negBlock.long(1)
+
+	tests := map[string][]byte{
+		"string length": ocf("null", 1, longString.Bytes()),
+		"array count":   ocf("null", 1, longArray.Bytes()),
+		"block count":   ocf("null", 1, negBlock.Bytes()),
+		"record count":  ocf("null", huge, nil),
+	}
+	for name, payload := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeOCF(payload); err == nil {
+				t.Error("expected an error for the crafted payload")
+			}
+		})
+	}
+}
+
+func FuzzDecodeOCF(f *testing.F) {
+	var recs encoder
+	recs.order("o-1", 1, []string{"a", "b"}, 1, nil)
+	f.Add(ocf("null", 1, recs.Bytes()))
+	f.Add(ocf("deflate", 1, recs.Bytes()))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = DecodeOCF(data) // must not panic
+	})
+}
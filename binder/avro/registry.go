@@ -0,0 +1,288 @@
+package avro
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// ErrUnknownSchema is returned when the registry has no schema for an ID.
+	ErrUnknownSchema = errors.New("eventmux/avro: unknown schema id")
+
+	// ErrMalformedWireFormat is returned when a payload does not start with
+	// the Confluent wire format header.
+	ErrMalformedWireFormat = errors.New("eventmux/avro: malformed wire format")
+)
+
+// SchemaFetcher retrieves writer schemas, in their JSON form, by registry
+// ID. It returns an error wrapping ErrUnknownSchema if the ID does not exist.
+type SchemaFetcher interface {
+	FetchSchema(ctx context.Context, id int) (string, error)
+}
+
+// HTTPRegistry fetches schemas from a Confluent-compatible schema registry.
+type HTTPRegistry struct {
+	// URL is the registry base URL, e.g. "http://registry:8081".
+	URL string
+	// Client is used for requests; http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// FetchSchema implements SchemaFetcher.
+func (h HTTPRegistry) FetchSchema(ctx context.Context, id int) (string, error) {
+	url := strings.TrimSuffix(h.URL, "/") + "/schemas/ids/" + strconv.Itoa(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("eventmux/avro: fetch schema %d: %w", id, err)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("eventmux/avro: fetch schema %d: %w", id, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %d", ErrUnknownSchema, id)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("eventmux/avro: fetch schema %d: registry returned %s", id, resp.Status)
+	}
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("eventmux/avro: fetch schema %d: %w", id, err)
+	}
+	return body.Schema, nil
+}
+
+// CacheOption configures a SchemaCache.
+type CacheOption func(*SchemaCache)
+
+// WithTTL sets how long a schema is served before it is refreshed in the
+// background. The default is 5 minutes.
+func WithTTL(d time.Duration) CacheOption {
+	return func(c *SchemaCache) { c.ttl = d }
+}
+
+// WithNegativeTTL sets how long an unknown ID is remembered, so bad
+// messages do not hit the registry every time. The default is 30 seconds.
+func WithNegativeTTL(d time.Duration) CacheOption {
+	return func(c *SchemaCache) { c.negativeTTL = d }
+}
+
+// WithMaxUnknown sets how many unknown IDs are remembered at once, keeping
+// the cache bounded when payloads carry random IDs. Beyond it the oldest
+// unknown ID is forgotten. The default is 1000; zero or less disables
+// negative caching.
+func WithMaxUnknown(n int) CacheOption {
+	return func(c *SchemaCache) { c.maxUnknown = n }
+}
+
+// WithFetchTimeout bounds each registry fetch, so a hung registry fails
+// the Bind instead of blocking the handler. The default is 10 seconds; zero
+// or less leaves fetches unbounded.
+func WithFetchTimeout(d time.Duration) CacheOption {
+	return func(c *SchemaCache) { c.fetchTimeout = d }
+}
+
+// CacheStats counts SchemaCache lookups.
+type CacheStats struct {
+	Hits   uint64 // served from the cache, including unknown IDs
+	Misses uint64 // fetched from the registry before being served
+	Stale  uint64 // background refreshes that failed, leaving the old schema
+}
+
+// SchemaCache caches parsed schemas by registry ID. Expired schemas keep
+// being served while a background refresh runs, and if the registry is
+// unavailable the stale schema is served until a later refresh succeeds.
+// Concurrent misses for the same ID share one fetch. It is safe for
+// concurrent use.
+type SchemaCache struct {
+	fetcher      SchemaFetcher
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	maxUnknown   int
+	fetchTimeout time.Duration
+	now          func() time.Time
+
+	mu      sync.Mutex
+	entries map[int]*cacheEntry
+	unknown int // entries with a nil schema
+	loads   singleflight.Group
+
+	hits, misses, stale atomic.Uint64
+}
+
+type cacheEntry struct {
+	schema     *schema // nil for an unknown ID
+	fetched    time.Time
+	refreshing bool
+}
+
+// NewSchemaCache returns a SchemaCache that loads schemas with f.
+func NewSchemaCache(f SchemaFetcher, opts ...CacheOption) *SchemaCache {
+	c := &SchemaCache{
+		fetcher:      f,
+		ttl:          5 * time.Minute,
+		negativeTTL:  30 * time.Second,
+		maxUnknown:   1000,
+		fetchTimeout: 10 * time.Second,
+		now:          time.Now,
+		entries:      make(map[int]*cacheEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Stats returns the cache counters.
+func (c *SchemaCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Stale: c.stale.Load()}
+}
+
+// get returns the parsed schema for id.
+func (c *SchemaCache) get(id int) (*schema, error) {
+	c.mu.Lock()
+	e := c.entries[id]
+	age := time.Duration(0)
+	if e != nil {
+		age = c.now().Sub(e.fetched)
+	}
+	switch {
+	case e != nil && e.schema == nil && age < c.negativeTTL:
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSchema, id)
+	case e != nil && e.schema != nil:
+		if age >= c.ttl && !e.refreshing {
+			e.refreshing = true
+			go c.refresh(id, e)
+		}
+		s := e.schema
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return s, nil
+	}
+	c.mu.Unlock()
+
+	v, err, _ := c.loads.Do(strconv.Itoa(id), func() (any, error) {
+		c.misses.Add(1)
+		s, err := c.fetch(id)
+		if err != nil && !errors.Is(err, ErrUnknownSchema) {
+			return nil, err // registry unavailable: nothing to serve, don't cache
+		}
+		c.mu.Lock()
+		c.put(id, &cacheEntry{schema: s, fetched: c.now()})
+		c.mu.Unlock()
+		return s, err
+	})
+	s, _ := v.(*schema)
+	return s, err
+}
+
+// put stores e for id, forgetting the oldest unknown ID if e would take
+// the number of unknown IDs past maxUnknown. c.mu must be held.
+func (c *SchemaCache) put(id int, e *cacheEntry) {
+	if old := c.entries[id]; old != nil && old.schema == nil {
+		c.unknown--
+		delete(c.entries, id)
+	}
+	if e.schema == nil {
+		if c.maxUnknown <= 0 {
+			return
+		}
+		if c.unknown >= c.maxUnknown {
+			oldest := -1
+			for k, v := range c.entries {
+				if v.schema == nil && (oldest < 0 || v.fetched.Before(c.entries[oldest].fetched)) {
+					oldest = k
+				}
+			}
+			delete(c.entries, oldest)
+			c.unknown--
+		}
+		c.unknown++
+	}
+	c.entries[id] = e
+}
+
+// refresh reloads e in the background, keeping the stale schema on failure.
+func (c *SchemaCache) refresh(id int, e *cacheEntry) {
+	s, err := c.fetch(id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refreshing = false
+	e.fetched = c.now()
+	if err != nil {
+		c.stale.Add(1)
+		return
+	}
+	e.schema = s
+}
+
+func (c *SchemaCache) fetch(id int) (*schema, error) {
+	ctx := context.Background()
+	if c.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.fetchTimeout)
+		defer cancel()
+	}
+	raw, err := c.fetcher.FetchSchema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSchema([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/avro: schema %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// RegistryBinder decodes payloads in the Confluent wire format: a zero magic
+// byte, a 4-byte big-endian schema ID, then one Avro-encoded record. Writer
+// schemas are looked up through Schemas. As with OCFBinder, the record is
+// bound to v with JSON.
+type RegistryBinder struct {
+	Schemas *SchemaCache
+	// JSON controls how decoded records are mapped onto v.
+	JSON core.JSONBinder
+}
+
+// Bind implements core.Binder.
+func (b RegistryBinder) Bind(msg core.Message, v any) error {
+	data := msg.Value()
+	if len(data) < 5 || data[0] != 0 {
+		return ErrMalformedWireFormat
+	}
+	s, err := b.Schemas.get(int(binary.BigEndian.Uint32(data[1:5])))
+	if err != nil {
+		return err
+	}
+	r := &reader{buf: data[5:]}
+	rec, err := r.value(s)
+	if err != nil {
+		return fmt.Errorf("eventmux/avro: record: %w", err)
+	}
+	out, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("eventmux/avro: bind: %w", err)
+	}
+	return b.JSON.Bind(&jsonMessage{Message: msg, value: out}, v)
+}
@@ -0,0 +1,308 @@
+package avro
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// fakeRegistry serves orderSchema for ID 1 and counts fetches.
+type fakeRegistry struct {
+	mu    sync.Mutex
+	calls int
+	down  bool
+}
+
+func (f *fakeRegistry) FetchSchema(_ context.Context, id int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	switch {
+	case f.down:
+		return "", errors.New("connection refused")
+	case id != 1:
+		return "", ErrUnknownSchema
+	}
+	return orderSchema, nil
+}
+
+func (f *fakeRegistry) fetches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeRegistry) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// clock is a manually advanced time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCache(f SchemaFetcher) (*SchemaCache, *clock) {
+	clk := &clock{now: time.Unix(0, 0)}
+	c := NewSchemaCache(f, WithTTL(time.Minute), WithNegativeTTL(10*time.Second))
+	c.now = clk.Now
+	return c, clk
+}
+
+// wireMessage encodes one order in the Confluent wire format.
+func wireMessage(id uint32, orderID string) *mock.Message {
+	var e encoder
+	e.WriteByte(0)
+	e.Write(binary.BigEndian.AppendUint32(nil, id))
+	e.order(orderID, 10, nil, 0, nil)
+	return &mock.Message{V: e.Bytes()}
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRegistryBinder(t *testing.T) {
+	f := &fakeRegistry{}
+	cache, _ := newTestCache(f)
+	b := RegistryBinder{Schemas: cache}
+
+	for _, id := range []string{"o-1", "o-2", "o-3"} {
+		var got testOrder
+		if err := b.Bind(wireMessage(1, id), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.OrderID != id || got.Amount != 10 || got.Status != "NEW" {
+			t.Errorf("bound %+v", got)
+		}
+	}
+	if f.fetches() != 1 {
+		t.Errorf("registry fetched %d times, want 1", f.fetches())
+	}
+	if s := cache.Stats(); s.Misses != 1 || s.Hits != 2 {
+		t.Errorf("stats = %+v, want 1 miss and 2 hits", s)
+	}
+}
+
+func TestRegistryBinder_Malformed(t *testing.T) {
+	b := RegistryBinder{Schemas: NewSchemaCache(&fakeRegistry{})}
+	var got testOrder
+	if err := b.Bind(&mock.Message{V: []byte{1, 0, 0, 0, 1}}, &got); !errors.Is(err, ErrMalformedWireFormat) {
+		t.Errorf("err = %v, want ErrMalformedWireFormat", err)
+	}
+}
+
+func TestSchemaCache_NegativeCaching(t *testing.T) {
+	f := &fakeRegistry{}
+	cache, clk := newTestCache(f)
+	b := RegistryBinder{Schemas: cache}
+
+	var got testOrder
+	for range 3 {
+		if err := b.Bind(wireMessage(7, "x"), &got); !errors.Is(err, ErrUnknownSchema) {
+			t.Fatalf("err = %v, want ErrUnknownSchema", err)
+		}
+	}
+	if f.fetches() != 1 {
+		t.Errorf("registry fetched %d times for an unknown id, want 1", f.fetches())
+	}
+
+	clk.advance(10 * time.Second)
+	b.Bind(wireMessage(7, "x"), &got)
+	if f.fetches() != 2 {
+		t.Errorf("unknown id not re-fetched after the negative TTL: %d fetches", f.fetches())
+	}
+}
+
+func TestSchemaCache_TTLRefresh(t *testing.T) {
+	f := &fakeRegistry{}
+	cache, clk := newTestCache(f)
+	b := RegistryBinder{Schemas: cache}
+
+	var got testOrder
+	if err := b.Bind(wireMessage(1, "a"), &got); err != nil {
+		t.Fatal(err)
+	}
+	clk.advance(time.Minute)
+	if err := b.Bind(wireMessage(1, "b"), &got); err != nil {
+		t.Fatalf("expired schema should be served during refresh: %v", err)
+	}
+	waitFor(t, func() bool { return f.fetches() == 2 })
+
+	// Refreshed: no further fetch until the TTL passes again.
+	waitFor(t, func() bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return !cache.entries[1].refreshing
+	})
+	b.Bind(wireMessage(1, "c"), &got)
+	if f.fetches() != 2 {
+		t.Errorf("fetched %d times, want 2", f.fetches())
+	}
+}
+
+func TestSchemaCache_ServesStaleWhenRegistryDown(t *testing.T) {
+	f := &fakeRegistry{}
+	cache, clk := newTestCache(f)
+	b := RegistryBinder{Schemas: cache}
+
+	var got testOrder
+	if err := b.Bind(wireMessage(1, "a"), &got); err != nil {
+		t.Fatal(err)
+	}
+	f.setDown(true)
+	clk.advance(2 * time.Minute)
+
+	if err := b.Bind(wireMessage(1, "b"), &got); err != nil || got.OrderID != "b" {
+		t.Fatalf("stale schema not served: %v", err)
+	}
+	waitFor(t, func() bool { return cache.Stats().Stale == 1 })
+	if err := b.Bind(wireMessage(1, "c"), &got); err != nil || got.OrderID != "c" {
+		t.Fatalf("stale schema not served after failed refresh: %v", err)
+	}
+
+	// With nothing cached, an unavailable registry is an error.
+	if err := b.Bind(wireMessage(2, "d"), &got); err == nil || errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("err = %v, want the registry error", err)
+	}
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"schema": "\"string\""}`))
+	}))
+	defer srv.Close()
+	reg := HTTPRegistry{URL: srv.URL + "/"}
+
+	s, err := reg.FetchSchema(context.Background(), 1)
+	if err != nil || s != `"string"` {
+		t.Errorf("FetchSchema(1) = %q, %v", s, err)
+	}
+	if _, err := reg.FetchSchema(context.Background(), 2); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("FetchSchema(2) err = %v, want ErrUnknownSchema", err)
+	}
+}
+
+func TestRegistryBinder_CraftedLength(t *testing.T) {
+	b := RegistryBinder{Schemas: NewSchemaCache(&fakeRegistry{})}
+
+	// Schema 1, then an order_id length of MaxInt64: 15 bytes in all.
+	var e encoder
+	e.WriteByte(0)
+	e.Write(binary.BigEndian.AppendUint32(nil, 1))
+	e.long(math.MaxInt64)
+	var got testOrder
+	if err := b.Bind(&mock.Message{V: e.Bytes()}, &got); err == nil {
+		t.Error("expected an error for the crafted length")
+	}
+}
+
+// blockingRegistry blocks each fetch until release is closed or the fetch
+// context ends.
+type blockingRegistry struct {
+	fakeRegistry
+	release chan struct{}
+}
+
+func (f *blockingRegistry) FetchSchema(ctx context.Context, id int) (string, error) {
+	select {
+	case <-f.release:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return f.fakeRegistry.FetchSchema(ctx, id)
+}
+
+func TestSchemaCache_FetchTimeout(t *testing.T) {
+	f := &blockingRegistry{release: make(chan struct{})}
+	b := RegistryBinder{Schemas: NewSchemaCache(f, WithFetchTimeout(20*time.Millisecond))}
+
+	var got testOrder
+	if err := b.Bind(wireMessage(1, "a"), &got); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the fetch to time out", err)
+	}
+}
+
+func TestSchemaCache_CoalescesMisses(t *testing.T) {
+	f := &blockingRegistry{release: make(chan struct{})}
+	cache := NewSchemaCache(f)
+	b := RegistryBinder{Schemas: cache}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got testOrder
+			if err := b.Bind(wireMessage(1, "a"), &got); err != nil {
+				t.Errorf("bind: %v", err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // let every Bind reach the fetch
+	close(f.release)
+	wg.Wait()
+
+	if n := f.fetches(); n != 1 {
+		t.Errorf("registry fetched %d times for concurrent misses, want 1", n)
+	}
+}
+
+func TestSchemaCache_MaxUnknown(t *testing.T) {
+	f := &fakeRegistry{}
+	cache, clk := newTestCache(f)
+	WithMaxUnknown(2)(cache)
+	b := RegistryBinder{Schemas: cache}
+
+	var got testOrder
+	for _, id := range []uint32{7, 8, 9} {
+		b.Bind(wireMessage(id, "x"), &got)
+		clk.advance(time.Second)
+	}
+	cache.mu.Lock()
+	n := len(cache.entries)
+	_, kept := cache.entries[9]
+	cache.mu.Unlock()
+	if n != 2 || !kept {
+		t.Errorf("cached %d unknown ids (newest kept: %v), want the 2 newest", n, kept)
+	}
+
+	b.Bind(wireMessage(7, "x"), &got)
+	if f.fetches() != 4 {
+		t.Errorf("fetched %d times, want the oldest unknown id fetched again", f.fetches())
+	}
+}
@@ -0,0 +1,155 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// schema is a parsed Avro schema node.
+type schema struct {
+	kind    string    // primitive name, "record", "enum", "array", "map", "fixed" or "union"
+	fields  []field   // record
+	symbols []string  // enum
+	items   *schema   // array, map values
+	size    int       // fixed
+	union   []*schema // union branches
+}
+
+type field struct {
+	name string
+	typ  *schema
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseSchema parses an Avro schema in its JSON form.
+func parseSchema(data []byte) (*schema, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	p := &schemaParser{named: make(map[string]*schema)}
+	return p.parse(raw, "")
+}
+
+type schemaParser struct {
+	named map[string]*schema // by full name
+}
+
+func (p *schemaParser) parse(raw any, namespace string) (*schema, error) {
+	switch v := raw.(type) {
+	case string:
+		if primitives[v] {
+			return &schema{kind: v}, nil
+		}
+		if s, ok := p.named[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", v)
+	case []any:
+		s := &schema{kind: "union"}
+		for _, branch := range v {
+			bs, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.union = append(s.union, bs)
+		}
+		return s, nil
+	case map[string]any:
+		return p.parseComplex(v, namespace)
+	default:
+		return nil, fmt.Errorf("invalid schema node %v", raw)
+	}
+}
+
+func (p *schemaParser) parseComplex(v map[string]any, namespace string) (*schema, error) {
+	typ, _ := v["type"].(string)
+	if ns, ok := v["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	switch typ {
+	case "record", "error":
+		s := &schema{kind: "record"}
+		if err := p.define(v, namespace, s); err != nil {
+			return nil, err
+		}
+		fields, _ := v["fields"].([]any)
+		for _, f := range fields {
+			fm, ok := f.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid record field %v", f)
+			}
+			name, _ := fm["name"].(string)
+			ft, err := p.parse(fm["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			s.fields = append(s.fields, field{name: name, typ: ft})
+		}
+		return s, nil
+	case "enum":
+		s := &schema{kind: "enum"}
+		if err := p.define(v, namespace, s); err != nil {
+			return nil, err
+		}
+		symbols, _ := v["symbols"].([]any)
+		for _, sym := range symbols {
+			str, _ := sym.(string)
+			s.symbols = append(s.symbols, str)
+		}
+		return s, nil
+	case "fixed":
+		s := &schema{kind: "fixed"}
+		if err := p.define(v, namespace, s); err != nil {
+			return nil, err
+		}
+		size, _ := v["size"].(float64)
+		s.size = int(size)
+		return s, nil
+	case "array":
+		items, err := p.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: "array", items: items}, nil
+	case "map":
+		values, err := p.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: "map", items: values}, nil
+	default:
+		// A primitive, possibly annotated with a logicalType; the logical
+		// type is decoded as its underlying representation.
+		return p.parse(v["type"], namespace)
+	}
+}
+
+// define registers a named schema so later nodes can reference it.
+func (p *schemaParser) define(v map[string]any, namespace string, s *schema) error {
+	name, _ := v["name"].(string)
+	if name == "" {
+		return fmt.Errorf("named type without a name")
+	}
+	p.named[fullName(name, namespace)] = s
+	if short := name[strings.LastIndex(name, ".")+1:]; short != name {
+		p.named[short] = s
+	}
+	return nil
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,104 @@
+// Package msgpack provides a core.Binder and a matching encoder for
+// MessagePack payloads, a compact binary alternative to JSON that keeps
+// JSON's schemaless data model. Struct fields are matched by msgpack tags,
+// and optionally by the json tags of types shared with JSON producers.
+package msgpack
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ContentType is the content-type header value set by NewMessage.
+const ContentType = "application/msgpack"
+
+// Binder decodes MessagePack payloads. Select it with core.WithBinder or
+// Router.SetBinder.
+//
+// Struct fields map to MessagePack keys through msgpack tags, falling back to
+// the field name. With UseJSONTag, json tags are used for fields that have no
+// msgpack tag, so types shared with JSON producers need no extra tags.
+type Binder struct {
+	// AllowEmpty makes Bind a no-op for empty payloads, leaving v at its
+	// current value. By default empty payloads return core.ErrEmptyPayload.
+	AllowEmpty bool
+
+	// UseJSONTag falls back to json tags for fields without a msgpack tag.
+	UseJSONTag bool
+}
+
+// Bind implements core.Binder.
+func (b Binder) Bind(msg core.Message, v any) error {
+	if msg == nil || len(msg.Value()) == 0 {
+		if b.AllowEmpty {
+			return nil
+		}
+		return core.ErrEmptyPayload
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(msg.Value()))
+	if b.UseJSONTag {
+		dec.SetCustomStructTag("json")
+	}
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("eventmux/msgpack: bind: %w", err)
+	}
+	return nil
+}
+
+// Encoder encodes values as MessagePack for publishing, mirroring the
+// Binder that decodes them.
+type Encoder struct {
+	// UseJSONTag falls back to json tags for fields without a msgpack tag.
+	UseJSONTag bool
+}
+
+// Encode returns the MessagePack encoding of v.
+func (e Encoder) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	if e.UseJSONTag {
+		enc.SetCustomStructTag("json")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("eventmux/msgpack: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// NewMessage encodes v and returns it as a message ready for Router.Publish,
+// with the content-type header set to ContentType.
+func (e Encoder) NewMessage(key []byte, v any) (core.Message, error) {
+	value, err := e.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return &message{key: key, value: value}, nil
+}
+
+// Marshal encodes v with the default Encoder.
+func Marshal(v any) ([]byte, error) {
+	return Encoder{}.Encode(v)
+}
+
+// NewMessage encodes v with the default Encoder and returns it as a message
+// ready for Router.Publish.
+func NewMessage(key []byte, v any) (core.Message, error) {
+	return Encoder{}.NewMessage(key, v)
+}
+
+// message is an outbound MessagePack message. Ack and Nack are no-ops.
+type message struct {
+	key   []byte
+	value []byte
+}
+
+func (m *message) Key() []byte   { return m.key }
+func (m *message) Value() []byte { return m.value }
+func (m *message) Headers() map[string]string {
+	return map[string]string{"content-type": ContentType}
+}
+func (m *message) Ack() error  { return nil }
+func (m *message) Nack() error { return nil }
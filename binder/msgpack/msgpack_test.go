@@ -0,0 +1,126 @@
+package msgpack
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+type testOrder struct {
+	OrderID string            `msgpack:"order_id"`
+	Amount  int64             `msgpack:"amount"`
+	Tags    []string          `msgpack:"tags"`
+	Meta    map[string]string `msgpack:"meta"`
+	Note    *string           `msgpack:"note"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	note := "gift"
+	in := testOrder{OrderID: "o-1", Amount: 4200, Tags: []string{"a", "b"}, Meta: map[string]string{"k": "v"}, Note: &note}
+
+	msg, err := NewMessage([]byte("o-1"), in)
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+	if got := msg.Headers()["content-type"]; got != ContentType {
+		t.Errorf("content-type = %q", got)
+	}
+	if string(msg.Key()) != "o-1" {
+		t.Errorf("key = %q", msg.Key())
+	}
+
+	var out testOrder
+	if err := (Binder{}).Bind(msg, &out); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if out.OrderID != in.OrderID || out.Amount != in.Amount || len(out.Tags) != 2 ||
+		out.Meta["k"] != "v" || out.Note == nil || *out.Note != note {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+func TestRoundTrip_JSONTags(t *testing.T) {
+	type event struct {
+		ID   string `json:"id"`
+		Kind string `msgpack:"type" json:"kind"`
+	}
+	data, err := Encoder{UseJSONTag: true}.Encode(event{ID: "e-1", Kind: "created"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	var generic map[string]any
+	if err := (Binder{}).Bind(&mock.Message{V: data}, &generic); err != nil {
+		t.Fatalf("bind map: %v", err)
+	}
+	if generic["id"] != "e-1" || generic["type"] != "created" {
+		t.Errorf("keys = %v, want id and type", generic)
+	}
+
+	var out event
+	if err := (Binder{UseJSONTag: true}).Bind(&mock.Message{V: data}, &out); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if out.ID != "e-1" || out.Kind != "created" {
+		t.Errorf("out = %+v", out)
+	}
+}
+
+func TestBind_Corrupt(t *testing.T) {
+	data, _ := Marshal(testOrder{OrderID: "o-1", Tags: []string{"a"}})
+	for name, v := range map[string][]byte{
+		"truncated":    data[:len(data)-2],
+		"invalid code": {0xc1},
+		"wrong type":   mustMarshal(t, "not a struct"),
+	} {
+		var out testOrder
+		err := (Binder{}).Bind(&mock.Message{V: v}, &out)
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "eventmux/msgpack: bind: ") {
+			t.Errorf("%s: error = %v", name, err)
+		}
+	}
+}
+
+func TestBind_Empty(t *testing.T) {
+	var out testOrder
+	if err := (Binder{}).Bind(&mock.Message{}, &out); !errors.Is(err, core.ErrEmptyPayload) {
+		t.Errorf("err = %v, want ErrEmptyPayload", err)
+	}
+	if err := (Binder{AllowEmpty: true}).Bind(&mock.Message{}, &out); err != nil {
+		t.Errorf("AllowEmpty: %v", err)
+	}
+}
+
+func TestBind_ViaRouter(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b, core.WithBinder(Binder{}))
+
+	var got testOrder
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		return core.Bind(ctx, msg, &got)
+	})
+	data, _ := Marshal(testOrder{OrderID: "o-7"})
+	if err := r.Dispatch(context.Background(), "orders", &mock.Message{V: data}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if got.OrderID != "o-7" {
+		t.Errorf("bound %+v", got)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,218 @@
+// Package protobuf bridges protobuf and JSON payloads through protojson, for
+// services whose producers and consumers disagree on the format. Binder
+// binds protobuf wire-format payloads into plain Go structs written for
+// JSON, and Encoder turns structs or raw JSON into protobuf wire format for
+// a registered message type. Field names follow protojson, so the JSON shape
+// of a struct must match the message's JSON mapping.
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ContentType is the content-type header value set by NewMessage.
+const ContentType = "application/x-protobuf"
+
+// HeaderMessageType carries the full name of the protobuf message in the
+// payload, e.g. "shop.v1.Order". NewMessage sets it; Binder and Encoder use
+// it to look the type up when none is configured.
+const HeaderMessageType = "x-eventmux-proto-type"
+
+// Binder decodes protobuf wire-format payloads. Select it with
+// core.WithBinder or Router.SetBinder.
+//
+// If v is a proto.Message, the payload is unmarshaled into it directly.
+// Otherwise it is unmarshaled into the message type, rendered as JSON with
+// protojson, and bound into v by JSON, so v can be an ordinary struct with
+// json tags. Note that protojson renders 64-bit integers as strings; give
+// such fields the ",string" tag option.
+type Binder struct {
+	// Type is the message type on the wire. If nil, it is looked up by the
+	// HeaderMessageType header in Types.
+	Type protoreflect.MessageType
+
+	// Types resolves HeaderMessageType. The default is
+	// protoregistry.GlobalTypes, which holds every generated message linked
+	// into the program.
+	Types protoregistry.MessageTypeResolver
+
+	// JSON controls how the intermediate JSON is rendered, e.g.
+	// UseProtoNames for snake_case keys instead of lowerCamelCase.
+	JSON protojson.MarshalOptions
+
+	// Into binds the intermediate JSON into v. The default is
+	// core.JSONBinder.
+	Into core.Binder
+
+	// AllowEmpty makes Bind a no-op for empty payloads, leaving v at its
+	// current value. By default empty payloads return core.ErrEmptyPayload.
+	AllowEmpty bool
+}
+
+// Bind implements core.Binder.
+func (b Binder) Bind(msg core.Message, v any) error {
+	if msg == nil || len(msg.Value()) == 0 {
+		if b.AllowEmpty {
+			return nil
+		}
+		return core.ErrEmptyPayload
+	}
+	if pm, ok := v.(proto.Message); ok {
+		if err := proto.Unmarshal(msg.Value(), pm); err != nil {
+			return fmt.Errorf("eventmux/protobuf: bind: %w", err)
+		}
+		return nil
+	}
+
+	mt, err := resolveType(b.Type, b.Types, msg)
+	if err != nil {
+		return err
+	}
+	pm := mt.New().Interface()
+	if err := proto.Unmarshal(msg.Value(), pm); err != nil {
+		return fmt.Errorf("eventmux/protobuf: bind %s: %w", mt.Descriptor().FullName(), err)
+	}
+	data, err := b.JSON.Marshal(pm)
+	if err != nil {
+		return fmt.Errorf("eventmux/protobuf: bind %s: %w", mt.Descriptor().FullName(), err)
+	}
+	into := b.Into
+	if into == nil {
+		into = core.JSONBinder{}
+	}
+	return into.Bind(&jsonMessage{Message: msg, value: data}, v)
+}
+
+// Encoder encodes values as protobuf wire format for publishing, mirroring
+// the Binder that decodes them.
+//
+// A proto.Message is marshaled as is. Any other value is marshaled to JSON
+// with encoding/json, or used as is if it is a json.RawMessage or []byte,
+// and then parsed into Type with protojson. This lets a JSON payload, such
+// as one received from a JSON producer, be republished to protobuf
+// consumers.
+type Encoder struct {
+	// Type is the message type to encode JSON values as. It is required for
+	// values that are not a proto.Message.
+	Type protoreflect.MessageType
+
+	// JSON controls how JSON values are parsed, e.g. DiscardUnknown to drop
+	// fields the message type does not declare.
+	JSON protojson.UnmarshalOptions
+}
+
+// Encode returns the protobuf wire encoding of v.
+func (e Encoder) Encode(v any) ([]byte, error) {
+	pm, err := e.message(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(pm)
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/protobuf: encode: %w", err)
+	}
+	return data, nil
+}
+
+// message converts v to the proto.Message to marshal.
+func (e Encoder) message(v any) (proto.Message, error) {
+	if pm, ok := v.(proto.Message); ok {
+		return pm, nil
+	}
+	if e.Type == nil {
+		return nil, fmt.Errorf("eventmux/protobuf: encode %T: no message type configured", v)
+	}
+	var data []byte
+	switch raw := v.(type) {
+	case json.RawMessage:
+		data = raw
+	case []byte:
+		data = raw
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("eventmux/protobuf: encode %T: %w", v, err)
+		}
+	}
+	pm := e.Type.New().Interface()
+	if err := e.JSON.Unmarshal(data, pm); err != nil {
+		return nil, fmt.Errorf("eventmux/protobuf: encode %s: %w", e.Type.Descriptor().FullName(), err)
+	}
+	return pm, nil
+}
+
+// NewMessage encodes v and returns it as a message ready for Router.Publish,
+// with the content-type header set to ContentType and HeaderMessageType set
+// to the message's full name.
+func (e Encoder) NewMessage(key []byte, v any) (core.Message, error) {
+	pm, err := e.message(v)
+	if err != nil {
+		return nil, err
+	}
+	value, err := proto.Marshal(pm)
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/protobuf: encode: %w", err)
+	}
+	return &message{key: key, value: value, name: string(pm.ProtoReflect().Descriptor().FullName())}, nil
+}
+
+// Marshal encodes a proto.Message with the default Encoder.
+func Marshal(m proto.Message) ([]byte, error) {
+	return Encoder{}.Encode(m)
+}
+
+// NewMessage encodes a proto.Message with the default Encoder and returns
+// it as a message ready for Router.Publish.
+func NewMessage(key []byte, m proto.Message) (core.Message, error) {
+	return Encoder{}.NewMessage(key, m)
+}
+
+// resolveType returns mt, or the type named by msg's HeaderMessageType.
+func resolveType(mt protoreflect.MessageType, types protoregistry.MessageTypeResolver, msg core.Message) (protoreflect.MessageType, error) {
+	if mt != nil {
+		return mt, nil
+	}
+	name := core.Header(msg, HeaderMessageType)
+	if name == "" {
+		return nil, fmt.Errorf("eventmux/protobuf: bind: no message type configured and no %s header", HeaderMessageType)
+	}
+	if types == nil {
+		types = protoregistry.GlobalTypes
+	}
+	mt, err := types.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/protobuf: bind: resolve %q: %w", name, err)
+	}
+	return mt, nil
+}
+
+// jsonMessage presents the protojson rendering of a message to the JSON
+// binder.
+type jsonMessage struct {
+	core.Message
+	value []byte
+}
+
+func (m *jsonMessage) Value() []byte { return m.value }
+
+// message is an outbound protobuf message. Ack and Nack are no-ops.
+type message struct {
+	key   []byte
+	value []byte
+	name  string
+}
+
+func (m *message) Key() []byte   { return m.key }
+func (m *message) Value() []byte { return m.value }
+func (m *message) Headers() map[string]string {
+	return map[string]string{"content-type": ContentType, HeaderMessageType: m.name}
+}
+func (m *message) Ack() error  { return nil }
+func (m *message) Nack() error { return nil }
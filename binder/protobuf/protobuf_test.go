@@ -0,0 +1,228 @@
+package protobuf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// orderType builds shop.v1.Order at runtime, standing in for generated code:
+//
+//	message Order {
+//	  string order_id = 1;
+//	  int64 amount = 2;
+//	  repeated string tags = 3;
+//	}
+func orderType(t *testing.T) protoreflect.MessageType {
+	t.Helper()
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+			JsonName: proto.String(jsonName(name)),
+		}
+	}
+	opt, rep := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("shop/v1/order.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("order_id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt),
+				field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, opt),
+				field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, rep),
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("build descriptor: %v", err)
+	}
+	return dynamicpb.NewMessageType(fd.Messages().ByName("Order"))
+}
+
+// jsonName converts a proto field name to protojson's lowerCamelCase.
+func jsonName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// newOrder returns a populated shop.v1.Order.
+func newOrder(mt protoreflect.MessageType) proto.Message {
+	m := mt.New()
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("order_id"), protoreflect.ValueOfString("o-1"))
+	m.Set(fields.ByName("amount"), protoreflect.ValueOfInt64(4200))
+	tags := m.Mutable(fields.ByName("tags")).List()
+	tags.Append(protoreflect.ValueOfString("gift"))
+	tags.Append(protoreflect.ValueOfString("express"))
+	return m.Interface()
+}
+
+// order is the JSON-side view of shop.v1.Order. protojson renders int64 as
+// a string, hence the ",string" option.
+type order struct {
+	OrderID string   `json:"orderId"`
+	Amount  int64    `json:"amount,string"`
+	Tags    []string `json:"tags"`
+}
+
+func TestRoundTrip_ProtoToStructAndBack(t *testing.T) {
+	mt := orderType(t)
+	in := newOrder(mt)
+
+	msg, err := NewMessage([]byte("o-1"), in)
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+	h := msg.Headers()
+	if h["content-type"] != ContentType || h[HeaderMessageType] != "shop.v1.Order" {
+		t.Errorf("headers = %v", h)
+	}
+
+	var got order
+	if err := (Binder{Type: mt}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got.OrderID != "o-1" || got.Amount != 4200 || len(got.Tags) != 2 || got.Tags[1] != "express" {
+		t.Errorf("bound %+v", got)
+	}
+
+	back, err := Encoder{Type: mt}.NewMessage([]byte("o-1"), got)
+	if err != nil {
+		t.Fatalf("encode struct: %v", err)
+	}
+	out := mt.New().Interface()
+	if err := proto.Unmarshal(back.Value(), out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !proto.Equal(in, out) {
+		t.Errorf("round trip = %v, want %v", out, in)
+	}
+}
+
+func TestBind_ResolvesTypeFromHeader(t *testing.T) {
+	mt := orderType(t)
+	types := new(protoregistry.Types)
+	if err := types.RegisterMessage(mt); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := NewMessage(nil, newOrder(mt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := (Binder{Types: types}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got["orderId"] != "o-1" || got["amount"] != "4200" {
+		t.Errorf("bound %v", got)
+	}
+
+	// UseProtoNames keeps the .proto field names.
+	got = nil
+	b := Binder{Types: types}
+	b.JSON.UseProtoNames = true
+	if err := b.Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got["order_id"] != "o-1" {
+		t.Errorf("bound %v, want order_id key", got)
+	}
+}
+
+func TestBind_IntoProtoMessage(t *testing.T) {
+	mt := orderType(t)
+	data, err := Marshal(newOrder(mt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := mt.New().Interface()
+	if err := (Binder{}).Bind(&mock.Message{V: data}, out); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if !proto.Equal(out, newOrder(mt)) {
+		t.Errorf("bound %v", out)
+	}
+}
+
+func TestEncode_RawJSON(t *testing.T) {
+	mt := orderType(t)
+	raw := json.RawMessage(`{"orderId":"o-1","amount":"4200","tags":["gift","express"],"channel":"web"}`)
+
+	if _, err := (Encoder{Type: mt}).Encode(raw); err == nil {
+		t.Error("unknown JSON fields should be rejected by default")
+	}
+	e := Encoder{Type: mt}
+	e.JSON.DiscardUnknown = true
+	data, err := e.Encode(raw)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	out := mt.New().Interface()
+	if err := proto.Unmarshal(data, out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !proto.Equal(out, newOrder(mt)) {
+		t.Errorf("encoded %v", out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var got order
+	if err := (Binder{}).Bind(&mock.Message{}, &got); !errors.Is(err, core.ErrEmptyPayload) {
+		t.Errorf("empty: err = %v, want ErrEmptyPayload", err)
+	}
+	if err := (Binder{AllowEmpty: true}).Bind(&mock.Message{}, &got); err != nil {
+		t.Errorf("AllowEmpty: %v", err)
+	}
+	if err := (Binder{}).Bind(&mock.Message{V: []byte{0x0a}}, &got); err == nil || !strings.Contains(err.Error(), HeaderMessageType) {
+		t.Errorf("no type: err = %v", err)
+	}
+	msg := &mock.Message{V: []byte{0x0a}, H: map[string]string{HeaderMessageType: "shop.v1.Missing"}}
+	if err := (Binder{}).Bind(msg, &got); !errors.Is(err, protoregistry.NotFound) {
+		t.Errorf("unknown type: err = %v, want NotFound", err)
+	}
+	if err := (Binder{Type: orderType(t)}).Bind(&mock.Message{V: []byte{0x0a, 0x05}}, &got); err == nil ||
+		!strings.HasPrefix(err.Error(), "eventmux/protobuf: bind shop.v1.Order: ") {
+		t.Errorf("truncated: err = %v", err)
+	}
+	if _, err := (Encoder{}).Encode(order{}); err == nil {
+		t.Error("encoding a struct without a type should fail")
+	}
+}
+
+func TestBind_ViaRouter(t *testing.T) {
+	mt := orderType(t)
+	r := core.New(mock.NewBroker(), core.WithBinder(Binder{Type: mt}))
+
+	var got order
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		return core.Bind(ctx, msg, &got)
+	})
+	data, _ := Marshal(newOrder(mt))
+	if err := r.Dispatch(context.Background(), "orders", &mock.Message{V: data}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if got.OrderID != "o-1" || got.Amount != 4200 {
+		t.Errorf("bound %+v", got)
+	}
+}
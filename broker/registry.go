@@ -2,6 +2,8 @@ package broker
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/miladsoleymani/eventmux/core"
@@ -22,13 +24,48 @@ func Register(name string, factory Factory) {
 	factories[name] = factory
 }
 
+// Registered returns the names of all registered brokers, sorted.
+func Registered() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Create instantiates a broker by name using the registered factory.
+// A panic in the factory is recovered and returned as an error naming the
+// broker.
 func Create(name string, cfg Config) (core.Broker, error) {
 	mu.RLock()
 	f, ok := factories[name]
 	mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("eventmux: unknown broker %q", name)
+		return nil, unknownBrokerError(name)
 	}
+	return callFactory(name, f, cfg)
+}
+
+// callFactory runs f, converting a panic into an error.
+func callFactory(name string, f Factory, cfg Config) (b core.Broker, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			b = nil
+			err = fmt.Errorf("eventmux: broker %q factory panicked: %v", name, rec)
+		}
+	}()
 	return f(cfg)
 }
+
+// unknownBrokerError lists the registered brokers so a typo or a missing
+// plugin import is easy to spot.
+func unknownBrokerError(name string) error {
+	names := Registered()
+	if len(names) == 0 {
+		return fmt.Errorf("eventmux: unknown broker %q (no brokers registered; did you forget a blank import such as _ \"github.com/miladsoleymani/eventmux/plugins/kafka\"?)", name)
+	}
+	return fmt.Errorf("eventmux: unknown broker %q (registered: %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// withRegistry runs fn against a registry holding only the given names.
+func withRegistry(t *testing.T, names []string, fn func()) {
+	t.Helper()
+	mu.Lock()
+	saved := factories
+	factories = make(map[string]Factory)
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		factories = saved
+		mu.Unlock()
+	}()
+
+	for _, name := range names {
+		Register(name, func(Config) (core.Broker, error) { return mock.NewBroker(), nil })
+	}
+	fn()
+}
+
+func TestRegistered(t *testing.T) {
+	withRegistry(t, []string{"rabbitmq", "kafka", "nats"}, func() {
+		got := Registered()
+		want := []string{"kafka", "nats", "rabbitmq"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Registered() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestCreate_UnknownListsRegistered(t *testing.T) {
+	withRegistry(t, []string{"kafka", "nats"}, func() {
+		_, err := Create("kafkaa", Config{})
+		if err == nil {
+			t.Fatal("expected error for unknown broker")
+		}
+		for _, want := range []string{`"kafkaa"`, "kafka, nats"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not contain %q", err, want)
+			}
+		}
+	})
+}
+
+func TestCreate_NothingRegistered(t *testing.T) {
+	withRegistry(t, nil, func() {
+		_, err := Create("kafka", Config{})
+		if err == nil || !strings.Contains(err.Error(), "blank import") {
+			t.Errorf("expected blank-import hint, got %v", err)
+		}
+	})
+}
+
+func TestCreate_FactoryPanic(t *testing.T) {
+	withRegistry(t, nil, func() {
+		Register("broken", func(Config) (core.Broker, error) {
+			var cfg *Config
+			_ = cfg.Brokers // nil dereference
+			return nil, nil
+		})
+
+		b, err := Create("broken", Config{})
+		if err == nil {
+			t.Fatal("expected error from panicking factory")
+		}
+		if b != nil {
+			t.Errorf("expected nil broker, got %v", b)
+		}
+		if !strings.Contains(err.Error(), `"broken"`) {
+			t.Errorf("error %q does not name the broker", err)
+		}
+	})
+}
+
+type typedConfig struct{ Name string }
+
+func TestCreateTyped(t *testing.T) {
+	withRegistry(t, nil, func() {
+		var got typedConfig
+		RegisterTyped("typed", func(cfg typedConfig) (core.Broker, error) {
+			got = cfg
+			return mock.NewBroker(), nil
+		})
+		defer func() {
+			mu.Lock()
+			delete(typedFactories, "typed")
+			mu.Unlock()
+		}()
+
+		if _, err := CreateTyped("typed", typedConfig{Name: "a"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if got.Name != "a" {
+			t.Errorf("factory got %+v", got)
+		}
+
+		_, err := CreateTyped("typed", Config{})
+		if err == nil || !strings.Contains(err.Error(), "broker.typedConfig") {
+			t.Errorf("expected type mismatch error, got %v", err)
+		}
+		if _, err := CreateTyped("missing", typedConfig{}); err == nil {
+			t.Error("expected error for unknown broker")
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// typedFactory creates a Broker from a plugin's own config struct, passed
+// as any and checked by the wrapper RegisterTyped builds.
+type typedFactory func(cfg any) (core.Broker, error)
+
+var typedFactories = make(map[string]typedFactory)
+
+// RegisterTyped adds a named factory that takes the plugin's strongly-typed
+// config struct C (e.g. kafka.Config), for use with CreateTyped. Plugins
+// call it from init() next to Register, which keeps serving the
+// map-based Config for configuration loaded at runtime.
+func RegisterTyped[C any](name string, factory func(cfg C) (core.Broker, error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	typedFactories[name] = func(cfg any) (core.Broker, error) {
+		c, ok := cfg.(C)
+		if !ok {
+			var want C
+			return nil, fmt.Errorf("eventmux: broker %q expects config of type %T, got %T", name, want, cfg)
+		}
+		return factory(c)
+	}
+}
+
+// CreateTyped instantiates a broker by name from a typed config registered
+// with RegisterTyped. Passing another plugin's config type is an error
+// rather than a silently ignored field. A panic in the factory is recovered
+// and returned as an error naming the broker.
+func CreateTyped(name string, cfg any) (b core.Broker, err error) {
+	mu.RLock()
+	f, ok := typedFactories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, unknownBrokerError(name)
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			b = nil
+			err = fmt.Errorf("eventmux: broker %q factory panicked: %v", name, rec)
+		}
+	}()
+	return f(cfg)
+}
@@ -0,0 +1,47 @@
+package core
+
+import "strconv"
+
+// HeaderAttempt carries the 1-based delivery attempt of a message that was
+// republished for retry. Because the count travels with the message, it
+// survives consumer restarts and redeployments.
+const HeaderAttempt = "x-eventmux-attempt"
+
+// AttemptReader is implemented by messages whose broker tracks delivery
+// attempts natively (e.g. JetStream delivery count, RabbitMQ x-death).
+type AttemptReader interface {
+	Attempt() int
+}
+
+// DeliveryCounter is implemented by AttemptReader messages whose broker
+// counts deliveries only in some cases, e.g. RabbitMQ, which counts them on
+// quorum queues but not on classic ones. CountsDeliveries reports whether
+// the broker counted this message's deliveries; WithMaxRedeliveries tracks
+// the others itself.
+type DeliveryCounter interface {
+	CountsDeliveries() bool
+}
+
+// Attempt returns the 1-based delivery attempt of msg. Broker-tracked counts
+// (AttemptReader) take precedence, then HeaderAttempt; a message with
+// neither is on its first attempt.
+func Attempt(msg Message) int {
+	if ar, ok := msg.(AttemptReader); ok {
+		return ar.Attempt()
+	}
+	if n, ok := AttemptFromHeader(msg); ok {
+		return n
+	}
+	return 1
+}
+
+// AttemptFromHeader parses HeaderAttempt. Plugins implementing
+// AttemptReader use it so a republished count wins over broker state that
+// was reset by the republish.
+func AttemptFromHeader(msg Message) (int, bool) {
+	n, err := strconv.Atoi(Header(msg, HeaderAttempt))
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
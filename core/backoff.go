@@ -0,0 +1,44 @@
+package core
+
+import "time"
+
+// DelayedNacker is implemented by messages whose broker can delay
+// redelivery of a nacked message, e.g. JetStream's NakWithDelay or a
+// RabbitMQ retry queue.
+type DelayedNacker interface {
+	NackWithDelay(d time.Duration) error
+}
+
+// NackWithDelay nacks msg asking the broker to redeliver it no sooner than
+// d. Messages whose broker cannot delay redelivery are nacked immediately.
+func NackWithDelay(msg Message, d time.Duration) error {
+	if dn, ok := msg.(DelayedNacker); ok && d > 0 {
+		return dn.NackWithDelay(d)
+	}
+	return msg.Nack()
+}
+
+// BackoffStrategy returns how long to delay redelivery after the given
+// 1-based delivery attempt failed.
+type BackoffStrategy func(attempt int) time.Duration
+
+// ExponentialBackoff returns a BackoffStrategy that waits base after the
+// first attempt and doubles the delay on each further attempt, up to max.
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// nack settles msg with a nack, delayed by the router's backoff for the
+// message's attempt when WithNackBackoff is set.
+func (r *Router) nack(msg Message) error {
+	if r.nackBackoff == nil {
+		return msg.Nack()
+	}
+	return NackWithDelay(msg, r.nackBackoff(Attempt(msg)))
+}
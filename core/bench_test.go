@@ -0,0 +1,157 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// Allocation budgets for a single dispatch through the mock broker.
+// Raising these should be a deliberate decision, not a side effect.
+const (
+	dispatchAllocBudget       = 0
+	dispatchResultAllocBudget = 0
+
+	// Router.Dispatch without middleware takes the fast path.
+	directDispatchAllocBudget = 0
+)
+
+// passthrough is a middleware that does nothing but call the next handler.
+func passthrough(next core.Handler) core.Handler {
+	return func(ctx context.Context, msg core.Message) error {
+		return next(ctx, msg)
+	}
+}
+
+// benchRouter starts a router with n passthrough middleware and a handler
+// returning ret, and returns the broker to deliver through.
+func benchRouter(tb testing.TB, n int, ret func() error) (*mock.Broker, context.CancelFunc) {
+	tb.Helper()
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	for i := 0; i < n; i++ {
+		r.Use(passthrough)
+	}
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return ret()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { r.Start(ctx) }()
+	// Wait for Start to subscribe.
+	for mb.Deliver(ctx, "orders.created", &mock.Message{}) == core.ErrNoHandler {
+		runtime.Gosched()
+	}
+	return mb, cancel
+}
+
+func TestDispatch_AllocBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		ret    func() error
+		budget float64
+	}{
+		{"nil", func() error { return nil }, dispatchAllocBudget},
+		{"ack", core.AckResult, dispatchResultAllocBudget},
+		{"nack", core.NackResult, dispatchResultAllocBudget},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb, cancel := benchRouter(t, 3, tt.ret)
+			defer cancel()
+
+			ctx := context.Background()
+			msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+			allocs := testing.AllocsPerRun(100, func() {
+				mb.Deliver(ctx, "orders.created", msg)
+			})
+			t.Logf("%s: %.0f allocs/dispatch (budget %.0f)", tt.name, allocs, tt.budget)
+			if allocs > tt.budget {
+				t.Errorf("dispatch allocated %.0f times, budget is %.0f", allocs, tt.budget)
+			}
+		})
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	for _, n := range []int{0, 1, 5} {
+		b.Run(fmt.Sprintf("middleware=%d", n), func(b *testing.B) {
+			mb, cancel := benchRouter(b, n, func() error { return nil })
+			defer cancel()
+
+			ctx := context.Background()
+			msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mb.Deliver(ctx, "orders.created", msg)
+			}
+		})
+	}
+}
+
+func BenchmarkDispatch_AckResult(b *testing.B) {
+	mb, cancel := benchRouter(b, 1, core.AckResult)
+	defer cancel()
+
+	ctx := context.Background()
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mb.Deliver(ctx, "orders.created", msg)
+	}
+}
+
+// BenchmarkRouterDispatch measures Router.Dispatch called directly, where
+// the chain is built per call unless there is no middleware.
+func BenchmarkRouterDispatch(b *testing.B) {
+	for _, n := range []int{0, 1} {
+		b.Run(fmt.Sprintf("middleware=%d", n), func(b *testing.B) {
+			r := directRouter(n)
+			ctx := context.Background()
+			msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Dispatch(ctx, "orders.created", msg)
+			}
+		})
+	}
+}
+
+// directRouter returns an unstarted router with n passthrough middleware
+// and a no-op handler, for calling Dispatch directly.
+func directRouter(n int) *core.Router {
+	r := core.New(mock.NewBroker())
+	for i := 0; i < n; i++ {
+		r.Use(passthrough)
+	}
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error { return nil })
+	return r
+}
+
+func TestRouterDispatch_AllocBudget(t *testing.T) {
+	r := directRouter(0)
+	ctx := context.Background()
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Dispatch(ctx, "orders.created", msg)
+	})
+	if allocs > directDispatchAllocBudget {
+		t.Errorf("Dispatch without middleware allocated %.0f times, budget is %d", allocs, directDispatchAllocBudget)
+	}
+}
+
+func BenchmarkDefaultMatcher(b *testing.B) {
+	m := core.DefaultMatcher{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Match("orders.*.#", "orders.us.east.created")
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import "reflect"
+
+// bindKey identifies a cached Bind: the Binder that decoded the payload and
+// the pointer type it decoded into.
+type bindKey struct {
+	binder Binder
+	typ    reflect.Type
+}
+
+// boundValue is a payload decoded by Bind, kept on the message's Store.
+type boundValue struct {
+	payload []byte        // the payload it was decoded from
+	v       reflect.Value // pointer to a copy of the decoded value
+}
+
+// cachedBind copies a value b previously decoded from payload into v, for
+// which cacheable holds. It reports false if there is none, or the payload
+// has since been replaced, e.g. by decompressing middleware.
+func (s *Store) cachedBind(b Binder, payload []byte, v any) bool {
+	rv := reflect.ValueOf(v)
+	s.mu.RLock()
+	bv, ok := s.binds[bindKey{b, rv.Type()}]
+	s.mu.RUnlock()
+	if !ok || !samePayload(bv.payload, payload) {
+		return false
+	}
+	rv.Elem().Set(bv.v.Elem())
+	return true
+}
+
+// cacheBind records a copy of v, just decoded by b from payload into a
+// target for which cacheable held.
+func (s *Store) cacheBind(b Binder, payload []byte, v any) {
+	rv := reflect.ValueOf(v)
+	c := reflect.New(rv.Type().Elem())
+	c.Elem().Set(rv.Elem())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.binds == nil {
+		s.binds = make(map[bindKey]boundValue)
+	}
+	s.binds[bindKey{b, rv.Type()}] = boundValue{payload: payload, v: c}
+}
+
+// cacheable reports whether binding payload into rv with b may use the
+// cache. Targets that already hold data are decoded afresh, so Binders that
+// merge into them keep doing so.
+func cacheable(b Binder, payload []byte, rv reflect.Value) bool {
+	return len(payload) > 0 && rv.Kind() == reflect.Pointer && !rv.IsNil() &&
+		rv.Elem().IsZero() && hashable(b)
+}
+
+// hashable reports whether b can be part of a map key. The check is on the
+// value, not its type: a struct with an interface field has a comparable
+// type, but hashing it panics if the field holds, say, a func or a slice.
+func hashable(b Binder) bool {
+	return b != nil && reflect.ValueOf(b).Comparable()
+}
+
+// samePayload reports whether a and b are the same bytes in memory, not
+// merely equal, so checking is cheap and a transformed payload misses.
+func samePayload(a, b []byte) bool {
+	return len(a) == len(b) && &a[0] == &b[0]
+}
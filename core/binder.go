@@ -0,0 +1,506 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Binder decodes a message payload into a Go value.
+type Binder interface {
+	Bind(msg Message, v any) error
+}
+
+// JSONBinder decodes JSON payloads with encoding/json. It is the default
+// Binder.
+type JSONBinder struct {
+	// SnakeCase maps snake_case and kebab-case keys onto untagged struct
+	// fields, so "user_id" binds to UserID without a json tag. Fields with an
+	// explicit json tag keep encoding/json semantics and always take
+	// precedence: a key that matches a tag is never remapped. When several
+	// keys name the same field, a key encoding/json would bind anyway, such
+	// as "userId" for UserID, wins over "user_id"; ties go to the first key
+	// in sorted order.
+	SnakeCase bool
+
+	// AllowEmpty makes Bind a no-op for empty payloads, leaving v at its
+	// current value. By default empty payloads return ErrEmptyPayload.
+	AllowEmpty bool
+
+	// MaxDepth limits how deeply objects and arrays may nest, and MaxBytes
+	// limits the payload size. Payloads over either limit are rejected with
+	// ErrPayloadTooComplex before decoding, which protects consumers of
+	// untrusted producers from JSON bombs. Zero means no limit.
+	MaxDepth int
+	MaxBytes int
+}
+
+// Bind implements Binder.
+func (b JSONBinder) Bind(msg Message, v any) error {
+	if isEmptyPayload(msg) {
+		if b.AllowEmpty {
+			return nil
+		}
+		return ErrEmptyPayload
+	}
+	data := msg.Value()
+	if err := b.checkComplexity(data); err != nil {
+		return err
+	}
+	if b.SnakeCase {
+		var err error
+		if data, err = remapKeys(data, reflect.TypeOf(v)); err != nil {
+			return fmt.Errorf("eventmux: bind: %w", err)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("eventmux: bind: %w", err)
+	}
+	return nil
+}
+
+// checkComplexity enforces MaxBytes and MaxDepth. The depth scan tracks
+// brackets outside string literals and stops at the first violation, so
+// it is linear in the payload and does not allocate.
+func (b JSONBinder) checkComplexity(data []byte) error {
+	if b.MaxBytes > 0 && len(data) > b.MaxBytes {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrPayloadTooComplex, len(data), b.MaxBytes)
+	}
+	if b.MaxDepth <= 0 {
+		return nil
+	}
+	depth, inString, escaped := 0, false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth++; depth > b.MaxDepth {
+				return fmt.Errorf("%w: nesting exceeds depth %d", ErrPayloadTooComplex, b.MaxDepth)
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// FieldError reports a JSON object member that could not be bound.
+type FieldError struct {
+	// Field is the JSON key as it appeared in the payload, or "" when the
+	// payload as a whole could not be decoded.
+	Field string
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return "eventmux: bind: " + e.Err.Error()
+	}
+	return fmt.Sprintf("eventmux: bind field %q: %v", e.Field, e.Err)
+}
+
+func (e FieldError) Unwrap() error { return e.Err }
+
+// BindLenient decodes a JSON object into the struct pointed to by v one
+// member at a time. Members that decode are set; members that fail (wrong
+// type, malformed value) are reported as FieldErrors and leave their field
+// untouched. Unknown members are ignored. The router's JSONBinder
+// SnakeCase setting is honoured, including its precedence between keys that
+// name the same field.
+//
+// A payload that is not a JSON object yields a single FieldError with an
+// empty Field; an empty payload is handled as in JSONBinder.
+func BindLenient(ctx context.Context, msg Message, v any) []FieldError {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return []FieldError{{Err: fmt.Errorf("BindLenient requires a non-nil struct pointer, got %T", v)}}
+	}
+	rv = rv.Elem()
+
+	if isEmptyPayload(msg) {
+		if jb, ok := binderFrom(ctx).(JSONBinder); ok && jb.AllowEmpty {
+			return nil
+		}
+		return []FieldError{{Err: ErrEmptyPayload}}
+	}
+	data := msg.Value()
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return []FieldError{{Err: err}}
+	}
+
+	snake := false
+	if jb, ok := binderFrom(ctx).(JSONBinder); ok {
+		snake = jb.SnakeCase
+	}
+	tagged, loose := jsonFields(rv.Type())
+
+	shadowed := shadowedKeys(obj, tagged, loose, snake)
+	var errs []FieldError
+	for _, key := range sortedKeys(obj) {
+		f, ok := lenientField(key, tagged, loose, snake)
+		if !ok || shadowed[key] {
+			continue
+		}
+		raw := obj[key]
+		if snake {
+			if remapped, err := remapKeys(raw, f.Type); err == nil {
+				raw = remapped
+			}
+		}
+		fv := rv.FieldByIndex(f.Index)
+		tmp := reflect.New(fv.Type())
+		if err := json.Unmarshal(raw, tmp.Interface()); err != nil {
+			errs = append(errs, FieldError{Field: key, Err: err})
+			continue
+		}
+		fv.Set(tmp.Elem())
+	}
+	return errs
+}
+
+// lenientField finds the struct field a JSON key binds to, following the
+// same precedence as JSONBinder: tags first, then Go names.
+func lenientField(key string, tagged, loose map[string]reflect.StructField, snake bool) (reflect.StructField, bool) {
+	if f, ok := tagged[strings.ToLower(key)]; ok {
+		return f, true
+	}
+	if snake {
+		f, ok := loose[normalizeName(key)]
+		return f, ok
+	}
+	for _, f := range loose {
+		if strings.EqualFold(f.Name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// shadowedKeys returns the keys of obj that lose to another key binding the
+// same untagged field, so the winner does not depend on map order. The
+// field's Go name itself wins, then a case-insensitive match of it, which
+// encoding/json binds without remapping, then a snake_case or kebab-case
+// match; ties go to the first key in sorted order. It returns nil when no
+// keys collide.
+func shadowedKeys(obj map[string]json.RawMessage, tagged, loose map[string]reflect.StructField, snake bool) map[string]bool {
+	type claim struct {
+		key  string
+		rank int
+	}
+	var claims map[string]claim
+	var shadowed map[string]bool
+	for _, key := range sortedKeys(obj) {
+		if _, ok := tagged[strings.ToLower(key)]; ok {
+			continue
+		}
+		f, ok := lenientField(key, tagged, loose, snake)
+		if !ok {
+			continue
+		}
+		rank := 0
+		switch {
+		case key == f.Name:
+			rank = 2
+		case strings.EqualFold(key, f.Name):
+			rank = 1
+		}
+		if claims == nil {
+			claims = make(map[string]claim)
+		}
+		prev, seen := claims[f.Name]
+		switch {
+		case !seen:
+			claims[f.Name] = claim{key, rank}
+			continue
+		case rank > prev.rank:
+			claims[f.Name] = claim{key, rank}
+			key = prev.key
+		}
+		if shadowed == nil {
+			shadowed = make(map[string]bool)
+		}
+		shadowed[key] = true
+	}
+	return shadowed
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isEmptyPayload reports whether msg is nil or carries only whitespace.
+func isEmptyPayload(msg Message) bool {
+	if msg == nil {
+		return true
+	}
+	return len(bytes.TrimSpace(msg.Value())) == 0
+}
+
+type binderKey struct{}
+
+// StoreKeyBindError is the Store key under which Bind records a BindError.
+const StoreKeyBindError = "bind_error"
+
+// HeaderBindError carries the decode error on messages dead-lettered after
+// a failed Bind.
+const HeaderBindError = "x-eventmux-bind-error"
+
+// BindError records a failed Bind: the payload as received and the error
+// the Binder returned.
+type BindError struct {
+	Raw []byte
+	Err error
+}
+
+func (e *BindError) Error() string { return e.Err.Error() }
+
+func (e *BindError) Unwrap() error { return e.Err }
+
+// Bind decodes msg into v using the Binder configured on the Router that
+// dispatched msg (see WithBinder), or JSONBinder outside a Router.
+//
+// If decoding fails, a copy of the payload and the error are recorded for
+// BindFailure, and under StoreKeyBindError if ctx carries a Store. When the
+// message is later dead-lettered, by DeadLetter, DLQResult or
+// WithMaxRedeliveries, the copy carries the error in HeaderBindError; this
+// needs no Store.
+//
+// A Binder that panics does not take down the handler: Bind recovers and
+// returns an error wrapping ErrBindPanic, recorded like any other failure.
+//
+// With a Store, a successful Bind into a zero value is also cached by the
+// Binder and the type of v, so that binding the same payload into another
+// zero value of that type, e.g. in validation middleware and then in the
+// handler, copies the cached value into v without decoding. The copy is
+// shallow: maps, slices and pointers inside the value are shared between
+// the two. Targets that already hold data are always decoded, so a Binder
+// that merges into them still does, and the cache is bypassed once the
+// payload is replaced, e.g. by decompressing middleware.
+func Bind(ctx context.Context, msg Message, v any) error {
+	s, b := StoreFrom(ctx), binderFrom(ctx)
+	cache := s != nil && msg != nil && cacheable(b, msg.Value(), reflect.ValueOf(v))
+	if cache && s.cachedBind(b, msg.Value(), v) {
+		return nil
+	}
+	err := safeBind(b, msg, v)
+	if err != nil {
+		recordBindFailure(ctx, s, msg, err)
+		return err
+	}
+	if cache {
+		s.cacheBind(b, msg.Value(), v)
+	}
+	return nil
+}
+
+// safeBind calls b.Bind, converting a panic into an error.
+func safeBind(b Binder, msg Message, v any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %T: %v", ErrBindPanic, b, r)
+		}
+	}()
+	return b.Bind(msg, v)
+}
+
+// recordBindFailure records err for BindFailure on the message's
+// dead-letter state and on s, whichever are present.
+func recordBindFailure(ctx context.Context, s *Store, msg Message, err error) {
+	st, _ := ctx.Value(deadLetterKey{}).(*deadLetterState)
+	if st == nil && s == nil {
+		return
+	}
+	var raw []byte
+	if msg != nil {
+		raw = bytes.Clone(msg.Value())
+	}
+	be := &BindError{Raw: raw, Err: err}
+	if st != nil {
+		st.bindErr.Store(be)
+	}
+	if s != nil {
+		s.Set(StoreKeyBindError, be)
+	}
+}
+
+// BindFailure returns the BindError recorded by the last failed Bind of the
+// message ctx belongs to, or, outside a dead-lettering Router, on ctx's
+// Store.
+func BindFailure(ctx context.Context) (*BindError, bool) {
+	if st, ok := ctx.Value(deadLetterKey{}).(*deadLetterState); ok {
+		if be := st.bindErr.Load(); be != nil {
+			return be, true
+		}
+	}
+	s := StoreFrom(ctx)
+	if s == nil {
+		return nil, false
+	}
+	v, _ := s.Get(StoreKeyBindError)
+	be, ok := v.(*BindError)
+	return be, ok
+}
+
+func binderFrom(ctx context.Context) Binder {
+	if b, ok := ctx.Value(binderKey{}).(Binder); ok {
+		return b
+	}
+	return JSONBinder{}
+}
+
+func withBinder(ctx context.Context, b Binder) context.Context {
+	if b == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, binderKey{}, b)
+}
+
+// normalizeName folds a field or key name for loose comparison:
+// "user_id", "user-id", "UserID" and "userId" all become "userid".
+func normalizeName(s string) string {
+	s = strings.ToLower(s)
+	if strings.ContainsAny(s, "_-") {
+		s = strings.NewReplacer("_", "", "-", "").Replace(s)
+	}
+	return s
+}
+
+// remapKeys rewrites object keys in data so loosely named keys match the
+// untagged fields of t. Nested structs, pointers and slices are followed.
+// Non-object payloads are returned unchanged.
+func remapKeys(data []byte, t reflect.Type) ([]byte, error) {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return data, nil
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			out, err := remapKeys(item, t)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = out
+		}
+		return json.Marshal(items)
+	case !strings.HasPrefix(trimmed, "{"):
+		return data, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	tagged, loose := jsonFields(t)
+	shadowed := shadowedKeys(obj, tagged, loose, true)
+	out := make(map[string]json.RawMessage, len(obj))
+	for key, val := range obj {
+		if shadowed[key] {
+			continue
+		}
+		name, ft := key, reflect.Type(nil)
+		if f, ok := tagged[strings.ToLower(key)]; ok {
+			ft = f.Type
+		} else if f, ok := loose[normalizeName(key)]; ok {
+			name, ft = f.Name, f.Type
+		}
+		if ft != nil {
+			remapped, err := remapKeys(val, ft)
+			if err != nil {
+				return nil, err
+			}
+			val = remapped
+		}
+		out[name] = val
+	}
+	return json.Marshal(out)
+}
+
+// structFields holds the jsonFields index for a struct type.
+type structFields struct {
+	tagged, loose map[string]reflect.StructField
+}
+
+// fieldCache is assigned in init because indexFields recurses through
+// jsonFields for embedded structs.
+var fieldCache *TypeCache[structFields]
+
+func init() {
+	fieldCache = NewTypeCache(func(t reflect.Type) structFields {
+		tagged, loose := indexFields(t)
+		return structFields{tagged: tagged, loose: loose}
+	})
+}
+
+// jsonFields returns the cached field index of struct t. The maps are
+// shared and must not be modified.
+func jsonFields(t reflect.Type) (tagged, loose map[string]reflect.StructField) {
+	f := fieldCache.Get(t)
+	return f.tagged, f.loose
+}
+
+// indexFields indexes the exported fields of struct t. Tagged fields are keyed
+// by their lowercased json name, untagged fields by their normalized Go name.
+// Index is the full path from t, including promoted embedded fields.
+func indexFields(t reflect.Type) (tagged, loose map[string]reflect.StructField) {
+	tagged = make(map[string]reflect.StructField)
+	loose = make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			tagged[strings.ToLower(name)] = f
+			continue
+		}
+		if ft := f.Type; f.Anonymous && ft.Kind() == reflect.Struct {
+			// Promote fields of untagged embedded structs, as encoding/json does.
+			et, el := jsonFields(ft)
+			for k, v := range et {
+				v.Index = append([]int{i}, v.Index...)
+				tagged[k] = v
+			}
+			for k, v := range el {
+				v.Index = append([]int{i}, v.Index...)
+				loose[k] = v
+			}
+			continue
+		}
+		loose[normalizeName(f.Name)] = f
+	}
+	return tagged, loose
+}
@@ -0,0 +1,584 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+type address struct {
+	StreetName string
+	PostalCode string
+}
+
+type order struct {
+	OrderID      string
+	CustomerName string
+	TotalCents   int
+	Shipping     address
+	Items        []struct{ SKUCode string }
+	Note         string `json:"memo"`
+}
+
+const snakeOrder = `{
+	"order_id": "o-1",
+	"customer_name": "Ada",
+	"total_cents": 4200,
+	"shipping": {"street_name": "Main", "postal_code": "12345"},
+	"items": [{"sku_code": "a"}, {"sku_code": "b"}],
+	"memo": "leave at door"
+}`
+
+func TestJSONBinder_SnakeCase(t *testing.T) {
+	msg := &mock.Message{V: []byte(snakeOrder)}
+
+	var got order
+	if err := (core.JSONBinder{SnakeCase: true}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+
+	if got.OrderID != "o-1" || got.CustomerName != "Ada" || got.TotalCents != 4200 {
+		t.Errorf("top-level fields not bound: %+v", got)
+	}
+	if got.Shipping.StreetName != "Main" || got.Shipping.PostalCode != "12345" {
+		t.Errorf("nested fields not bound: %+v", got.Shipping)
+	}
+	if len(got.Items) != 2 || got.Items[0].SKUCode != "a" || got.Items[1].SKUCode != "b" {
+		t.Errorf("slice fields not bound: %+v", got.Items)
+	}
+	if got.Note != "leave at door" {
+		t.Errorf("tagged field Note = %q, want %q", got.Note, "leave at door")
+	}
+}
+
+func TestJSONBinder_DefaultIgnoresSnakeCase(t *testing.T) {
+	msg := &mock.Message{V: []byte(snakeOrder)}
+
+	var got order
+	if err := (core.JSONBinder{}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got.OrderID != "" {
+		t.Errorf("OrderID = %q, want zero value without SnakeCase", got.OrderID)
+	}
+}
+
+func TestJSONBinder_TagPrecedence(t *testing.T) {
+	// "note" loosely matches the Note field name, but Note is tagged "memo",
+	// so only "memo" binds to it.
+	msg := &mock.Message{V: []byte(`{"note": "ignored", "memo": "kept"}`)}
+
+	var got order
+	if err := (core.JSONBinder{SnakeCase: true}).Bind(msg, &got); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got.Note != "kept" {
+		t.Errorf("Note = %q, want %q", got.Note, "kept")
+	}
+}
+
+func TestBind_UsesRouterBinder(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithBinder(core.JSONBinder{SnakeCase: true}))
+
+	var got order
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return core.Bind(ctx, msg, &got)
+	})
+
+	msg := &mock.Message{V: []byte(snakeOrder)}
+	if err := r.Dispatch(context.Background(), "orders.created", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if got.OrderID != "o-1" {
+		t.Errorf("OrderID = %q, want %q", got.OrderID, "o-1")
+	}
+}
+
+func TestBindLenient(t *testing.T) {
+	msg := &mock.Message{V: []byte(`{
+		"OrderID": "o-1",
+		"TotalCents": "not a number",
+		"CustomerName": "Ada",
+		"memo": 42
+	}`)}
+
+	var got order
+	errs := core.BindLenient(context.Background(), msg, &got)
+
+	if got.OrderID != "o-1" || got.CustomerName != "Ada" {
+		t.Errorf("valid fields not bound: %+v", got)
+	}
+	if got.TotalCents != 0 || got.Note != "" {
+		t.Errorf("malformed fields should be left untouched: %+v", got)
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 field errors, got %v", errs)
+	}
+	if errs[0].Field != "TotalCents" || errs[1].Field != "memo" {
+		t.Errorf("field errors = [%q %q], want [TotalCents memo]", errs[0].Field, errs[1].Field)
+	}
+}
+
+func TestBindLenient_NotObject(t *testing.T) {
+	var got order
+	errs := core.BindLenient(context.Background(), &mock.Message{V: []byte(`[1,2]`)}, &got)
+	if len(errs) != 1 || errs[0].Field != "" {
+		t.Errorf("expected one payload-level error, got %v", errs)
+	}
+}
+
+func TestJSONBinder_EmptyPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  core.Message
+	}{
+		{"nil message", nil},
+		{"nil value", &mock.Message{}},
+		{"empty value", &mock.Message{V: []byte{}}},
+		{"whitespace", &mock.Message{V: []byte(" \n\t ")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got order
+			if err := (core.JSONBinder{}).Bind(tt.msg, &got); !errors.Is(err, core.ErrEmptyPayload) {
+				t.Errorf("expected ErrEmptyPayload, got %v", err)
+			}
+
+			got = order{OrderID: "keep"}
+			if err := (core.JSONBinder{AllowEmpty: true}).Bind(tt.msg, &got); err != nil {
+				t.Errorf("AllowEmpty: unexpected error %v", err)
+			}
+			if got.OrderID != "keep" {
+				t.Errorf("AllowEmpty: value modified: %+v", got)
+			}
+		})
+	}
+}
+
+func TestTypeCache_PerType(t *testing.T) {
+	type a struct{ X int }
+	type b struct{ Y, Z int }
+
+	builds := 0
+	c := core.NewTypeCache(func(t reflect.Type) int {
+		builds++
+		return t.NumField()
+	})
+
+	for i := 0; i < 3; i++ {
+		if got := c.Get(reflect.TypeOf(a{})); got != 1 {
+			t.Errorf("Get(a) = %d, want 1", got)
+		}
+		if got := c.Get(reflect.TypeOf(b{})); got != 2 {
+			t.Errorf("Get(b) = %d, want 2", got)
+		}
+	}
+	if builds != 2 {
+		t.Errorf("build ran %d times, want once per type", builds)
+	}
+}
+
+func TestJSONBinder_SnakeCaseDistinctTypes(t *testing.T) {
+	// Two types with overlapping loose names must not share cached fields.
+	type left struct{ UserID string }
+	type right struct {
+		UserID string `json:"uid"`
+	}
+	msg := &mock.Message{V: []byte(`{"user_id": "u-1", "uid": "u-2"}`)}
+	b := core.JSONBinder{SnakeCase: true}
+
+	var l left
+	var r right
+	for i := 0; i < 2; i++ {
+		if err := b.Bind(msg, &l); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Bind(msg, &r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.UserID != "u-1" || r.UserID != "u-2" {
+		t.Errorf("left = %+v, right = %+v", l, r)
+	}
+}
+
+func TestJSONBinder_SnakeCaseCollision(t *testing.T) {
+	type user struct{ UserID string }
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"user_id": "snake", "userId": "camel"}`, "camel"},
+		{`{"userId": "camel", "user_id": "snake"}`, "camel"},
+		{`{"user-id": "kebab", "user_id": "snake"}`, "kebab"},
+		{`{"userid": "lower", "UserID": "exact", "user_id": "snake"}`, "exact"},
+	}
+	var lenient user
+	r := core.New(mock.NewBroker(), core.WithBinder(core.JSONBinder{SnakeCase: true}))
+	r.Handle("users", func(ctx context.Context, msg core.Message) error {
+		if errs := core.BindLenient(ctx, msg, &lenient); len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
+	for _, tt := range tests {
+		msg := &mock.Message{V: []byte(tt.payload)}
+		for i := 0; i < 20; i++ { // map order varies between runs
+			var strict user
+			if err := (core.JSONBinder{SnakeCase: true}).Bind(msg, &strict); err != nil {
+				t.Fatal(err)
+			}
+			lenient = user{}
+			if err := r.Dispatch(context.Background(), "users", msg); err != nil {
+				t.Fatal(err)
+			}
+			if strict.UserID != tt.want || lenient.UserID != tt.want {
+				t.Fatalf("%s: Bind = %q, BindLenient = %q, want %q", tt.payload, strict.UserID, lenient.UserID, tt.want)
+			}
+		}
+	}
+}
+
+func BenchmarkJSONBinder_SnakeCase(b *testing.B) {
+	msg := &mock.Message{V: []byte(snakeOrder)}
+	binder := core.JSONBinder{SnakeCase: true}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var o order
+		if err := binder.Bind(msg, &o); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestJSONBinder_MaxDepth(t *testing.T) {
+	b := core.JSONBinder{MaxDepth: 8}
+
+	bomb := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	var v any
+	if err := b.Bind(&mock.Message{V: []byte(bomb)}, &v); !errors.Is(err, core.ErrPayloadTooComplex) {
+		t.Errorf("deep payload: expected ErrPayloadTooComplex, got %v", err)
+	}
+
+	// Brackets inside strings, including after escaped quotes, do not count.
+	var got order
+	payload := `{"OrderID":"[[[[[[[[[[\"{{{{{{{{{{","Shipping":{"PostalCode":"12345"}}`
+	if err := b.Bind(&mock.Message{V: []byte(payload)}, &got); err != nil {
+		t.Fatalf("normal payload: %v", err)
+	}
+	if got.Shipping.PostalCode != "12345" || !strings.HasPrefix(got.OrderID, "[[[") {
+		t.Errorf("decoded %+v", got)
+	}
+}
+
+func TestJSONBinder_MaxBytes(t *testing.T) {
+	b := core.JSONBinder{MaxBytes: 16}
+	var v map[string]string
+	if err := b.Bind(&mock.Message{V: []byte(`{"k":"` + strings.Repeat("x", 32) + `"}`)}, &v); !errors.Is(err, core.ErrPayloadTooComplex) {
+		t.Errorf("large payload: expected ErrPayloadTooComplex, got %v", err)
+	}
+	if err := b.Bind(&mock.Message{V: []byte(`{"k":"v"}`)}, &v); err != nil {
+		t.Errorf("small payload: %v", err)
+	}
+}
+
+func TestBind_RecordsFailure(t *testing.T) {
+	ctx, _ := core.WithStore(context.Background())
+	msg := &mock.Message{V: []byte(`{"street_name":`)}
+
+	var a address
+	err := core.Bind(ctx, msg, &a)
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+
+	be, ok := core.BindFailure(ctx)
+	if !ok {
+		t.Fatal("expected a recorded bind failure")
+	}
+	if string(be.Raw) != `{"street_name":` {
+		t.Errorf("Raw = %q", be.Raw)
+	}
+	if be.Err.Error() != err.Error() {
+		t.Errorf("Err = %v, want %v", be.Err, err)
+	}
+	if v, _ := core.StoreFrom(ctx).Get("bind_error"); v != be {
+		t.Errorf(`Get("bind_error") = %v, want the BindError`, v)
+	}
+
+	msg.V[0] = 'x'
+	if be.Raw[0] != '{' {
+		t.Error("Raw must be a copy of the payload")
+	}
+}
+
+func TestBind_SuccessRecordsNothing(t *testing.T) {
+	ctx, _ := core.WithStore(context.Background())
+	var a address
+	if err := core.Bind(ctx, &mock.Message{V: []byte(`{}`)}, &a); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if _, ok := core.BindFailure(ctx); ok {
+		t.Error("no failure should be recorded")
+	}
+	if _, ok := core.BindFailure(context.Background()); ok {
+		t.Error("no failure without a store")
+	}
+}
+
+// panickingBinder is a buggy custom Binder.
+type panickingBinder struct{}
+
+func (panickingBinder) Bind(core.Message, any) error { panic("reflect: call of nil func") }
+
+func TestBind_BinderPanic(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithBinder(panickingBinder{}), core.WithDeadLetterTopic("dlq"))
+
+	var bindErr error
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		var o order
+		if bindErr = core.Bind(ctx, msg, &o); bindErr != nil {
+			return core.DLQResult("undecodable")
+		}
+		return core.AckResult()
+	})
+
+	// The next message is handled as usual after the panic.
+	for i := 0; i < 2; i++ {
+		msg := &mock.Message{V: []byte(`{}`)}
+		if err := r.Dispatch(context.Background(), "orders.created", msg); err != nil {
+			t.Fatalf("dispatch %d: %v", i, err)
+		}
+		if !errors.Is(bindErr, core.ErrBindPanic) {
+			t.Fatalf("Bind = %v, want ErrBindPanic", bindErr)
+		}
+		if !strings.Contains(bindErr.Error(), "reflect: call of nil func") {
+			t.Errorf("Bind = %q, want the panic value", bindErr)
+		}
+		if !msg.Acked {
+			t.Errorf("dispatch %d: message should be dead-lettered and acked", i)
+		}
+	}
+	dlq := mb.PublishedTo("dlq")
+	if len(dlq) != 2 {
+		t.Errorf("dead-lettered %d messages, want 2", len(dlq))
+	}
+}
+
+func TestBind_BinderPanicRecordsFailure(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithBinder(panickingBinder{}))
+
+	var recorded bool
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		ctx, _ = core.WithStore(ctx)
+		var o order
+		_ = core.Bind(ctx, msg, &o)
+		be, ok := core.BindFailure(ctx)
+		recorded = ok && errors.Is(be, core.ErrBindPanic)
+		return nil
+	})
+	if err := r.Dispatch(context.Background(), "orders.created", &mock.Message{V: []byte(`{}`)}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if !recorded {
+		t.Error("a binder panic should be recorded as a BindError")
+	}
+}
+
+// countingBinder counts Bind calls before delegating to JSONBinder.
+type countingBinder struct{ calls *int }
+
+func (b countingBinder) Bind(msg core.Message, v any) error {
+	*b.calls++
+	return core.JSONBinder{}.Bind(msg, v)
+}
+
+func TestBind_CachedByType(t *testing.T) {
+	var calls int
+	r := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&calls}))
+
+	// Validation middleware binds first, with a Store for the cache.
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			ctx, _ = core.WithStore(ctx)
+			var a address
+			if err := core.Bind(ctx, msg, &a); err != nil || a.PostalCode == "" {
+				return core.NackResult()
+			}
+			a.StreetName = "changed by middleware"
+			return next(ctx, msg)
+		}
+	})
+
+	var first, second address
+	var other struct{ PostalCode string }
+	r.Handle("addresses", func(ctx context.Context, msg core.Message) error {
+		if err := core.Bind(ctx, msg, &first); err != nil {
+			return err
+		}
+		if err := core.Bind(ctx, msg, &second); err != nil {
+			return err
+		}
+		if calls != 1 {
+			t.Errorf("binder called %d times for one type, want 1", calls)
+		}
+		if err := core.Bind(ctx, msg, &other); err != nil {
+			return err
+		}
+		if calls != 2 {
+			t.Errorf("binder called %d times after binding a second type, want 2", calls)
+		}
+		return core.AckResult()
+	})
+
+	msg := &mock.Message{V: []byte(`{"StreetName":"Main","PostalCode":"12345"}`)}
+	if err := r.Dispatch(context.Background(), "addresses", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	want := address{StreetName: "Main", PostalCode: "12345"}
+	if first != want || second != want {
+		t.Errorf("bound %+v and %+v, want %+v", first, second, want)
+	}
+	if other.PostalCode != "12345" {
+		t.Errorf("other.PostalCode = %q, want 12345", other.PostalCode)
+	}
+}
+
+func TestBind_CacheMissesTransformedPayload(t *testing.T) {
+	var calls int
+	r := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&calls}))
+
+	var before, after address
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			ctx, _ = core.WithStore(ctx)
+			if err := core.Bind(ctx, msg, &before); err != nil {
+				return err
+			}
+			// Replace the payload, as decompressing middleware would.
+			return next(ctx, &mock.Message{V: []byte(`{"PostalCode":"2"}`)})
+		}
+	})
+	r.Handle("addresses", func(ctx context.Context, msg core.Message) error {
+		return core.Bind(ctx, msg, &after)
+	})
+
+	if err := r.Dispatch(context.Background(), "addresses", &mock.Message{V: []byte(`{"PostalCode":"1"}`)}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 2 || before.PostalCode != "1" || after.PostalCode != "2" {
+		t.Errorf("calls = %d, PostalCode before/after = %q/%q; want 2, 1/2", calls, before.PostalCode, after.PostalCode)
+	}
+}
+
+func TestBind_CacheKeepsMergeIntoPopulatedTarget(t *testing.T) {
+	var calls int
+	r := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&calls}))
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			ctx, _ = core.WithStore(ctx)
+			var a address
+			if err := core.Bind(ctx, msg, &a); err != nil {
+				return err
+			}
+			return next(ctx, msg)
+		}
+	})
+	got := address{StreetName: "Main"}
+	r.Handle("addresses", func(ctx context.Context, msg core.Message) error {
+		return core.Bind(ctx, msg, &got)
+	})
+
+	if err := r.Dispatch(context.Background(), "addresses", &mock.Message{V: []byte(`{"PostalCode":"12345"}`)}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if want := (address{StreetName: "Main", PostalCode: "12345"}); got != want {
+		t.Errorf("bound %+v, want %+v merged into the populated target", got, want)
+	}
+	if calls != 2 {
+		t.Errorf("binder called %d times, want 2: a populated target is always decoded", calls)
+	}
+}
+
+func TestBind_CachePerBinder(t *testing.T) {
+	var first, second int
+	handle := func(ctx context.Context, msg core.Message) error {
+		var a address
+		return core.Bind(ctx, msg, &a)
+	}
+	r1 := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&first}))
+	r1.Handle("addresses", handle)
+	r2 := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&second}))
+	r2.Handle("addresses", handle)
+
+	// Both routers see the same Store and payload.
+	ctx, _ := core.WithStore(context.Background())
+	msg := &mock.Message{V: []byte(`{"PostalCode":"12345"}`)}
+	for _, r := range []*core.Router{r1, r2} {
+		if err := r.Dispatch(ctx, "addresses", msg); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+	}
+	if first != 1 || second != 1 {
+		t.Errorf("binder calls = %d/%d, want each binder to decode once", first, second)
+	}
+}
+
+func TestBind_NoCacheWithoutStore(t *testing.T) {
+	var calls int
+	r := core.New(mock.NewBroker(), core.WithBinder(countingBinder{&calls}))
+	r.Handle("addresses", func(ctx context.Context, msg core.Message) error {
+		var a, b address
+		_ = core.Bind(ctx, msg, &a)
+		return core.Bind(ctx, msg, &b)
+	})
+	if err := r.Dispatch(context.Background(), "addresses", &mock.Message{V: []byte(`{}`)}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("binder called %d times without a Store, want 2", calls)
+	}
+}
+
+// hookBinder is a struct binder with an interface field. Its type is
+// comparable, but not when the field holds a func or a slice.
+type hookBinder struct{ hook any }
+
+func (b hookBinder) Bind(msg core.Message, v any) error {
+	return core.JSONBinder{}.Bind(msg, v)
+}
+
+func TestBind_UncomparableBinderValue(t *testing.T) {
+	hooks := map[string]any{
+		"func":  func() {},
+		"slice": []string{"a"},
+	}
+	for name, hook := range hooks {
+		t.Run(name, func(t *testing.T) {
+			r := core.New(mock.NewBroker(), core.WithBinder(hookBinder{hook}))
+			var a, b address
+			r.Handle("addresses", func(ctx context.Context, msg core.Message) error {
+				ctx, _ = core.WithStore(ctx)
+				if err := core.Bind(ctx, msg, &a); err != nil {
+					return err
+				}
+				return core.Bind(ctx, msg, &b)
+			})
+			msg := &mock.Message{V: []byte(`{"PostalCode":"12345"}`)}
+			if err := r.Dispatch(context.Background(), "addresses", msg); err != nil {
+				t.Fatalf("dispatch: %v", err)
+			}
+			if a.PostalCode != "12345" || b.PostalCode != "12345" {
+				t.Errorf("bound %+v and %+v, want both decoded without caching", a, b)
+			}
+		})
+	}
+}
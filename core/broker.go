@@ -1,6 +1,9 @@
 package core
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Broker defines the contract for message broker implementations.
 // Each broker plugin must implement this interface.
@@ -9,3 +12,43 @@ type Broker interface {
 	Subscribe(ctx context.Context, topic string, handler Handler) error
 	Close() error
 }
+
+// Publisher publishes messages to a topic. Both Broker and *Router
+// implement it, so middleware can republish without knowing which it has.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg Message) error
+}
+
+// ReconnectEvent describes a broker re-establishing its connection.
+type ReconnectEvent struct {
+	// Broker is the plugin name, e.g. "nats".
+	Broker string
+	// Time is when the connection was re-established.
+	Time time.Time
+	// Attempt counts reconnects since the broker was created, starting at 1.
+	Attempt int
+}
+
+// Reconnecter is implemented by brokers that report reconnections.
+// The channel is closed when the broker is closed.
+type Reconnecter interface {
+	Reconnects() <-chan ReconnectEvent
+}
+
+// Replayer is implemented by brokers that can re-read a topic's history.
+// ReplayFrom delivers every message published to topic at or after since,
+// in order, and returns once it has caught up with the messages present
+// when it started. It must not disturb the position of regular
+// subscriptions.
+type Replayer interface {
+	ReplayFrom(ctx context.Context, topic string, since time.Time, h Handler) error
+}
+
+// Provisioner is implemented by brokers that can create the infrastructure
+// subscriptions need, such as Kafka topics, NATS streams or RabbitMQ
+// queues. Provision receives the subscription pattern of every route and
+// must be idempotent: existing infrastructure is left in place or updated
+// to match the broker's options.
+type Provisioner interface {
+	Provision(ctx context.Context, topics []string) error
+}
@@ -0,0 +1,55 @@
+package core
+
+import "slices"
+
+// Clone returns a Router that shares r's broker and starts with a copy of
+// its configuration: options given to New, the binder and matcher, and the
+// middleware registered with Use, UseRaw and UsePublisher. Routes, the
+// default handler and OnReconnect callbacks are not copied, and later
+// changes to either Router do not affect the other. This lets one broker
+// serve several processing profiles, e.g. a fast path and a slow
+// enrichment path on different topics, each started on its own.
+//
+// The broker stays owned by r: closing the clone, by Close or by cancelling
+// its Start context, only stops the clone's subscriptions and publishing.
+// Close r last, once every clone has stopped, to close the broker.
+func (r *Router) Clone() *Router {
+	r.mu.RLock()
+	c := &Router{
+		broker:      r.broker,
+		borrowed:    true,
+		middlewares: slices.Clone(r.middlewares),
+		raw:         slices.Clone(r.raw),
+		publishers:  slices.Clone(r.publishers),
+		routes:      make(map[string]Handler),
+		matcher:     r.matcher,
+
+		deadLetterTopic: r.deadLetterTopic,
+		binder:          r.binder,
+		publishMode:     r.publishMode,
+		publishBuffer:   r.publishBuffer,
+		ingressFilter:   r.ingressFilter,
+		egressFilter:    r.egressFilter,
+		closeTimeout:    r.closeTimeout,
+		framer:          r.framer,
+		validateTopic:   r.validateTopic,
+		nackBackoff:     r.nackBackoff,
+		dispatchMode:    r.dispatchMode,
+		goroutineLimit:  r.goroutineLimit,
+		propagators:     slices.Clone(r.propagators),
+		shutdownPolicy:  r.shutdownPolicy,
+		topicAllowlist:  slices.Clone(r.topicAllowlist),
+		maxRedeliveries: r.maxRedeliveries,
+		redeliveryTopic: r.redeliveryTopic,
+		logUnmatched:    r.logUnmatched,
+		unmatchedLimit:  r.unmatchedLimit,
+		publishRetry:    r.publishRetry,
+		workers:         r.workers,
+	}
+	r.mu.RUnlock()
+	if c.publishMode == PublishBestEffort && c.broker != nil {
+		c.publishQueue = newPublishQueue(c.publishBuffer)
+		c.spawn(func() { c.publishQueue.run(c.sender(c.broker)) })
+	}
+	return c
+}
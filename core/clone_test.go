@@ -0,0 +1,96 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestRouter_Clone(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("dlq"))
+
+	var calls []string
+	tag := func(name string) core.Middleware {
+		return func(next core.Handler) core.Handler {
+			return func(ctx context.Context, msg core.Message) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+	noop := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Use(tag("shared"))
+	r.Handle("orders", noop)
+
+	c := r.Clone()
+	c.Use(tag("enrich"))
+	c.Handle("orders.enrich", func(ctx context.Context, msg core.Message) error {
+		return core.DLQResult("slow path")
+	})
+	r.Use(tag("fast"))
+
+	if err := c.Dispatch(context.Background(), "orders", &mock.Message{}); !errors.Is(err, core.ErrNoHandler) {
+		t.Errorf("clone should start without routes, got %v", err)
+	}
+	if err := r.Dispatch(context.Background(), "orders.enrich", &mock.Message{}); !errors.Is(err, core.ErrNoHandler) {
+		t.Errorf("routes added to the clone should not reach the original, got %v", err)
+	}
+
+	calls = nil
+	if err := r.Dispatch(context.Background(), "orders", &mock.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Dispatch(context.Background(), "orders.enrich", &mock.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"shared", "fast", "shared", "enrich"}
+	if len(calls) != len(want) {
+		t.Fatalf("middleware calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+
+	// Config is copied: the clone dead-letters through the shared broker.
+	if pubs := mb.PublishedTo("dlq"); len(pubs) != 1 {
+		t.Errorf("clone published %d dead letters to the shared broker, want 1", len(pubs))
+	}
+}
+
+func TestRouter_CloneLifecycle(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	c := r.Clone()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Handle("slow", func(ctx context.Context, msg core.Message) error { return nil })
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	waitStats(t, c, func(s core.Stats) bool { return s.Subscriptions == 1 })
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("clone Start = %v", err)
+	}
+	if mb.IsClosed() {
+		t.Fatal("stopping the clone should leave the shared broker open")
+	}
+	if err := c.Publish(context.Background(), "t", &mock.Message{}); !errors.Is(err, core.ErrBrokerClosed) {
+		t.Errorf("closed clone Publish = %v, want ErrBrokerClosed", err)
+	}
+	if err := r.Publish(context.Background(), "t", &mock.Message{}); err != nil {
+		t.Errorf("original should still publish: %v", err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !mb.IsClosed() {
+		t.Error("closing the original should close the broker")
+	}
+}
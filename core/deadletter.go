@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type deadLetterKey struct{}
+
+// deadLetterState is attached to the context of each message dispatched by
+// a Router that can dead-letter. It gives DeadLetter the Router and carries
+// the message's last Bind failure to the dead-letter copy, whether or not
+// the handler chain installed a Store.
+type deadLetterState struct {
+	r       *Router
+	bindErr atomic.Pointer[BindError]
+}
+
+// DeadLetter republishes msg to the dead-letter topic of the Router that
+// dispatched it (see WithDeadLetterTopic), stamped with the reason and
+// attempt headers, then acknowledges it. Use it when a handler knows at once
+// that a message can never be processed, e.g. an unknown schema version;
+// returning DLQResult has the same effect. It returns ErrNoDeadLetterTopic
+// outside a Router or if no dead-letter topic is configured.
+func DeadLetter(ctx context.Context, msg Message, reason string) error {
+	st, ok := ctx.Value(deadLetterKey{}).(*deadLetterState)
+	if !ok {
+		return ErrNoDeadLetterTopic
+	}
+	return st.r.deadLetter(ctx, msg, reason)
+}
+
+// withDeadLetter attaches a fresh deadLetterState for one message. Routers
+// that never dead-letter skip it to keep dispatch allocation-free.
+func (r *Router) withDeadLetter(ctx context.Context) context.Context {
+	if r.deadLetterTopic == "" && r.maxRedeliveries <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, deadLetterKey{}, &deadLetterState{r: r})
+}
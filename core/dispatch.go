@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// DispatchMode selects which routes Router.Dispatch runs when several
+// patterns match a topic.
+type DispatchMode int
+
+const (
+	// DispatchMostSpecific runs only the most specific matching route (see
+	// Router.Dispatch). It is the default and suits business handlers,
+	// which should process a message exactly once.
+	DispatchMostSpecific DispatchMode = iota
+
+	// DispatchAllMatching runs every matching route, in lexical order of
+	// their patterns, which suits observability handlers that want to see
+	// everything alongside the business ones. Each route's handler chain
+	// settles only its own view of the message, as with framed payloads:
+	// the original is nacked if any route nacked it, acked once every route
+	// has acked (or dead-lettered) it, and left unsettled otherwise. A plain
+	// error stops the remaining routes and is returned.
+	DispatchAllMatching
+)
+
+// routeMatch is a route selected for a topic, with its captured params.
+type routeMatch struct {
+	h      Handler
+	params Params
+}
+
+// matchAll returns every route matching topic in lexical order of pattern,
+// or the default handler alone if none does. Callers must hold r.mu.
+func (r *Router) matchAll(topic string) []routeMatch {
+	patterns := make([]string, 0, len(r.routes))
+	for p := range r.routes {
+		if r.matcher.Match(p, topic) {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		r.recordUnmatched(topic)
+		if r.fallback == nil {
+			return nil
+		}
+		return []routeMatch{{h: r.fallback}}
+	}
+	sort.Strings(patterns)
+	matches := make([]routeMatch, len(patterns))
+	for i, p := range patterns {
+		matches[i].h = r.routes[p]
+		if hasCaptures(p) {
+			matches[i].params, _ = captureParams(r.matcher, p, topic)
+		}
+	}
+	return matches
+}
+
+// fanoutMessage is the view of a message given to one of several routes.
+// Settling it only records the outcome; the Router settles the original
+// once every route has handled it.
+type fanoutMessage struct {
+	Message
+	acked  bool
+	nacked bool
+}
+
+func (m *fanoutMessage) Topic() string { return Topic(m.Message) }
+func (m *fanoutMessage) Ack() error    { m.acked = true; return nil }
+func (m *fanoutMessage) Nack() error   { m.nacked = true; return nil }
+
+// runAll runs msg through each route in matches, wrapped in mws, and then
+// settles the original as described for DispatchAllMatching.
+func (r *Router) runAll(ctx context.Context, msg Message, matches []routeMatch, mws []Middleware) error {
+	acked, nacked := 0, false
+	for _, m := range matches {
+		fm := &fanoutMessage{Message: msg}
+		hctx := withParams(withBinder(ctx, r.binder), m.params)
+		if err := r.run(hctx, fm, applyMiddleware(m.h, mws)); err != nil {
+			return err
+		}
+		if fm.nacked {
+			nacked = true
+		} else if fm.acked {
+			acked++
+		}
+	}
+	return r.settleParts(msg, acked, len(matches), nacked)
+}
+
+// settleParts settles msg once for the parts it was handled as, frames or
+// routes: nacked if any part was nacked, acked if all were acked, and left
+// unsettled otherwise.
+func (r *Router) settleParts(msg Message, acked, total int, nacked bool) error {
+	switch {
+	case nacked:
+		return r.nack(msg)
+	case acked == total:
+		return msg.Ack()
+	default:
+		return nil
+	}
+}
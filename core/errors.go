@@ -1,6 +1,11 @@
 package core
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 var (
 	// ErrBrokerClosed is returned when operations are attempted on a closed broker.
@@ -9,9 +14,96 @@ var (
 	// ErrNoHandler is returned when no handler matches the incoming topic.
 	ErrNoHandler = errors.New("eventmux: no handler registered for topic")
 
-	// ErrAlreadyStarted is returned when Start is called on a running router.
+	// ErrAlreadyStarted is returned when Start is called on a running router,
+	// or when a setter such as SetBinder is called after Start.
 	ErrAlreadyStarted = errors.New("eventmux: router already started")
 
 	// ErrNoBroker is returned when a router is created without a broker.
 	ErrNoBroker = errors.New("eventmux: broker is nil")
+
+	// ErrNoDeadLetterTopic is returned when a message is dead-lettered on a
+	// router without a dead-letter topic.
+	ErrNoDeadLetterTopic = errors.New("eventmux: no dead-letter topic configured")
+
+	// ErrEmptyPayload is returned by Bind when the message is nil or its
+	// payload is empty or whitespace-only. Handlers can branch on it, e.g.
+	// to treat the message as a tombstone.
+	ErrEmptyPayload = errors.New("eventmux: empty payload")
+
+	// ErrBindPanic is returned by Bind when the Binder panics.
+	ErrBindPanic = errors.New("eventmux: bind panic")
+
+	// ErrPayloadTooComplex is returned by JSONBinder when a payload exceeds
+	// its MaxDepth or MaxBytes limit.
+	ErrPayloadTooComplex = errors.New("eventmux: payload too complex")
+
+	// ErrUnexpectedElement is returned by XMLBinder when the document's root
+	// element is not the one the target type expects.
+	ErrUnexpectedElement = errors.New("eventmux: unexpected XML element")
+
+	// ErrInvalidTopic is returned when publishing to a topic rejected by the
+	// Router's TopicValidator.
+	ErrInvalidTopic = errors.New("eventmux: invalid topic")
+
+	// ErrTopicNotAllowed is returned by Start when a route pattern is
+	// outside the allowlist set by WithTopicAllowlist.
+	ErrTopicNotAllowed = errors.New("eventmux: topic pattern not in allowlist")
+
+	// ErrMissingHeaders is returned for messages rejected by RequireHeaders.
+	ErrMissingHeaders = errors.New("eventmux: missing required headers")
+
+	// ErrMalformedFrame is returned when a Framer cannot split a payload.
+	ErrMalformedFrame = errors.New("eventmux: malformed frame")
+
+	// ErrReplayUnsupported is returned by Router.ReplayFrom when the broker
+	// does not implement Replayer.
+	ErrReplayUnsupported = errors.New("eventmux: broker does not support replay")
+
+	// ErrProvisionUnsupported is returned by Router.Provision when the broker
+	// does not implement Provisioner.
+	ErrProvisionUnsupported = errors.New("eventmux: broker does not support provisioning")
+
+	// ErrConsumingStopped is returned to the broker for messages delivered
+	// after Router.StopConsuming, so they are left for redelivery.
+	ErrConsumingStopped = errors.New("eventmux: router stopped consuming")
+
+	// ErrCloseTimeout is returned when the broker's Close did not finish
+	// within the timeout set by WithCloseTimeout.
+	ErrCloseTimeout = errors.New("eventmux: broker close timed out")
+
+	// ErrPublishDropped is delivered by Router.EmitAsync when a best-effort
+	// message was dropped because the publish queue was full.
+	ErrPublishDropped = errors.New("eventmux: publish dropped, queue full")
 )
+
+// FanoutError is returned by Router.PublishFanout when publishing to one or
+// more topics fails. Delivery to the remaining topics is not rolled back.
+type FanoutError struct {
+	// Succeeded lists the topics the message was published to.
+	Succeeded []string
+	// Failed maps each failed topic to its publish error.
+	Failed map[string]error
+}
+
+func (e *FanoutError) Error() string {
+	topics := make([]string, 0, len(e.Failed))
+	for topic := range e.Failed {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	parts := make([]string, len(topics))
+	for i, topic := range topics {
+		parts[i] = fmt.Sprintf("%q: %v", topic, e.Failed[topic])
+	}
+	return fmt.Sprintf("eventmux: fanout failed for %d of %d topics: %s",
+		len(e.Failed), len(e.Failed)+len(e.Succeeded), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the individual publish errors for errors.Is and errors.As.
+func (e *FanoutError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Framer splits a payload carrying several logical messages into frames.
+// When a Router has a Framer, each frame is handled as its own message;
+// see WithFramer.
+type Framer interface {
+	Split(data []byte) ([][]byte, error)
+}
+
+// NoFraming treats the whole payload as a single frame.
+type NoFraming struct{}
+
+// Split implements Framer.
+func (NoFraming) Split(data []byte) ([][]byte, error) {
+	return [][]byte{data}, nil
+}
+
+// NewlineFramer splits newline-delimited payloads such as NDJSON. A
+// trailing "\r" is stripped from each line and blank lines are skipped.
+type NewlineFramer struct{}
+
+// Split implements Framer.
+func (NewlineFramer) Split(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(bytes.TrimSpace(line)) > 0 {
+			frames = append(frames, line)
+		}
+	}
+	return frames, nil
+}
+
+// VarintFramer splits payloads where each frame is preceded by its length
+// as an unsigned varint, the framing used for delimited protobuf streams.
+type VarintFramer struct{}
+
+// Split implements Framer.
+func (VarintFramer) Split(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for off := 0; off < len(data); {
+		n, w := binary.Uvarint(data[off:])
+		if w <= 0 {
+			return nil, fmt.Errorf("%w: bad varint length at offset %d", ErrMalformedFrame, off)
+		}
+		off += w
+		if n > uint64(len(data)-off) {
+			return nil, fmt.Errorf("%w: frame of %d bytes at offset %d overruns payload", ErrMalformedFrame, n, off)
+		}
+		frames = append(frames, data[off:off+int(n)])
+		off += int(n)
+	}
+	return frames, nil
+}
+
+// FixedLengthFramer splits payloads where each frame is preceded by its
+// length as a big-endian unsigned integer of Size bytes (1, 2, 4 or 8).
+type FixedLengthFramer struct {
+	Size int
+}
+
+// Split implements Framer.
+func (f FixedLengthFramer) Split(data []byte) ([][]byte, error) {
+	switch f.Size {
+	case 1, 2, 4, 8:
+	default:
+		return nil, fmt.Errorf("%w: unsupported length prefix size %d", ErrMalformedFrame, f.Size)
+	}
+	var frames [][]byte
+	for off := 0; off < len(data); {
+		if len(data)-off < f.Size {
+			return nil, fmt.Errorf("%w: truncated length prefix at offset %d", ErrMalformedFrame, off)
+		}
+		var n uint64
+		for _, b := range data[off : off+f.Size] {
+			n = n<<8 | uint64(b)
+		}
+		off += f.Size
+		if n > uint64(len(data)-off) {
+			return nil, fmt.Errorf("%w: frame of %d bytes at offset %d overruns payload", ErrMalformedFrame, n, off)
+		}
+		frames = append(frames, data[off:off+int(n)])
+		off += int(n)
+	}
+	return frames, nil
+}
+
+// frameMessage presents one frame of a framed message. Settling it only
+// records the outcome; the Router settles the original once every frame
+// has been handled. Topic, attempt, delivery counting and the original's
+// log position are forwarded, so redelivery limits and offset tracking work
+// per frame; sibling frames share the position and differ in FrameIndex.
+type frameMessage struct {
+	Message
+	value  []byte
+	index  int
+	acked  bool
+	nacked bool
+	delay  time.Duration // longest delay a frame was nacked with
+}
+
+func (m *frameMessage) Value() []byte           { return m.value }
+func (m *frameMessage) Size() int               { return len(m.value) }
+func (m *frameMessage) Topic() string           { return Topic(m.Message) }
+func (m *frameMessage) Attempt() int            { return Attempt(m.Message) }
+func (m *frameMessage) CountsDeliveries() bool  { return countsDeliveries(m.Message) }
+func (m *frameMessage) FrameIndex() (int, bool) { return m.index, true }
+func (m *frameMessage) Ack() error              { m.acked = true; return nil }
+func (m *frameMessage) Nack() error             { m.nacked = true; return nil }
+
+func (m *frameMessage) NackWithDelay(d time.Duration) error {
+	m.nacked = true
+	m.delay = max(m.delay, d)
+	return nil
+}
+
+// runFrames splits msg with the router's Framer and runs h for each frame
+// in order, resolving each frame's Result on its own. A plain error stops
+// the remaining frames and is returned to the broker. Otherwise the original
+// is nacked if any frame was nacked, with the longest delay a frame asked
+// for, acked if every frame was acked (including dead-lettered frames), and
+// left unsettled if not.
+func (r *Router) runFrames(ctx context.Context, msg Message, h Handler) error {
+	frames, err := r.framer.Split(msg.Value())
+	if err != nil {
+		return fmt.Errorf("eventmux: frame: %w", err)
+	}
+	acked, nacked := 0, false
+	var delay time.Duration
+	for i, frame := range frames {
+		fm := &frameMessage{Message: msg, value: frame, index: i}
+		fmsg := withPosition(fm, msg)
+		fctx := r.withDeadLetter(ctx)
+		if err := r.resolve(fctx, fmsg, h(fctx, fmsg)); err != nil {
+			return err
+		}
+		if fm.nacked {
+			nacked = true
+			delay = max(delay, fm.delay)
+		} else if fm.acked {
+			acked++
+		}
+	}
+	if nacked && delay > 0 {
+		return NackWithDelay(msg, delay)
+	}
+	return r.settleParts(msg, acked, len(frames), nacked)
+}
@@ -0,0 +1,246 @@
+package core_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func frameStrings(frames [][]byte) string {
+	s := make([]string, len(frames))
+	for i, f := range frames {
+		s[i] = string(f)
+	}
+	return strings.Join(s, "|")
+}
+
+func TestFramers(t *testing.T) {
+	varint := func(frames ...string) []byte {
+		var out []byte
+		for _, f := range frames {
+			out = binary.AppendUvarint(out, uint64(len(f)))
+			out = append(out, f...)
+		}
+		return out
+	}
+	fixed := func(frames ...string) []byte {
+		var out []byte
+		for _, f := range frames {
+			out = binary.BigEndian.AppendUint16(out, uint16(len(f)))
+			out = append(out, f...)
+		}
+		return out
+	}
+	long := strings.Repeat("x", 300) // needs a two-byte varint
+
+	tests := []struct {
+		name   string
+		framer core.Framer
+		data   []byte
+		want   string
+	}{
+		{"none", core.NoFraming{}, []byte("a\nb"), "a\nb"},
+		{"newline", core.NewlineFramer{}, []byte("{\"a\":1}\r\n\n{\"b\":2}\n"), `{"a":1}|{"b":2}`},
+		{"newline without trailing", core.NewlineFramer{}, []byte("a\nb"), "a|b"},
+		{"varint", core.VarintFramer{}, varint("ab", "", long), "ab||" + long},
+		{"fixed", core.FixedLengthFramer{Size: 2}, fixed("ab", "cde"), "ab|cde"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := tt.framer.Split(tt.data)
+			if err != nil {
+				t.Fatalf("Split: %v", err)
+			}
+			if got := frameStrings(frames); got != tt.want {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFramers_Malformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		framer core.Framer
+		data   []byte
+	}{
+		{"varint overrun", core.VarintFramer{}, []byte{5, 'a', 'b'}},
+		{"varint truncated", core.VarintFramer{}, []byte{0x80}},
+		{"fixed overrun", core.FixedLengthFramer{Size: 4}, []byte{0, 0, 0, 9, 'a'}},
+		{"fixed truncated prefix", core.FixedLengthFramer{Size: 4}, []byte{0, 0}},
+		{"fixed bad size", core.FixedLengthFramer{Size: 3}, []byte{0, 0, 1, 'a'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.framer.Split(tt.data); !errors.Is(err, core.ErrMalformedFrame) {
+				t.Errorf("expected ErrMalformedFrame, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRouter_Framer(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithFramer(core.NewlineFramer{}))
+
+	var ids []string
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		var o struct{ ID string }
+		if err := core.Bind(ctx, msg, &o); err != nil {
+			return err
+		}
+		ids = append(ids, o.ID)
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{V: []byte("{\"ID\":\"a\"}\n{\"ID\":\"b\"}\n{\"ID\":\"c\"}\n")}
+	if err := mb.Deliver(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Errorf("handled %v, want [a b c]", ids)
+	}
+	if !msg.Acked {
+		t.Error("original should be acked once every frame is acked")
+	}
+}
+
+func TestRouter_FramerNackAndError(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithFramer(core.VarintFramer{}))
+
+	boom := errors.New("boom")
+	var handled []string
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		handled = append(handled, string(msg.Value()))
+		switch string(msg.Value()) {
+		case "nack":
+			return core.NackResult()
+		case "fail":
+			return boom
+		}
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	nacked := &mock.Message{V: []byte{2, 'o', 'k', 4, 'n', 'a', 'c', 'k'}}
+	if err := mb.Deliver(context.Background(), "orders", nacked); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if !nacked.Nacked || nacked.Acked {
+		t.Error("original should be nacked when a frame is nacked")
+	}
+
+	handled = nil
+	failed := &mock.Message{V: []byte{4, 'f', 'a', 'i', 'l', 2, 'o', 'k'}}
+	if err := mb.Deliver(context.Background(), "orders", failed); !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if len(handled) != 1 || failed.Acked || failed.Nacked {
+		t.Errorf("a failing frame should stop processing and leave the original unsettled (handled %v)", handled)
+	}
+
+	malformed := &mock.Message{V: []byte{9, 'x'}}
+	if err := mb.Deliver(context.Background(), "orders", malformed); !errors.Is(err, core.ErrMalformedFrame) {
+		t.Errorf("expected ErrMalformedFrame, got %v", err)
+	}
+}
+
+func TestRouter_FramerRedeliveryCounting(t *testing.T) {
+	for attempt := 1; attempt <= 2; attempt++ {
+		mb := mock.NewBroker()
+		r := core.New(mb, core.WithFramer(core.NewlineFramer{}), core.WithMaxRedeliveries(1, "dead"))
+		r.Handle("orders", func(_ context.Context, msg core.Message) error {
+			if string(msg.Value()) == "bad" {
+				return core.NackResult()
+			}
+			return core.AckResult()
+		})
+
+		msg := &countedMessage{Message: mock.Message{V: []byte("ok\nbad\n"), T: "orders"}, attempt: attempt}
+		if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+		if n := len(mb.PublishedTo("orders")); n != 0 {
+			t.Errorf("attempt %d: republished %d frames; the broker counts deliveries", attempt, n)
+		}
+		dead := mb.PublishedTo("dead")
+		switch attempt {
+		case 1:
+			if !msg.Nacked || len(dead) != 0 {
+				t.Errorf("attempt 1: nacked=%v, dead-lettered %d, want the original nacked for redelivery", msg.Nacked, len(dead))
+			}
+		case 2:
+			if !msg.Acked || len(dead) != 1 || string(dead[0].Value) != "bad" {
+				t.Errorf("attempt 2: acked=%v, dead-lettered %v, want the failing frame dead-lettered", msg.Acked, dead)
+			}
+		}
+	}
+}
+
+// delayedMessage records the delay it was nacked with.
+type delayedMessage struct {
+	mock.Message
+	delay time.Duration
+}
+
+func (m *delayedMessage) NackWithDelay(d time.Duration) error {
+	m.delay = d
+	return m.Nack()
+}
+
+func TestRouter_FramerNackBackoff(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithFramer(core.NewlineFramer{}),
+		core.WithNackBackoff(func(int) time.Duration { return time.Second }))
+	r.Handle("orders", func(context.Context, core.Message) error { return core.NackResult() })
+
+	msg := &delayedMessage{Message: mock.Message{V: []byte("a\nb\n"), T: "orders"}}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Nacked || msg.delay != time.Second {
+		t.Errorf("nacked=%v delay=%v, want the original nacked after 1s", msg.Nacked, msg.delay)
+	}
+}
+
+func TestRouter_FramerForwardsPosition(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithFramer(core.NewlineFramer{}))
+	var got []string
+	r.Handle("orders", func(_ context.Context, msg core.Message) error {
+		or, ok := msg.(core.OffsetReader)
+		fi, _ := msg.(core.FrameIndexer)
+		if !ok || fi == nil {
+			t.Fatalf("frame %q hides its position or index", msg.Value())
+		}
+		i, _ := fi.FrameIndex()
+		got = append(got, fmt.Sprintf("%d/%d#%d", or.Partition(), or.Offset(), i))
+		return nil
+	})
+
+	msg := &positionedMessage{Message: mock.Message{V: []byte("a\nb\n"), T: "orders"}, partition: 2, offset: 7}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "2/7#0,2/7#1" {
+		t.Errorf("frames saw %v, want [2/7#0 2/7#1]", got)
+	}
+}
+
+type positionedMessage struct {
+	mock.Message
+	partition int
+	offset    int64
+}
+
+func (m *positionedMessage) Partition() int { return m.partition }
+func (m *positionedMessage) Offset() int64  { return m.offset }
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Flusher is implemented by brokers that buffer work, such as publishes
+// held during a reconnect or pending offset commits. GracefulShutdown calls
+// Flush before closing the broker.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// ShutdownPhase is a step of Router.GracefulShutdown.
+type ShutdownPhase string
+
+const (
+	// PhaseStopFetching cancels every subscription and waits for the
+	// broker's Subscribe calls to return. Messages delivered from then on
+	// are rejected with ErrConsumingStopped.
+	PhaseStopFetching ShutdownPhase = "stop-fetching"
+
+	// PhaseDrain waits for in-flight handlers to finish.
+	PhaseDrain ShutdownPhase = "drain"
+
+	// PhaseFlush publishes the best-effort queue and flushes the broker if
+	// it implements Flusher.
+	PhaseFlush ShutdownPhase = "flush"
+
+	// PhaseClose closes the broker, as Close does.
+	PhaseClose ShutdownPhase = "close"
+)
+
+// ShutdownEvent reports a finished phase of GracefulShutdown.
+type ShutdownEvent struct {
+	Phase    ShutdownPhase
+	Duration time.Duration
+	Err      error // nil, or the phase's failure or timeout
+}
+
+// ShutdownOption configures GracefulShutdown.
+type ShutdownOption func(*shutdownConfig)
+
+type shutdownConfig struct {
+	timeouts map[ShutdownPhase]time.Duration
+	onPhase  func(ShutdownEvent)
+}
+
+// WithPhaseTimeout bounds how long GracefulShutdown waits for phase. By
+// default each phase is bounded only by the context.
+func WithPhaseTimeout(phase ShutdownPhase, d time.Duration) ShutdownOption {
+	return func(c *shutdownConfig) { c.timeouts[phase] = d }
+}
+
+// OnShutdownPhase registers fn to be called as each phase finishes, in
+// addition to the log line GracefulShutdown writes.
+func OnShutdownPhase(fn func(ShutdownEvent)) ShutdownOption {
+	return func(c *shutdownConfig) { c.onPhase = fn }
+}
+
+// GracefulShutdown stops the router in four phases, in order: stop fetching
+// new messages, drain in-flight handlers, flush pending publishes, and close
+// the broker. It combines StopConsuming and Close with a timeout per phase
+// (see WithPhaseTimeout) and logs each phase as it finishes. A phase that
+// fails or times out does not stop the ones after it, so the broker is
+// always closed; GracefulShutdown then returns the phases' errors joined.
+// Start returns nil once fetching has stopped.
+func (r *Router) GracefulShutdown(ctx context.Context, opts ...ShutdownOption) error {
+	cfg := shutdownConfig{timeouts: make(map[ShutdownPhase]time.Duration)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r.mu.RLock()
+	stop, subsDone := r.stopSubs, r.subsDone
+	r.mu.RUnlock()
+	var handlersDone <-chan struct{}
+
+	phases := []struct {
+		phase ShutdownPhase
+		run   func(ctx context.Context) error
+	}{
+		{PhaseStopFetching, func(ctx context.Context) error { return waitDone(ctx, subsDone) }},
+		{PhaseDrain, func(ctx context.Context) error { return waitDone(ctx, handlersDone) }},
+		{PhaseFlush, r.flush},
+		{PhaseClose, func(context.Context) error {
+			if r.broker == nil {
+				return ErrNoBroker
+			}
+			return r.close()
+		}},
+	}
+	if stop != nil {
+		// Both return at once; the phases wait for their effect.
+		stop()
+		handlersDone = r.inflight.close()
+	}
+
+	var errs []error
+	for _, p := range phases {
+		if err := r.runPhase(ctx, p.phase, cfg, p.run); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runPhase runs fn under the phase's timeout and reports the outcome. fn
+// runs on its own goroutine so a phase that ignores its context, such as a
+// hung broker Close, cannot hold up the ones after it.
+func (r *Router) runPhase(ctx context.Context, phase ShutdownPhase, cfg shutdownConfig, fn func(context.Context) error) error {
+	if d := cfg.timeouts[phase]; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	r.spawn(func() { done <- fn(ctx) })
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	ev := ShutdownEvent{Phase: phase, Duration: time.Since(start), Err: err}
+	if err != nil {
+		ev.Err = fmt.Errorf("eventmux: shutdown phase %s: %w", phase, err)
+		log.Printf("[EventMux] shutdown: %s failed after %v: %v", phase, ev.Duration, err)
+	} else {
+		log.Printf("[EventMux] shutdown: %s done in %v", phase, ev.Duration)
+	}
+	if cfg.onPhase != nil {
+		cfg.onPhase(ev)
+	}
+	return ev.Err
+}
+
+// flush publishes the best-effort queue, then flushes the broker.
+func (r *Router) flush(ctx context.Context) error {
+	r.mu.RLock()
+	closed := r.closed
+	r.mu.RUnlock()
+	if closed {
+		return nil
+	}
+	if r.publishQueue != nil {
+		if err := r.publishQueue.flush(ctx); err != nil {
+			return err
+		}
+	}
+	if f, ok := r.broker.(Flusher); ok && !r.borrowed {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// waitDone blocks until done is closed or ctx ends. A nil done is ready.
+func waitDone(ctx context.Context, done <-chan struct{}) error {
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,167 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// eventLog records what happens during a shutdown, in order.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(ev string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, ev)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.events)
+}
+
+// flushBroker is a mock broker that implements core.Flusher and logs
+// publishes, flushes and closes.
+type flushBroker struct {
+	*mock.Broker
+	log      *eventLog
+	hangOnce bool // Close blocks forever if set
+}
+
+func (b *flushBroker) Publish(ctx context.Context, topic string, msg core.Message) error {
+	b.log.add("publish " + string(msg.Value()))
+	return b.Broker.Publish(ctx, topic, msg)
+}
+
+func (b *flushBroker) Flush(context.Context) error {
+	b.log.add("broker flush")
+	return nil
+}
+
+func (b *flushBroker) Close() error {
+	if b.hangOnce {
+		select {}
+	}
+	b.log.add("broker close")
+	return b.Broker.Close()
+}
+
+func TestGracefulShutdown_PhaseOrder(t *testing.T) {
+	log := &eventLog{}
+	b := &flushBroker{Broker: mock.NewBroker(), log: log}
+	r := core.New(b, core.WithPublishMode(core.PublishBestEffort))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		close(started)
+		<-release
+		r.Publish(ctx, "audit", &mock.Message{V: []byte("derived")})
+		log.add("handler done")
+		return core.AckResult()
+	})
+	startErr := make(chan error, 1)
+	go func() { startErr <- r.Start(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	go b.Deliver(context.Background(), "orders", &mock.Message{V: []byte("order")})
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- r.GracefulShutdown(context.Background(), core.OnShutdownPhase(func(ev core.ShutdownEvent) {
+			log.add("phase " + string(ev.Phase))
+		}))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("GracefulShutdown: %v", err)
+	}
+	if err := <-startErr; err != nil {
+		t.Errorf("Start = %v, want nil", err)
+	}
+	got := log.get()
+	// The queued publish may go out any time after the handler, but must
+	// be flushed before the broker is.
+	pub := slices.Index(got, "publish derived")
+	if pub < 0 {
+		t.Fatalf("events %q: the derived publish was never sent", got)
+	}
+	if pub < slices.Index(got, "handler done") || pub > slices.Index(got, "broker flush") {
+		t.Errorf("events %q: the derived publish must come between the handler and the broker flush", got)
+	}
+	got = slices.Delete(got, pub, pub+1)
+	want := []string{
+		"phase stop-fetching",
+		"handler done",
+		"phase drain",
+		"broker flush",
+		"phase flush",
+		"broker close",
+		"phase close",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("events:\n got %q\nwant %q", got, want)
+	}
+	if err := b.Deliver(context.Background(), "orders", &mock.Message{}); err == nil {
+		t.Error("delivery after shutdown should be rejected")
+	}
+}
+
+func TestGracefulShutdown_PhaseTimeouts(t *testing.T) {
+	log := &eventLog{}
+	b := &flushBroker{Broker: mock.NewBroker(), log: log, hangOnce: true}
+	r := core.New(b)
+
+	started := make(chan struct{})
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		close(started)
+		select {} // stuck handler
+	})
+	go r.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	go b.Deliver(context.Background(), "orders", &mock.Message{})
+	<-started
+
+	var phases []core.ShutdownEvent
+	err := r.GracefulShutdown(context.Background(),
+		core.WithPhaseTimeout(core.PhaseDrain, 20*time.Millisecond),
+		core.WithPhaseTimeout(core.PhaseClose, 20*time.Millisecond),
+		core.OnShutdownPhase(func(ev core.ShutdownEvent) { phases = append(phases, ev) }),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GracefulShutdown = %v, want DeadlineExceeded", err)
+	}
+	if len(phases) != 4 {
+		t.Fatalf("ran %d phases, want all 4 despite timeouts", len(phases))
+	}
+	for i, wantErr := range []bool{false, true, false, true} {
+		if (phases[i].Err != nil) != wantErr {
+			t.Errorf("phase %s: err = %v, want failure %v", phases[i].Phase, phases[i].Err, wantErr)
+		}
+	}
+	if got := log.get(); !slices.Equal(got, []string{"broker flush"}) {
+		t.Errorf("events = %q, want only the flush", got)
+	}
+}
+
+func TestGracefulShutdown_NotStarted(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	if err := r.GracefulShutdown(context.Background()); err != nil {
+		t.Fatalf("GracefulShutdown: %v", err)
+	}
+	if !mb.IsClosed() {
+		t.Error("broker should be closed")
+	}
+}
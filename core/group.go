@@ -0,0 +1,46 @@
+package core
+
+import "context"
+
+// GroupReader is implemented by brokers whose subscriptions consume as a
+// named consumer group, such as a Kafka group ID or a JetStream durable.
+type GroupReader interface {
+	Group() string
+}
+
+// WithGroup makes the route consume as group instead of the broker's
+// consumer group, e.g. to give a slow route its own offsets. It sets the
+// route's SubscribeOptions.Group; brokers without consumer groups ignore it.
+func WithGroup(group string) RouteOption {
+	return func(c *routeConfig) { c.subscribe.Group = group }
+}
+
+type groupKey struct{}
+
+// Group returns the consumer group of the subscription that delivered the
+// message being handled: the route's WithGroup override, or else the
+// broker's group if it implements GroupReader. It returns "" for messages
+// passed to Dispatch and for brokers without consumer groups.
+func Group(ctx context.Context) string {
+	g, _ := ctx.Value(groupKey{}).(string)
+	return g
+}
+
+// routeGroup returns the effective consumer group for a route subscribed
+// with so.
+func (r *Router) routeGroup(so SubscribeOptions) string {
+	if so.Group != "" {
+		return so.Group
+	}
+	if gr, ok := r.broker.(GroupReader); ok {
+		return gr.Group()
+	}
+	return ""
+}
+
+// withGroup wraps h to make group available via Group.
+func withGroup(group string, h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		return h(context.WithValue(ctx, groupKey{}, group), msg)
+	}
+}
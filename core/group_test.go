@@ -0,0 +1,133 @@
+package core_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// groupBroker is a mock broker that consumes as a consumer group and
+// records the group each topic was subscribed with.
+type groupBroker struct {
+	*mock.Broker
+	group string
+
+	mu         sync.Mutex
+	subscribed map[string]string
+}
+
+func newGroupBroker(group string) *groupBroker {
+	return &groupBroker{Broker: mock.NewBroker(), group: group, subscribed: make(map[string]string)}
+}
+
+func (b *groupBroker) Group() string { return b.group }
+
+func (b *groupBroker) Subscribe(ctx context.Context, topic string, h core.Handler) error {
+	so, _ := core.SubscribeOptionsFrom(ctx)
+	b.mu.Lock()
+	b.subscribed[topic] = so.Group
+	b.mu.Unlock()
+	return b.Broker.Subscribe(ctx, topic, h)
+}
+
+func (b *groupBroker) SubscribedGroup(topic string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribed[topic]
+}
+
+func TestGroup_BrokerDefaultAndRouteOverride(t *testing.T) {
+	b := newGroupBroker("billing")
+	r := core.New(b)
+
+	var mu sync.Mutex
+	got := make(map[string]string)
+	record := func(ctx context.Context, msg core.Message) error {
+		mu.Lock()
+		got[core.Topic(msg)] = core.Group(ctx)
+		mu.Unlock()
+		return nil
+	}
+	r.Handle("orders", record)
+	r.Handle("reports", record, core.WithGroup("billing-slow"))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	for _, topic := range []string{"orders", "reports"} {
+		if err := b.Deliver(context.Background(), topic, &mock.Message{T: topic}); err != nil {
+			t.Fatalf("deliver %s: %v", topic, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got["orders"] != "billing" {
+		t.Errorf("orders: Group = %q, want the broker group %q", got["orders"], "billing")
+	}
+	if got["reports"] != "billing-slow" {
+		t.Errorf("reports: Group = %q, want the route override %q", got["reports"], "billing-slow")
+	}
+	if g := b.SubscribedGroup("reports"); g != "billing-slow" {
+		t.Errorf("reports subscribed with SubscribeOptions.Group %q, want %q", g, "billing-slow")
+	}
+	if g := b.SubscribedGroup("orders"); g != "" {
+		t.Errorf("orders subscribed with SubscribeOptions.Group %q, want none", g)
+	}
+}
+
+func TestGroup_VisibleToRawMiddleware(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b)
+
+	var seen string
+	r.UseRaw(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			seen = core.Group(ctx)
+			return next(ctx, msg)
+		}
+	})
+	r.Handle("audit", func(context.Context, core.Message) error { return nil }, core.WithGroup("auditors"))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	if err := b.Deliver(context.Background(), "audit", &mock.Message{T: "audit"}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if seen != "auditors" {
+		t.Errorf("raw middleware saw Group %q, want %q", seen, "auditors")
+	}
+}
+
+func TestGroup_EmptyWithoutConsumerGroup(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b)
+
+	got := "unset"
+	r.Handle("orders", func(ctx context.Context, _ core.Message) error {
+		got = core.Group(ctx)
+		return nil
+	})
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	if err := b.Deliver(context.Background(), "orders", &mock.Message{T: "orders"}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if got != "" {
+		t.Errorf("Group = %q for a broker without consumer groups, want empty", got)
+	}
+
+	got = "unset"
+	if err := r.Dispatch(context.Background(), "orders", &mock.Message{T: "orders"}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if got != "" {
+		t.Errorf("Group = %q for Dispatch, want empty", got)
+	}
+}
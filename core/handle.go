@@ -0,0 +1,48 @@
+package core
+
+import "context"
+
+// Handle controls a Router started with StartAsync.
+type Handle struct {
+	done chan struct{}
+	err  error
+	stop context.CancelFunc
+}
+
+// StartAsync starts the Router like Start but returns as soon as the
+// subscriptions have been launched. Errors that prevent starting, such as
+// ErrNoBroker or ErrAlreadyStarted, are returned directly; the error that
+// ends a running Router is reported by the Handle.
+func (r *Router) StartAsync(ctx context.Context) (*Handle, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	wait, err := r.begin(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	h := &Handle{done: make(chan struct{}), stop: cancel}
+	r.spawn(func() {
+		defer cancel()
+		h.err = wait()
+		close(h.done)
+	})
+	return h, nil
+}
+
+// Done returns a channel that is closed once the Router has stopped.
+func (h *Handle) Done() <-chan struct{} { return h.done }
+
+// Err returns the error the Router stopped with, as Start would have
+// returned it. It is nil while the Router is running.
+func (h *Handle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return nil
+	}
+}
+
+// Stop shuts the Router down as if the context passed to StartAsync had
+// been cancelled. It does not wait; use Done for that.
+func (h *Handle) Stop() { h.stop() }
@@ -0,0 +1,71 @@
+package core
+
+import "hash/fnv"
+
+// Hasher maps a message key to a stable 32-bit hash, e.g. to pick a worker
+// or partition for it. Implementations must return the same hash for the
+// same key in every process and run.
+type Hasher interface {
+	Hash(key []byte) uint32
+}
+
+// FNV1a hashes keys with 32-bit FNV-1a.
+type FNV1a struct{}
+
+// Hash implements Hasher.
+func (FNV1a) Hash(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
+}
+
+// Murmur2 hashes keys with the murmur2 variant used by Kafka's default
+// partitioner, so keys map to the same partition as in Java clients and
+// librdkafka's "murmur2_random" partitioner.
+type Murmur2 struct{}
+
+// Hash implements Hasher. Converted to int32, the result equals Kafka's
+// Utils.murmur2.
+func (Murmur2) Hash(key []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	n := len(key)
+	h := uint32(seed) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := uint32(key[i]) | uint32(key[i+1])<<8 | uint32(key[i+2])<<16 | uint32(key[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := key[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// Partition returns the partition, or worker, in [0, n) for key, computed as
+// Kafka's default partitioner does: the hash with its sign bit cleared,
+// modulo n. It returns 0 if n is not positive.
+func Partition(h Hasher, key []byte, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return int(h.Hash(key)&0x7fffffff) % n
+}
@@ -0,0 +1,45 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+func TestMurmur2_MatchesKafka(t *testing.T) {
+	// Expected values from Kafka's UtilsTest.testMurmur2.
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, want := range cases {
+		if got := int32(core.Murmur2{}.Hash([]byte(key))); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	// toPositive(murmur2(key)) % 12, from the hashes above.
+	for key, want := range map[string]int{"foobar": 6, "abc": 3, "21": 0} {
+		if got := core.Partition(core.Murmur2{}, []byte(key), 12); got != want {
+			t.Errorf("partition(%q) = %d, want %d", key, got, want)
+		}
+	}
+	if got := core.Partition(core.Murmur2{}, []byte("x"), 0); got != 0 {
+		t.Errorf("partition with n=0 = %d, want 0", got)
+	}
+}
+
+func TestFNV1a(t *testing.T) {
+	// Reference vectors for 32-bit FNV-1a.
+	for key, want := range map[string]uint32{"": 0x811c9dc5, "a": 0xe40c292c, "foobar": 0xbf9cf968} {
+		if got := (core.FNV1a{}).Hash([]byte(key)); got != want {
+			t.Errorf("fnv1a(%q) = %#x, want %#x", key, got, want)
+		}
+	}
+}
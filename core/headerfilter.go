@@ -0,0 +1,74 @@
+package core
+
+import "time"
+
+// HeaderFilter reports whether a header should be kept. Filters configured
+// with WithIngressHeaderFilter and WithEgressHeaderFilter hide or strip
+// every header for which it returns false.
+type HeaderFilter func(key string) bool
+
+// AllowHeaders returns a HeaderFilter that keeps only the given keys.
+func AllowHeaders(keys ...string) HeaderFilter {
+	set := headerSet(keys)
+	return func(key string) bool {
+		_, ok := set[key]
+		return ok
+	}
+}
+
+// DenyHeaders returns a HeaderFilter that drops the given keys and keeps
+// everything else.
+func DenyHeaders(keys ...string) HeaderFilter {
+	set := headerSet(keys)
+	return func(key string) bool {
+		_, ok := set[key]
+		return !ok
+	}
+}
+
+func headerSet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+// filteredMessage exposes only the headers that passed a HeaderFilter.
+// Topic, Size, Attempt, delivery counting, delayed nacks and the frame
+// index are forwarded
+// so route params and retry counting keep working behind the filter, and
+// filterHeaders adds the original's log position.
+type filteredMessage struct {
+	headerMessage
+}
+
+func (m *filteredMessage) Topic() string { return Topic(m.Message) }
+
+func (m *filteredMessage) Size() int { return Size(m.Message) }
+
+func (m *filteredMessage) Attempt() int { return Attempt(m.Message) }
+
+func (m *filteredMessage) CountsDeliveries() bool { return countsDeliveries(m.Message) }
+
+func (m *filteredMessage) NackWithDelay(d time.Duration) error {
+	return NackWithDelay(m.Message, d)
+}
+
+func (m *filteredMessage) FrameIndex() (int, bool) { return frameIndex(m.Message) }
+
+// filterHeaders returns msg with only the headers accepted by keep. A nil
+// filter returns msg unchanged. Ack and Nack still settle the original.
+func filterHeaders(msg Message, keep HeaderFilter) Message {
+	if keep == nil {
+		return msg
+	}
+	base := msg.Headers()
+	h := make(map[string]string, len(base))
+	for k, v := range base {
+		if keep(k) {
+			h[k] = v
+		}
+	}
+	return withPosition(&filteredMessage{headerMessage{Message: msg, headers: h}}, msg)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RouteOption configures a single route registered with Handle.
+type RouteOption func(*routeConfig)
+
+type routeConfig struct {
+	idleTimeout time.Duration
+	stopOnIdle  bool
+	limit       int
+	priority    int
+	subscribe   SubscribeOptions
+
+	requiredHeaders []string
+}
+
+// WithIdleTimeout stops the route's subscription once no message has been
+// delivered to it for d, e.g. in a scheduled job that drains a topic and
+// exits. The window starts when the subscription starts and restarts when
+// each message finishes, so a slow handler is never counted as idle. Other
+// routes keep consuming unless StopRouterOnIdle is also given.
+func WithIdleTimeout(d time.Duration) RouteOption {
+	return func(c *routeConfig) { c.idleTimeout = d }
+}
+
+// StopRouterOnIdle makes a route's WithIdleTimeout stop every subscription,
+// as StopConsuming does, so Start returns nil. The broker stays open for
+// publishing until Close.
+func StopRouterOnIdle() RouteOption {
+	return func(c *routeConfig) { c.stopOnIdle = true }
+}
+
+// idleTimer calls onIdle once no message has been in flight for d.
+type idleTimer struct {
+	d      time.Duration
+	onIdle func()
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	active int
+}
+
+// start arms the timer. It must be called before the wrapped handler runs.
+func (t *idleTimer) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timer = time.AfterFunc(t.d, t.onIdle)
+}
+
+// stop disarms the timer.
+func (t *idleTimer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timer.Stop()
+}
+
+// wrap pauses the timer while h runs and restarts it once no message is
+// in flight.
+func (t *idleTimer) wrap(h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		t.mu.Lock()
+		t.active++
+		t.timer.Stop()
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			t.active--
+			if t.active == 0 {
+				t.timer.Reset(t.d)
+			}
+			t.mu.Unlock()
+		}()
+		return h(ctx, msg)
+	}
+}
@@ -0,0 +1,109 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestRouter_IdleTimeoutStopsSubscription(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	noop := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Handle("jobs", noop, core.WithIdleTimeout(50*time.Millisecond))
+	r.Handle("orders", noop)
+
+	h, err := r.StartAsync(context.Background())
+	if err != nil {
+		t.Fatalf("StartAsync: %v", err)
+	}
+	defer h.Stop()
+
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+	time.Sleep(100 * time.Millisecond)
+	if got := r.Stats().Subscriptions; got != 1 {
+		t.Errorf("subscriptions = %d, want the route without a timeout to keep running", got)
+	}
+	select {
+	case <-h.Done():
+		t.Error("router stopped, want only the idle subscription stopped")
+	default:
+	}
+}
+
+func TestRouter_IdleTimeoutResetsOnDelivery(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	r.Handle("jobs", func(ctx context.Context, msg core.Message) error { return nil },
+		core.WithIdleTimeout(150*time.Millisecond))
+
+	h, err := r.StartAsync(context.Background())
+	if err != nil {
+		t.Fatalf("StartAsync: %v", err)
+	}
+	defer h.Stop()
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+
+	// Keep delivering for twice the idle window.
+	for i := 0; i < 6; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if err := mb.Deliver(context.Background(), "jobs", &mock.Message{}); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+	}
+	if got := r.Stats().Subscriptions; got != 1 {
+		t.Fatalf("subscription stopped while messages were arriving")
+	}
+
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 0 })
+}
+
+func TestRouter_IdleTimeoutPausesWhileHandling(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	r.Handle("jobs", func(ctx context.Context, msg core.Message) error {
+		time.Sleep(150 * time.Millisecond)
+		return nil
+	}, core.WithIdleTimeout(50*time.Millisecond))
+
+	h, err := r.StartAsync(context.Background())
+	if err != nil {
+		t.Fatalf("StartAsync: %v", err)
+	}
+	defer h.Stop()
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+
+	mb.Deliver(context.Background(), "jobs", &mock.Message{})
+	if got := r.Stats().Subscriptions; got != 1 {
+		t.Error("a slow handler must not count as idle")
+	}
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 0 })
+}
+
+func TestRouter_StopRouterOnIdle(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	noop := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Handle("jobs", noop, core.WithIdleTimeout(50*time.Millisecond), core.StopRouterOnIdle())
+	r.Handle("orders", noop)
+
+	done := make(chan error, 1)
+	go func() { done <- r.Start(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after the idle timeout")
+	}
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 0 })
+	if mb.IsClosed() {
+		t.Error("broker should stay open until Close")
+	}
+	r.Close()
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// inflight tracks handlers running on behalf of subscriptions so consuming
+// can be drained. Once closed, enter refuses new handlers, which makes
+// waiting on the group safe while brokers may still be delivering.
+type inflight struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// enter registers a handler, reporting false once the gate is closed.
+func (g *inflight) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *inflight) exit() { g.wg.Done() }
+
+// close refuses new handlers and returns a channel closed once the running
+// ones have finished.
+func (g *inflight) close() <-chan struct{} {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// track wraps h so it is counted by g. Messages arriving after g is closed
+// are rejected with ErrConsumingStopped, leaving redelivery to the broker.
+func (g *inflight) track(h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		if !g.enter() {
+			return ErrConsumingStopped
+		}
+		defer g.exit()
+		return h(ctx, msg)
+	}
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// messageLimit admits at most n messages and calls onLimit once all of them
+// have been handled.
+type messageLimit struct {
+	n        int64
+	onLimit  func()
+	admitted atomic.Int64
+	handled  atomic.Int64
+}
+
+// wrap rejects messages beyond the limit with ErrConsumingStopped, leaving
+// them unsettled for redelivery.
+func (l *messageLimit) wrap(h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		if l.admitted.Add(1) > l.n {
+			return ErrConsumingStopped
+		}
+		defer func() {
+			if l.handled.Add(1) == l.n {
+				l.onLimit()
+			}
+		}()
+		return h(ctx, msg)
+	}
+}
@@ -0,0 +1,91 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestRouter_HandleN(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	var handled []string
+	r.HandleN("orders", 10, func(ctx context.Context, msg core.Message) error {
+		handled = append(handled, string(msg.Key()))
+		return msg.Ack()
+	})
+	r.Handle("audit", func(ctx context.Context, msg core.Message) error { return nil })
+
+	done := make(chan error, 1)
+	go func() { done <- r.Start(context.Background()) }()
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 2 })
+
+	var rejected []*mock.Message
+	for i := 0; i < 20; i++ {
+		msg := &mock.Message{K: []byte(fmt.Sprint(i))}
+		err := mb.Deliver(context.Background(), "orders", msg)
+		switch {
+		case i < 10 && err != nil:
+			t.Fatalf("message %d: %v", i, err)
+		case i >= 10:
+			if !errors.Is(err, core.ErrConsumingStopped) {
+				t.Errorf("message %d: err = %v, want ErrConsumingStopped", i, err)
+			}
+			rejected = append(rejected, msg)
+		}
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after n messages")
+	}
+	if len(handled) != 10 || handled[0] != "0" || handled[9] != "9" {
+		t.Errorf("handled = %v, want messages 0-9", handled)
+	}
+	for _, msg := range rejected {
+		if msg.Acked || msg.Nacked {
+			t.Errorf("message %s beyond the limit should be left unsettled", msg.K)
+		}
+	}
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 0 })
+	if mb.IsClosed() {
+		t.Error("broker should stay open until Close")
+	}
+	r.Close()
+}
+
+func TestRouter_HandleN_Unlimited(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	count := 0
+	r.HandleN("orders", 0, func(ctx context.Context, msg core.Message) error {
+		count++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Start(ctx) }()
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+
+	for i := 0; i < 5; i++ {
+		if err := mb.Deliver(context.Background(), "orders", &mock.Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count != 5 {
+		t.Errorf("handled %d, want 5", count)
+	}
+	cancel()
+	<-done
+}
@@ -7,8 +7,17 @@ type TopicMatcher interface {
 	Match(pattern string, topic string) bool
 }
 
+// CapturingMatcher is a TopicMatcher that also returns the segments captured
+// by ":name" tokens. When the Router's matcher implements it, captured params
+// are made available to handlers via Param.
+type CapturingMatcher interface {
+	TopicMatcher
+	MatchParams(pattern string, topic string) (Params, bool)
+}
+
 // DefaultMatcher supports exact matching, single-level wildcard (*),
-// and multi-level wildcard (#).
+// and multi-level wildcard (#). A ":name" segment matches one level like
+// "*" and additionally captures it as a route param (see Param).
 //
 // Examples:
 //
@@ -17,71 +26,60 @@ type TopicMatcher interface {
 //	"orders.*"       does NOT match "orders.us.created"
 //	"payments.#"     matches "payments.us.created"  (multi-level)
 //	"payments.#"     matches "payments.created"
+//	"tenant.:id.#"   matches "tenant.acme.orders"   (captures id=acme)
 type DefaultMatcher struct{}
 
 func (DefaultMatcher) Match(pattern, topic string) bool {
-	patParts := strings.Split(pattern, ".")
-	topParts := strings.Split(topic, ".")
+	return matchFrom(strings.Split(pattern, "."), 0, strings.Split(topic, "."), 0, nil)
+}
 
-	pi, ti := 0, 0
-	for pi < len(patParts) && ti < len(topParts) {
-		switch patParts[pi] {
-		case "#":
-			// # at the end matches all remaining levels
-			if pi == len(patParts)-1 {
-				return true
-			}
-			// # in the middle: try all remaining positions
-			pi++
-			for ti <= len(topParts) {
-				if matchFrom(patParts, pi, topParts, ti) {
-					return true
-				}
-				ti++
-			}
-			return false
-		case "*":
-			// matches exactly one level — just advance both
-			pi++
-			ti++
-		default:
-			if patParts[pi] != topParts[ti] {
-				return false
-			}
-			pi++
-			ti++
-		}
+// MatchParams implements CapturingMatcher. Captures may appear on either
+// side of "*" and "#" wildcards.
+func (DefaultMatcher) MatchParams(pattern, topic string) (Params, bool) {
+	params := make(Params)
+	if !matchFrom(strings.Split(pattern, "."), 0, strings.Split(topic, "."), 0, params) {
+		return nil, false
 	}
-
-	// Both must be fully consumed
-	return pi == len(patParts) && ti == len(topParts)
+	return params, true
 }
 
-func matchFrom(pat []string, pi int, top []string, ti int) bool {
+// matchFrom matches pat[pi:] against top[ti:]. When params is non-nil,
+// ":name" segments are recorded in it; a failed "#" branch may leave stale
+// entries, but every capture is rewritten on the branch that succeeds.
+func matchFrom(pat []string, pi int, top []string, ti int, params Params) bool {
 	for pi < len(pat) && ti < len(top) {
 		switch pat[pi] {
 		case "#":
+			// # at the end matches all remaining levels
 			if pi == len(pat)-1 {
 				return true
 			}
+			// # in the middle: try all remaining positions
 			pi++
 			for ti <= len(top) {
-				if matchFrom(pat, pi, top, ti) {
+				if matchFrom(pat, pi, top, ti, params) {
 					return true
 				}
 				ti++
 			}
 			return false
 		case "*":
+			// matches exactly one level — just advance both
 			pi++
 			ti++
 		default:
-			if pat[pi] != top[ti] {
+			if isCapture(pat[pi]) {
+				if params != nil {
+					params[pat[pi][1:]] = top[ti]
+				}
+			} else if pat[pi] != top[ti] {
 				return false
 			}
 			pi++
 			ti++
 		}
 	}
+
+	// Both must be fully consumed
 	return pi == len(pat) && ti == len(top)
 }
@@ -33,6 +33,12 @@ func TestDefaultMatcher(t *testing.T) {
 		{"orders.*.#", "orders.us.created", true},
 		{"orders.*.#", "orders.us.east.created", true},
 
+		// Named captures
+		{"tenant.:id.orders.created", "tenant.acme.orders.created", true},
+		{"tenant.:id.orders.created", "tenant.acme.orders.updated", false},
+		{"tenant.:id.orders.created", "tenant.orders.created", false},
+		{"tenant.:id.#", "tenant.acme.orders.created", true},
+
 		// Edge cases
 		{"orders.created", "orders", false},
 		{"orders", "orders.created", false},
@@ -48,3 +54,47 @@ func TestDefaultMatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultMatcher_MatchParams(t *testing.T) {
+	m := DefaultMatcher{}
+
+	tests := []struct {
+		pattern string
+		topic   string
+		want    Params
+		ok      bool
+	}{
+		// Single capture
+		{"orders.:region.created", "orders.eu.created", Params{"region": "eu"}, true},
+		{"orders.:region.created", "orders.eu.updated", nil, false},
+
+		// Multiple captures
+		{"tenant.:id.:entity.created", "tenant.acme.orders.created", Params{"id": "acme", "entity": "orders"}, true},
+
+		// Interaction with wildcards
+		{"orders.*.:event", "orders.eu.created", Params{"event": "created"}, true},
+		{"tenant.:id.#", "tenant.acme.orders.created", Params{"id": "acme"}, true},
+		{"#.:event", "orders.eu.created", Params{"event": "created"}, true},
+		{"tenant.:id.#.:event", "tenant.acme.orders.eu.created", Params{"id": "acme", "event": "created"}, true},
+
+		// No captures
+		{"orders.*", "orders.created", Params{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"→"+tt.topic, func(t *testing.T) {
+			got, ok := m.MatchParams(tt.pattern, tt.topic)
+			if ok != tt.ok {
+				t.Fatalf("MatchParams(%q, %q) ok = %v, want %v", tt.pattern, tt.topic, ok, tt.ok)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("MatchParams(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("param %q = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
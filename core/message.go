@@ -17,3 +17,85 @@ type Handler func(ctx context.Context, msg Message) error
 
 // Middleware wraps a Handler to add cross-cutting behavior.
 type Middleware func(Handler) Handler
+
+// PublishInterceptor transforms a message before the Router publishes it to
+// topic, typically to stamp outbound headers. It must not return nil.
+type PublishInterceptor func(topic string, msg Message) Message
+
+// HeaderReader is implemented by messages that can look up a single header
+// without building the full Headers map. Broker plugins implement it as an
+// optimization; callers should use Header rather than asserting on it.
+type HeaderReader interface {
+	Header(key string) (string, bool)
+}
+
+// Header returns the value of the header key on msg, or "" if it is absent.
+func Header(msg Message, key string) string {
+	if hr, ok := msg.(HeaderReader); ok {
+		v, _ := hr.Header(key)
+		return v
+	}
+	return msg.Headers()[key]
+}
+
+// Sizer is implemented by messages that can report their payload size
+// without materializing the payload.
+type Sizer interface {
+	Size() int
+}
+
+// Size returns the payload size of msg in bytes. Nil messages and
+// tombstones (nil values) have size 0.
+func Size(msg Message) int {
+	if msg == nil {
+		return 0
+	}
+	if s, ok := msg.(Sizer); ok {
+		return s.Size()
+	}
+	return len(msg.Value())
+}
+
+// TopicReader is implemented by messages that know the concrete topic they
+// were received on. The Router needs it to capture ":name" route params.
+type TopicReader interface {
+	Topic() string
+}
+
+// Topic returns the topic msg was received on, or "" if unknown.
+func Topic(msg Message) string {
+	if tr, ok := msg.(TopicReader); ok {
+		return tr.Topic()
+	}
+	return ""
+}
+
+// HeaderDeadLetterReason carries the reason a message was dead-lettered.
+const HeaderDeadLetterReason = "x-eventmux-dlq-reason"
+
+// headerMessage overlays headers on a Message without mutating it.
+type headerMessage struct {
+	Message
+	headers map[string]string
+}
+
+func (m *headerMessage) Headers() map[string]string { return m.headers }
+
+func (m *headerMessage) Header(key string) (string, bool) {
+	v, ok := m.headers[key]
+	return v, ok
+}
+
+// MergeHeaders returns msg with extra merged over its own headers. The
+// original message is not modified; Ack and Nack still settle it.
+func MergeHeaders(msg Message, extra map[string]string) Message {
+	base := msg.Headers()
+	h := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		h[k] = v
+	}
+	for k, v := range extra {
+		h[k] = v
+	}
+	return &headerMessage{Message: msg, headers: h}
+}
@@ -0,0 +1,55 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestHeader(t *testing.T) {
+	msg := &mock.Message{H: map[string]string{"trace-id": "abc"}}
+
+	if got := core.Header(msg, "trace-id"); got != "abc" {
+		t.Errorf("Header(trace-id) = %q, want %q", got, "abc")
+	}
+	if got := core.Header(msg, "missing"); got != "" {
+		t.Errorf("Header(missing) = %q, want empty", got)
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  core.Message
+		want int
+	}{
+		{"nil message", nil, 0},
+		{"tombstone", &mock.Message{K: []byte("k")}, 0},
+		{"empty", &mock.Message{V: []byte{}}, 0},
+		{"payload", &mock.Message{V: []byte("hello")}, 5},
+	}
+	for _, tt := range tests {
+		if got := core.Size(tt.msg); got != tt.want {
+			t.Errorf("%s: Size = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	tests := []struct {
+		name string
+		h    map[string]string
+		want int
+	}{
+		{"no header", nil, 1},
+		{"header", map[string]string{core.HeaderAttempt: "3"}, 3},
+		{"invalid header", map[string]string{core.HeaderAttempt: "x"}, 1},
+		{"zero header", map[string]string{core.HeaderAttempt: "0"}, 1},
+	}
+	for _, tt := range tests {
+		if got := core.Attempt(&mock.Message{H: tt.h}); got != tt.want {
+			t.Errorf("%s: Attempt = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Debounce returns middleware that coalesces messages sharing a key: the
+// first message for a key opens a window, and when it closes only the
+// latest message received in it is handled. Superseded messages are acked
+// as soon as a newer one arrives. keyFn derives the key; nil uses the
+// message key.
+//
+// Messages are held rather than handled inline, so the middleware returns
+// nil at once and the broker moves on. The held message is handled on a
+// timer goroutine and settled with core.Settle; failures are logged. If
+// the context ends before the window closes, the message is left
+// unsettled for redelivery. Held messages are not waited for by
+// Router.StopConsuming.
+//
+// Because a held message is settled after later ones, use Debounce with
+// brokers that settle messages individually (NATS, RabbitMQ) or with
+// kafka.WithKeyedWorkers, whose commits never pass an unsettled offset.
+func Debounce(window time.Duration, keyFn func(core.Message) string) core.Middleware {
+	if keyFn == nil {
+		keyFn = func(msg core.Message) string { return string(msg.Key()) }
+	}
+	return func(next core.Handler) core.Handler {
+		d := &debouncer{window: window, keyFn: keyFn, next: next, pending: make(map[string]*heldMessage)}
+		return d.hold
+	}
+}
+
+type debouncer struct {
+	window time.Duration
+	keyFn  func(core.Message) string
+	next   core.Handler
+
+	mu      sync.Mutex
+	pending map[string]*heldMessage
+}
+
+// heldMessage is the latest message for a key in an open window.
+type heldMessage struct {
+	ctx context.Context
+	msg core.Message
+}
+
+func (d *debouncer) hold(ctx context.Context, msg core.Message) error {
+	key := d.keyFn(msg)
+	d.mu.Lock()
+	h, open := d.pending[key]
+	var superseded core.Message
+	if open {
+		superseded = h.msg
+		h.ctx, h.msg = ctx, msg
+	} else {
+		d.pending[key] = &heldMessage{ctx: ctx, msg: msg}
+		time.AfterFunc(d.window, func() { d.flush(key) })
+	}
+	d.mu.Unlock()
+
+	if superseded != nil {
+		if err := superseded.Ack(); err != nil {
+			log.Printf("[EventMux] debounce: ack superseded message key=%s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// flush handles the latest message for key once its window has closed.
+func (d *debouncer) flush(key string) {
+	d.mu.Lock()
+	h := d.pending[key]
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	if h.ctx.Err() != nil {
+		return // shutting down: leave it for redelivery
+	}
+	if err := core.Settle(h.ctx, h.msg, d.next(h.ctx, h.msg)); err != nil {
+		log.Printf("[EventMux] debounce: key=%s: %v", key, err)
+	}
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// HeaderContentEncoding names the compression the producing application
+// applied to a message's payload, as in HTTP.
+const HeaderContentEncoding = "content-encoding"
+
+// DefaultMaxDecompressedSize is the largest payload Decompress inflates
+// unless WithMaxDecompressedSize says otherwise.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// DecompressOption configures Decompress.
+type DecompressOption func(*decompressConfig)
+
+type decompressConfig struct {
+	maxSize int64
+}
+
+// WithMaxDecompressedSize sets the largest payload, in bytes, Decompress
+// inflates. A payload that would decode to more is resolved with
+// core.DLQResult, so a few KB of crafted gzip cannot exhaust memory. The
+// default is DefaultMaxDecompressedSize.
+func WithMaxDecompressedSize(n int64) DecompressOption {
+	return func(c *decompressConfig) { c.maxSize = n }
+}
+
+// Decompress returns middleware that decodes application-compressed
+// payloads before the handler sees them.
+//
+// Payloads can be compressed at two layers. Transport compression, such as
+// a Kafka record batch compressed by the producer's client (see
+// kafka.WithCompression), is undone by the client library before the
+// message reaches eventmux and needs nothing here. Application compression
+// is applied by the producer to the payload itself and survives transport;
+// the producer declares it with the content-encoding header ("gzip" or
+// "deflate"). Decompress acts on that header alone and never sniffs the
+// payload, so a message is decompressed exactly once however its transport
+// was compressed.
+//
+// Payloads without the header, or with "identity", pass through unchanged.
+// The handler receives the decoded payload without the content-encoding
+// header. An unsupported encoding, a corrupt payload or one that inflates
+// past the size limit will never succeed on redelivery, so it is resolved
+// with core.DLQResult. The header must pass any ingress header filter.
+func Decompress(opts ...DecompressOption) core.Middleware {
+	cfg := decompressConfig{maxSize: DefaultMaxDecompressedSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			enc := strings.ToLower(strings.TrimSpace(core.Header(msg, HeaderContentEncoding)))
+			if enc == "" || enc == "identity" {
+				return next(ctx, msg)
+			}
+			value, err := decompress(enc, msg.Value(), cfg.maxSize)
+			if err != nil {
+				return core.DLQResult(err.Error())
+			}
+			return next(ctx, newDecodedMessage(msg, value))
+		}
+	}
+}
+
+// decompress decodes data compressed with the content encoding enc,
+// failing once the output exceeds maxSize bytes.
+func decompress(enc string, data []byte, maxSize int64) ([]byte, error) {
+	var r io.ReadCloser
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompress gzip: %v", err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported content-encoding %q", enc)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %v", enc, err)
+	}
+	if int64(len(out)) > maxSize {
+		return nil, fmt.Errorf("decompress %s: payload inflates past the limit of %d bytes", enc, maxSize)
+	}
+	return out, nil
+}
+
+// decodedMessage presents msg with its decompressed payload. Topic, Attempt,
+// delayed nacks and the frame index are forwarded to the original; so are
+// positions, by decodedOffsetMessage and decodedSequenceMessage.
+type decodedMessage struct {
+	core.Message
+	value   []byte
+	headers map[string]string
+}
+
+func newDecodedMessage(msg core.Message, value []byte) core.Message {
+	base := msg.Headers()
+	h := make(map[string]string, len(base))
+	for k, v := range base {
+		if !strings.EqualFold(k, HeaderContentEncoding) {
+			h[k] = v
+		}
+	}
+	dm := &decodedMessage{Message: msg, value: value, headers: h}
+	switch pos := msg.(type) {
+	case core.OffsetReader:
+		return &decodedOffsetMessage{dm, pos}
+	case core.SequenceReader:
+		return &decodedSequenceMessage{dm, pos}
+	}
+	return dm
+}
+
+func (m *decodedMessage) Value() []byte              { return m.value }
+func (m *decodedMessage) Size() int                  { return len(m.value) }
+func (m *decodedMessage) Headers() map[string]string { return m.headers }
+func (m *decodedMessage) Topic() string              { return core.Topic(m.Message) }
+func (m *decodedMessage) Attempt() int               { return core.Attempt(m.Message) }
+
+func (m *decodedMessage) Header(key string) (string, bool) {
+	v, ok := m.headers[key]
+	return v, ok
+}
+
+func (m *decodedMessage) NackWithDelay(d time.Duration) error {
+	return core.NackWithDelay(m.Message, d)
+}
+
+func (m *decodedMessage) FrameIndex() (int, bool) {
+	if f, ok := m.Message.(core.FrameIndexer); ok {
+		return f.FrameIndex()
+	}
+	return 0, false
+}
+
+// decodedOffsetMessage is a decodedMessage whose original has a log offset.
+type decodedOffsetMessage struct {
+	*decodedMessage
+	pos core.OffsetReader
+}
+
+func (m *decodedOffsetMessage) Partition() int { return m.pos.Partition() }
+func (m *decodedOffsetMessage) Offset() int64  { return m.pos.Offset() }
+
+// decodedSequenceMessage is a decodedMessage whose original has a stream
+// sequence.
+type decodedSequenceMessage struct {
+	*decodedMessage
+	pos core.SequenceReader
+}
+
+func (m *decodedSequenceMessage) StreamSequence() (string, uint64, bool) {
+	return m.pos.StreamSequence()
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// OffsetDedupKey returns an idempotency key for msg derived from its
+// position in the broker: topic, partition and offset for Kafka
+// (core.OffsetReader), stream and sequence for NATS (core.SequenceReader).
+// These are unique per stored message, so deduplication works without
+// producers stamping message IDs. Messages without position metadata fall
+// back to a SHA-256 of topic, key and payload, which treats identical
+// payloads as duplicates. Frames of one message (core.FrameIndexer) share
+// its position, so their keys end in the frame index.
+func OffsetDedupKey(msg core.Message) string {
+	if or, ok := msg.(core.OffsetReader); ok {
+		return "offset:" + core.Topic(msg) + "/" + strconv.Itoa(or.Partition()) + "/" + strconv.FormatInt(or.Offset(), 10) + frameSuffix(msg)
+	}
+	if sr, ok := msg.(core.SequenceReader); ok {
+		if stream, seq, ok := sr.StreamSequence(); ok {
+			return "seq:" + stream + "/" + strconv.FormatUint(seq, 10) + frameSuffix(msg)
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(core.Topic(msg)))
+	h.Write([]byte{0})
+	h.Write(msg.Key())
+	h.Write([]byte{0})
+	h.Write(msg.Value())
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// frameSuffix returns "#i" for frame i of a framed message and "" otherwise.
+func frameSuffix(msg core.Message) string {
+	if f, ok := msg.(core.FrameIndexer); ok {
+		if i, ok := f.FrameIndex(); ok {
+			return "#" + strconv.Itoa(i)
+		}
+	}
+	return ""
+}
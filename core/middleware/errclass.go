@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Error categories assigned by DefaultErrorClassifier.
+const (
+	CategoryBind       = "bind"
+	CategoryTimeout    = "timeout"
+	CategoryCanceled   = "canceled"
+	CategoryNack       = "nack"
+	CategoryDeadLetter = "dead_letter"
+	CategoryOther      = "other"
+)
+
+// ErrorCollector is optionally implemented by a MetricsCollector to count
+// failures by category. category is always one of the classifier's
+// Categories, so it is safe to use as a metric label.
+type ErrorCollector interface {
+	MessageFailed(topic, category string)
+}
+
+// ErrorClassifier maps handler errors onto a fixed set of categories.
+// Build one with NewErrorClassifier.
+type ErrorClassifier struct {
+	classify   func(error) string
+	categories map[string]struct{}
+}
+
+// NewErrorClassifier returns a classifier that labels errors with classify.
+// categories declares every label classify may return; any other result is
+// reported as CategoryOther, which keeps label cardinality bounded even if
+// classify misbehaves.
+func NewErrorClassifier(classify func(error) string, categories ...string) *ErrorClassifier {
+	set := make(map[string]struct{}, len(categories)+1)
+	for _, c := range categories {
+		set[c] = struct{}{}
+	}
+	set[CategoryOther] = struct{}{}
+	return &ErrorClassifier{classify: classify, categories: set}
+}
+
+// Classify returns the category of err.
+func (c *ErrorClassifier) Classify(err error) string {
+	category := c.classify(err)
+	if _, ok := c.categories[category]; !ok {
+		return CategoryOther
+	}
+	return category
+}
+
+// Categories returns every label Classify can return, sorted.
+func (c *ErrorClassifier) Categories() []string {
+	out := make([]string, 0, len(c.categories))
+	for category := range c.categories {
+		out = append(out, category)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// DefaultErrorClassifier recognizes the error types produced by EventMux and
+// the standard library: binding failures, timeouts, cancellation, and
+// NackResult and DLQResult outcomes. Wrap its Classify in a custom
+// classifier to add application categories such as validation failures.
+var DefaultErrorClassifier = NewErrorClassifier(classifyDefault,
+	CategoryBind, CategoryTimeout, CategoryCanceled, CategoryNack, CategoryDeadLetter)
+
+func classifyDefault(err error) string {
+	var (
+		result    *core.Result
+		field     core.FieldError
+		syntax    *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		timeouter interface{ Timeout() bool }
+	)
+	switch {
+	case errors.Is(err, core.NackResult()):
+		return CategoryNack
+	case errors.As(err, &result):
+		return CategoryDeadLetter
+	case errors.Is(err, core.ErrEmptyPayload), errors.Is(err, core.ErrPayloadTooComplex),
+		errors.As(err, &field), errors.As(err, &syntax), errors.As(err, &typeErr):
+		return CategoryBind
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &timeouter) && timeouter.Timeout():
+		return CategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return CategoryCanceled
+	default:
+		return CategoryOther
+	}
+}
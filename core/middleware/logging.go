@@ -9,6 +9,8 @@ import (
 )
 
 // Logging returns middleware that logs message processing duration and errors.
+// Handlers interrupted by shutdown (see core.Interrupted) are logged as STOP
+// rather than ERROR.
 func Logging() core.Middleware {
 	return func(next core.Handler) core.Handler {
 		return func(ctx context.Context, msg core.Message) error {
@@ -16,9 +18,12 @@ func Logging() core.Middleware {
 			err := next(ctx, msg)
 			elapsed := time.Since(start)
 
-			if err != nil {
+			switch {
+			case core.Interrupted(ctx, err):
+				log.Printf("[EventMux] STOP  key=%s elapsed=%s interrupted by shutdown", string(msg.Key()), elapsed)
+			case core.Failed(err):
 				log.Printf("[EventMux] ERROR key=%s elapsed=%s err=%v", string(msg.Key()), elapsed, err)
-			} else {
+			default:
 				log.Printf("[EventMux] OK    key=%s elapsed=%s", string(msg.Key()), elapsed)
 			}
 			return err
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// MaxMessageSize returns middleware that rejects messages whose payload is
+// larger than maxBytes without calling the handler. An oversized message
+// will never succeed on redelivery, so it is resolved with core.DLQResult;
+// configure a dead-letter topic with core.WithDeadLetterTopic.
+func MaxMessageSize(maxBytes int) core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			if n := core.Size(msg); n > maxBytes {
+				return core.DLQResult(fmt.Sprintf("payload of %d bytes exceeds limit of %d", n, maxBytes))
+			}
+			return next(ctx, msg)
+		}
+	}
+}
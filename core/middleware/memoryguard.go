@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// MemoryGuard returns middleware that bounds the total payload size of
+// messages being processed concurrently. A message whose Value would push
+// the in-flight total past maxBytes waits until enough earlier messages
+// finish, or until its context is cancelled, in which case the context error
+// is returned and the broker redelivers it.
+//
+// Waiting messages are admitted in arrival order, so a stream of small
+// payloads cannot starve a large one. A single message larger than maxBytes
+// is admitted once nothing else is in flight.
+func MemoryGuard(maxBytes int64) core.Middleware {
+	g := &byteBudget{max: maxBytes}
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			n := int64(core.Size(msg))
+			if err := g.acquire(ctx, n); err != nil {
+				return err
+			}
+			defer g.release(n)
+			return next(ctx, msg)
+		}
+	}
+}
+
+// byteBudget is a weighted semaphore over payload bytes that admits waiters
+// first in, first out.
+type byteBudget struct {
+	mu       sync.Mutex
+	max      int64
+	inFlight int64
+	waiters  list.List // of *budgetWaiter, in arrival order
+}
+
+type budgetWaiter struct {
+	n     int64
+	ready chan struct{} // closed once admitted
+}
+
+// fits reports whether n more bytes may start now. g.mu must be held.
+func (g *byteBudget) fits(n int64) bool {
+	return g.inFlight == 0 || g.inFlight+n <= g.max
+}
+
+func (g *byteBudget) acquire(ctx context.Context, n int64) error {
+	g.mu.Lock()
+	if g.waiters.Len() == 0 && g.fits(n) {
+		g.inFlight += n
+		g.mu.Unlock()
+		return nil
+	}
+	w := &budgetWaiter{n: n, ready: make(chan struct{})}
+	elem := g.waiters.PushBack(w)
+	g.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		select {
+		case <-w.ready:
+			// Admitted just as ctx ended; hand the bytes on.
+			g.inFlight -= n
+		default:
+			g.waiters.Remove(elem)
+		}
+		// Waiters held back by this one may fit now.
+		g.admit()
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (g *byteBudget) release(n int64) {
+	g.mu.Lock()
+	g.inFlight -= n
+	g.admit()
+	g.mu.Unlock()
+}
+
+// admit starts waiters from the front of the queue while they fit. g.mu
+// must be held.
+func (g *byteBudget) admit() {
+	for {
+		front := g.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*budgetWaiter)
+		if !g.fits(w.n) {
+			return
+		}
+		g.inFlight += w.n
+		g.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/miladsoleymani/eventmux/core"
@@ -12,18 +13,119 @@ import (
 type MetricsCollector interface {
 	// MessageProcessed records that a message was processed.
 	// topic is the subscription pattern, duration is processing time,
-	// and err is nil on success.
+	// and err is nil on success (including AckResult).
 	MessageProcessed(topic string, duration time.Duration, err error)
 }
 
+// SizeCollector is optionally implemented by a MetricsCollector to also
+// record payload sizes. bucket is SizeBucket(size), suitable as a low
+// cardinality label.
+type SizeCollector interface {
+	MessageSize(topic string, size int, bucket string)
+}
+
+// GaugeCollector is optionally implemented by a MetricsCollector to track
+// saturation. InFlight is called with the number of messages currently
+// inside the middleware each time a message enters or leaves it. Registered
+// outside a limiter such as MemoryGuard, the count includes messages waiting
+// for capacity.
+type GaugeCollector interface {
+	InFlight(topic string, n int)
+}
+
+// RetryCollector is optionally implemented by a MetricsCollector to count
+// redeliveries and retries, whose rate spikes when a dependency is failing.
+// attempt is the delivery attempt reported by core.Attempt.
+type RetryCollector interface {
+	// Redelivered records a message received again, i.e. with attempt > 1.
+	Redelivered(topic string, attempt int)
+	// Retried records a message republished by the Retry middleware after
+	// the given attempt failed (see WithRetryCollector).
+	Retried(topic string, attempt int)
+}
+
+// SizeBucket returns a coarse label for a payload size in bytes.
+func SizeBucket(size int) string {
+	switch {
+	case size == 0:
+		return "0"
+	case size < 1<<10:
+		return "<1KiB"
+	case size < 16<<10:
+		return "<16KiB"
+	case size < 256<<10:
+		return "<256KiB"
+	case size < 1<<20:
+		return "<1MiB"
+	default:
+		return ">=1MiB"
+	}
+}
+
+// MetricsOption configures Metrics.
+type MetricsOption func(*metricsConfig)
+
+type metricsConfig struct {
+	classifier *ErrorClassifier
+}
+
+// WithErrorClassifier sets the classifier used to label failures reported
+// to an ErrorCollector. The default is DefaultErrorClassifier.
+func WithErrorClassifier(c *ErrorClassifier) MetricsOption {
+	return func(cfg *metricsConfig) { cfg.classifier = c }
+}
+
 // Metrics returns middleware that reports processing metrics to the given collector.
 // The topic parameter identifies the subscription for metric labeling.
-func Metrics(topic string, collector MetricsCollector) core.Middleware {
+// If collector also implements SizeCollector, payload sizes are reported too;
+// if it implements ErrorCollector, failures are reported with their category;
+// if it implements GaugeCollector, the in-flight count is reported; if it
+// implements RetryCollector, redelivered messages are counted.
+func Metrics(topic string, collector MetricsCollector, opts ...MetricsOption) core.Middleware {
+	cfg := metricsConfig{classifier: DefaultErrorClassifier}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	sizes, _ := collector.(SizeCollector)
+	failures, _ := collector.(ErrorCollector)
+	gauges, _ := collector.(GaugeCollector)
+	retries, _ := collector.(RetryCollector)
+	var (
+		mu       sync.Mutex
+		inFlight int
+	)
+	// track adjusts the in-flight count, reporting under the lock so the
+	// collector never sees values out of order.
+	track := func(delta int) {
+		mu.Lock()
+		inFlight += delta
+		gauges.InFlight(topic, inFlight)
+		mu.Unlock()
+	}
 	return func(next core.Handler) core.Handler {
 		return func(ctx context.Context, msg core.Message) error {
+			if sizes != nil {
+				n := core.Size(msg)
+				sizes.MessageSize(topic, n, SizeBucket(n))
+			}
+			if retries != nil {
+				if attempt := core.Attempt(msg); attempt > 1 {
+					retries.Redelivered(topic, attempt)
+				}
+			}
+			if gauges != nil {
+				track(1)
+				defer track(-1)
+			}
 			start := time.Now()
 			err := next(ctx, msg)
-			collector.MessageProcessed(topic, time.Since(start), err)
+			reported := err
+			if !core.Failed(err) {
+				reported = nil
+			} else if failures != nil {
+				failures.MessageFailed(topic, cfg.classifier.Classify(err))
+			}
+			collector.MessageProcessed(topic, time.Since(start), reported)
 			return err
 		}
 	}
@@ -2,11 +2,17 @@ package middleware_test
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/miladsoleymani/eventmux/core"
 	"github.com/miladsoleymani/eventmux/core/middleware"
@@ -54,6 +60,24 @@ func TestLogging_Error(t *testing.T) {
 	}
 }
 
+func TestLogging_Interrupted(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer log.SetOutput(nil)
+
+	handler := middleware.Logging()(func(ctx context.Context, msg core.Message) error {
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler(ctx, &mock.Message{K: []byte("k")})
+
+	if strings.Contains(buf.String(), "ERROR") || !strings.Contains(buf.String(), "STOP") {
+		t.Errorf("expected STOP log without ERROR, got: %s", buf.String())
+	}
+}
+
 func TestRecovery(t *testing.T) {
 	var buf bytes.Buffer
 	log.SetOutput(&buf)
@@ -87,3 +111,927 @@ func TestRecovery_NoPanic(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestLogging_AckResult(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer log.SetOutput(nil)
+
+	handler := middleware.Logging()(func(ctx context.Context, msg core.Message) error {
+		return core.AckResult()
+	})
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	handler(context.Background(), msg)
+
+	if !strings.Contains(buf.String(), "OK") {
+		t.Errorf("expected OK log for AckResult, got: %s", buf.String())
+	}
+}
+
+func TestMemoryGuard(t *testing.T) {
+	const maxBytes = 100
+
+	var inFlight, peak atomic.Int64
+	handler := middleware.MemoryGuard(maxBytes)(func(ctx context.Context, msg core.Message) error {
+		n := inFlight.Add(int64(len(msg.Value())))
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-int64(len(msg.Value())))
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := &mock.Message{K: []byte("k"), V: make([]byte, 40)}
+			if err := handler(context.Background(), msg); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > maxBytes {
+		t.Errorf("peak in-flight bytes = %d, budget is %d", got, maxBytes)
+	}
+}
+
+func TestMemoryGuard_Oversized(t *testing.T) {
+	handler := middleware.MemoryGuard(10)(func(ctx context.Context, msg core.Message) error {
+		return nil
+	})
+
+	msg := &mock.Message{K: []byte("k"), V: make([]byte, 50)}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("oversized message should run alone, got %v", err)
+	}
+}
+
+func TestMemoryGuard_ContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	handler := middleware.MemoryGuard(10)(func(ctx context.Context, msg core.Message) error {
+		<-release
+		return nil
+	})
+	defer close(release)
+
+	go handler(context.Background(), &mock.Message{V: make([]byte, 10)})
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := handler(ctx, &mock.Message{V: make([]byte, 5)}); err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded while over budget, got %v", err)
+	}
+}
+
+func TestMemoryGuard_FIFO(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []int
+	handler := middleware.MemoryGuard(10)(func(ctx context.Context, msg core.Message) error {
+		mu.Lock()
+		order = append(order, len(msg.Value()))
+		mu.Unlock()
+		if len(msg.Value()) == 6 {
+			<-release
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	run := func(size int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := handler(context.Background(), &mock.Message{V: make([]byte, size)}); err != nil {
+				t.Errorf("size %d: %v", size, err)
+			}
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	run(6)  // holds 6 of 10 bytes
+	run(8)  // waits for the 6
+	run(2)  // would fit now, but queues behind the 8
+	run(50) // oversized: runs once the others are done
+	mu.Lock()
+	early := fmt.Sprint(order)
+	mu.Unlock()
+	close(release)
+	wg.Wait()
+
+	if early != "[6]" {
+		t.Errorf("admitted %s while the 8-byte message waited, want [6]", early)
+	}
+	if len(order) != 4 || order[3] != 50 {
+		t.Errorf("admission order = %v, want the oversized message last", order)
+	}
+}
+
+func TestMemoryGuard_CancelledWaiterUnblocksQueue(t *testing.T) {
+	release := make(chan struct{})
+	handler := middleware.MemoryGuard(10)(func(ctx context.Context, msg core.Message) error {
+		if len(msg.Value()) == 6 {
+			<-release
+		}
+		return nil
+	})
+	defer close(release)
+
+	go handler(context.Background(), &mock.Message{V: make([]byte, 6)})
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bigErr := make(chan error, 1)
+	go func() { bigErr <- handler(ctx, &mock.Message{V: make([]byte, 8)}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	small := make(chan error, 1)
+	go func() { small <- handler(context.Background(), &mock.Message{V: make([]byte, 2)}) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-bigErr; err != context.Canceled {
+		t.Errorf("cancelled waiter = %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-small:
+		if err != nil {
+			t.Errorf("small message: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("message queued behind a cancelled waiter was never admitted")
+	}
+}
+
+func TestUseByName(t *testing.T) {
+	var seen string
+	core.RegisterMiddleware("test.tagger", func(params map[string]any) (core.Middleware, error) {
+		tag, _ := params["tag"].(string)
+		return func(next core.Handler) core.Handler {
+			return func(ctx context.Context, msg core.Message) error {
+				seen = tag
+				return next(ctx, msg)
+			}
+		}, nil
+	})
+
+	r := core.New(mock.NewBroker())
+	if err := r.UseByName("test.tagger", map[string]any{"tag": "from-config"}); err != nil {
+		t.Fatalf("UseByName: %v", err)
+	}
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error { return nil })
+
+	if err := r.Dispatch(context.Background(), "orders.created", &mock.Message{}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if seen != "from-config" {
+		t.Errorf("named middleware not applied, seen = %q", seen)
+	}
+}
+
+func TestUseByName_BuiltIns(t *testing.T) {
+	r := core.New(mock.NewBroker())
+
+	for _, name := range []string{"recovery", "logging"} {
+		if err := r.UseByName(name, nil); err != nil {
+			t.Errorf("UseByName(%q): %v", name, err)
+		}
+	}
+	// Numbers decoded from YAML/JSON config arrive as float64.
+	if err := r.UseByName("memory_guard", map[string]any{"max_bytes": float64(1 << 20)}); err != nil {
+		t.Errorf("UseByName(memory_guard): %v", err)
+	}
+	if err := r.UseByName("memory_guard", nil); err == nil {
+		t.Error("memory_guard without max_bytes should fail")
+	}
+	if err := r.UseByName("no-such-middleware", nil); err == nil {
+		t.Error("unknown middleware should fail")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	var called bool
+	handler := middleware.MaxMessageSize(4)(func(ctx context.Context, msg core.Message) error {
+		called = true
+		return nil
+	})
+
+	if err := handler(context.Background(), &mock.Message{V: []byte("tiny")}); err != nil || !called {
+		t.Fatalf("message at the limit should pass, err=%v called=%v", err, called)
+	}
+
+	called = false
+	err := handler(context.Background(), &mock.Message{V: []byte("too large")})
+	var res *core.Result
+	if !errors.As(err, &res) || !core.Failed(err) {
+		t.Fatalf("expected DLQ result, got %v", err)
+	}
+	if called {
+		t.Error("handler should not run for oversized messages")
+	}
+}
+
+type sizeCollector struct {
+	sizes   []int
+	buckets []string
+}
+
+func (c *sizeCollector) MessageProcessed(string, time.Duration, error) {}
+
+func (c *sizeCollector) MessageSize(_ string, size int, bucket string) {
+	c.sizes = append(c.sizes, size)
+	c.buckets = append(c.buckets, bucket)
+}
+
+func TestMetrics_Size(t *testing.T) {
+	c := &sizeCollector{}
+	handler := middleware.Metrics("orders", c)(func(ctx context.Context, msg core.Message) error {
+		return nil
+	})
+
+	handler(context.Background(), &mock.Message{})
+	handler(context.Background(), &mock.Message{V: make([]byte, 2048)})
+
+	if len(c.sizes) != 2 || c.sizes[0] != 0 || c.sizes[1] != 2048 {
+		t.Errorf("sizes = %v, want [0 2048]", c.sizes)
+	}
+	if c.buckets[0] != "0" || c.buckets[1] != "<16KiB" {
+		t.Errorf("buckets = %v, want [0 <16KiB]", c.buckets)
+	}
+}
+
+type gaugeCollector struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (c *gaugeCollector) MessageProcessed(string, time.Duration, error) {}
+
+func (c *gaugeCollector) InFlight(_ string, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = append(c.values, n)
+}
+
+func TestMetrics_InFlightGauge(t *testing.T) {
+	c := &gaugeCollector{}
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := middleware.Metrics("orders", c)(func(ctx context.Context, msg core.Message) error {
+		entered <- struct{}{}
+		<-release
+		return nil
+	})
+
+	const workers = 3
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(context.Background(), &mock.Message{})
+		}()
+	}
+	for range workers {
+		<-entered
+	}
+	close(release)
+	wg.Wait()
+
+	want := []int{1, 2, 3}
+	if len(c.values) != 2*workers {
+		t.Fatalf("gauge values = %v, want %d reports", c.values, 2*workers)
+	}
+	for i, v := range want {
+		if c.values[i] != v {
+			t.Fatalf("gauge values = %v, want rise to %d first", c.values, workers)
+		}
+	}
+	for i, v := range c.values[workers:] {
+		if v != workers-1-i {
+			t.Fatalf("gauge values = %v, want fall back to 0", c.values)
+		}
+	}
+}
+
+type errorCollector struct {
+	categories []string
+}
+
+func (c *errorCollector) MessageProcessed(string, time.Duration, error) {}
+
+func (c *errorCollector) MessageFailed(_ string, category string) {
+	c.categories = append(c.categories, category)
+}
+
+func TestMetrics_ErrorCategories(t *testing.T) {
+	var v struct{ N int }
+	bindErr := core.Bind(context.Background(), &mock.Message{V: []byte(`{"N":"x"}`)}, &v)
+
+	results := []error{
+		nil,
+		core.AckResult(),
+		bindErr,
+		core.ErrEmptyPayload,
+		fmt.Errorf("call inventory: %w", context.DeadlineExceeded),
+		context.Canceled,
+		core.NackResult(),
+		core.DLQResult("bad"),
+		errors.New("boom"),
+	}
+	want := []string{"bind", "bind", "timeout", "canceled", "nack", "dead_letter", "other"}
+
+	c := &errorCollector{}
+	for _, res := range results {
+		middleware.Metrics("orders", c)(func(ctx context.Context, msg core.Message) error {
+			return res
+		})(context.Background(), &mock.Message{})
+	}
+
+	if strings.Join(c.categories, ",") != strings.Join(want, ",") {
+		t.Errorf("categories = %v, want %v", c.categories, want)
+	}
+}
+
+var errValidation = errors.New("validation failed")
+
+func TestMetrics_CustomClassifier(t *testing.T) {
+	classifier := middleware.NewErrorClassifier(func(err error) string {
+		if errors.Is(err, errValidation) {
+			return "validation"
+		}
+		if err.Error() == "unbounded" {
+			return "user-" + err.Error() // not declared, reported as other
+		}
+		return middleware.DefaultErrorClassifier.Classify(err)
+	}, "validation", middleware.CategoryTimeout)
+
+	c := &errorCollector{}
+	for _, res := range []error{errValidation, errors.New("unbounded"), context.DeadlineExceeded, core.NackResult()} {
+		middleware.Metrics("orders", c, middleware.WithErrorClassifier(classifier))(func(ctx context.Context, msg core.Message) error {
+			return res
+		})(context.Background(), &mock.Message{})
+	}
+
+	want := []string{"validation", "other", "timeout", "other"}
+	if strings.Join(c.categories, ",") != strings.Join(want, ",") {
+		t.Errorf("categories = %v, want %v", c.categories, want)
+	}
+	if got := strings.Join(classifier.Categories(), ","); got != "other,timeout,validation" {
+		t.Errorf("Categories() = %s", got)
+	}
+}
+
+type offsetMessage struct {
+	mock.Message
+	partition int
+	offset    int64
+}
+
+func (m *offsetMessage) Partition() int { return m.partition }
+func (m *offsetMessage) Offset() int64  { return m.offset }
+
+type sequenceMessage struct {
+	mock.Message
+	stream string
+	seq    uint64
+}
+
+func (m *sequenceMessage) StreamSequence() (string, uint64, bool) {
+	return m.stream, m.seq, m.seq > 0
+}
+
+func TestOffsetDedupKey(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  core.Message
+		want string
+	}{
+		{"kafka offset", &offsetMessage{Message: mock.Message{T: "orders"}, partition: 3, offset: 1042}, "offset:orders/3/1042"},
+		{"nats sequence", &sequenceMessage{stream: "ORDERS", seq: 77}, "seq:ORDERS/77"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := middleware.OffsetDedupKey(tt.msg); got != tt.want {
+				t.Errorf("OffsetDedupKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffsetDedupKey_IngressHeaderFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  core.Message
+		want string
+	}{
+		{"kafka offset", &offsetMessage{Message: mock.Message{T: "orders", V: []byte("v")}, partition: 3, offset: 1042}, "offset:orders/3/1042"},
+		{"nats sequence", &sequenceMessage{Message: mock.Message{T: "orders", V: []byte("v")}, stream: "ORDERS", seq: 77}, "seq:ORDERS/77"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := core.New(mock.NewBroker(), core.WithIngressHeaderFilter(core.AllowHeaders("trace")))
+			var got string
+			r.Handle("orders", func(_ context.Context, msg core.Message) error {
+				got = middleware.OffsetDedupKey(msg)
+				return nil
+			})
+			if err := r.Dispatch(context.Background(), "orders", tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("OffsetDedupKey behind the filter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffsetDedupKey_Fallback(t *testing.T) {
+	a := middleware.OffsetDedupKey(&mock.Message{T: "orders", K: []byte("k"), V: []byte("v")})
+	b := middleware.OffsetDedupKey(&mock.Message{T: "orders", K: []byte("k"), V: []byte("v")})
+	c := middleware.OffsetDedupKey(&mock.Message{T: "orders", K: []byte("kv")})
+	noMeta := middleware.OffsetDedupKey(&sequenceMessage{Message: mock.Message{V: []byte("v")}})
+
+	if !strings.HasPrefix(a, "sha256:") {
+		t.Errorf("fallback key = %q, want sha256 prefix", a)
+	}
+	if a != b {
+		t.Error("identical messages should share a fallback key")
+	}
+	if a == c {
+		t.Error("key and payload boundaries must not collide")
+	}
+	if !strings.HasPrefix(noMeta, "sha256:") {
+		t.Errorf("missing sequence metadata should fall back to a hash, got %q", noMeta)
+	}
+}
+
+func TestTap(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		wantMirror int
+	}{
+		{"all", 1, 10},
+		{"none", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := mock.NewBroker()
+			var handled int
+			handler := middleware.Tap(mb, "debug.tap", tt.rate)(func(ctx context.Context, msg core.Message) error {
+				handled++
+				return nil
+			})
+			for i := 0; i < 10; i++ {
+				handler(context.Background(), &mock.Message{V: []byte("v")})
+			}
+			if handled != 10 {
+				t.Errorf("handler ran %d times, want 10", handled)
+			}
+			pubs := mb.Published()
+			if len(pubs) != tt.wantMirror {
+				t.Fatalf("mirrored %d messages, want %d", len(pubs), tt.wantMirror)
+			}
+			for _, p := range pubs {
+				if p.Topic != "debug.tap" {
+					t.Errorf("mirrored to %q, want debug.tap", p.Topic)
+				}
+			}
+		})
+	}
+}
+
+func TestTap_PublishFailureIgnored(t *testing.T) {
+	mb := mock.NewBroker()
+	mb.PublishErr = errors.New("broker down")
+	boom := errors.New("boom")
+
+	err := middleware.Tap(mb, "debug.tap", 1)(func(ctx context.Context, msg core.Message) error {
+		return boom
+	})(context.Background(), &mock.Message{})
+	if err != boom {
+		t.Errorf("expected handler error to pass through, got %v", err)
+	}
+
+	err = middleware.Tap(mb, "debug.tap", 1)(func(ctx context.Context, msg core.Message) error {
+		return nil
+	})(context.Background(), &mock.Message{})
+	if err != nil {
+		t.Errorf("tap failure leaked into the result: %v", err)
+	}
+}
+
+func TestRetry_SurvivesRestart(t *testing.T) {
+	mb := mock.NewBroker()
+	failing := func(ctx context.Context, msg core.Message) error {
+		return errors.New("boom")
+	}
+
+	// First process: attempt 1 fails and is republished as attempt 2.
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	err := middleware.Retry(mb, "orders.retry", 3)(failing)(context.Background(), msg)
+	if err != core.AckResult() {
+		t.Fatalf("expected AckResult after republish, got %v", err)
+	}
+	pubs := mb.Published()
+	if len(pubs) != 1 || pubs[0].Topic != "orders.retry" {
+		t.Fatalf("expected one republish to orders.retry, got %+v", pubs)
+	}
+	if got := core.Attempt(pubs[0].Message); got != 2 {
+		t.Fatalf("republished attempt = %d, want 2", got)
+	}
+
+	// A restart loses any in-process state; a fresh middleware instance
+	// must continue from the header-carried count.
+	redelivered := &mock.Message{K: []byte("k"), V: []byte("v"), H: pubs[0].Message.Headers()}
+	err = middleware.Retry(mb, "orders.retry", 3)(failing)(context.Background(), redelivered)
+	if err != core.AckResult() {
+		t.Fatalf("expected AckResult after second republish, got %v", err)
+	}
+	pubs = mb.Published()
+	if got := core.Attempt(pubs[1].Message); got != 3 {
+		t.Fatalf("republished attempt = %d, want 3", got)
+	}
+
+	// After another restart, the final attempt is dead-lettered.
+	final := &mock.Message{K: []byte("k"), V: []byte("v"), H: pubs[1].Message.Headers()}
+	err = middleware.Retry(mb, "orders.retry", 3)(failing)(context.Background(), final)
+	var res *core.Result
+	if !errors.As(err, &res) || res.Reason() == "" {
+		t.Fatalf("expected DLQResult at max attempts, got %v", err)
+	}
+	if len(mb.Published()) != 2 {
+		t.Error("final attempt must not be republished")
+	}
+}
+
+func TestRetry_PassesThrough(t *testing.T) {
+	mb := mock.NewBroker()
+	for _, ret := range []error{nil, core.AckResult(), core.NackResult(), core.DLQResult("bad")} {
+		handler := middleware.Retry(mb, "orders.retry", 3)(func(ctx context.Context, msg core.Message) error {
+			return ret
+		})
+		if err := handler(context.Background(), &mock.Message{}); err != ret {
+			t.Errorf("Retry changed %v into %v", ret, err)
+		}
+	}
+	if len(mb.Published()) != 0 {
+		t.Error("results chosen by the handler must not be retried")
+	}
+}
+
+type retryCollector struct {
+	redelivered []string
+	retried     []string
+}
+
+func (c *retryCollector) MessageProcessed(string, time.Duration, error) {}
+
+func (c *retryCollector) Redelivered(topic string, attempt int) {
+	c.redelivered = append(c.redelivered, fmt.Sprintf("%s/%d", topic, attempt))
+}
+
+func (c *retryCollector) Retried(topic string, attempt int) {
+	c.retried = append(c.retried, fmt.Sprintf("%s/%d", topic, attempt))
+}
+
+func TestMetrics_Redelivered(t *testing.T) {
+	c := &retryCollector{}
+	h := middleware.Metrics("orders", c)(func(ctx context.Context, msg core.Message) error {
+		return nil
+	})
+
+	h(context.Background(), &mock.Message{})
+	h(context.Background(), &mock.Message{H: map[string]string{core.HeaderAttempt: "1"}})
+	h(context.Background(), &mock.Message{H: map[string]string{core.HeaderAttempt: "3"}})
+
+	if got := strings.Join(c.redelivered, ","); got != "orders/3" {
+		t.Errorf("redelivered = %q, want orders/3", got)
+	}
+}
+
+func TestRetry_ReportsRetries(t *testing.T) {
+	c := &retryCollector{}
+	mb := mock.NewBroker()
+	h := middleware.Retry(mb, "orders.retry", 3, middleware.WithRetryCollector("orders", c))(
+		func(ctx context.Context, msg core.Message) error { return errors.New("boom") })
+
+	h(context.Background(), &mock.Message{})
+	h(context.Background(), &mock.Message{H: map[string]string{core.HeaderAttempt: "2"}})
+	h(context.Background(), &mock.Message{H: map[string]string{core.HeaderAttempt: "3"}}) // dead-lettered
+
+	if got := strings.Join(c.retried, ","); got != "orders/1,orders/2" {
+		t.Errorf("retried = %q, want orders/1,orders/2", got)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	handled := make(chan *mock.Message, 10)
+	h := middleware.Debounce(50*time.Millisecond, nil)(func(ctx context.Context, msg core.Message) error {
+		msg.Ack()
+		handled <- msg.(*mock.Message)
+		return nil
+	})
+
+	msg := func(key, value string) *mock.Message {
+		return &mock.Message{K: []byte(key), V: []byte(value)}
+	}
+	v1, v2, w1, v3 := msg("k1", "v1"), msg("k1", "v2"), msg("k2", "w1"), msg("k1", "v3")
+	for _, m := range []*mock.Message{v1, v2, w1, v3} {
+		if err := h(context.Background(), m); err != nil {
+			t.Fatalf("hold %s: %v", m.V, err)
+		}
+	}
+	if !v1.Acked || !v2.Acked {
+		t.Errorf("superseded messages not acked: v1=%v v2=%v", v1.Acked, v2.Acked)
+	}
+
+	got := map[string]bool{}
+	for range 2 {
+		select {
+		case m := <-handled:
+			got[string(m.V)] = m.Acked
+		case <-time.After(time.Second):
+			t.Fatalf("handled only %v", got)
+		}
+	}
+	if len(got) != 2 || !got["v3"] || !got["w1"] {
+		t.Errorf("handled %v, want only the latest per key: v3 and w1", got)
+	}
+	select {
+	case m := <-handled:
+		t.Errorf("unexpected extra handling of %s", m.V)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// nackSignal reports Nack on a channel, for settling done off the test goroutine.
+type nackSignal struct {
+	*mock.Message
+	nacked chan struct{}
+}
+
+func (m *nackSignal) Nack() error {
+	close(m.nacked)
+	return nil
+}
+
+func TestDebounce_SettlesResult(t *testing.T) {
+	h := middleware.Debounce(10*time.Millisecond, func(core.Message) string { return "all" })(
+		func(ctx context.Context, msg core.Message) error {
+			return core.NackResult()
+		})
+
+	m := &nackSignal{Message: &mock.Message{}, nacked: make(chan struct{})}
+	h(context.Background(), m)
+	select {
+	case <-m.nacked:
+	case <-time.After(time.Second):
+		t.Error("NackResult from a debounced message should nack it")
+	}
+}
+
+func TestDebounce_CancelledLeavesUnsettled(t *testing.T) {
+	called := make(chan struct{}, 1)
+	h := middleware.Debounce(20*time.Millisecond, nil)(func(ctx context.Context, msg core.Message) error {
+		called <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &mock.Message{K: []byte("k")}
+	h(ctx, m)
+	cancel()
+
+	select {
+	case <-called:
+		t.Error("handler ran after the context was cancelled")
+	case <-time.After(60 * time.Millisecond):
+	}
+	if m.Acked || m.Nacked {
+		t.Errorf("acked=%v nacked=%v, want unsettled", m.Acked, m.Nacked)
+	}
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	var got core.Message
+	handler := middleware.Decompress()(func(ctx context.Context, msg core.Message) error {
+		got = msg
+		return nil
+	})
+
+	body := []byte(`{"id":1}`)
+	msg := &mock.Message{T: "orders", V: gzipped(t, body), H: map[string]string{
+		middleware.HeaderContentEncoding: "gzip",
+		"trace-id":                       "abc",
+	}}
+	if err := handler(context.Background(), msg); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if !bytes.Equal(got.Value(), body) || core.Size(got) != len(body) {
+		t.Errorf("value = %q, want %q", got.Value(), body)
+	}
+	if _, ok := got.Headers()[middleware.HeaderContentEncoding]; ok {
+		t.Error("content-encoding should be removed once decoded")
+	}
+	if core.Header(got, "trace-id") != "abc" || core.Topic(got) != "orders" {
+		t.Error("other headers and the topic should be kept")
+	}
+	if err := got.Ack(); err != nil || !msg.Acked {
+		t.Error("Ack should settle the original")
+	}
+
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.BestSpeed)
+	fw.Write(body)
+	fw.Close()
+	if err := handler(context.Background(), &mock.Message{V: deflated.Bytes(), H: map[string]string{"content-encoding": "deflate"}}); err != nil {
+		t.Fatalf("deflate: %v", err)
+	}
+	if !bytes.Equal(got.Value(), body) {
+		t.Errorf("deflate value = %q", got.Value())
+	}
+}
+
+func TestDecompress_OnlyWithHeader(t *testing.T) {
+	var got []byte
+	handler := middleware.Decompress()(func(ctx context.Context, msg core.Message) error {
+		got = msg.Value()
+		return nil
+	})
+
+	// Gzip bytes without the header are an opaque payload, e.g. a file the
+	// producer sends as-is; they must not be decoded.
+	raw := gzipped(t, []byte("archive"))
+	for _, h := range []map[string]string{nil, {"content-encoding": "identity"}} {
+		if err := handler(context.Background(), &mock.Message{V: raw, H: h}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, raw) {
+			t.Errorf("headers %v: payload was modified", h)
+		}
+	}
+}
+
+func TestDecompress_Invalid(t *testing.T) {
+	var called bool
+	handler := middleware.Decompress()(func(ctx context.Context, msg core.Message) error {
+		called = true
+		return nil
+	})
+	for name, msg := range map[string]*mock.Message{
+		"corrupt":     {V: []byte("not gzip"), H: map[string]string{"content-encoding": "gzip"}},
+		"truncated":   {V: gzipped(t, []byte("payload"))[:12], H: map[string]string{"content-encoding": "gzip"}},
+		"unsupported": {V: []byte("x"), H: map[string]string{"content-encoding": "br"}},
+	} {
+		if err := handler(context.Background(), msg); !core.Failed(err) {
+			t.Errorf("%s: expected DLQ result, got %v", name, err)
+		}
+	}
+	if called {
+		t.Error("handler should not run for undecodable payloads")
+	}
+}
+
+func TestDecompress_SizeLimit(t *testing.T) {
+	bomb := gzipped(t, make([]byte, 4<<20)) // 4 MiB of zeros, a few KB compressed
+	var got int
+	handle := func(ctx context.Context, msg core.Message) error {
+		got = len(msg.Value())
+		return nil
+	}
+	header := map[string]string{"content-encoding": "gzip"}
+
+	limited := middleware.Decompress(middleware.WithMaxDecompressedSize(1 << 20))(handle)
+	if err := limited(context.Background(), &mock.Message{V: bomb, H: header}); !core.Failed(err) {
+		t.Errorf("oversized payload: expected DLQ result, got %v", err)
+	}
+	if got != 0 {
+		t.Error("handler should not run for a payload over the limit")
+	}
+
+	exact := middleware.Decompress(middleware.WithMaxDecompressedSize(4 << 20))(handle)
+	if err := exact(context.Background(), &mock.Message{V: bomb, H: header}); err != nil || got != 4<<20 {
+		t.Errorf("payload at the limit: err %v, %d bytes", err, got)
+	}
+}
+
+func TestDecompress_ForwardsPosition(t *testing.T) {
+	var got core.Message
+	handler := middleware.Decompress()(func(ctx context.Context, msg core.Message) error {
+		got = msg
+		return nil
+	})
+	header := map[string]string{"content-encoding": "gzip"}
+	body := gzipped(t, []byte("payload"))
+
+	for _, msg := range []core.Message{
+		&offsetMessage{Message: mock.Message{T: "orders", V: body, H: header}, partition: 3, offset: 1042},
+		&sequenceMessage{Message: mock.Message{V: body, H: header}, stream: "ORDERS", seq: 77},
+	} {
+		if err := handler(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+		if a, b := middleware.OffsetDedupKey(got), middleware.OffsetDedupKey(msg); a != b {
+			t.Errorf("%T: dedup key behind Decompress = %q, want %q", msg, a, b)
+		}
+	}
+
+	if err := handler(context.Background(), &mock.Message{T: "orders", V: body, H: header}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(core.OffsetReader); ok {
+		t.Error("a message without an offset should not gain one")
+	}
+}
+
+type spanKey struct{}
+
+func TestRedrive(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	var spans []string
+	r.Use(middleware.Redrivable())
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			if prev := ctx.Value(spanKey{}); prev != nil {
+				t.Errorf("attempt %d inherited span %v", middleware.Redrives(ctx), prev)
+			}
+			span := fmt.Sprintf("span-%d", len(spans)+1)
+			spans = append(spans, span)
+			return next(context.WithValue(ctx, spanKey{}, span), msg)
+		}
+	})
+
+	var seen []string
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		seen = append(seen, fmt.Sprintf("%d:%v", middleware.Redrives(ctx), ctx.Value(spanKey{})))
+		if middleware.Redrives(ctx) < 2 {
+			return middleware.Redrive(ctx, msg)
+		}
+		return core.AckResult()
+	})
+
+	msg := &mock.Message{}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if len(spans) != 3 {
+		t.Errorf("middleware ran %d times, want 3", len(spans))
+	}
+	want := []string{"0:span-1", "1:span-2", "2:span-3"}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("handler saw %v, want %v", seen, want)
+	}
+	if !msg.Acked {
+		t.Error("the final attempt's result should settle the message")
+	}
+}
+
+func TestRedrive_NotRedrivable(t *testing.T) {
+	if err := middleware.Redrive(context.Background(), &mock.Message{}); !errors.Is(err, middleware.ErrNotRedrivable) {
+		t.Errorf("Redrive = %v, want ErrNotRedrivable", err)
+	}
+	if n := middleware.Redrives(context.Background()); n != 0 {
+		t.Errorf("Redrives = %d, want 0", n)
+	}
+}
+
+func TestOffsetDedupKey_Frames(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithFramer(core.NewlineFramer{}))
+	var keys []string
+	r.Handle("orders", func(_ context.Context, msg core.Message) error {
+		keys = append(keys, middleware.OffsetDedupKey(msg))
+		return nil
+	})
+	msg := &offsetMessage{Message: mock.Message{T: "orders", V: []byte("a\nb\n")}, partition: 3, offset: 1042}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "offset:orders/3/1042#0,offset:orders/3/1042#1" {
+		t.Errorf("frame keys = %v, want one per frame", keys)
+	}
+}
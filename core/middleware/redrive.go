@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// ErrNotRedrivable is returned by Redrive when the context did not come
+// through Redrivable.
+var ErrNotRedrivable = errors.New("eventmux: context has no Redrivable middleware to redrive")
+
+type redriveKey struct{}
+
+// redrive is the re-entry point recorded by Redrivable.
+type redrive struct {
+	ctx     context.Context // as Redrivable received it
+	next    core.Handler
+	attempt int
+}
+
+// Redrivable returns middleware that marks a re-entry point in the chain for
+// Redrive. Everything it wraps, the handler and the middleware registered
+// after it, runs again on each redrive; register it first with Router.Use
+// to re-run the whole chain, e.g. to start a fresh span or timeout per
+// attempt. Unlike Retry, redrives happen in process and do not republish.
+func Redrivable() core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			return next(context.WithValue(ctx, redriveKey{}, &redrive{ctx: ctx, next: next}), msg)
+		}
+	}
+}
+
+// Redrive runs msg through the chain again from the Redrivable point and
+// returns its result, which the caller usually returns in turn. The new
+// attempt gets a fresh context derived from the one Redrivable received, so
+// values and deadlines that wrapped middleware set on the previous attempt
+// are gone. A Store attached outside the Redrivable point is shared between
+// attempts. Bound the number of attempts with Redrives. It returns
+// ErrNotRedrivable if ctx did not come through Redrivable.
+func Redrive(ctx context.Context, msg core.Message) error {
+	rd, ok := ctx.Value(redriveKey{}).(*redrive)
+	if !ok {
+		return ErrNotRedrivable
+	}
+	next := &redrive{ctx: rd.ctx, next: rd.next, attempt: rd.attempt + 1}
+	return rd.next(context.WithValue(rd.ctx, redriveKey{}, next), msg)
+}
+
+// Redrives returns how many times the current message has been redriven,
+// 0 on its first pass.
+func Redrives(ctx context.Context) int {
+	if rd, ok := ctx.Value(redriveKey{}).(*redrive); ok {
+		return rd.attempt
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+func init() {
+	core.RegisterMiddleware("recovery", func(map[string]any) (core.Middleware, error) {
+		return Recovery(), nil
+	})
+	core.RegisterMiddleware("logging", func(map[string]any) (core.Middleware, error) {
+		return Logging(), nil
+	})
+	core.RegisterMiddleware("metrics", func(params map[string]any) (core.Middleware, error) {
+		topic, _ := params["topic"].(string)
+		collector, ok := params["collector"].(MetricsCollector)
+		if !ok {
+			return nil, fmt.Errorf("param \"collector\" must be a MetricsCollector")
+		}
+		var opts []MetricsOption
+		if c, ok := params["classifier"].(*ErrorClassifier); ok {
+			opts = append(opts, WithErrorClassifier(c))
+		}
+		return Metrics(topic, collector, opts...), nil
+	})
+	core.RegisterMiddleware("memory_guard", func(params map[string]any) (core.Middleware, error) {
+		n, ok := intParam(params, "max_bytes")
+		if !ok || n <= 0 {
+			return nil, fmt.Errorf("param \"max_bytes\" must be a positive integer")
+		}
+		return MemoryGuard(n), nil
+	})
+	core.RegisterMiddleware("max_message_size", func(params map[string]any) (core.Middleware, error) {
+		n, ok := intParam(params, "max_bytes")
+		if !ok || n <= 0 {
+			return nil, fmt.Errorf("param \"max_bytes\" must be a positive integer")
+		}
+		return MaxMessageSize(int(n)), nil
+	})
+	core.RegisterMiddleware("decompress", func(params map[string]any) (core.Middleware, error) {
+		var opts []DecompressOption
+		if _, set := params["max_bytes"]; set {
+			n, ok := intParam(params, "max_bytes")
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("param \"max_bytes\" must be a positive integer")
+			}
+			opts = append(opts, WithMaxDecompressedSize(n))
+		}
+		return Decompress(opts...), nil
+	})
+}
+
+// intParam reads an integer parameter, accepting the numeric types YAML and
+// JSON decoders produce.
+func intParam(params map[string]any, key string) (int64, bool) {
+	switch v := params[key].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Retry returns middleware that retries failed messages by republishing
+// them to retryTopic with HeaderAttempt incremented, then acknowledging the
+// original. Once a message has been attempted maxAttempts times it is
+// resolved with core.DLQResult instead.
+//
+// The attempt count is read with core.Attempt and carried in headers rather
+// than held in memory, so a poison message cannot retry forever across
+// restarts. Messages the handler already resolved with a Result are passed
+// through unchanged. retryTopic is usually the original topic or a
+// dedicated delay topic consumed by the same handler.
+func Retry(p core.Publisher, retryTopic string, maxAttempts int, opts ...RetryOption) core.Middleware {
+	var cfg retryConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			err := next(ctx, msg)
+			if !core.Failed(err) || isResult(err) {
+				return err
+			}
+
+			attempt := core.Attempt(msg)
+			if attempt >= maxAttempts {
+				return core.DLQResult(fmt.Sprintf("gave up after %d attempts: %v", attempt, err))
+			}
+
+			retry := core.MergeHeaders(msg, map[string]string{
+				core.HeaderAttempt: strconv.Itoa(attempt + 1),
+			})
+			if perr := p.Publish(ctx, retryTopic, retry); perr != nil {
+				// Leave the message to the broker's own redelivery.
+				return fmt.Errorf("eventmux: retry publish to %q: %w (handler error: %v)", retryTopic, perr, err)
+			}
+			if cfg.collector != nil {
+				cfg.collector.Retried(cfg.topic, attempt)
+			}
+			return core.AckResult()
+		}
+	}
+}
+
+// RetryOption configures Retry.
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	topic     string
+	collector RetryCollector
+}
+
+// WithRetryCollector reports every retry Retry publishes to c, labelled
+// with topic.
+func WithRetryCollector(topic string, c RetryCollector) RetryOption {
+	return func(cfg *retryConfig) { cfg.topic, cfg.collector = topic, c }
+}
+
+// isResult reports whether err is a core.Result the handler chose itself.
+func isResult(err error) bool {
+	var res *core.Result
+	return errors.As(err, &res)
+}
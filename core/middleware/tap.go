@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+	"math/rand/v2"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Tap returns middleware that mirrors a sample of messages to targetTopic
+// for inspection, then calls next as usual. sampleRate is the fraction of
+// messages copied, from 0 (none) to 1 (all). Mirroring is best effort: a
+// failed tap publish is ignored and never affects the handler's outcome.
+// Pass a Router as p to apply its publish interceptors and header filters
+// to the copies.
+func Tap(p core.Publisher, targetTopic string, sampleRate float64) core.Middleware {
+	return func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			if sampleRate >= 1 || (sampleRate > 0 && rand.Float64() < sampleRate) {
+				_ = p.Publish(ctx, targetTopic, msg)
+			}
+			return next(ctx, msg)
+		}
+	}
+}
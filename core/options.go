@@ -0,0 +1,113 @@
+package core
+
+import "time"
+
+// Option configures a Router.
+type Option func(*Router)
+
+// WithDeadLetterTopic sets the topic that messages resolved with DLQResult
+// are republished to.
+func WithDeadLetterTopic(topic string) Option {
+	return func(r *Router) { r.deadLetterTopic = topic }
+}
+
+// WithBinder sets the Binder used by Bind for messages dispatched by the
+// Router. The default is JSONBinder.
+func WithBinder(b Binder) Option {
+	return func(r *Router) { r.binder = b }
+}
+
+// WithPublishMode sets how Publish delivers messages to the broker.
+// See PublishSync and PublishBestEffort.
+func WithPublishMode(m PublishMode) Option {
+	return func(r *Router) { r.publishMode = m }
+}
+
+// WithPublishBuffer sets the queue size for PublishBestEffort.
+// The default is 1024, which also replaces sizes below 1.
+func WithPublishBuffer(n int) Option {
+	return func(r *Router) { r.publishBuffer = n }
+}
+
+// WithIngressHeaderFilter hides headers rejected by f from handlers and
+// handler middleware. Raw middleware still sees the message as delivered.
+func WithIngressHeaderFilter(f HeaderFilter) Option {
+	return func(r *Router) { r.ingressFilter = f }
+}
+
+// WithEgressHeaderFilter strips headers rejected by f from every message the
+// Router publishes, after publish interceptors have run.
+func WithEgressHeaderFilter(f HeaderFilter) Option {
+	return func(r *Router) { r.egressFilter = f }
+}
+
+// WithCloseTimeout bounds how long closing the router waits for the
+// broker's Close. If it has not returned by then, Start (or Close) logs the
+// timeout and returns ErrCloseTimeout while the broker finishes closing in
+// the background. The default of zero waits indefinitely.
+func WithCloseTimeout(d time.Duration) Option {
+	return func(r *Router) { r.closeTimeout = d }
+}
+
+// WithFramer splits every received payload into frames with f and handles
+// each frame as its own message, before binding. Frames are handled in
+// order and settle only their own Result; the original message is acked
+// once every frame has been acked and nacked if any frame was nacked.
+func WithFramer(f Framer) Option {
+	return func(r *Router) { r.framer = f }
+}
+
+// WithTopicValidator replaces the check applied to every topic the Router
+// publishes to, for brokers with their own naming rules. The default is
+// ValidateTopic; pass nil to disable validation.
+func WithTopicValidator(v TopicValidator) Option {
+	return func(r *Router) { r.validateTopic = v }
+}
+
+// WithTopicAllowlist makes Start fail with ErrTopicNotAllowed unless every
+// registered route pattern is allowed by one of patterns, guarding shared
+// clusters against accidental firehose subscriptions such as "#". A pattern
+// ending in "#" allows every route pattern that starts with the text before
+// it, so "orders.#" allows "orders.created" and "orders.*" but not "#"; any
+// other pattern allows only an identical route pattern. A nil or empty list
+// allows everything.
+func WithTopicAllowlist(patterns []string) Option {
+	return func(r *Router) { r.topicAllowlist = patterns }
+}
+
+// WithNackBackoff delays redelivery of messages the Router nacks, i.e.
+// those resolved with NackResult, by b applied to the message's Attempt.
+// It takes effect on brokers whose messages implement DelayedNacker; others
+// redeliver as usual. Plain errors keep the broker's redelivery semantics.
+func WithNackBackoff(b BackoffStrategy) Option {
+	return func(r *Router) { r.nackBackoff = b }
+}
+
+// WithDispatchMode sets which routes Dispatch runs when several patterns
+// match a topic. The default is DispatchMostSpecific. Subscriptions made by
+// Start are unaffected: each route receives what the broker delivers to its
+// own subscription.
+func WithDispatchMode(m DispatchMode) Option {
+	return func(r *Router) { r.dispatchMode = m }
+}
+
+// WithGoroutineLimit logs a warning when the number of goroutines the Router
+// owns (see Router.Stats) goes above n. Zero, the default, disables it.
+func WithGoroutineLimit(n int) Option {
+	return func(r *Router) { r.goroutineLimit = n }
+}
+
+// WithPropagator adds a ContextPropagator, e.g. for OpenTelemetry baggage.
+// Handler contexts receive the values extracted from each message's
+// headers, and every message the Router publishes, including dead-letter
+// copies, carries the values found in the publishing context. Propagators
+// run in the order they were added.
+func WithPropagator(p ContextPropagator) Option {
+	return func(r *Router) { r.propagators = append(r.propagators, p) }
+}
+
+// WithShutdownPolicy sets how messages whose handlers were interrupted by
+// shutdown are settled. The default is ShutdownLeaveUnsettled.
+func WithShutdownPolicy(p ShutdownPolicy) Option {
+	return func(r *Router) { r.shutdownPolicy = p }
+}
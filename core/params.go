@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"strings"
+)
+
+// Params holds the topic segments captured by ":name" tokens in a route
+// pattern. For the pattern "tenant.:id.orders.created" and the topic
+// "tenant.acme.orders.created", Params is {"id": "acme"}.
+type Params map[string]string
+
+type paramsKey struct{}
+
+// Param returns the captured segment name for the message being handled,
+// or "" if the route pattern has no such capture.
+func Param(ctx context.Context, name string) string {
+	return ParamsFrom(ctx)[name]
+}
+
+// ParamsFrom returns all captured segments for the message being handled.
+func ParamsFrom(ctx context.Context) Params {
+	p, _ := ctx.Value(paramsKey{}).(Params)
+	return p
+}
+
+func withParams(ctx context.Context, p Params) context.Context {
+	if len(p) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, paramsKey{}, p)
+}
+
+// isCapture reports whether a pattern segment is a ":name" capture.
+func isCapture(seg string) bool {
+	return len(seg) > 1 && seg[0] == ':'
+}
+
+// hasCaptures reports whether pattern contains any ":name" segments.
+func hasCaptures(pattern string) bool {
+	return strings.Contains(pattern, ".:") || strings.HasPrefix(pattern, ":")
+}
+
+// subscriptionPattern rewrites ":name" captures to "*" so the pattern can be
+// handed to a broker that only understands wildcards.
+func subscriptionPattern(pattern string) string {
+	if !hasCaptures(pattern) {
+		return pattern
+	}
+	parts := strings.Split(pattern, ".")
+	for i, p := range parts {
+		if isCapture(p) {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// captureParams extracts ":name" segments from topic using m if it is a
+// CapturingMatcher. Other matchers fall back to a positional capture that
+// supports literals, "*" and captures, each consuming exactly one level.
+func captureParams(m TopicMatcher, pattern, topic string) (Params, bool) {
+	if cm, ok := m.(CapturingMatcher); ok {
+		return cm.MatchParams(pattern, topic)
+	}
+	patParts := strings.Split(pattern, ".")
+	topParts := strings.Split(topic, ".")
+	if len(patParts) != len(topParts) {
+		return nil, false
+	}
+	var params Params
+	for i, p := range patParts {
+		switch {
+		case isCapture(p):
+			if params == nil {
+				params = make(Params)
+			}
+			params[p[1:]] = topParts[i]
+		case p == "*":
+		case p != topParts[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,74 @@
+package core
+
+// OffsetReader is implemented by messages from partitioned logs such as
+// Kafka. Together with the topic, the partition and offset identify the
+// message uniquely.
+type OffsetReader interface {
+	Partition() int
+	Offset() int64
+}
+
+// SequenceReader is implemented by messages stored in a sequenced stream
+// such as NATS JetStream. ok is false if the broker metadata is unavailable.
+type SequenceReader interface {
+	StreamSequence() (stream string, seq uint64, ok bool)
+}
+
+// FrameIndexer reports the 0-based index of a frame a Framer split from a
+// larger message; ok is false for messages that are not frames. Frames
+// share their original's position, so keys derived from it add the index
+// to tell them apart.
+type FrameIndexer interface {
+	FrameIndex() (index int, ok bool)
+}
+
+// frameIndex returns msg's frame index, if msg is a frame.
+func frameIndex(msg Message) (int, bool) {
+	if f, ok := msg.(FrameIndexer); ok {
+		return f.FrameIndex()
+	}
+	return 0, false
+}
+
+// forwarder is a wrapper that forwards its original's topic, size, attempt,
+// delivery counting, delayed nacks and frame index.
+type forwarder interface {
+	Message
+	TopicReader
+	Sizer
+	AttemptReader
+	DeliveryCounter
+	DelayedNacker
+	FrameIndexer
+}
+
+// withPosition returns w extended with orig's log position, if orig has one,
+// so position-based deduplication and offset tracking still see it.
+func withPosition(w forwarder, orig Message) Message {
+	switch pos := orig.(type) {
+	case OffsetReader:
+		return &offsetMessage{w, pos}
+	case SequenceReader:
+		return &sequenceMessage{w, pos}
+	}
+	return w
+}
+
+// offsetMessage is a wrapper whose original has a partition offset.
+type offsetMessage struct {
+	forwarder
+	pos OffsetReader
+}
+
+func (m *offsetMessage) Partition() int { return m.pos.Partition() }
+func (m *offsetMessage) Offset() int64  { return m.pos.Offset() }
+
+// sequenceMessage is a wrapper whose original has a stream sequence.
+type sequenceMessage struct {
+	forwarder
+	pos SequenceReader
+}
+
+func (m *sequenceMessage) StreamSequence() (string, uint64, bool) {
+	return m.pos.StreamSequence()
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WithWorkerPool runs the handlers of all subscribed routes on n shared
+// workers instead of on the goroutines the broker delivers from. The
+// broker's delivering goroutine waits for its message's result before it
+// fetches the next, so acknowledgement is unchanged and the pool's queue
+// holds at most one message per delivering goroutine: usually one per
+// subscription, more for plugins that run several handler goroutines per
+// subscription.
+//
+// When every worker is busy, a freed worker takes the waiting message of
+// the route with the highest WithPriority, then the oldest. Priority thus
+// breaks ties between the routes' next messages; it does not reach into a
+// backlog still held by the broker, and a lower-priority route is served
+// whenever no higher-priority message is waiting. Dispatch does not use the
+// pool. Zero, the default, disables it.
+func WithWorkerPool(n int) Option {
+	return func(r *Router) { r.workers = n }
+}
+
+// WithPriority sets the route's priority in the WithWorkerPool queue:
+// waiting messages of routes with a higher priority are handled before
+// those of routes with a lower one. The default is 0; negative priorities
+// rank below it. Without a worker pool the priority has no effect.
+func WithPriority(n int) RouteOption {
+	return func(c *routeConfig) { c.priority = n }
+}
+
+// job is a delivered message waiting for a worker.
+type job struct {
+	ctx      context.Context
+	msg      Message
+	h        Handler
+	priority int
+	seq      uint64
+	done     chan error
+
+	// Guarded by scheduler.mu.
+	index int // position in the queue
+	taken bool
+}
+
+// jobQueue is a heap of jobs, highest priority first, then oldest first.
+type jobQueue []*job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *jobQueue) Push(x any) {
+	j := x.(*job)
+	j.index = len(*q)
+	*q = append(*q, j)
+}
+
+func (q *jobQueue) Pop() any {
+	old := *q
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return j
+}
+
+// scheduler feeds delivered messages to the workers of a WithWorkerPool in
+// priority order.
+type scheduler struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	queue   jobQueue
+	seq     uint64
+	stopped bool
+	queued  *atomic.Int64 // Stats.Queued
+
+	// interrupted settles a withdrawn message by the ShutdownPolicy.
+	interrupted func(Message) error
+}
+
+func newScheduler(queued *atomic.Int64, interrupted func(Message) error) *scheduler {
+	s := &scheduler{queued: queued, interrupted: interrupted}
+	s.ready = sync.NewCond(&s.mu)
+	return s
+}
+
+// wrap returns a Handler that queues each message for the pool at priority
+// and waits for its result. A message whose context ends while it is still
+// queued is withdrawn and settled like an interrupted handler.
+func (s *scheduler) wrap(priority int, h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		j := &job{ctx: ctx, msg: msg, h: h, priority: priority, done: make(chan error, 1)}
+		if !s.push(j) {
+			return ErrConsumingStopped
+		}
+		select {
+		case err := <-j.done:
+			return err
+		case <-ctx.Done():
+			if s.withdraw(j) {
+				return s.interrupted(msg)
+			}
+			return <-j.done
+		}
+	}
+}
+
+// push queues j, reporting false once the scheduler has stopped.
+func (s *scheduler) push(j *job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.seq++
+	j.seq = s.seq
+	heap.Push(&s.queue, j)
+	s.queued.Add(1)
+	s.ready.Signal()
+	return true
+}
+
+// withdraw removes j from the queue unless a worker has already taken it.
+func (s *scheduler) withdraw(j *job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j.taken {
+		return false
+	}
+	heap.Remove(&s.queue, j.index)
+	s.queued.Add(-1)
+	return true
+}
+
+// next blocks until a job is queued and returns the most urgent one, or nil
+// once the scheduler has stopped and the queue is drained.
+func (s *scheduler) next() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) == 0 && !s.stopped {
+		s.ready.Wait()
+	}
+	if len(s.queue) == 0 {
+		return nil
+	}
+	j := heap.Pop(&s.queue).(*job)
+	s.queued.Add(-1)
+	j.taken = true
+	return j
+}
+
+// work runs queued jobs until the scheduler stops.
+func (s *scheduler) work() {
+	for j := s.next(); j != nil; j = s.next() {
+		j.done <- j.h(j.ctx, j.msg)
+	}
+}
+
+// stop refuses new jobs and lets the workers exit once the queue is empty.
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.ready.Broadcast()
+}
@@ -0,0 +1,280 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// waitQueued waits until the router has n messages waiting for a worker.
+func waitQueued(t *testing.T, r *core.Router, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats().Queued = %d, want %d", r.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerPool_HigherPriorityFirst(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b, core.WithWorkerPool(1))
+
+	var mu sync.Mutex
+	var order []string
+	started, gate := make(chan struct{}), make(chan struct{})
+	record := func(_ context.Context, msg core.Message) error {
+		id := string(msg.Value())
+		if id == "block" {
+			close(started)
+			<-gate
+		}
+		mu.Lock()
+		order = append(order, id)
+		mu.Unlock()
+		return nil
+	}
+	r.Handle("clicks", record)
+	r.Handle("reports", record, core.WithPriority(5))
+	r.Handle("payments", record, core.WithPriority(10))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	// Occupy the only worker, then queue messages from low to high priority.
+	var wg sync.WaitGroup
+	deliver := func(topic, id string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Deliver(context.Background(), topic, &mock.Message{T: topic, V: []byte(id)}); err != nil {
+				t.Errorf("deliver %s: %v", id, err)
+			}
+		}()
+	}
+	deliver("clicks", "block")
+	<-started
+	queue := []struct{ topic, id string }{
+		{"clicks", "click-1"},
+		{"clicks", "click-2"},
+		{"reports", "report-1"},
+		{"payments", "payment-1"},
+		{"payments", "payment-2"},
+	}
+	for i, m := range queue {
+		deliver(m.topic, m.id)
+		waitQueued(t, r, i+1)
+	}
+	close(gate)
+	wg.Wait()
+
+	want := []string{"block", "payment-1", "payment-2", "report-1", "click-1", "click-2"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != len(want) {
+		t.Fatalf("handled %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("handled %v, want %v", order, want)
+		}
+	}
+}
+
+func TestWorkerPool_BoundsConcurrency(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b, core.WithWorkerPool(2))
+
+	var running, peak atomic.Int64
+	handle := func(context.Context, core.Message) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}
+	r.Handle("orders", handle)
+	r.Handle("audit", handle, core.WithPriority(1))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		topic := "orders"
+		if i%2 == 1 {
+			topic = "audit"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = b.Deliver(context.Background(), topic, &mock.Message{T: topic})
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent handlers = %d, want the pool size 2", got)
+	}
+}
+
+func TestWorkerPool_ResultReachesBroker(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b, core.WithWorkerPool(1))
+	errInvalid := errors.New("invalid order")
+	r.Handle("orders", func(context.Context, core.Message) error { return errInvalid })
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	if err := b.Deliver(context.Background(), "orders", &mock.Message{T: "orders"}); !errors.Is(err, errInvalid) {
+		t.Errorf("deliver = %v, want the handler's error", err)
+	}
+}
+
+func TestWorkerPool_WithdrawsOnContextEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy core.ShutdownPolicy
+		nacked bool
+	}{
+		{"leave unsettled", core.ShutdownLeaveUnsettled, false},
+		{"nack", core.ShutdownNack, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := mock.NewBroker()
+			r := core.New(b, core.WithWorkerPool(1), core.WithShutdownPolicy(tt.policy))
+
+			started, gate := make(chan struct{}), make(chan struct{})
+			var handled atomic.Int64
+			r.Handle("orders", func(_ context.Context, msg core.Message) error {
+				if string(msg.Value()) == "block" {
+					close(started)
+					<-gate
+				}
+				handled.Add(1)
+				return nil
+			})
+
+			cancel := startRouter(t, r)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- b.Deliver(context.Background(), "orders", &mock.Message{T: "orders", V: []byte("block")})
+			}()
+			<-started
+
+			ctx, cancelMsg := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancelMsg()
+			msg := &mock.Message{T: "orders"}
+			if err := b.Deliver(ctx, "orders", msg); err != nil {
+				t.Errorf("deliver = %v, want the withdrawn message settled by the shutdown policy", err)
+			}
+			if msg.Acked || msg.Nacked != tt.nacked {
+				t.Errorf("withdrawn message acked=%v nacked=%v, want nacked=%v", msg.Acked, msg.Nacked, tt.nacked)
+			}
+			if n := r.Stats().Queued; n != 0 {
+				t.Errorf("Stats().Queued = %d after withdrawal, want 0", n)
+			}
+
+			close(gate)
+			if err := <-done; err != nil {
+				t.Fatalf("blocking deliver: %v", err)
+			}
+			if n := handled.Load(); n != 1 {
+				t.Errorf("handled %d messages, want only the one that was running", n)
+			}
+		})
+	}
+}
+
+// TestWorkerPool_SequentialDelivery delivers each route's messages one at a
+// time, as broker plugins do: priority picks between the routes' waiting
+// heads, and the queue never holds more than one message per route.
+func TestWorkerPool_SequentialDelivery(t *testing.T) {
+	b := mock.NewBroker()
+	r := core.New(b, core.WithWorkerPool(1))
+
+	var mu sync.Mutex
+	var order []string
+	var peak int
+	started, gate := make(chan struct{}), make(chan struct{})
+	record := func(_ context.Context, msg core.Message) error {
+		id := string(msg.Value())
+		if id == "block" {
+			close(started)
+			<-gate
+		}
+		mu.Lock()
+		order = append(order, id)
+		peak = max(peak, r.Stats().Queued)
+		mu.Unlock()
+		return nil
+	}
+	r.Handle("warmup", record)
+	r.Handle("clicks", record)
+	r.Handle("payments", record, core.WithPriority(10))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	go func() { _ = b.Deliver(context.Background(), "warmup", &mock.Message{T: "warmup", V: []byte("block")}) }()
+	<-started
+
+	var wg sync.WaitGroup
+	stream := func(topic string, ids ...string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, id := range ids {
+				if err := b.Deliver(context.Background(), topic, &mock.Message{T: topic, V: []byte(id)}); err != nil {
+					t.Errorf("deliver %s: %v", id, err)
+				}
+			}
+		}()
+	}
+	stream("clicks", "click-1", "click-2", "click-3")
+	waitQueued(t, r, 1)
+	stream("payments", "payment-1", "payment-2", "payment-3")
+	waitQueued(t, r, 2)
+	close(gate)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 7 || order[1] != "payment-1" {
+		t.Fatalf("handled %v, want payment-1 first after the blocking message", order)
+	}
+	if peak > 2 {
+		t.Errorf("queue reached %d messages, want at most one per route", peak)
+	}
+	var clicks, payments []string
+	for _, id := range order[1:] {
+		if id[0] == 'c' {
+			clicks = append(clicks, id)
+		} else {
+			payments = append(payments, id)
+		}
+	}
+	for i := range 3 {
+		if clicks[i] != fmt.Sprintf("click-%d", i+1) || payments[i] != fmt.Sprintf("payment-%d", i+1) {
+			t.Fatalf("handled %v, want each route in delivery order", order)
+		}
+	}
+}
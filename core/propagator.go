@@ -0,0 +1,33 @@
+package core
+
+import "context"
+
+// ContextPropagator carries request-scoped values, such as OpenTelemetry
+// baggage, between message headers and handler contexts. The Router
+// extracts them before each handler runs and injects them into every
+// message it publishes, so a handler that republishes or emits passes them
+// on without copying headers itself.
+type ContextPropagator interface {
+	// Extract returns ctx with the values carried by msg's headers.
+	Extract(ctx context.Context, msg Message) context.Context
+
+	// Inject returns msg with the values carried by ctx added as headers.
+	// It must not modify msg; use MergeHeaders.
+	Inject(ctx context.Context, msg Message) Message
+}
+
+// extract applies each propagator's Extract in registration order.
+func extract(ctx context.Context, msg Message, ps []ContextPropagator) context.Context {
+	for _, p := range ps {
+		ctx = p.Extract(ctx, msg)
+	}
+	return ctx
+}
+
+// inject applies each propagator's Inject in registration order.
+func inject(ctx context.Context, msg Message, ps []ContextPropagator) Message {
+	for _, p := range ps {
+		msg = p.Inject(ctx, msg)
+	}
+	return msg
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// PublishMode selects how Router.Publish hands messages to the broker.
+type PublishMode int
+
+const (
+	// PublishSync publishes on the caller's goroutine and returns the
+	// broker's error. It is the default.
+	PublishSync PublishMode = iota
+
+	// PublishBestEffort enqueues the message and returns immediately. A
+	// background goroutine publishes queued messages in order. When the
+	// queue is full the message is dropped; dropped messages and background
+	// publish failures are counted by Router.DroppedPublishes. Messages
+	// still queued when the router closes are discarded; EmitAsync reports
+	// them as ErrBrokerClosed.
+	PublishBestEffort
+)
+
+// defaultPublishBuffer is the best-effort queue size used when
+// WithPublishBuffer is not given.
+const defaultPublishBuffer = 1024
+
+type queuedPublish struct {
+	topic  string
+	msg    Message
+	result chan<- error // nil unless queued by EmitAsync
+
+	flushed chan struct{} // set instead of topic and msg by flush
+}
+
+// publishQueue is the background sender for PublishBestEffort.
+type publishQueue struct {
+	items   chan queuedPublish
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// mu orders sends on items against stop, so nothing is queued once
+	// run may have discarded the queue and returned.
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newPublishQueue(size int) *publishQueue {
+	if size < 1 {
+		size = defaultPublishBuffer
+	}
+	return &publishQueue{
+		items: make(chan queuedPublish, size),
+		done:  make(chan struct{}),
+	}
+}
+
+// enqueue adds a message without blocking, counting it as dropped if the
+// queue is full. If result is non-nil it receives the outcome. Once the
+// queue has stopped, the message is refused with ErrBrokerClosed.
+func (q *publishQueue) enqueue(topic string, msg Message, result chan<- error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		if result != nil {
+			result <- ErrBrokerClosed
+		}
+		return ErrBrokerClosed
+	}
+	select {
+	case q.items <- queuedPublish{topic: topic, msg: msg, result: result}:
+	default:
+		q.dropped.Add(1)
+		if result != nil {
+			result <- ErrPublishDropped
+		}
+	}
+	return nil
+}
+
+// run publishes queued messages with publish until stop is called.
+func (q *publishQueue) run(publish func(ctx context.Context, topic string, msg Message) error) {
+	for {
+		select {
+		case <-q.done:
+			q.discard()
+			return
+		case p := <-q.items:
+			if p.flushed != nil {
+				close(p.flushed)
+				continue
+			}
+			err := publish(context.Background(), p.topic, p.msg)
+			if err != nil {
+				q.dropped.Add(1)
+			}
+			if p.result != nil {
+				p.result <- err
+			}
+		}
+	}
+}
+
+// discard empties the queue, failing any pending EmitAsync results.
+func (q *publishQueue) discard() {
+	for {
+		select {
+		case p := <-q.items:
+			if p.flushed != nil {
+				close(p.flushed)
+			}
+			if p.result != nil {
+				p.result <- ErrBrokerClosed
+			}
+		default:
+			return
+		}
+	}
+}
+
+// flush waits until every message queued before the call has been
+// published, or ctx ends.
+func (q *publishQueue) flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	if err := q.send(ctx, queuedPublish{flushed: flushed}); err != nil {
+		return err
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// send queues p, waiting for room until ctx ends.
+func (q *publishQueue) send(ctx context.Context, p queuedPublish) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return ErrBrokerClosed
+	}
+	select {
+	case q.items <- p:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop makes run discard the queue and return. Later enqueues are refused.
+func (q *publishQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.done)
+	}
+}
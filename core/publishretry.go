@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// publishRetry is the configuration set by WithPublishRetry.
+type publishRetry struct {
+	maxAttempts int
+	backoff     BackoffStrategy
+	retryable   func(error) bool
+}
+
+// WithPublishRetry retries publishes that fail with a transient broker
+// error, such as a timeout during a leader election, up to maxAttempts
+// attempts in total. backoff gives the wait after each failed attempt; nil
+// uses ExponentialBackoff(100ms, 5s). retryable reports whether an error is
+// worth retrying; nil retries every error except ErrBrokerClosed,
+// ErrInvalidTopic and context errors. Waiting stops when the publish context
+// ends.
+//
+// It applies to Publish, EmitAsync and the PublishBestEffort sender. A
+// publish that timed out may still have reached the broker, so a retry can
+// duplicate it; pair retries with the broker's deduplication, e.g.
+// nats.WithDedupID, or with idempotent consumers.
+func WithPublishRetry(maxAttempts int, backoff BackoffStrategy, retryable func(error) bool) Option {
+	return func(r *Router) {
+		if backoff == nil {
+			backoff = ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+		}
+		if retryable == nil {
+			retryable = transientPublishError
+		}
+		r.publishRetry = publishRetry{maxAttempts: maxAttempts, backoff: backoff, retryable: retryable}
+	}
+}
+
+// transientPublishError is the default retryable predicate.
+func transientPublishError(err error) bool {
+	return !errors.Is(err, ErrBrokerClosed) &&
+		!errors.Is(err, ErrInvalidTopic) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// sender returns send bound to b, for the PublishBestEffort queue.
+func (r *Router) sender(b Broker) func(context.Context, string, Message) error {
+	return func(ctx context.Context, topic string, msg Message) error {
+		return r.send(ctx, b, topic, msg)
+	}
+}
+
+// send publishes msg through b, retrying as configured by WithPublishRetry.
+func (r *Router) send(ctx context.Context, b Broker, topic string, msg Message) error {
+	err := b.Publish(ctx, topic, msg)
+	pr := r.publishRetry
+	if err == nil || pr.maxAttempts <= 1 {
+		return err
+	}
+	attempt := 1
+	for ; attempt < pr.maxAttempts && pr.retryable(err); attempt++ {
+		timer := time.NewTimer(pr.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("eventmux: publish to %q: %w (last error: %v)", topic, ctx.Err(), err)
+		case <-timer.C:
+		}
+		if err = b.Publish(ctx, topic, msg); err == nil {
+			return nil
+		}
+	}
+	if attempt == 1 {
+		return err // not retryable
+	}
+	return fmt.Errorf("eventmux: publish to %q failed after %d attempts: %w", topic, attempt, err)
+}
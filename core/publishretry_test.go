@@ -0,0 +1,162 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+var errLeaderElection = errors.New("leader not available")
+
+// flakyBroker fails the first failures publishes with err.
+type flakyBroker struct {
+	*mock.Broker
+	mu       sync.Mutex
+	failures int
+	err      error
+	attempts int
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, topic string, msg core.Message) error {
+	b.mu.Lock()
+	b.attempts++
+	fail := b.attempts <= b.failures
+	b.mu.Unlock()
+	if fail {
+		return b.err
+	}
+	return b.Broker.Publish(ctx, topic, msg)
+}
+
+func (b *flakyBroker) Attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.attempts
+}
+
+func newFlakyBroker(failures int) *flakyBroker {
+	return &flakyBroker{Broker: mock.NewBroker(), failures: failures, err: errLeaderElection}
+}
+
+// recordBackoff returns a BackoffStrategy without delay that records the
+// attempts it was asked about.
+func recordBackoff(attempts *[]int) core.BackoffStrategy {
+	return func(attempt int) time.Duration {
+		*attempts = append(*attempts, attempt)
+		return 0
+	}
+}
+
+func TestPublishRetry_EventuallyPublishes(t *testing.T) {
+	b := newFlakyBroker(2)
+	var waits []int
+	r := core.New(b, core.WithPublishRetry(5, recordBackoff(&waits), nil))
+
+	if err := r.Publish(context.Background(), "orders", &mock.Message{V: []byte("o-1")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if got := b.Attempts(); got != 3 {
+		t.Errorf("publish attempts = %d, want 3", got)
+	}
+	if len(waits) != 2 || waits[0] != 1 || waits[1] != 2 {
+		t.Errorf("backoff asked for %v, want [1 2]", waits)
+	}
+	if pubs := b.PublishedTo("orders"); len(pubs) != 1 || string(pubs[0].Value) != "o-1" {
+		t.Errorf("published %v, want the message once", pubs)
+	}
+}
+
+func TestPublishRetry_GivesUp(t *testing.T) {
+	b := newFlakyBroker(10)
+	var waits []int
+	r := core.New(b, core.WithPublishRetry(3, recordBackoff(&waits), nil))
+
+	err := r.Publish(context.Background(), "orders", &mock.Message{})
+	if !errors.Is(err, errLeaderElection) || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Errorf("publish = %v, want the last error after 3 attempts", err)
+	}
+	if got := b.Attempts(); got != 3 {
+		t.Errorf("publish attempts = %d, want 3", got)
+	}
+}
+
+func TestPublishRetry_NotRetryable(t *testing.T) {
+	b := newFlakyBroker(10)
+	r := core.New(b, core.WithPublishRetry(5, func(int) time.Duration { return 0 },
+		func(err error) bool { return !errors.Is(err, errLeaderElection) }))
+
+	if err := r.Publish(context.Background(), "orders", &mock.Message{}); err != errLeaderElection {
+		t.Errorf("publish = %v, want the broker error unchanged", err)
+	}
+	if got := b.Attempts(); got != 1 {
+		t.Errorf("publish attempts = %d, want 1", got)
+	}
+}
+
+func TestPublishRetry_DefaultSkipsPermanentErrors(t *testing.T) {
+	b := newFlakyBroker(10)
+	b.err = core.ErrBrokerClosed
+	r := core.New(b, core.WithPublishRetry(5, func(int) time.Duration { return 0 }, nil))
+
+	if err := r.Publish(context.Background(), "orders", &mock.Message{}); !errors.Is(err, core.ErrBrokerClosed) {
+		t.Errorf("publish = %v, want ErrBrokerClosed", err)
+	}
+	if got := b.Attempts(); got != 1 {
+		t.Errorf("publish attempts = %d, want 1", got)
+	}
+}
+
+func TestPublishRetry_ContextCancelled(t *testing.T) {
+	b := newFlakyBroker(10)
+	r := core.New(b, core.WithPublishRetry(5, func(int) time.Duration { return time.Hour }, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := r.Publish(ctx, "orders", &mock.Message{})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), errLeaderElection.Error()) {
+		t.Errorf("publish = %v, want the context error and the last publish error", err)
+	}
+	if got := b.Attempts(); got != 1 {
+		t.Errorf("publish attempts = %d, want 1", got)
+	}
+}
+
+func TestPublishRetry_EmitAsyncAndBestEffort(t *testing.T) {
+	noWait := func(int) time.Duration { return 0 }
+
+	b := newFlakyBroker(2)
+	r := core.New(b, core.WithPublishRetry(3, noWait, nil))
+	if err := <-r.EmitAsync(context.Background(), "orders", &mock.Message{}); err != nil {
+		t.Errorf("EmitAsync: %v", err)
+	}
+
+	b = newFlakyBroker(2)
+	r = core.New(b, core.WithPublishRetry(3, noWait, nil), core.WithPublishMode(core.PublishBestEffort))
+	defer r.Close()
+	if err := <-r.EmitAsync(context.Background(), "orders", &mock.Message{}); err != nil {
+		t.Errorf("best-effort EmitAsync: %v", err)
+	}
+	if n := r.DroppedPublishes(); n != 0 {
+		t.Errorf("dropped %d publishes, want 0", n)
+	}
+	if len(b.PublishedTo("orders")) != 1 {
+		t.Error("best-effort message was not published")
+	}
+}
+
+func TestPublishRetry_DisabledByDefault(t *testing.T) {
+	b := newFlakyBroker(1)
+	r := core.New(b)
+	if err := r.Publish(context.Background(), "orders", &mock.Message{}); err != errLeaderElection {
+		t.Errorf("publish = %v, want the broker error", err)
+	}
+	if got := b.Attempts(); got != 1 {
+		t.Errorf("publish attempts = %d, want 1", got)
+	}
+}
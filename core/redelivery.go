@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// WithMaxRedeliveries dead-letters a message to dlqTopic when it fails
+// again after n redeliveries, i.e. on its attempt n+1, the same way on
+// every broker. A failure is a plain error or NackResult. Before the limit
+// is reached:
+//   - Messages whose broker counts deliveries (AttemptReader, as on NATS and
+//     RabbitMQ quorum queues, see DeliveryCounter) are left to the broker to
+//     redeliver.
+//   - Other messages, e.g. from Kafka or classic RabbitMQ queues, are
+//     republished to the topic they were received on with HeaderAttempt
+//     incremented, and the original is acked. They must implement
+//     TopicReader; otherwise the broker's semantics apply.
+//
+// An empty dlqTopic uses the topic set with WithDeadLetterTopic. The
+// dead-letter copy carries HeaderDeadLetterReason and HeaderAttempt.
+func WithMaxRedeliveries(n int, dlqTopic string) Option {
+	return func(r *Router) {
+		r.maxRedeliveries = n
+		r.redeliveryTopic = dlqTopic
+	}
+}
+
+// redeliverable reports whether err is a failure WithMaxRedeliveries counts.
+func redeliverable(err error) bool {
+	if err == nil {
+		return false
+	}
+	res, ok := asResult(err)
+	return !ok || res.action == resolveNack
+}
+
+// redeliver settles a failed msg under WithMaxRedeliveries.
+func (r *Router) redeliver(ctx context.Context, msg Message, err error) error {
+	attempt := Attempt(msg)
+	if attempt > r.maxRedeliveries {
+		topic := r.redeliveryTopic
+		if topic == "" {
+			topic = r.deadLetterTopic
+		}
+		if topic == "" {
+			return ErrNoDeadLetterTopic
+		}
+		reason := fmt.Sprintf("eventmux: gave up after %d deliveries: %v", attempt, err)
+		return r.deadLetterTo(ctx, topic, msg, reason)
+	}
+
+	topic := Topic(msg)
+	if countsDeliveries(msg) || topic == "" {
+		if res, ok := asResult(err); ok && res.action == resolveNack {
+			return r.nack(msg)
+		}
+		return err
+	}
+	retry := MergeHeaders(msg, map[string]string{HeaderAttempt: strconv.Itoa(attempt + 1)})
+	if perr := r.Publish(ctx, topic, retry); perr != nil {
+		// Leave the message to the broker's own redelivery.
+		return fmt.Errorf("eventmux: redeliver to %q: %w (handler error: %v)", topic, perr, err)
+	}
+	return msg.Ack()
+}
+
+// countsDeliveries reports whether msg's broker tracks its delivery count.
+// Wrappers that forward Attempt for every message, such as the ingress
+// header filter, implement DeliveryCounter to report their original's.
+func countsDeliveries(msg Message) bool {
+	if dc, ok := msg.(DeliveryCounter); ok {
+		return dc.CountsDeliveries()
+	}
+	_, ok := msg.(AttemptReader)
+	return ok
+}
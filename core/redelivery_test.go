@@ -0,0 +1,172 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+var errPoison = errors.New("poison")
+
+// countedMessage is a message whose broker tracks deliveries natively.
+type countedMessage struct {
+	mock.Message
+	attempt int
+}
+
+func (m *countedMessage) Attempt() int { return m.attempt }
+
+func TestMaxRedeliveries_HeaderTracked(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithMaxRedeliveries(2, "orders.dlq"))
+	var calls atomic.Int32
+	r.Handle("orders", func(context.Context, core.Message) error {
+		calls.Add(1)
+		return errPoison
+	})
+	defer startRouter(t, r)()
+
+	// Deliver the original, then every copy the router republishes to
+	// "orders", as a broker without delivery counts would.
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{"trace": "t1"}, T: "orders"}
+	var settled []*mock.Message
+	for i := 0; i < 5; i++ {
+		if err := mb.Deliver(context.Background(), "orders", msg); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+		settled = append(settled, msg)
+		pubs := mb.PublishedTo("orders")
+		if len(pubs) == i {
+			break
+		}
+		msg = &mock.Message{V: pubs[i].Value, H: pubs[i].Headers, T: "orders"}
+	}
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("handler ran %d times, want 3 (1 + 2 redeliveries)", got)
+	}
+	for i, m := range settled {
+		if !m.Acked || m.Nacked {
+			t.Errorf("delivery %d acked=%v nacked=%v, want acked", i+1, m.Acked, m.Nacked)
+		}
+	}
+	dlq := mb.PublishedTo("orders.dlq")
+	if len(dlq) != 1 {
+		t.Fatalf("dead-lettered %d messages, want 1", len(dlq))
+	}
+	if got := dlq[0].Header(core.HeaderAttempt); got != "3" {
+		t.Errorf("dead-letter attempt = %q, want 3", got)
+	}
+	if reason := dlq[0].Header(core.HeaderDeadLetterReason); !strings.Contains(reason, "gave up after 3 deliveries: poison") {
+		t.Errorf("dead-letter reason = %q", reason)
+	}
+	if dlq[0].Header("trace") != "t1" {
+		t.Error("dead-letter copy should keep the original headers")
+	}
+}
+
+func TestMaxRedeliveries_BrokerCounted(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("dead"), core.WithMaxRedeliveries(2, ""))
+	r.Handle("orders", func(context.Context, core.Message) error { return core.NackResult() })
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		msg := &countedMessage{Message: mock.Message{V: []byte("o"), T: "orders"}, attempt: attempt}
+		if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+		if last := attempt == 3; msg.Acked != last || msg.Nacked == last {
+			t.Errorf("attempt %d acked=%v nacked=%v", attempt, msg.Acked, msg.Nacked)
+		}
+	}
+	if n := len(mb.PublishedTo("orders")); n != 0 {
+		t.Errorf("republished %d messages; the broker redelivers counted messages", n)
+	}
+	if n := len(mb.PublishedTo("dead")); n != 1 {
+		t.Errorf("dead-lettered %d messages to the router's dead-letter topic, want 1", n)
+	}
+}
+
+// uncountedMessage reports an attempt its broker did not actually count,
+// like a requeued delivery from a classic RabbitMQ queue.
+type uncountedMessage struct {
+	countedMessage
+}
+
+func (m *uncountedMessage) CountsDeliveries() bool { return false }
+
+func TestMaxRedeliveries_UncountedAttemptsRepublished(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithMaxRedeliveries(2, "orders.dlq"))
+	r.Handle("orders", func(context.Context, core.Message) error { return core.NackResult() })
+
+	msg := &uncountedMessage{countedMessage{Message: mock.Message{V: []byte("o"), T: "orders"}, attempt: 2}}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Acked || msg.Nacked {
+		t.Errorf("acked=%v nacked=%v, want the original acked", msg.Acked, msg.Nacked)
+	}
+	pubs := mb.PublishedTo("orders")
+	if len(pubs) != 1 || pubs[0].Headers[core.HeaderAttempt] != "3" {
+		t.Errorf("republished %v, want one copy on attempt 3", pubs)
+	}
+}
+
+func TestMaxRedeliveries_PlainErrorKeptForCountedMessages(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithMaxRedeliveries(1, "dead"))
+	r.Handle("orders", func(context.Context, core.Message) error { return errPoison })
+
+	msg := &countedMessage{Message: mock.Message{T: "orders"}, attempt: 1}
+	if err := r.Dispatch(context.Background(), "orders", msg); !errors.Is(err, errPoison) {
+		t.Errorf("dispatch = %v, want the handler error for the broker to redeliver", err)
+	}
+}
+
+func TestMaxRedeliveries_AckAndDLQResultsUnaffected(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("dead"), core.WithMaxRedeliveries(1, "redelivery.dlq"))
+	r.Handle("ok", func(context.Context, core.Message) error { return core.AckResult() })
+	r.Handle("bad", func(context.Context, core.Message) error { return core.DLQResult("bad payload") })
+
+	ok := &mock.Message{T: "ok", H: map[string]string{core.HeaderAttempt: "5"}}
+	if err := r.Dispatch(context.Background(), "ok", ok); err != nil || !ok.Acked {
+		t.Errorf("ack: err=%v acked=%v", err, ok.Acked)
+	}
+	bad := &mock.Message{T: "bad"}
+	if err := r.Dispatch(context.Background(), "bad", bad); err != nil {
+		t.Fatalf("dlq: %v", err)
+	}
+	if len(mb.PublishedTo("dead")) != 1 || len(mb.PublishedTo("redelivery.dlq")) != 0 {
+		t.Error("DLQResult should use the router's dead-letter topic")
+	}
+}
+
+func TestMaxRedeliveries_NoDeadLetterTopic(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithMaxRedeliveries(1, ""))
+	r.Handle("orders", func(context.Context, core.Message) error { return errPoison })
+
+	msg := &mock.Message{T: "orders", H: map[string]string{core.HeaderAttempt: "2"}}
+	if err := r.Dispatch(context.Background(), "orders", msg); !errors.Is(err, core.ErrNoDeadLetterTopic) {
+		t.Errorf("dispatch = %v, want ErrNoDeadLetterTopic", err)
+	}
+}
+
+func TestMaxRedeliveries_IngressFilterDoesNotHideTracking(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithMaxRedeliveries(1, "dead"), core.WithIngressHeaderFilter(core.AllowHeaders("trace")))
+	r.Handle("orders", func(context.Context, core.Message) error { return errPoison })
+
+	msg := &mock.Message{T: "orders"}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if pubs := mb.PublishedTo("orders"); len(pubs) != 1 || pubs[0].Header(core.HeaderAttempt) != "2" {
+		t.Errorf("republished %v, want one copy with attempt 2", pubs)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MiddlewareFactory builds a Middleware from configuration parameters.
+// params may be nil.
+type MiddlewareFactory func(params map[string]any) (Middleware, error)
+
+var (
+	mwMu        sync.RWMutex
+	mwFactories = make(map[string]MiddlewareFactory)
+)
+
+// RegisterMiddleware adds a named middleware factory so it can be attached
+// from configuration with Router.UseByName. Built-in middleware registers
+// itself when github.com/miladsoleymani/eventmux/core/middleware is imported.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	mwMu.Lock()
+	defer mwMu.Unlock()
+	mwFactories[name] = factory
+}
+
+// BuildMiddleware instantiates a registered middleware by name.
+func BuildMiddleware(name string, params map[string]any) (Middleware, error) {
+	mwMu.RLock()
+	f, ok := mwFactories[name]
+	mwMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("eventmux: unknown middleware %q (registered: %s)", name, strings.Join(registeredMiddleware(), ", "))
+	}
+	mw, err := f(params)
+	if err != nil {
+		return nil, fmt.Errorf("eventmux: build middleware %q: %w", name, err)
+	}
+	return mw, nil
+}
+
+func registeredMiddleware() []string {
+	mwMu.RLock()
+	defer mwMu.RUnlock()
+	names := make([]string, 0, len(mwFactories))
+	for name := range mwFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseByName builds the named middleware with params and registers it as
+// with Use.
+func (r *Router) UseByName(name string, params map[string]any) error {
+	mw, err := BuildMiddleware(name, params)
+	if err != nil {
+		return err
+	}
+	r.Use(mw)
+	return nil
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RequireHeaders rejects messages on the route that lack any of keys, or
+// carry them empty, before the handler runs. The rejection is a Result:
+// with a dead-letter topic (see WithDeadLetterTopic) the message is
+// dead-lettered with the missing keys as its reason; without one, an error
+// wrapping ErrMissingHeaders is returned to the broker. Middleware still
+// runs, so it can log or count rejected messages.
+func RequireHeaders(keys ...string) RouteOption {
+	return func(c *routeConfig) { c.requiredHeaders = append(c.requiredHeaders, keys...) }
+}
+
+// requireHeaders wraps h to reject messages missing any of keys.
+func (r *Router) requireHeaders(keys []string, h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		var missing []string
+		for _, k := range keys {
+			if Header(msg, k) == "" {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) == 0 {
+			return h(ctx, msg)
+		}
+		err := fmt.Errorf("%w: %s", ErrMissingHeaders, strings.Join(missing, ", "))
+		if r.deadLetterTopic != "" {
+			return DLQResult(err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestRequireHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantHandle bool
+		wantReason string
+	}{
+		{"complete", map[string]string{"tenant-id": "acme", "schema-version": "2"}, true, ""},
+		{"one missing", map[string]string{"tenant-id": "acme"}, false, "eventmux: missing required headers: schema-version"},
+		{"empty counts as missing", map[string]string{"tenant-id": "", "schema-version": "2"}, false, "eventmux: missing required headers: tenant-id"},
+		{"all missing", nil, false, "eventmux: missing required headers: tenant-id, schema-version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := mock.NewBroker()
+			r := core.New(mb, core.WithDeadLetterTopic("orders.dlq"))
+			handled := false
+			r.Handle("orders", func(context.Context, core.Message) error {
+				handled = true
+				return core.AckResult()
+			}, core.RequireHeaders("tenant-id", "schema-version"))
+
+			msg := &mock.Message{H: tt.headers}
+			if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+				t.Fatalf("dispatch: %v", err)
+			}
+			if handled != tt.wantHandle {
+				t.Errorf("handled = %v, want %v", handled, tt.wantHandle)
+			}
+			if !msg.Acked {
+				t.Error("message should be acked, by the handler or after dead-lettering")
+			}
+			dlq := mb.PublishedTo("orders.dlq")
+			if tt.wantReason == "" {
+				if len(dlq) != 0 {
+					t.Errorf("dead-lettered %d messages, want none", len(dlq))
+				}
+				return
+			}
+			if len(dlq) != 1 || dlq[0].Header(core.HeaderDeadLetterReason) != tt.wantReason {
+				t.Errorf("dead-letter = %+v, want reason %q", dlq, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRequireHeaders_NoDeadLetterTopic(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	r.Handle("orders", func(context.Context, core.Message) error {
+		t.Error("handler ran for a message missing required headers")
+		return nil
+	}, core.RequireHeaders("tenant-id"))
+
+	msg := &mock.Message{}
+	err := r.Dispatch(context.Background(), "orders", msg)
+	if !errors.Is(err, core.ErrMissingHeaders) {
+		t.Errorf("Dispatch = %v, want ErrMissingHeaders", err)
+	}
+	if msg.Acked || msg.Nacked {
+		t.Error("the broker should settle the rejected message")
+	}
+}
+
+func TestRequireHeaders_MiddlewareSeesRejection(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	var seen error
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			seen = next(ctx, msg)
+			return seen
+		}
+	})
+	r.Handle("orders", func(context.Context, core.Message) error { return nil }, core.RequireHeaders("tenant-id"))
+
+	_ = r.Dispatch(context.Background(), "orders", &mock.Message{})
+	if !errors.Is(seen, core.ErrMissingHeaders) {
+		t.Errorf("middleware saw %v, want ErrMissingHeaders", seen)
+	}
+}
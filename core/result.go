@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// resolution is the action the Router takes to settle a message.
+type resolution int
+
+const (
+	resolveAck resolution = iota + 1
+	resolveNack
+	resolveDeadLetter
+)
+
+// Result is a structured handler outcome. Instead of calling Ack/Nack and
+// returning an error, a handler can return one of AckResult, NackResult or
+// DLQResult and let the Router settle the message accordingly.
+//
+// Result implements error so it travels through the regular Handler return
+// path; a plain error keeps its existing meaning (processing failure, left to
+// the broker's redelivery semantics).
+type Result struct {
+	action resolution
+	reason string
+}
+
+// Ack and Nack results carry no state, so they are shared to keep the
+// dispatch path allocation-free.
+var (
+	ackResult  = &Result{action: resolveAck}
+	nackResult = &Result{action: resolveNack}
+)
+
+// AckResult tells the Router to acknowledge the message.
+func AckResult() error {
+	return ackResult
+}
+
+// NackResult tells the Router to negatively acknowledge the message so the
+// broker redelivers it.
+func NackResult() error {
+	return nackResult
+}
+
+// DLQResult tells the Router to republish the message to the dead-letter
+// topic (see WithDeadLetterTopic) with the given reason, then acknowledge it.
+func DLQResult(reason string) error {
+	return &Result{action: resolveDeadLetter, reason: reason}
+}
+
+func (r *Result) Error() string {
+	switch r.action {
+	case resolveAck:
+		return "eventmux: ack"
+	case resolveNack:
+		return "eventmux: nack"
+	case resolveDeadLetter:
+		return "eventmux: dead-letter: " + r.reason
+	default:
+		return "eventmux: unknown result"
+	}
+}
+
+// Reason returns the reason given to DLQResult, if any.
+func (r *Result) Reason() string { return r.reason }
+
+// Failed reports whether a handler return value represents a processing
+// failure. nil and AckResult are successes; everything else, including
+// NackResult and DLQResult, is a failure.
+func Failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	if res, ok := asResult(err); ok {
+		return res.action != resolveAck
+	}
+	return true
+}
+
+// asResult extracts a Result from err. The direct type assertion covers the
+// common unwrapped case without the allocation errors.As incurs.
+func asResult(err error) (*Result, bool) {
+	if err == nil {
+		return nil, false
+	}
+	if res, ok := err.(*Result); ok {
+		return res, true
+	}
+	var res *Result
+	if errors.As(err, &res) {
+		return res, true
+	}
+	return nil, false
+}
+
+// Bytes adapts a function that only needs the payload into a Handler. A nil
+// return acks the message and an error nacks it, through AckResult and
+// NackResult, so the Router settles it. The error is wrapped in the nack
+// result, so middleware still sees it.
+func Bytes(fn func(ctx context.Context, value []byte) error) Handler {
+	return func(ctx context.Context, msg Message) error {
+		if err := fn(ctx, msg.Value()); err != nil {
+			return fmt.Errorf("%w: %w", NackResult(), err)
+		}
+		return AckResult()
+	}
+}
+
+// Settle applies a handler's return value to msg the way the Router does,
+// for middleware that runs the handler outside the Router's call, e.g. on
+// a timer: AckResult acks, NackResult nacks and DLQResult dead-letters
+// through the Router in ctx. Because no broker sees the return value, a
+// plain error also nacks msg; it is returned unless settling fails. nil
+// leaves msg as the handler settled it.
+func Settle(ctx context.Context, msg Message, err error) error {
+	if err == nil {
+		return nil
+	}
+	res, ok := asResult(err)
+	if !ok {
+		if nerr := msg.Nack(); nerr != nil {
+			return nerr
+		}
+		return err
+	}
+	switch res.action {
+	case resolveAck:
+		return msg.Ack()
+	case resolveNack:
+		return msg.Nack()
+	case resolveDeadLetter:
+		return DeadLetter(ctx, msg, res.reason)
+	default:
+		return err
+	}
+}
@@ -3,35 +3,111 @@ package core
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Router is the central message routing engine. It provides an Echo-like API
 // for registering topic handlers and middleware.
 type Router struct {
 	broker      Broker
+	borrowed    bool // broker is owned by the Router this was cloned from
 	middlewares []Middleware
+	raw         []Middleware
+	publishers  []PublishInterceptor
 	routes      map[string]Handler
+	routeOpts   map[string]routeConfig
+	trie        patternTrie
+	after       map[string]string
+	fallback    Handler
+	onReconnect []func(ReconnectEvent)
 	matcher     TopicMatcher
 	mu          sync.RWMutex
 	started     bool
+	closed      bool
+
+	deadLetterTopic string
+	binder          Binder
+	publishMode     PublishMode
+	publishBuffer   int
+	publishQueue    *publishQueue
+	ingressFilter   HeaderFilter
+	egressFilter    HeaderFilter
+	closeTimeout    time.Duration
+	framer          Framer
+	validateTopic   TopicValidator
+	nackBackoff     BackoffStrategy
+	dispatchMode    DispatchMode
+	goroutineLimit  int
+	propagators     []ContextPropagator
+	shutdownPolicy  ShutdownPolicy
+	topicAllowlist  []string
+	maxRedeliveries int
+	redeliveryTopic string
+	logUnmatched    bool
+	unmatchedLimit  int
+	publishRetry    publishRetry
+	workers         int
+
+	goroutines     atomic.Int64
+	subscriptions  atomic.Int64
+	unmatchedCount atomic.Int64
+	queued         atomic.Int64
+	unmatched      unmatchedTopics
+
+	// Set by Start for StopConsuming.
+	stopSubs context.CancelFunc
+	subsDone chan struct{}
+	inflight inflight
 }
 
 // New creates a Router bound to the given Broker.
 // It uses DefaultMatcher for topic matching.
-func New(b Broker) *Router {
-	return &Router{
+func New(b Broker, opts ...Option) *Router {
+	r := &Router{
 		broker:  b,
 		routes:  make(map[string]Handler),
 		matcher: DefaultMatcher{},
+
+		publishBuffer: defaultPublishBuffer,
+		validateTopic: ValidateTopic,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.publishMode == PublishBestEffort && b != nil {
+		r.publishQueue = newPublishQueue(r.publishBuffer)
+		r.spawn(func() { r.publishQueue.run(r.sender(b)) })
 	}
+	return r
 }
 
-// SetMatcher replaces the topic matcher. Must be called before Start.
-func (r *Router) SetMatcher(m TopicMatcher) {
+// SetMatcher replaces the topic matcher. It returns ErrAlreadyStarted once
+// Start has been called, since the matcher is fixed for the running router.
+func (r *Router) SetMatcher(m TopicMatcher) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.started {
+		return ErrAlreadyStarted
+	}
 	r.matcher = m
+	return nil
+}
+
+// SetBinder replaces the Binder used by Bind, like WithBinder. It returns
+// ErrAlreadyStarted once Start has been called.
+func (r *Router) SetBinder(b Binder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return ErrAlreadyStarted
+	}
+	r.binder = b
+	return nil
 }
 
 // Use registers global middleware. Middleware is applied in reverse
@@ -42,29 +118,365 @@ func (r *Router) Use(m Middleware) {
 	r.middlewares = append(r.middlewares, m)
 }
 
-// Handle registers a handler for a topic pattern.
-func (r *Router) Handle(topic string, h Handler) {
+// UsePublisher registers an interceptor applied to every message the Router
+// publishes, including fan-out and dead-letter copies. Interceptors run in
+// registration order.
+func (r *Router) UsePublisher(i PublishInterceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.publishers = append(r.publishers, i)
+}
+
+// UseRaw registers middleware that wraps the handler the broker calls
+// directly, outside route matching, param capture, binder injection and
+// Result resolution. Raw middleware sees every delivered message and the
+// final error returned to the broker, which makes it the place for
+// transport-level concerns such as metrics or trace extraction. Raw
+// middleware is applied in the same order as Use.
+func (r *Router) UseRaw(m Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.raw = append(r.raw, m)
+}
+
+// Handle registers a handler for a topic pattern. opts configure the route's
+// subscription, e.g. WithIdleTimeout. Registering a pattern again replaces
+// both its handler and its options.
+func (r *Router) Handle(topic string, h Handler, opts ...RouteOption) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	delete(r.after, topic)
+	if len(opts) == 0 {
+		r.addRoute(topic, h)
+		delete(r.routeOpts, topic)
+		return
+	}
+	var cfg routeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(cfg.requiredHeaders) > 0 {
+		h = r.requireHeaders(cfg.requiredHeaders, h)
+	}
+	r.addRoute(topic, h)
+	if r.routeOpts == nil {
+		r.routeOpts = make(map[string]routeConfig)
+	}
+	r.routeOpts[topic] = cfg
+}
+
+// HandleN registers h for topic like Handle, for tools that inspect the next
+// n messages and exit. Once h has handled n messages, every subscription
+// stops as with StopConsuming and Start returns nil; call Close to finish.
+// Messages delivered to the route after the n-th are rejected with
+// ErrConsumingStopped and left for redelivery. With n <= 0 the route is
+// unlimited, as with Handle.
+func (r *Router) HandleN(topic string, n int, h Handler, opts ...RouteOption) {
+	r.Handle(topic, h, append(opts, func(c *routeConfig) { c.limit = n })...)
+}
+
+// addRoute registers h for topic. Callers must hold r.mu.
+func (r *Router) addRoute(topic string, h Handler) {
 	r.routes[topic] = h
+	r.trie.insert(topic)
+}
+
+// Default registers a handler for messages that match no registered topic
+// pattern in Dispatch. It runs through the same middleware and Result
+// handling as any other route, so it must settle the message itself or
+// return a Result; a nil return leaves the message unacknowledged.
+func (r *Router) Default(h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = h
+}
+
+// Dispatch routes msg in-process as if it had arrived on topic. With
+// DefaultMatcher, the most specific matching pattern handles it: patterns
+// are compared level by level from the left, and at each level a literal
+// beats "*" or ":name", which beats "#". So with routes for
+// "orders.created" and "orders.#", "orders.created" goes to the former and
+// "orders.updated" to the latter. With a custom TopicMatcher, an exact
+// pattern is preferred, then the lexically first matching one. With
+// WithDispatchMode(DispatchAllMatching), every matching route runs instead.
+// If nothing matches, the Default handler runs; without one, Dispatch
+// returns ErrNoHandler and leaves the message unsettled.
+func (r *Router) Dispatch(ctx context.Context, topic string, msg Message) error {
+	r.mu.RLock()
+	if len(r.middlewares) == 0 && len(r.raw) == 0 && r.dispatchMode == DispatchMostSpecific {
+		// Fast path: with no middleware there is no chain to build, and
+		// nothing needs to outlive the call.
+		h, params := r.match(topic)
+		r.mu.RUnlock()
+		if h == nil {
+			return ErrNoHandler
+		}
+		return r.run(withParams(withBinder(ctx, r.binder), params), msg, h)
+	}
+	var matches []routeMatch
+	if r.dispatchMode == DispatchAllMatching {
+		matches = r.matchAll(topic)
+	} else if h, params := r.match(topic); h != nil {
+		matches = []routeMatch{{h: h, params: params}}
+	}
+	mws := make([]Middleware, len(r.middlewares))
+	copy(mws, r.middlewares)
+	raw := make([]Middleware, len(r.raw))
+	copy(raw, r.raw)
+	r.mu.RUnlock()
+
+	if len(matches) == 0 {
+		return ErrNoHandler
+	}
+	bridge := func(ctx context.Context, msg Message) error {
+		if len(matches) > 1 {
+			return r.runAll(ctx, msg, matches, mws)
+		}
+		ctx = withParams(withBinder(ctx, r.binder), matches[0].params)
+		return r.run(ctx, msg, applyMiddleware(matches[0].h, mws))
+	}
+	return applyMiddleware(bridge, raw)(ctx, msg)
+}
+
+// match returns the handler for topic and any params it captures, falling
+// back to the default handler and counting topic as unmatched. Callers must
+// hold r.mu.
+func (r *Router) match(topic string) (Handler, Params) {
+	if h, ok := r.routes[topic]; ok {
+		return h, nil
+	}
+	p, ok := r.matchPattern(topic)
+	if !ok {
+		r.recordUnmatched(topic)
+		return r.fallback, nil
+	}
+	var params Params
+	if hasCaptures(p) {
+		params, _ = captureParams(r.matcher, p, topic)
+	}
+	return r.routes[p], params
+}
+
+// matchPattern returns the registered pattern that handles topic: the most
+// specific one with DefaultMatcher, or the lexically first match with a
+// custom matcher. Callers must hold r.mu.
+func (r *Router) matchPattern(topic string) (string, bool) {
+	if _, ok := r.matcher.(DefaultMatcher); ok {
+		return r.trie.lookup(topic)
+	}
+	patterns := make([]string, 0, len(r.routes))
+	for p := range r.routes {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		if r.matcher.Match(p, topic) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// withCaptures wraps h to make the params captured by pattern available via
+// Param. It needs the message to implement TopicReader; otherwise the
+// handler runs without params.
+func withCaptures(m TopicMatcher, pattern string, h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		if params, ok := captureParams(m, pattern, Topic(msg)); ok {
+			ctx = withParams(ctx, params)
+		}
+		return h(ctx, msg)
+	}
+}
+
+// ReplayFrom runs h over the messages published to topic since the given
+// time, then returns once it has caught up. It uses a temporary consumer, so
+// regular subscriptions are unaffected and the router need not be started.
+// h runs through the router's middleware and Result handling like a route.
+// Replay stops at the first failing message and returns its error; it
+// returns ErrReplayUnsupported if the broker does not implement Replayer.
+func (r *Router) ReplayFrom(ctx context.Context, topic string, since time.Time, h Handler) error {
+	r.mu.RLock()
+	b := r.broker
+	mws := make([]Middleware, len(r.middlewares))
+	copy(mws, r.middlewares)
+	r.mu.RUnlock()
+
+	if b == nil {
+		return ErrNoBroker
+	}
+	rp, ok := b.(Replayer)
+	if !ok {
+		return ErrReplayUnsupported
+	}
+	wrapped := applyMiddleware(h, mws)
+	return rp.ReplayFrom(ctx, topic, since, func(ctx context.Context, msg Message) error {
+		ctx = withBinder(ctx, r.binder)
+		return r.run(ctx, msg, wrapped)
+	})
+}
+
+// Provision asks the broker to create the topics, streams or queues for
+// every registered route (see Provisioner). Call it before Start; running it
+// again is safe. It returns ErrProvisionUnsupported if the broker cannot
+// provision.
+func (r *Router) Provision(ctx context.Context) error {
+	r.mu.RLock()
+	b := r.broker
+	topics := make([]string, 0, len(r.routes))
+	for pattern := range r.routes {
+		topics = append(topics, subscriptionPattern(pattern))
+	}
+	r.mu.RUnlock()
+
+	if b == nil {
+		return ErrNoBroker
+	}
+	p, ok := b.(Provisioner)
+	if !ok {
+		return ErrProvisionUnsupported
+	}
+	sort.Strings(topics)
+	if err := p.Provision(ctx, topics); err != nil {
+		return fmt.Errorf("eventmux: provision: %w", err)
+	}
+	return nil
+}
+
+// OnReconnect registers fn to be called for every reconnect reported by the
+// broker while the router is running. It has no effect if the broker does
+// not implement Reconnecter. Must be called before Start.
+func (r *Router) OnReconnect(fn func(ReconnectEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReconnect = append(r.onReconnect, fn)
 }
 
 // Publish sends a message to the given topic through the broker.
+// It returns ErrNoBroker if the router has no broker, ErrBrokerClosed
+// once Start has returned and closed it, and ErrInvalidTopic for topics
+// rejected by the TopicValidator. With PublishBestEffort, Publish
+// only enqueues the message and never returns a broker error.
 func (r *Router) Publish(ctx context.Context, topic string, msg Message) error {
-	return r.broker.Publish(ctx, topic, msg)
+	b, msg, err := r.outbound(ctx, topic, msg)
+	if err != nil {
+		return err
+	}
+	if r.publishQueue != nil {
+		return r.publishQueue.enqueue(topic, msg, nil)
+	}
+	return r.send(ctx, b, topic, msg)
+}
+
+// EmitAsync publishes msg without blocking the caller and returns a channel
+// that receives the publish result once it is known: nil on success, or the
+// error. With PublishBestEffort the result arrives when the background
+// sender has published the message, ErrPublishDropped if the queue was full,
+// or ErrBrokerClosed if the router closed first. The channel is buffered, so
+// callers that lose interest need not drain it.
+func (r *Router) EmitAsync(ctx context.Context, topic string, msg Message) <-chan error {
+	result := make(chan error, 1)
+	b, msg, err := r.outbound(ctx, topic, msg)
+	switch {
+	case err != nil:
+		result <- err
+	case r.publishQueue != nil:
+		_ = r.publishQueue.enqueue(topic, msg, result)
+	default:
+		r.spawn(func() { result <- r.send(ctx, b, topic, msg) })
+	}
+	return result
+}
+
+// outbound checks that the router can publish and applies propagators,
+// publish interceptors and the egress header filter to msg.
+func (r *Router) outbound(ctx context.Context, topic string, msg Message) (Broker, Message, error) {
+	r.mu.RLock()
+	b, closed, publishers := r.broker, r.closed, r.publishers
+	r.mu.RUnlock()
+	if b == nil {
+		return nil, nil, ErrNoBroker
+	}
+	if closed {
+		return nil, nil, ErrBrokerClosed
+	}
+	if r.validateTopic != nil {
+		if err := r.validateTopic(topic); err != nil {
+			return nil, nil, err
+		}
+	}
+	msg = inject(ctx, msg, r.propagators)
+	for _, intercept := range publishers {
+		msg = intercept(topic, msg)
+	}
+	return b, filterHeaders(msg, r.egressFilter), nil
+}
+
+// DroppedPublishes returns how many best-effort messages were dropped
+// because the queue was full or the background publish failed.
+func (r *Router) DroppedPublishes() uint64 {
+	if r.publishQueue == nil {
+		return 0
+	}
+	return r.publishQueue.dropped.Load()
+}
+
+// PublishFanout publishes msg to each of topics in order. Publishing is best
+// effort: a failure on one topic does not stop the others. If any topic
+// fails, the returned *FanoutError reports which topics succeeded and why
+// the rest failed.
+func (r *Router) PublishFanout(ctx context.Context, topics []string, msg Message) error {
+	var fe *FanoutError
+	succeeded := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if err := r.Publish(ctx, topic, msg); err != nil {
+			if fe == nil {
+				fe = &FanoutError{Failed: make(map[string]error)}
+			}
+			fe.Failed[topic] = err
+			continue
+		}
+		succeeded = append(succeeded, topic)
+	}
+	if fe != nil {
+		fe.Succeeded = succeeded
+		return fe
+	}
+	return nil
 }
 
 // Start subscribes to all registered topic patterns and begins consuming
-// messages. It blocks until the context is cancelled or an error occurs.
+// messages. It blocks until the context is cancelled, StopConsuming is
+// called, or an error occurs.
 func (r *Router) Start(ctx context.Context) error {
+	wait, err := r.begin(ctx)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+// begin starts the subscriptions and returns a function that blocks until
+// consuming ends, with Start's result. Errors that prevent starting at all
+// are returned directly.
+func (r *Router) begin(ctx context.Context) (wait func() error, err error) {
 	r.mu.Lock()
 	if r.broker == nil {
 		r.mu.Unlock()
-		return ErrNoBroker
+		return nil, ErrNoBroker
 	}
 	if r.started {
 		r.mu.Unlock()
-		return ErrAlreadyStarted
+		return nil, ErrAlreadyStarted
+	}
+	if err := checkAllowlist(r.routes, r.topicAllowlist); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	signals, err := readySignals(r.routes, r.after)
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
 	}
 	r.started = true
 
@@ -73,11 +485,38 @@ func (r *Router) Start(ctx context.Context) error {
 	for k, v := range r.routes {
 		routes[k] = v
 	}
+	after := make(map[string]string, len(r.after))
+	for k, v := range r.after {
+		after[k] = v
+	}
+	routeOpts := make(map[string]routeConfig, len(r.routeOpts))
+	for k, v := range r.routeOpts {
+		routeOpts[k] = v
+	}
 	mws := make([]Middleware, len(r.middlewares))
 	copy(mws, r.middlewares)
+	raw := make([]Middleware, len(r.raw))
+	copy(raw, r.raw)
 	matcher := r.matcher
+	onReconnect := make([]func(ReconnectEvent), len(r.onReconnect))
+	copy(onReconnect, r.onReconnect)
+	subCtx, stopSubs := context.WithCancel(ctx)
+	r.stopSubs = stopSubs
+	r.subsDone = make(chan struct{})
 	r.mu.Unlock()
 
+	if rc, ok := r.broker.(Reconnecter); ok && len(onReconnect) > 0 {
+		r.spawn(func() { watchReconnects(ctx, rc.Reconnects(), onReconnect) })
+	}
+
+	var pool *scheduler
+	if r.workers > 0 {
+		pool = newScheduler(&r.queued, r.interrupted)
+		for range r.workers {
+			r.spawn(pool.work)
+		}
+	}
+
 	// Build the dispatching handler for each route
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(routes))
@@ -86,39 +525,256 @@ func (r *Router) Start(ctx context.Context) error {
 		wrapped := applyMiddleware(handler, mws)
 
 		dispatchHandler := func(ctx context.Context, msg Message) error {
-			return wrapped(ctx, msg)
+			ctx = withBinder(ctx, r.binder)
+			return r.run(ctx, msg, wrapped)
+		}
+		if s := signals[pattern]; s != nil {
+			dispatchHandler = withReady(s, dispatchHandler)
+		}
+		if hasCaptures(pattern) {
+			dispatchHandler = withCaptures(matcher, pattern, dispatchHandler)
+		}
+		dispatchHandler = r.inflight.track(applyMiddleware(dispatchHandler, raw))
+		if group := r.routeGroup(routeOpts[pattern].subscribe); group != "" {
+			dispatchHandler = withGroup(group, dispatchHandler)
+		}
+		if pool != nil {
+			dispatchHandler = pool.wrap(routeOpts[pattern].priority, dispatchHandler)
 		}
 
-		// For wildcard patterns, subscribe to the pattern and let the broker
-		// deliver matching messages. The matcher is used as a safety check.
-		_ = matcher // matcher available for future per-message filtering
+		routeCtx, cancelRoute := subCtx, context.CancelFunc(func() {})
+		var idle *idleTimer
+		if cfg := routeOpts[pattern]; cfg.idleTimeout > 0 {
+			routeCtx, cancelRoute = context.WithCancel(subCtx)
+			idle = &idleTimer{d: cfg.idleTimeout, onIdle: cancelRoute}
+			if cfg.stopOnIdle {
+				idle.onIdle = stopSubs
+			}
+			dispatchHandler = idle.wrap(dispatchHandler)
+		}
+		if cfg := routeOpts[pattern]; cfg.subscribe != (SubscribeOptions{}) {
+			routeCtx = WithSubscribeOptions(routeCtx, cfg.subscribe)
+		}
+		if cfg := routeOpts[pattern]; cfg.limit > 0 {
+			limit := &messageLimit{n: int64(cfg.limit), onLimit: stopSubs}
+			dispatchHandler = limit.wrap(dispatchHandler)
+		}
 
+		// For wildcard patterns, subscribe to the pattern and let the broker
+		// deliver matching messages.
+		var waitFor <-chan struct{}
+		if dep, ok := after[pattern]; ok {
+			waitFor = signals[dep].ch
+		}
+		p, h := subscriptionPattern(pattern), dispatchHandler
 		wg.Add(1)
-		go func(p string, h Handler) {
+		r.spawn(func() {
 			defer wg.Done()
-			if err := r.broker.Subscribe(ctx, p, h); err != nil {
+			defer cancelRoute()
+			if waitFor != nil {
+				select {
+				case <-waitFor:
+				case <-subCtx.Done():
+					return
+				}
+			}
+			if idle != nil {
+				idle.start()
+				defer idle.stop()
+			}
+			r.subscriptions.Add(1)
+			defer r.subscriptions.Add(-1)
+			if err := r.broker.Subscribe(routeCtx, p, h); err != nil {
 				errCh <- fmt.Errorf("eventmux: subscribe %q: %w", p, err)
 			}
-		}(pattern, dispatchHandler)
+		})
 	}
 
-	// Wait for context cancellation or subscription errors
-	go func() {
+	// Wait for context cancellation, StopConsuming or subscription errors
+	r.spawn(func() {
 		wg.Wait()
+		if pool != nil {
+			pool.stop()
+		}
+		close(r.subsDone)
 		close(errCh)
-	}()
+	})
 
-	select {
-	case <-ctx.Done():
-		return r.broker.Close()
-	case err := <-errCh:
-		if err != nil {
-			return err
+	return func() error {
+		defer stopSubs()
+		select {
+		case <-subCtx.Done():
+		case err := <-errCh:
+			if err != nil {
+				return err
+			}
+			// All subscriptions returned without error — wait for context
+			<-subCtx.Done()
+		}
+		if ctx.Err() == nil {
+			return nil // StopConsuming: the broker stays open until Close
 		}
-		// All subscriptions returned without error — wait for context
-		<-ctx.Done()
+		return r.close()
+	}, nil
+}
+
+// StopConsuming cancels all subscriptions and waits for in-flight handlers
+// to finish, but leaves the broker open so the service can keep publishing,
+// e.g. to flush derived events during a drain. Messages delivered after the
+// call are rejected with ErrConsumingStopped. Start returns nil once
+// consuming has stopped; call Close for full teardown. StopConsuming
+// returns ctx.Err() if ctx ends before the handlers have finished.
+func (r *Router) StopConsuming(ctx context.Context) error {
+	r.mu.RLock()
+	stop, subsDone := r.stopSubs, r.subsDone
+	r.mu.RUnlock()
+	if stop == nil {
+		return nil // not started
+	}
+	stop()
+	handlersDone := r.inflight.close()
+	for _, done := range []<-chan struct{}{subsDone, handlersDone} {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close stops publishing and closes the broker. It is the second phase of a
+// shutdown begun with StopConsuming; cancelling the context passed to Start
+// closes the router as well. Close is a no-op once the router is closed.
+// A Clone leaves the shared broker open.
+func (r *Router) Close() error {
+	r.mu.RLock()
+	stop := r.stopSubs
+	r.mu.RUnlock()
+	if stop != nil {
+		stop()
+	}
+	if r.broker == nil {
+		return ErrNoBroker
+	}
+	return r.close()
+}
+
+// close marks the router closed and closes the broker, unless the router
+// is a Clone and the broker belongs to another.
+func (r *Router) close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+	if r.publishQueue != nil {
+		r.publishQueue.stop()
+	}
+	if r.borrowed {
+		return nil
+	}
+	if r.closeTimeout <= 0 {
 		return r.broker.Close()
 	}
+
+	done := make(chan error, 1)
+	r.spawn(func() { done <- r.broker.Close() })
+	timer := time.NewTimer(r.closeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		log.Printf("[EventMux] broker close did not finish within %v; abandoning it", r.closeTimeout)
+		return ErrCloseTimeout
+	}
+}
+
+// run passes msg, with ingress headers filtered, to the wrapped route
+// handler and settles the outcome. Propagated values are extracted into ctx
+// before filtering. With a Framer, each frame is handled separately.
+func (r *Router) run(ctx context.Context, msg Message, h Handler) error {
+	ctx = extract(ctx, msg, r.propagators)
+	msg = filterHeaders(msg, r.ingressFilter)
+	if r.framer != nil {
+		return r.runFrames(ctx, msg, h)
+	}
+	ctx = r.withDeadLetter(ctx)
+	return r.resolve(ctx, msg, h(ctx, msg))
+}
+
+// resolve settles msg according to a Result returned by the handler chain.
+// Interrupted handlers are settled by the ShutdownPolicy. Other plain errors
+// are passed through unchanged so the broker applies its own failure
+// semantics.
+func (r *Router) resolve(ctx context.Context, msg Message, err error) error {
+	if Interrupted(ctx, err) {
+		return r.interrupted(msg)
+	}
+	if r.maxRedeliveries > 0 && redeliverable(err) {
+		return r.redeliver(ctx, msg, err)
+	}
+	res, ok := asResult(err)
+	if !ok {
+		return err
+	}
+	switch res.action {
+	case resolveAck:
+		return msg.Ack()
+	case resolveNack:
+		return r.nack(msg)
+	case resolveDeadLetter:
+		return r.deadLetter(ctx, msg, res.reason)
+	default:
+		return err
+	}
+}
+
+// deadLetter republishes msg to the dead-letter topic, recording the reason,
+// delivery attempt and any failed Bind, and acknowledges the original once
+// the copy has been published.
+func (r *Router) deadLetter(ctx context.Context, msg Message, reason string) error {
+	if r.deadLetterTopic == "" {
+		return ErrNoDeadLetterTopic
+	}
+	return r.deadLetterTo(ctx, r.deadLetterTopic, msg, reason)
+}
+
+// deadLetterTo is deadLetter with an explicit topic.
+func (r *Router) deadLetterTo(ctx context.Context, topic string, msg Message, reason string) error {
+	headers := map[string]string{
+		HeaderDeadLetterReason: reason,
+		HeaderAttempt:          strconv.Itoa(Attempt(msg)),
+	}
+	if be, ok := BindFailure(ctx); ok {
+		headers[HeaderBindError] = be.Error()
+	}
+	dlq := MergeHeaders(msg, headers)
+	if err := r.Publish(ctx, topic, dlq); err != nil {
+		return fmt.Errorf("eventmux: dead-letter to %q: %w", topic, err)
+	}
+	return msg.Ack()
+}
+
+// watchReconnects forwards reconnect events to the callbacks until ctx is
+// cancelled or the broker closes the channel.
+func watchReconnects(ctx context.Context, events <-chan ReconnectEvent, fns []func(ReconnectEvent)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			for _, fn := range fns {
+				fn(ev)
+			}
+		}
+	}
 }
 
 // applyMiddleware wraps a handler with middleware in reverse order.
@@ -2,6 +2,13 @@ package core_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -143,3 +150,1689 @@ func TestRouter_DoubleStart(t *testing.T) {
 		t.Errorf("expected ErrAlreadyStarted, got %v", err)
 	}
 }
+
+// startRouter runs r in the background and waits for it to subscribe.
+func startRouter(t *testing.T, r *core.Router) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { r.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	return cancel
+}
+
+func TestRouter_Results(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     error
+		wantAcked  bool
+		wantNacked bool
+		wantDLQ    bool
+	}{
+		{"ack", core.AckResult(), true, false, false},
+		{"nack", core.NackResult(), false, true, false},
+		{"dlq", core.DLQResult("bad payload"), true, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := mock.NewBroker()
+			r := core.New(mb, core.WithDeadLetterTopic("orders.dlq"))
+			r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+				return tt.result
+			})
+			cancel := startRouter(t, r)
+			defer cancel()
+
+			msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+			if err := mb.Deliver(context.Background(), "orders.created", msg); err != nil {
+				t.Fatalf("deliver: %v", err)
+			}
+
+			if msg.Acked != tt.wantAcked {
+				t.Errorf("Acked = %v, want %v", msg.Acked, tt.wantAcked)
+			}
+			if msg.Nacked != tt.wantNacked {
+				t.Errorf("Nacked = %v, want %v", msg.Nacked, tt.wantNacked)
+			}
+
+			pubs := mb.Published()
+			if !tt.wantDLQ {
+				if len(pubs) != 0 {
+					t.Fatalf("expected no published messages, got %d", len(pubs))
+				}
+				return
+			}
+			if len(pubs) != 1 {
+				t.Fatalf("expected 1 dead-lettered message, got %d", len(pubs))
+			}
+			if pubs[0].Topic != "orders.dlq" {
+				t.Errorf("dead-lettered to %q, want %q", pubs[0].Topic, "orders.dlq")
+			}
+			if got := pubs[0].Message.Headers()[core.HeaderDeadLetterReason]; got != "bad payload" {
+				t.Errorf("reason header = %q, want %q", got, "bad payload")
+			}
+		})
+	}
+}
+
+func TestRouter_ResultPlainError(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	boom := errors.New("boom")
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return boom
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := mb.Deliver(context.Background(), "orders.created", msg); err != boom {
+		t.Errorf("expected handler error to pass through, got %v", err)
+	}
+	if msg.Acked || msg.Nacked {
+		t.Error("plain error must not settle the message")
+	}
+}
+
+func TestRouter_ResultDLQWithoutTopic(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return core.DLQResult("bad payload")
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := mb.Deliver(context.Background(), "orders.created", msg); err != core.ErrNoDeadLetterTopic {
+		t.Errorf("expected ErrNoDeadLetterTopic, got %v", err)
+	}
+	if msg.Acked {
+		t.Error("message must not be acked when dead-lettering fails")
+	}
+}
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{core.AckResult(), false},
+		{core.NackResult(), true},
+		{core.DLQResult("x"), true},
+		{errors.New("boom"), true},
+	}
+	for _, tt := range tests {
+		if got := core.Failed(tt.err); got != tt.want {
+			t.Errorf("Failed(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_PublishNilBroker(t *testing.T) {
+	r := core.New(nil)
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.Publish(context.Background(), "out.topic", msg); err != core.ErrNoBroker {
+		t.Errorf("expected ErrNoBroker, got %v", err)
+	}
+}
+
+func TestRouter_PublishAfterClose(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.Publish(context.Background(), "out.topic", msg); err != core.ErrBrokerClosed {
+		t.Errorf("expected ErrBrokerClosed, got %v", err)
+	}
+	if len(mb.Published()) != 0 {
+		t.Error("nothing should reach the broker after close")
+	}
+}
+
+func TestRouter_PublishFanout(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	topics := []string{"audit.orders", "search.orders", "billing.orders"}
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.PublishFanout(context.Background(), topics, msg); err != nil {
+		t.Fatalf("fanout: %v", err)
+	}
+
+	pubs := mb.Published()
+	if len(pubs) != len(topics) {
+		t.Fatalf("expected %d published messages, got %d", len(topics), len(pubs))
+	}
+	for i, topic := range topics {
+		if pubs[i].Topic != topic {
+			t.Errorf("pubs[%d].Topic = %q, want %q", i, pubs[i].Topic, topic)
+		}
+		if pubs[i].Message != msg {
+			t.Errorf("pubs[%d] carried a different message", i)
+		}
+	}
+}
+
+func TestRouter_PublishFanoutPartialFailure(t *testing.T) {
+	boom := errors.New("boom")
+	mb := mock.NewBroker()
+	fb := &failingBroker{Broker: mb, fail: map[string]error{"search.orders": boom}}
+	r := core.New(fb)
+
+	topics := []string{"audit.orders", "search.orders", "billing.orders"}
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	err := r.PublishFanout(context.Background(), topics, msg)
+
+	var fe *core.FanoutError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FanoutError, got %v", err)
+	}
+	if !errors.Is(err, boom) {
+		t.Error("FanoutError should unwrap to the publish error")
+	}
+	if len(fe.Failed) != 1 || fe.Failed["search.orders"] != boom {
+		t.Errorf("Failed = %v, want only search.orders", fe.Failed)
+	}
+	want := []string{"audit.orders", "billing.orders"}
+	if len(fe.Succeeded) != len(want) || fe.Succeeded[0] != want[0] || fe.Succeeded[1] != want[1] {
+		t.Errorf("Succeeded = %v, want %v", fe.Succeeded, want)
+	}
+	if len(mb.Published()) != 2 {
+		t.Errorf("expected 2 published messages, got %d", len(mb.Published()))
+	}
+}
+
+// failingBroker fails Publish for selected topics.
+type failingBroker struct {
+	*mock.Broker
+	fail map[string]error
+}
+
+func (b *failingBroker) Publish(ctx context.Context, topic string, msg core.Message) error {
+	if err, ok := b.fail[topic]; ok {
+		return err
+	}
+	return b.Broker.Publish(ctx, topic, msg)
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	r := core.New(mock.NewBroker())
+
+	var got string
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		got = "exact"
+		return nil
+	})
+	r.Handle("orders.*", func(ctx context.Context, msg core.Message) error {
+		got = "wildcard"
+		return nil
+	})
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"orders.created", "exact"},
+		{"orders.updated", "wildcard"},
+	}
+	for _, tt := range tests {
+		got = ""
+		msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+		if err := r.Dispatch(context.Background(), tt.topic, msg); err != nil {
+			t.Fatalf("dispatch %q: %v", tt.topic, err)
+		}
+		if got != tt.want {
+			t.Errorf("dispatch %q ran %q handler, want %q", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_DispatchMostSpecific(t *testing.T) {
+	r := core.New(mock.NewBroker())
+
+	var got string
+	for _, pattern := range []string{"orders.#", "orders.*", "orders.created"} {
+		pattern := pattern
+		r.Handle(pattern, func(ctx context.Context, msg core.Message) error {
+			got = pattern
+			return nil
+		})
+	}
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"orders.created", "orders.created"},
+		{"orders.updated", "orders.*"},
+		{"orders.us.created", "orders.#"},
+	}
+	for _, tt := range tests {
+		got = ""
+		if err := r.Dispatch(context.Background(), tt.topic, &mock.Message{}); err != nil {
+			t.Fatalf("dispatch %q: %v", tt.topic, err)
+		}
+		if got != tt.want {
+			t.Errorf("dispatch %q ran %q, want %q", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_DispatchModes(t *testing.T) {
+	patterns := []string{"orders.#", "orders.*", "orders.created"}
+
+	tests := []struct {
+		mode core.DispatchMode
+		want string
+	}{
+		{core.DispatchMostSpecific, "orders.created"},
+		{core.DispatchAllMatching, "orders.#,orders.*,orders.created"},
+	}
+	for _, tt := range tests {
+		r := core.New(mock.NewBroker(), core.WithDispatchMode(tt.mode))
+		var ran []string
+		for _, pattern := range patterns {
+			pattern := pattern
+			r.Handle(pattern, func(ctx context.Context, msg core.Message) error {
+				ran = append(ran, pattern)
+				return core.AckResult()
+			})
+		}
+
+		msg := &mock.Message{}
+		if err := r.Dispatch(context.Background(), "orders.created", msg); err != nil {
+			t.Fatalf("mode %d: dispatch: %v", tt.mode, err)
+		}
+		if got := strings.Join(ran, ","); got != tt.want {
+			t.Errorf("mode %d: ran %s, want %s", tt.mode, got, tt.want)
+		}
+		if !msg.Acked || msg.Nacked {
+			t.Errorf("mode %d: acked=%v nacked=%v, want acked once", tt.mode, msg.Acked, msg.Nacked)
+		}
+	}
+}
+
+func TestRouter_DispatchAllMatchingSettlement(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name       string
+		audit      error
+		wantErr    error
+		wantAcked  bool
+		wantNacked bool
+		wantRan    int
+	}{
+		{"all ack", core.AckResult(), nil, true, false, 2},
+		{"one nacks", core.NackResult(), nil, false, true, 2},
+		{"one leaves it unsettled", nil, nil, false, false, 2},
+		{"plain error stops", errBoom, errBoom, false, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := core.New(mock.NewBroker(), core.WithDispatchMode(core.DispatchAllMatching))
+			ran := 0
+			r.Handle("#", func(ctx context.Context, msg core.Message) error {
+				ran++
+				return tt.audit
+			})
+			r.Handle("orders.:event", func(ctx context.Context, msg core.Message) error {
+				ran++
+				if core.Param(ctx, "event") != "created" {
+					t.Errorf("param event = %q", core.Param(ctx, "event"))
+				}
+				return msg.Ack()
+			})
+
+			msg := &mock.Message{}
+			err := r.Dispatch(context.Background(), "orders.created", msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if msg.Acked != tt.wantAcked || msg.Nacked != tt.wantNacked {
+				t.Errorf("acked=%v nacked=%v, want %v/%v", msg.Acked, msg.Nacked, tt.wantAcked, tt.wantNacked)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("ran %d handlers, want %d", ran, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestRouter_DispatchUnmatched(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return nil
+	})
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.Dispatch(context.Background(), "payments.created", msg); err != core.ErrNoHandler {
+		t.Errorf("expected ErrNoHandler, got %v", err)
+	}
+	if msg.Acked || msg.Nacked {
+		t.Error("unmatched message must not be settled")
+	}
+}
+
+func TestRouter_Default(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		t.Error("route handler should not run for an unmatched topic")
+		return nil
+	})
+
+	var called bool
+	r.Default(func(ctx context.Context, msg core.Message) error {
+		called = true
+		return core.AckResult()
+	})
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.Dispatch(context.Background(), "payments.created", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if !called {
+		t.Error("default handler was not called")
+	}
+	if !msg.Acked {
+		t.Error("AckResult from default handler should ack the message")
+	}
+}
+
+func TestRouter_OnReconnect(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	got := make(chan core.ReconnectEvent, 1)
+	r.OnReconnect(func(ev core.ReconnectEvent) { got <- ev })
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	want := core.ReconnectEvent{Broker: "mock", Time: time.Now(), Attempt: 2}
+	mb.EmitReconnect(want)
+
+	select {
+	case ev := <-got:
+		if ev.Broker != want.Broker || ev.Attempt != want.Attempt || !ev.Time.Equal(want.Time) {
+			t.Errorf("got %+v, want %+v", ev, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnReconnect callback did not fire")
+	}
+}
+
+func TestRouter_ParamsFromSubscription(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	got := make(chan string, 1)
+	r.Handle("tenant.:id.orders.created", func(ctx context.Context, msg core.Message) error {
+		got <- core.Param(ctx, "id")
+		return nil
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	// The broker is subscribed with the capture rewritten to a wildcard.
+	msg := &mock.Message{T: "tenant.acme.orders.created", V: []byte("v")}
+	if err := mb.Deliver(context.Background(), "tenant.*.orders.created", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if id := <-got; id != "acme" {
+		t.Errorf("Param(id) = %q, want %q", id, "acme")
+	}
+}
+
+func TestRouter_ParamsFromDispatch(t *testing.T) {
+	r := core.New(mock.NewBroker())
+
+	var params core.Params
+	r.Handle("tenant.:id.orders.:event", func(ctx context.Context, msg core.Message) error {
+		params = core.ParamsFrom(ctx)
+		return nil
+	})
+
+	msg := &mock.Message{V: []byte("v")}
+	if err := r.Dispatch(context.Background(), "tenant.acme.orders.created", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if params["id"] != "acme" || params["event"] != "created" {
+		t.Errorf("params = %v, want id=acme event=created", params)
+	}
+}
+
+func TestRouter_ParamsWithMultiLevelWildcard(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	got := make(chan core.Params, 1)
+	r.Handle("tenant.:id.#.:event", func(ctx context.Context, msg core.Message) error {
+		got <- core.ParamsFrom(ctx)
+		return nil
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{T: "tenant.acme.orders.eu.created", V: []byte("v")}
+	if err := mb.Deliver(context.Background(), "tenant.*.#.*", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	params := <-got
+	if params["id"] != "acme" || params["event"] != "created" {
+		t.Errorf("params = %v, want id=acme event=created", params)
+	}
+}
+
+type tenantKey struct{}
+
+// tenantPropagator carries a tenant between the "tenant" header and ctx.
+type tenantPropagator struct{}
+
+func (tenantPropagator) Extract(ctx context.Context, msg core.Message) context.Context {
+	if t := core.Header(msg, "tenant"); t != "" {
+		return context.WithValue(ctx, tenantKey{}, t)
+	}
+	return ctx
+}
+
+func (tenantPropagator) Inject(ctx context.Context, msg core.Message) core.Message {
+	if t, ok := ctx.Value(tenantKey{}).(string); ok {
+		return core.MergeHeaders(msg, map[string]string{"tenant": t})
+	}
+	return msg
+}
+
+func TestRouter_WithPropagator(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb,
+		core.WithPropagator(tenantPropagator{}),
+		core.WithDeadLetterTopic("dlq"),
+		core.WithIngressHeaderFilter(core.DenyHeaders("tenant")),
+	)
+
+	var seen any
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		seen = ctx.Value(tenantKey{})
+		if err := r.Publish(ctx, "invoices", &mock.Message{V: []byte("inv")}); err != nil {
+			return err
+		}
+		return core.DLQResult("audit")
+	})
+
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{"tenant": "acme"}}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if seen != "acme" {
+		t.Errorf("handler context tenant = %v, want acme (extracted before the ingress filter)", seen)
+	}
+	for _, topic := range []string{"invoices", "dlq"} {
+		pubs := mb.PublishedTo(topic)
+		if len(pubs) != 1 || pubs[0].Header("tenant") != "acme" {
+			t.Errorf("%s: published %+v, want tenant header acme", topic, pubs)
+		}
+	}
+
+	if err := r.Publish(context.Background(), "plain", &mock.Message{V: []byte("p")}); err != nil {
+		t.Fatal(err)
+	}
+	if pubs := mb.PublishedTo("plain"); len(pubs) != 1 || pubs[0].Header("tenant") != "" {
+		t.Errorf("publish without tenant in ctx should add no header: %+v", pubs)
+	}
+}
+
+func TestRouter_UsePublisher(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	var order []string
+	stamp := func(name string, headers map[string]string) core.PublishInterceptor {
+		return func(topic string, msg core.Message) core.Message {
+			order = append(order, name+":"+topic)
+			return core.MergeHeaders(msg, headers)
+		}
+	}
+	r.UsePublisher(stamp("service", map[string]string{"service-name": "orders", "env": "dev"}))
+	r.UsePublisher(stamp("env", map[string]string{"env": "prod", "schema-version": "2"}))
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v"), H: map[string]string{"trace-id": "abc"}}
+	if err := r.PublishFanout(context.Background(), []string{"a", "b"}, msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	wantOrder := []string{"service:a", "env:a", "service:b", "env:b"}
+	if len(order) != len(wantOrder) {
+		t.Fatalf("interceptor calls = %v, want %v", order, wantOrder)
+	}
+	for i := range wantOrder {
+		if order[i] != wantOrder[i] {
+			t.Errorf("call %d = %q, want %q", i, order[i], wantOrder[i])
+		}
+	}
+
+	want := map[string]string{"trace-id": "abc", "service-name": "orders", "env": "prod", "schema-version": "2"}
+	for _, topic := range []string{"a", "b"} {
+		pubs := mb.PublishedTo(topic)
+		if len(pubs) != 1 {
+			t.Fatalf("%s: %d published messages, want 1", topic, len(pubs))
+		}
+		for k, v := range want {
+			if got := pubs[0].Header(k); got != v {
+				t.Errorf("%s: header %q = %q, want %q", topic, k, got, v)
+			}
+		}
+	}
+	if len(msg.H) != 1 {
+		t.Errorf("original message headers were modified: %v", msg.H)
+	}
+}
+
+func TestRouter_UseRaw(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	var order []string
+	var rawParam string
+	var rawErr error
+	r.UseRaw(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			order = append(order, "raw")
+			rawParam = core.Param(ctx, "id")
+			rawErr = next(ctx, msg)
+			return rawErr
+		}
+	})
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			order = append(order, "route")
+			return next(ctx, msg)
+		}
+	})
+	r.Handle("tenant.:id.orders", func(ctx context.Context, msg core.Message) error {
+		order = append(order, "handler:"+core.Param(ctx, "id"))
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{T: "tenant.acme.orders", V: []byte("v")}
+	if err := mb.Deliver(context.Background(), "tenant.*.orders", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	want := []string{"raw", "route", "handler:acme"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order[%d] = %q, want %q", i, order[i], want[i])
+		}
+	}
+	if rawParam != "" {
+		t.Errorf("raw middleware saw param %q before capture", rawParam)
+	}
+	if rawErr != nil {
+		t.Errorf("raw middleware should see the resolved result, got %v", rawErr)
+	}
+	if !msg.Acked {
+		t.Error("message should be acked")
+	}
+}
+
+// blockingBroker blocks Publish until release is closed.
+type blockingBroker struct {
+	*mock.Broker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBroker) Publish(ctx context.Context, topic string, msg core.Message) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.Broker.Publish(ctx, topic, msg)
+}
+
+func TestRouter_PublishSyncError(t *testing.T) {
+	mb := mock.NewBroker()
+	mb.PublishErr = errors.New("broker down")
+	r := core.New(mb, core.WithPublishMode(core.PublishSync))
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	if err := r.Publish(context.Background(), "out.topic", msg); err != mb.PublishErr {
+		t.Errorf("expected broker error in sync mode, got %v", err)
+	}
+}
+
+func TestRouter_PublishBestEffort(t *testing.T) {
+	mb := mock.NewBroker()
+	bb := &blockingBroker{Broker: mb, started: make(chan struct{}, 1), release: make(chan struct{})}
+	r := core.New(bb, core.WithPublishMode(core.PublishBestEffort), core.WithPublishBuffer(1))
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	publish := func() {
+		done := make(chan error, 1)
+		go func() { done <- r.Publish(context.Background(), "out.topic", msg) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("best-effort publish returned %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("best-effort publish blocked")
+		}
+	}
+
+	publish() // taken by the sender, which blocks in the broker
+	<-bb.started
+	publish() // fills the buffer
+	publish() // dropped
+
+	if got := r.DroppedPublishes(); got != 1 {
+		t.Errorf("DroppedPublishes = %d, want 1", got)
+	}
+
+	close(bb.release)
+	<-bb.started
+	deadline := time.Now().Add(time.Second)
+	for len(mb.Published()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(mb.Published()); got != 2 {
+		t.Errorf("expected 2 published messages, got %d", got)
+	}
+}
+
+func TestRouter_IngressHeaderFilter(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithIngressHeaderFilter(core.DenyHeaders("x-internal-route", "baggage")))
+
+	var seen map[string]string
+	var seenParam string
+	r.Handle("tenant.:id.orders", func(ctx context.Context, msg core.Message) error {
+		seen = msg.Headers()
+		seenParam = core.Param(ctx, "id")
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{T: "tenant.acme.orders", V: []byte("v"), H: map[string]string{
+		"trace-id": "abc", "x-internal-route": "edge-1", "baggage": "user=42",
+	}}
+	if err := mb.Deliver(context.Background(), "tenant.*.orders", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if seen["trace-id"] != "abc" {
+		t.Errorf("allowed header missing: %v", seen)
+	}
+	for _, k := range []string{"x-internal-route", "baggage"} {
+		if _, ok := seen[k]; ok {
+			t.Errorf("denied header %q reached the handler", k)
+		}
+	}
+	if seenParam != "acme" {
+		t.Errorf("param id = %q, want acme", seenParam)
+	}
+	if !msg.Acked {
+		t.Error("filtered message should still settle the original")
+	}
+	if len(msg.H) != 3 {
+		t.Errorf("original headers were modified: %v", msg.H)
+	}
+}
+
+func TestRouter_EgressHeaderFilter(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithEgressHeaderFilter(core.AllowHeaders("trace-id", "service-name")))
+	r.UsePublisher(func(topic string, msg core.Message) core.Message {
+		return core.MergeHeaders(msg, map[string]string{"service-name": "orders", "x-debug": "1"})
+	})
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		if err := r.Publish(ctx, "orders.enriched", msg); err != nil {
+			return err
+		}
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{V: []byte("v"), H: map[string]string{"trace-id": "abc", "baggage": "user=42"}}
+	if err := mb.Deliver(context.Background(), "orders.created", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	pubs := mb.PublishedTo("orders.enriched")
+	if len(pubs) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(pubs))
+	}
+	got := pubs[0].Headers
+	want := map[string]string{"trace-id": "abc", "service-name": "orders"}
+	if len(got) != len(want) {
+		t.Errorf("published headers = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("header %q = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+// replayBroker serves ReplayFrom from a fixed, time-ordered history.
+type replayBroker struct {
+	*mock.Broker
+	history []replayEntry
+}
+
+type replayEntry struct {
+	at  time.Time
+	msg *mock.Message
+}
+
+func (b *replayBroker) ReplayFrom(ctx context.Context, topic string, since time.Time, h core.Handler) error {
+	for _, e := range b.history {
+		if e.at.Before(since) || e.msg.T != topic {
+			continue
+		}
+		if err := h(ctx, e.msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRouter_ReplayFrom(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	msg := func(topic, v string) *mock.Message { return &mock.Message{T: topic, V: []byte(v)} }
+	rb := &replayBroker{Broker: mock.NewBroker(), history: []replayEntry{
+		{base, msg("orders", "1")},
+		{base.Add(time.Minute), msg("orders", "2")},
+		{base.Add(time.Minute), msg("payments", "x")},
+		{base.Add(2 * time.Minute), msg("orders", "3")},
+	}}
+	r := core.New(rb)
+
+	var mwCalls int
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			mwCalls++
+			return next(ctx, msg)
+		}
+	})
+
+	var got []string
+	err := r.ReplayFrom(context.Background(), "orders", base.Add(time.Minute), func(ctx context.Context, msg core.Message) error {
+		got = append(got, string(msg.Value()))
+		return core.AckResult()
+	})
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+	if len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("replayed %v, want [2 3]", got)
+	}
+	if mwCalls != 2 {
+		t.Errorf("middleware ran %d times, want 2", mwCalls)
+	}
+	if rb.history[0].msg.Acked || !rb.history[1].msg.Acked || !rb.history[3].msg.Acked {
+		t.Error("only replayed messages should be acked")
+	}
+}
+
+func TestRouter_ReplayFromStopsOnError(t *testing.T) {
+	base := time.Now()
+	rb := &replayBroker{Broker: mock.NewBroker(), history: []replayEntry{
+		{base, &mock.Message{T: "orders", V: []byte("1")}},
+		{base, &mock.Message{T: "orders", V: []byte("2")}},
+	}}
+	r := core.New(rb)
+
+	boom := errors.New("boom")
+	var calls int
+	err := r.ReplayFrom(context.Background(), "orders", base, func(ctx context.Context, msg core.Message) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestRouter_ReplayFromUnsupported(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	err := r.ReplayFrom(context.Background(), "orders", time.Now(), func(context.Context, core.Message) error { return nil })
+	if !errors.Is(err, core.ErrReplayUnsupported) {
+		t.Errorf("expected ErrReplayUnsupported, got %v", err)
+	}
+}
+
+func TestRouter_StopConsuming(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var finished atomic.Bool
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		close(entered)
+		<-release
+		finished.Store(true)
+		return core.AckResult()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startErr := make(chan error, 1)
+	go func() { startErr <- r.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+
+	go mb.Deliver(context.Background(), "orders.created", &mock.Message{V: []byte("v")})
+	<-entered
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- r.StopConsuming(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("StopConsuming returned %v before the in-flight handler finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("StopConsuming: %v", err)
+	}
+	if !finished.Load() {
+		t.Error("in-flight handler should have finished")
+	}
+	if err := <-startErr; err != nil {
+		t.Fatalf("Start returned %v", err)
+	}
+
+	if err := mb.Deliver(context.Background(), "orders.created", &mock.Message{V: []byte("late")}); !errors.Is(err, core.ErrConsumingStopped) {
+		t.Errorf("late delivery: expected ErrConsumingStopped, got %v", err)
+	}
+	if mb.IsClosed() {
+		t.Fatal("broker should stay open after StopConsuming")
+	}
+	if err := r.Publish(context.Background(), "orders.flushed", &mock.Message{V: []byte("v")}); err != nil {
+		t.Fatalf("publish after StopConsuming: %v", err)
+	}
+	if len(mb.Published()) != 1 {
+		t.Errorf("expected 1 published message, got %d", len(mb.Published()))
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !mb.IsClosed() {
+		t.Error("broker should be closed after Close")
+	}
+	if err := r.Publish(context.Background(), "orders.flushed", &mock.Message{}); err != core.ErrBrokerClosed {
+		t.Errorf("publish after Close: expected ErrBrokerClosed, got %v", err)
+	}
+}
+
+func TestRouter_StopConsumingTimeout(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		close(entered)
+		<-release
+		return nil
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	go mb.Deliver(context.Background(), "orders.created", &mock.Message{})
+	<-entered
+
+	ctx, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+	if err := r.StopConsuming(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+// hangingBroker never returns from Close.
+type hangingBroker struct {
+	*mock.Broker
+}
+
+func (b *hangingBroker) Close() error {
+	select {}
+}
+
+func TestRouter_CloseTimeout(t *testing.T) {
+	r := core.New(&hangingBroker{mock.NewBroker()}, core.WithCloseTimeout(50*time.Millisecond))
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	start := time.Now()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, core.ErrCloseTimeout) {
+			t.Errorf("expected ErrCloseTimeout, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("Start returned after %v, before the close timeout", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after the close timeout")
+	}
+}
+
+func receive(t *testing.T, ch <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("no result on EmitAsync channel")
+		return nil
+	}
+}
+
+func TestRouter_EmitAsync(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	results := []<-chan error{
+		r.EmitAsync(context.Background(), "a", msg),
+		r.EmitAsync(context.Background(), "b", msg),
+	}
+	for _, ch := range results {
+		if err := receive(t, ch); err != nil {
+			t.Errorf("expected nil result, got %v", err)
+		}
+	}
+	if len(mb.Published()) != 2 {
+		t.Errorf("expected 2 published messages, got %d", len(mb.Published()))
+	}
+
+	mb.PublishErr = errors.New("broker down")
+	if err := receive(t, r.EmitAsync(context.Background(), "c", msg)); err != mb.PublishErr {
+		t.Errorf("expected broker error, got %v", err)
+	}
+}
+
+func TestRouter_EmitAsyncNilBroker(t *testing.T) {
+	r := core.New(nil)
+	if err := receive(t, r.EmitAsync(context.Background(), "a", &mock.Message{})); err != core.ErrNoBroker {
+		t.Errorf("expected ErrNoBroker, got %v", err)
+	}
+}
+
+func TestRouter_EmitAsyncBestEffort(t *testing.T) {
+	mb := mock.NewBroker()
+	bb := &blockingBroker{Broker: mb, started: make(chan struct{}, 1), release: make(chan struct{})}
+	r := core.New(bb, core.WithPublishMode(core.PublishBestEffort), core.WithPublishBuffer(1))
+
+	msg := &mock.Message{K: []byte("k"), V: []byte("v")}
+	first := r.EmitAsync(context.Background(), "out", msg) // taken by the sender
+	<-bb.started
+	second := r.EmitAsync(context.Background(), "out", msg) // fills the buffer
+	third := r.EmitAsync(context.Background(), "out", msg)  // dropped
+
+	if err := receive(t, third); err != core.ErrPublishDropped {
+		t.Errorf("expected ErrPublishDropped, got %v", err)
+	}
+	close(bb.release)
+	<-bb.started
+	for _, ch := range []<-chan error{first, second} {
+		if err := receive(t, ch); err != nil {
+			t.Errorf("expected nil result, got %v", err)
+		}
+	}
+}
+
+func TestRouter_EmitAsyncDuringClose(t *testing.T) {
+	for range 20 {
+		r := core.New(mock.NewBroker(), core.WithPublishMode(core.PublishBestEffort))
+
+		var wg sync.WaitGroup
+		results := make([]<-chan error, 50)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = r.EmitAsync(context.Background(), "out", &mock.Message{V: []byte("v")})
+			}()
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		wg.Wait()
+
+		for _, ch := range results {
+			if err := receive(t, ch); err != nil && err != core.ErrBrokerClosed {
+				t.Errorf("result = %v, want nil or ErrBrokerClosed", err)
+			}
+		}
+	}
+}
+
+func TestRouter_PublishBufferBelowOne(t *testing.T) {
+	mb := mock.NewBroker()
+	bb := &blockingBroker{Broker: mb, started: make(chan struct{}, 1), release: make(chan struct{})}
+	r := core.New(bb, core.WithPublishMode(core.PublishBestEffort), core.WithPublishBuffer(0))
+
+	msg := &mock.Message{V: []byte("v")}
+	first := r.EmitAsync(context.Background(), "out", msg)
+	<-bb.started
+	second := r.EmitAsync(context.Background(), "out", msg)
+	close(bb.release)
+	for _, ch := range []<-chan error{first, second} {
+		if err := receive(t, ch); err != nil {
+			t.Errorf("result = %v, want the default buffer to hold the message", err)
+		}
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("orders.dlq"))
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		if core.Header(msg, "schema-version") != "2" {
+			return core.DeadLetter(ctx, msg, "unsupported schema version")
+		}
+		return core.AckResult()
+	})
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	msg := &mock.Message{V: []byte("v"), H: map[string]string{"schema-version": "1", core.HeaderAttempt: "3"}}
+	if err := mb.Deliver(context.Background(), "orders.created", msg); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if !msg.Acked {
+		t.Error("original should be acked after dead-lettering")
+	}
+
+	pubs := mb.Published()
+	if len(pubs) != 1 || pubs[0].Topic != "orders.dlq" {
+		t.Fatalf("expected one message on orders.dlq, got %+v", pubs)
+	}
+	h := pubs[0].Message.Headers()
+	if h[core.HeaderDeadLetterReason] != "unsupported schema version" {
+		t.Errorf("reason header = %q", h[core.HeaderDeadLetterReason])
+	}
+	if h[core.HeaderAttempt] != "3" {
+		t.Errorf("attempt header = %q, want 3", h[core.HeaderAttempt])
+	}
+}
+
+func TestDeadLetter_BindError(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("orders.dlq"))
+	r.Use(func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			ctx, _ = core.WithStore(ctx)
+			err := next(ctx, msg)
+			if _, ok := core.BindFailure(ctx); ok {
+				return core.DeadLetter(ctx, msg, "undecodable")
+			}
+			return err
+		}
+	})
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		var v struct{ ID int }
+		return core.Bind(ctx, msg, &v)
+	})
+
+	msg := &mock.Message{V: []byte(`{"ID":"x"}`)}
+	if err := r.Dispatch(context.Background(), "orders.created", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	pubs := mb.Published()
+	if len(pubs) != 1 {
+		t.Fatalf("expected one dead-lettered message, got %d", len(pubs))
+	}
+	if got := string(pubs[0].Message.Value()); got != `{"ID":"x"}` {
+		t.Errorf("payload = %q, want the raw payload", got)
+	}
+	if h := pubs[0].Message.Headers()[core.HeaderBindError]; !strings.Contains(h, "cannot unmarshal") {
+		t.Errorf("bind error header = %q", h)
+	}
+}
+
+func TestDeadLetter_BindErrorOnDLQResult(t *testing.T) {
+	withStore := func(next core.Handler) core.Handler {
+		return func(ctx context.Context, msg core.Message) error {
+			ctx, _ = core.WithStore(ctx)
+			return next(ctx, msg)
+		}
+	}
+	tests := []struct {
+		name string
+		opts []core.Option
+		mw   core.Middleware
+		fail error // returned after the failed Bind
+	}{
+		{"no store", []core.Option{core.WithDeadLetterTopic("orders.dlq")}, nil, core.DLQResult("undecodable")},
+		{"middleware store", []core.Option{core.WithDeadLetterTopic("orders.dlq")}, withStore, core.DLQResult("undecodable")},
+		{"max redeliveries", []core.Option{core.WithMaxRedeliveries(1, "orders.dlq")}, nil, errPoison},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := mock.NewBroker()
+			r := core.New(mb, tt.opts...)
+			if tt.mw != nil {
+				r.Use(tt.mw)
+			}
+			r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+				var v struct{ ID int }
+				if err := core.Bind(ctx, msg, &v); err != nil {
+					return tt.fail
+				}
+				return nil
+			})
+
+			msg := &mock.Message{V: []byte(`{"ID":"x"}`), H: map[string]string{core.HeaderAttempt: "2"}}
+			if err := r.Dispatch(context.Background(), "orders.created", msg); err != nil {
+				t.Fatalf("dispatch: %v", err)
+			}
+			pubs := mb.PublishedTo("orders.dlq")
+			if len(pubs) != 1 {
+				t.Fatalf("dead-lettered %d messages, want 1", len(pubs))
+			}
+			if h := pubs[0].Header(core.HeaderBindError); !strings.Contains(h, "cannot unmarshal") {
+				t.Errorf("bind error header = %q", h)
+			}
+		})
+	}
+}
+
+func TestDeadLetter_BindErrorPerMessage(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithDeadLetterTopic("orders.dlq"))
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		var v struct{ ID int }
+		_ = core.Bind(ctx, msg, &v)
+		return core.DLQResult("rejected")
+	})
+
+	for _, payload := range []string{`{"ID":"x"}`, `{"ID":1}`} {
+		if err := r.Dispatch(context.Background(), "orders.created", &mock.Message{V: []byte(payload)}); err != nil {
+			t.Fatalf("dispatch %s: %v", payload, err)
+		}
+	}
+	pubs := mb.PublishedTo("orders.dlq")
+	if len(pubs) != 2 {
+		t.Fatalf("dead-lettered %d messages, want 2", len(pubs))
+	}
+	if pubs[0].Header(core.HeaderBindError) == "" {
+		t.Error("first copy should carry its bind error")
+	}
+	if h := pubs[1].Header(core.HeaderBindError); h != "" {
+		t.Errorf("second copy carries %q from an earlier message", h)
+	}
+}
+
+func TestDeadLetter_NoTopic(t *testing.T) {
+	msg := &mock.Message{}
+	if err := core.DeadLetter(context.Background(), msg, "bad"); !errors.Is(err, core.ErrNoDeadLetterTopic) {
+		t.Errorf("outside a router: expected ErrNoDeadLetterTopic, got %v", err)
+	}
+
+	r := core.New(mock.NewBroker())
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		return core.DeadLetter(ctx, msg, "bad")
+	})
+	if err := r.Dispatch(context.Background(), "orders", msg); !errors.Is(err, core.ErrNoDeadLetterTopic) {
+		t.Errorf("without topic: expected ErrNoDeadLetterTopic, got %v", err)
+	}
+	if msg.Acked {
+		t.Error("message must not be acked when dead-lettering fails")
+	}
+}
+
+func TestRouter_PublishInvalidTopic(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	msg := &mock.Message{V: []byte("v")}
+
+	for _, topic := range []string{"orders.*", "payments.#", "events.>", "", "orders created"} {
+		if err := r.Publish(context.Background(), topic, msg); !errors.Is(err, core.ErrInvalidTopic) {
+			t.Errorf("Publish(%q): expected ErrInvalidTopic, got %v", topic, err)
+		}
+	}
+	if err := receive(t, r.EmitAsync(context.Background(), "orders.*", msg)); !errors.Is(err, core.ErrInvalidTopic) {
+		t.Errorf("EmitAsync: expected ErrInvalidTopic, got %v", err)
+	}
+	if len(mb.Published()) != 0 {
+		t.Error("nothing should reach the broker for invalid topics")
+	}
+	if err := r.Publish(context.Background(), "orders.created", msg); err != nil {
+		t.Errorf("valid topic: %v", err)
+	}
+}
+
+func TestRouter_TopicValidator(t *testing.T) {
+	mb := mock.NewBroker()
+	noSlash := func(topic string) error {
+		if strings.Contains(topic, "/") {
+			return fmt.Errorf("%w: %q contains '/'", core.ErrInvalidTopic, topic)
+		}
+		return nil
+	}
+	r := core.New(mb, core.WithTopicValidator(noSlash))
+
+	if err := r.Publish(context.Background(), "orders/created", &mock.Message{}); !errors.Is(err, core.ErrInvalidTopic) {
+		t.Errorf("expected custom validator to reject, got %v", err)
+	}
+	if err := r.Publish(context.Background(), "orders.#", &mock.Message{}); err != nil {
+		t.Errorf("custom validator replaces the default, got %v", err)
+	}
+
+	r = core.New(mb, core.WithTopicValidator(nil))
+	if err := r.Publish(context.Background(), "orders.*", &mock.Message{}); err != nil {
+		t.Errorf("nil validator should disable validation, got %v", err)
+	}
+}
+
+func TestRouter_HandleAfter(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+
+	var snapshot atomic.Int32
+	r.Handle("prices.snapshot", func(ctx context.Context, msg core.Message) error {
+		snapshot.Add(1)
+		if string(msg.Value()) == "eof" {
+			core.Ready(ctx)
+			core.Ready(ctx) // idempotent
+		}
+		return core.AckResult()
+	})
+	var events atomic.Int32
+	r.HandleAfter("prices.snapshot", "prices.events", func(ctx context.Context, msg core.Message) error {
+		if snapshot.Load() < 2 {
+			t.Error("event consumed before the snapshot was bootstrapped")
+		}
+		events.Add(1)
+		return core.AckResult()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := mb.Deliver(ctx, "prices.events", &mock.Message{V: []byte("e")}); err != core.ErrNoHandler {
+		t.Fatalf("dependent route subscribed before Ready: Deliver returned %v", err)
+	}
+	if err := mb.DeliverMany(ctx, "prices.snapshot",
+		&mock.Message{V: []byte("p1")}, &mock.Message{V: []byte("eof")}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := mb.Deliver(ctx, "prices.events", &mock.Message{V: []byte("e")}); err != nil {
+		t.Fatalf("Deliver after Ready: %v", err)
+	}
+	if events.Load() != 1 {
+		t.Errorf("events handled = %d, want 1", events.Load())
+	}
+}
+
+func TestRouter_HandleAfterInvalid(t *testing.T) {
+	h := func(ctx context.Context, msg core.Message) error { return nil }
+
+	r := core.New(mock.NewBroker())
+	r.HandleAfter("missing", "orders.created", h)
+	if err := r.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "unregistered route") {
+		t.Errorf("unknown dependency: Start returned %v", err)
+	}
+
+	r = core.New(mock.NewBroker())
+	r.HandleAfter("b", "a", h)
+	r.HandleAfter("a", "b", h)
+	if err := r.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("cycle: Start returned %v", err)
+	}
+}
+
+func TestReady_OutsideRouter(t *testing.T) {
+	core.Ready(context.Background()) // must not panic
+}
+
+func TestRouter_SettersAfterStart(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	if err := r.SetBinder(core.JSONBinder{SnakeCase: true}); err != nil {
+		t.Fatalf("SetBinder before Start: %v", err)
+	}
+	if err := r.SetMatcher(core.DefaultMatcher{}); err != nil {
+		t.Fatalf("SetMatcher before Start: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := r.SetBinder(core.JSONBinder{}); err != core.ErrAlreadyStarted {
+		t.Errorf("SetBinder after Start = %v, want ErrAlreadyStarted", err)
+	}
+	if err := r.SetMatcher(core.DefaultMatcher{}); err != core.ErrAlreadyStarted {
+		t.Errorf("SetMatcher after Start = %v, want ErrAlreadyStarted", err)
+	}
+}
+
+func TestRouter_NackBackoff(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithNackBackoff(core.ExponentialBackoff(100*time.Millisecond, time.Second)))
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return core.NackResult()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	want := map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		6: time.Second,
+	}
+	for attempt, delay := range want {
+		msg := &mock.Message{H: map[string]string{core.HeaderAttempt: strconv.Itoa(attempt)}}
+		mb.Deliver(ctx, "orders.created", msg)
+		if !msg.Nacked || msg.NackDelay != delay {
+			t.Errorf("attempt %d: nacked=%v delay=%v, want delay %v", attempt, msg.Nacked, msg.NackDelay, delay)
+		}
+	}
+}
+
+func TestRouter_NackWithoutBackoff(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error {
+		return core.NackResult()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	msg := &mock.Message{}
+	mb.Deliver(ctx, "orders.created", msg)
+	if !msg.Nacked || msg.NackDelay != 0 {
+		t.Errorf("nacked=%v delay=%v, want an immediate nack", msg.Nacked, msg.NackDelay)
+	}
+}
+
+func TestRouter_Stats(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	h := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Handle("orders.created", h)
+	r.Handle("orders.paid", h)
+	r.Handle("orders.shipped", h)
+
+	if s := r.Stats(); s.Subscriptions != 0 || s.Goroutines != 0 {
+		t.Fatalf("before Start: %+v", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Start(ctx) }()
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 3 })
+	// One goroutine per subscription plus the one waiting on them.
+	if s := r.Stats(); s.Goroutines != 4 {
+		t.Errorf("running: %+v, want 4 goroutines", s)
+	}
+
+	cancel()
+	<-done
+	waitStats(t, r, func(s core.Stats) bool { return s == core.Stats{} })
+}
+
+func TestRouter_GoroutineLimit(t *testing.T) {
+	warned := make(chan string, 1)
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		select {
+		case warned <- string(p):
+		default:
+		}
+		return len(p), nil
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	r := core.New(mock.NewBroker(), core.WithGoroutineLimit(2))
+	h := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Handle("a", h)
+	r.Handle("b", h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Start(ctx)
+	var msg string
+	select {
+	case msg = <-warned:
+	case <-time.After(time.Second):
+		t.Fatal("no warning logged")
+	}
+	if !strings.Contains(msg, "router owns 3 goroutines, above the limit of 2") {
+		t.Errorf("warning = %q", msg)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// waitStats polls r.Stats until cond holds or a second has passed.
+func waitStats(t *testing.T, r *core.Router, cond func(core.Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond(r.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats %+v did not reach the expected state", r.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRouter_StartAsync(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb)
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error { return nil })
+
+	h, err := r.StartAsync(context.Background())
+	if err != nil {
+		t.Fatalf("StartAsync: %v", err)
+	}
+	select {
+	case <-h.Done():
+		t.Fatal("Done closed while running")
+	default:
+	}
+	if h.Err() != nil {
+		t.Errorf("Err while running = %v", h.Err())
+	}
+	waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+
+	h.Stop()
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after Stop")
+	}
+	if h.Err() != nil {
+		t.Errorf("Err after Stop = %v", h.Err())
+	}
+	if !mb.IsClosed() {
+		t.Error("broker should be closed after Stop")
+	}
+	if _, err := r.StartAsync(context.Background()); err != core.ErrAlreadyStarted {
+		t.Errorf("second StartAsync = %v, want ErrAlreadyStarted", err)
+	}
+}
+
+func TestRouter_StartAsyncError(t *testing.T) {
+	if _, err := core.New(nil).StartAsync(context.Background()); err != core.ErrNoBroker {
+		t.Errorf("StartAsync without broker = %v, want ErrNoBroker", err)
+	}
+
+	mb := mock.NewBroker()
+	mb.SubscribeErr = errors.New("connection refused")
+	r := core.New(mb)
+	r.Handle("orders.created", func(ctx context.Context, msg core.Message) error { return nil })
+
+	h, err := r.StartAsync(context.Background())
+	if err != nil {
+		t.Fatalf("StartAsync: %v", err)
+	}
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after the subscription failed")
+	}
+	if h.Err() == nil || !strings.Contains(h.Err().Error(), "connection refused") {
+		t.Errorf("Err = %v, want the subscribe error", h.Err())
+	}
+}
+
+type provisionBroker struct {
+	*mock.Broker
+	calls [][]string
+}
+
+func (b *provisionBroker) Provision(_ context.Context, topics []string) error {
+	b.calls = append(b.calls, topics)
+	return nil
+}
+
+func TestRouter_Provision(t *testing.T) {
+	pb := &provisionBroker{Broker: mock.NewBroker()}
+	r := core.New(pb)
+	h := func(ctx context.Context, msg core.Message) error { return nil }
+	r.Handle("orders.created", h)
+	r.Handle("tenants.:tenant.events", h)
+
+	if err := r.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"orders.created", "tenants.*.events"}
+	if len(pb.calls) != 1 || fmt.Sprint(pb.calls[0]) != fmt.Sprint(want) {
+		t.Errorf("provisioned %v, want %v", pb.calls, want)
+	}
+}
+
+func TestRouter_ProvisionUnsupported(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	if err := r.Provision(context.Background()); !errors.Is(err, core.ErrProvisionUnsupported) {
+		t.Errorf("expected ErrProvisionUnsupported, got %v", err)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name          string
+		err           error
+		acked, nacked bool
+		wantErr       bool
+	}{
+		{"nil", nil, false, false, false},
+		{"ack", core.AckResult(), true, false, false},
+		{"nack", core.NackResult(), false, true, false},
+		{"plain error", errors.New("boom"), false, true, true},
+		{"dlq without router", core.DLQResult("bad"), false, false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mock.Message{}
+			err := core.Settle(ctx, m, tc.err)
+			if m.Acked != tc.acked || m.Nacked != tc.nacked || (err != nil) != tc.wantErr {
+				t.Errorf("acked=%v nacked=%v err=%v", m.Acked, m.Nacked, err)
+			}
+		})
+	}
+}
+
+func TestRouter_TopicAllowlist(t *testing.T) {
+	noop := func(context.Context, core.Message) error { return nil }
+	allowlist := []string{"orders.#", "billing.invoices"}
+	tests := []struct {
+		name     string
+		patterns []string
+		wantErr  bool
+	}{
+		{"firehose", []string{"orders.created", "#"}, true},
+		{"wildcard outside prefix", []string{"*.created"}, true},
+		{"exact not listed", []string{"billing.refunds"}, true},
+		{"within prefix", []string{"orders.created", "orders.*", "orders.#"}, false},
+		{"exact listed", []string{"billing.invoices"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := core.New(mock.NewBroker(), core.WithTopicAllowlist(allowlist))
+			for _, p := range tt.patterns {
+				r.Handle(p, noop)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- r.Start(ctx) }()
+
+			if tt.wantErr {
+				select {
+				case err := <-done:
+					if !errors.Is(err, core.ErrTopicNotAllowed) {
+						t.Errorf("Start = %v, want ErrTopicNotAllowed", err)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Start did not fail fast")
+				}
+				cancel()
+				return
+			}
+			select {
+			case err := <-done:
+				t.Fatalf("Start returned early: %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+			cancel()
+			<-done
+		})
+	}
+}
+
+func TestRouter_TopicAllowlistNamesPatterns(t *testing.T) {
+	noop := func(context.Context, core.Message) error { return nil }
+	r := core.New(mock.NewBroker(), core.WithTopicAllowlist([]string{"orders.#"}))
+	r.Handle("#", noop)
+	r.Handle("payments.*", noop)
+	r.Handle("orders.created", noop)
+
+	err := r.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `"#", "payments.*"`) {
+		t.Errorf("Start = %v, want both disallowed patterns named", err)
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+// ShutdownPolicy selects how the Router settles a message whose handler was
+// interrupted by shutdown, i.e. returned its context's own cancellation or
+// deadline error after Start's context was cancelled or StopConsuming was
+// called (see Interrupted). Such a return is not a processing failure, so it
+// never reaches the broker's error path.
+type ShutdownPolicy int
+
+const (
+	// ShutdownLeaveUnsettled leaves the message unsettled, so the broker
+	// redelivers it once its subscription ends (or the ack deadline passes),
+	// without counting a failure. It is the default.
+	ShutdownLeaveUnsettled ShutdownPolicy = iota
+
+	// ShutdownNack nacks the message, applying WithNackBackoff, for brokers
+	// that should make it available to other consumers immediately.
+	ShutdownNack
+)
+
+// Interrupted reports whether err is ctx's own cancellation or deadline
+// error, i.e. the handler stopped because ctx ended rather than because it
+// failed. An error that merely wraps context.Canceled while ctx is still
+// live, such as a timeout the handler set on a call of its own, is a real
+// failure.
+func Interrupted(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	cerr := ctx.Err()
+	return cerr != nil && errors.Is(err, cerr)
+}
+
+// interrupted settles msg according to the router's ShutdownPolicy.
+func (r *Router) interrupted(msg Message) error {
+	if r.shutdownPolicy == ShutdownNack {
+		return r.nack(msg)
+	}
+	return nil
+}
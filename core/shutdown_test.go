@@ -0,0 +1,91 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestInterrupted(t *testing.T) {
+	live := context.Background()
+	cancelled, cancel := context.WithCancel(live)
+	cancel()
+	expired, cancel2 := context.WithDeadline(live, time.Now().Add(-time.Second))
+	defer cancel2()
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"cancelled", cancelled, context.Canceled, true},
+		{"wrapped", cancelled, fmt.Errorf("fetch: %w", context.Canceled), true},
+		{"deadline", expired, context.DeadlineExceeded, true},
+		{"live ctx", live, context.Canceled, false},
+		{"own timeout", cancelled, context.DeadlineExceeded, false},
+		{"other error", cancelled, errors.New("boom"), false},
+		{"nil", cancelled, nil, false},
+	} {
+		if got := core.Interrupted(tc.ctx, tc.err); got != tc.want {
+			t.Errorf("%s: Interrupted = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRouter_ShutdownPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       []core.Option
+		wantNacked bool
+	}{
+		{"default leaves unsettled", nil, false},
+		{"nack", []core.Option{core.WithShutdownPolicy(core.ShutdownNack)}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mb := mock.NewBroker()
+			r := core.New(mb, tc.opts...)
+			started := make(chan struct{})
+			r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+				close(started)
+				<-ctx.Done()
+				return fmt.Errorf("enrich: %w", ctx.Err())
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- r.Start(ctx) }()
+			waitStats(t, r, func(s core.Stats) bool { return s.Subscriptions == 1 })
+
+			msg := &mock.Message{}
+			delivered := make(chan error, 1)
+			go func() { delivered <- mb.Deliver(ctx, "orders", msg) }()
+			<-started
+			cancel()
+
+			if err := <-delivered; err != nil {
+				t.Errorf("broker got %v, want nil: an interrupted handler is not a failure", err)
+			}
+			if msg.Nacked != tc.wantNacked || msg.Acked {
+				t.Errorf("acked=%v nacked=%v, want nacked=%v", msg.Acked, msg.Nacked, tc.wantNacked)
+			}
+			<-done
+		})
+	}
+}
+
+func TestRouter_CanceledWithoutShutdownIsFailure(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		return context.Canceled // e.g. from a call whose own context was cancelled
+	})
+	msg := &mock.Message{}
+	if err := r.Dispatch(context.Background(), "orders", msg); !errors.Is(err, context.Canceled) {
+		t.Errorf("Dispatch = %v, want the error passed to the broker", err)
+	}
+}
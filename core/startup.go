@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// readySignal is closed once a route's bootstrap has completed.
+type readySignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (s *readySignal) signal() { s.once.Do(func() { close(s.ch) }) }
+
+type readyKey struct{}
+
+// HandleAfter registers h for topic like Handle, but Start does not
+// subscribe to topic until the route registered for dependsOn has called
+// Ready. Use it when one subscription must bootstrap state first, e.g. a
+// compacted topic that has to be read up to date before the event topic
+// that depends on it is consumed.
+func (r *Router) HandleAfter(dependsOn, topic string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addRoute(topic, h)
+	delete(r.routeOpts, topic)
+	if r.after == nil {
+		r.after = make(map[string]string)
+	}
+	r.after[topic] = dependsOn
+}
+
+// Ready signals that the route handling the current message has finished
+// bootstrapping, releasing the routes registered with HandleAfter on it.
+// Calling it again, outside a Router, or from a route nothing depends on
+// is a no-op.
+func Ready(ctx context.Context) {
+	if s, ok := ctx.Value(readyKey{}).(*readySignal); ok {
+		s.signal()
+	}
+}
+
+// readySignals checks the dependencies declared with HandleAfter against
+// routes and returns a signal for each route that others depend on.
+func readySignals(routes map[string]Handler, after map[string]string) (map[string]*readySignal, error) {
+	signals := make(map[string]*readySignal)
+	for topic, dep := range after {
+		if _, ok := routes[dep]; !ok {
+			return nil, fmt.Errorf("eventmux: route %q depends on unregistered route %q", topic, dep)
+		}
+		for seen, d := map[string]bool{topic: true}, dep; d != ""; d = after[d] {
+			if seen[d] {
+				return nil, fmt.Errorf("eventmux: route %q has a dependency cycle", topic)
+			}
+			seen[d] = true
+		}
+		if signals[dep] == nil {
+			signals[dep] = &readySignal{ch: make(chan struct{})}
+		}
+	}
+	return signals, nil
+}
+
+// withReady makes s available to Ready for messages handled by h.
+func withReady(s *readySignal, h Handler) Handler {
+	return func(ctx context.Context, msg Message) error {
+		return h(context.WithValue(ctx, readyKey{}, s), msg)
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import "log"
+
+// Stats is a snapshot of the Router's runtime state, see Router.Stats.
+type Stats struct {
+	// Subscriptions is the number of broker subscriptions currently running.
+	Subscriptions int
+	// Goroutines is the number of goroutines the Router owns: one per
+	// subscription and per pool worker, plus helpers such as the
+	// best-effort publisher, the reconnect watcher and asynchronous
+	// publishes. Goroutines started by the broker itself are not included.
+	Goroutines int
+	// Queued is the number of delivered messages waiting for a worker of
+	// the WithWorkerPool pool.
+	Queued int
+	// Unmatched is the number of messages Dispatch received on topics no
+	// route pattern matched, including those the Default handler took. See
+	// Router.UnmatchedTopics for a breakdown by topic.
+	Unmatched int64
+}
+
+// Stats returns a snapshot of the Router's runtime state. A Goroutines count
+// that keeps growing while Subscriptions is steady points to a leak.
+func (r *Router) Stats() Stats {
+	return Stats{
+		Subscriptions: int(r.subscriptions.Load()),
+		Goroutines:    int(r.goroutines.Load()),
+		Queued:        int(r.queued.Load()),
+		Unmatched:     r.unmatchedCount.Load(),
+	}
+}
+
+// spawn runs fn on a goroutine counted in Stats, warning when the count
+// goes above the limit set with WithGoroutineLimit.
+func (r *Router) spawn(fn func()) {
+	n := r.goroutines.Add(1)
+	if r.goroutineLimit > 0 && n == int64(r.goroutineLimit)+1 {
+		log.Printf("[EventMux] router owns %d goroutines, above the limit of %d; check for leaked subscriptions or publishes", n, r.goroutineLimit)
+	}
+	go func() {
+		defer r.goroutines.Add(-1)
+		fn()
+	}()
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Store holds values that middleware attaches to a message for handlers
+// further down the chain, such as a decoded tenant or an authenticated
+// principal. Unlike context values it is mutable, and it is safe for
+// concurrent use. Attach one with WithStore. Its map is allocated on the
+// first Set, so a Store that is never written costs a single allocation.
+type Store struct {
+	mu     sync.RWMutex
+	values map[string]any
+	binds  map[bindKey]boundValue // see Bind; not cloned
+}
+
+type storeKey struct{}
+
+// WithStore returns a child of ctx carrying a new, empty Store.
+func WithStore(ctx context.Context) (context.Context, *Store) {
+	s := &Store{}
+	return context.WithValue(ctx, storeKey{}, s), s
+}
+
+// StoreFrom returns the Store attached to ctx, or nil if there is none.
+func StoreFrom(ctx context.Context) *Store {
+	s, _ := ctx.Value(storeKey{}).(*Store)
+	return s
+}
+
+// Set stores v under key.
+func (s *Store) Set(key string, v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]any)
+	}
+	s.values[key] = v
+}
+
+// Get returns the value stored under key.
+func (s *Store) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// GetTyped returns the value stored under key in ctx's Store as a T. ok is
+// false if ctx has no Store, the key is missing, or the value is not a T, so
+// a mismatch never panics the way a bare type assertion would.
+func GetTyped[T any](ctx context.Context, key string) (T, bool) {
+	var zero T
+	s := StoreFrom(ctx)
+	if s == nil {
+		return zero, false
+	}
+	v, ok := s.Get(key)
+	if !ok {
+		return zero, false
+	}
+	t, ok := v.(T)
+	return t, ok
+}
+
+// Clone returns an independent Store with the same keys, for handing work
+// to another goroutine. Values whose type has a copy function registered
+// with RegisterCopier are deep-copied; all others are copied by assignment,
+// so pointers, maps and slices remain shared unless a copier is registered
+// for them.
+func (s *Store) Clone() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.values) == 0 {
+		return &Store{}
+	}
+	c := &Store{values: make(map[string]any, len(s.values))}
+	for k, v := range s.values {
+		c.values[k] = copyValue(v)
+	}
+	return c
+}
+
+// WithClone returns a child of ctx carrying a Clone of its Store, if any.
+func WithClone(ctx context.Context) context.Context {
+	s := StoreFrom(ctx)
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, storeKey{}, s.Clone())
+}
+
+// WithStoreValues returns a child of ctx whose Value method also reads
+// through to the Store attached to ctx, so libraries that look values up on
+// a plain context.Context see what middleware stored. Only string keys are
+// looked up in the Store; other keys, and strings it does not hold, fall
+// back to ctx. Lookups are live: a value Set later is visible too.
+//
+// Values are returned as stored, not copied. Store values that downstream
+// code reads concurrently must be immutable, or cloned (see Clone) before
+// being handed to another goroutine. Prefer unexported key types with
+// context.WithValue for new code; string keys exist for interoperability.
+func WithStoreValues(ctx context.Context) context.Context {
+	s := StoreFrom(ctx)
+	if s == nil {
+		return ctx
+	}
+	return storeContext{Context: ctx, store: s}
+}
+
+type storeContext struct {
+	context.Context
+	store *Store
+}
+
+func (c storeContext) Value(key any) any {
+	if k, ok := key.(string); ok {
+		if v, ok := c.store.Get(k); ok {
+			return v
+		}
+	}
+	return c.Context.Value(key)
+}
+
+// copiers maps a reflect.Type to its func(any) any copy function.
+var copiers sync.Map
+
+// RegisterCopier registers fn to copy stored values of type T when a Store
+// is cloned. fn must return a copy that shares no mutable state with its
+// argument. Register copiers from init; a later registration for the same
+// type replaces the earlier one.
+func RegisterCopier[T any](fn func(T) T) {
+	copiers.Store(reflect.TypeFor[T](), func(v any) any { return fn(v.(T)) })
+}
+
+func copyValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	if fn, ok := copiers.Load(reflect.TypeOf(v)); ok {
+		return fn.(func(any) any)(v)
+	}
+	return v
+}
@@ -0,0 +1,159 @@
+package core_test
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+type cart struct {
+	Items []string
+}
+
+func init() {
+	core.RegisterCopier(func(c *cart) *cart {
+		return &cart{Items: append([]string(nil), c.Items...)}
+	})
+	core.RegisterCopier(maps.Clone[map[string]int])
+}
+
+func TestStore_SetGet(t *testing.T) {
+	if core.StoreFrom(context.Background()) != nil {
+		t.Fatal("expected no store on a bare context")
+	}
+	ctx, s := core.WithStore(context.Background())
+	s.Set("tenant", "acme")
+
+	got, ok := core.StoreFrom(ctx).Get("tenant")
+	if !ok || got != "acme" {
+		t.Errorf("Get(tenant) = %v, %v", got, ok)
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("missing key should report ok=false")
+	}
+}
+
+func TestStore_LazyBeforeSet(t *testing.T) {
+	ctx, s := core.WithStore(context.Background())
+	if _, ok := s.Get("tenant"); ok {
+		t.Error("Get before any Set should report ok=false")
+	}
+	if _, ok := core.GetTyped[string](ctx, "tenant"); ok {
+		t.Error("GetTyped before any Set should report ok=false")
+	}
+	if v := core.WithStoreValues(ctx).Value("tenant"); v != nil {
+		t.Errorf("Value before any Set = %v, want nil", v)
+	}
+
+	c := s.Clone()
+	c.Set("tenant", "clone")
+	s.Set("tenant", "acme")
+	if v, _ := s.Get("tenant"); v != "acme" {
+		t.Errorf("store = %v, want acme", v)
+	}
+	if v, _ := c.Get("tenant"); v != "clone" {
+		t.Errorf("clone of an empty store = %v, want its own value", v)
+	}
+}
+
+func TestGetTyped(t *testing.T) {
+	ctx, s := core.WithStore(context.Background())
+	s.Set("tenant", "acme")
+	s.Set("cart", &cart{Items: []string{"book"}})
+
+	if v, ok := core.GetTyped[string](ctx, "tenant"); !ok || v != "acme" {
+		t.Errorf("GetTyped[string](tenant) = %q, %v; want acme, true", v, ok)
+	}
+	if c, ok := core.GetTyped[*cart](ctx, "cart"); !ok || c.Items[0] != "book" {
+		t.Errorf("GetTyped[*cart](cart) = %v, %v", c, ok)
+	}
+	if v, ok := core.GetTyped[int](ctx, "tenant"); ok || v != 0 {
+		t.Errorf("wrong type: got %d, %v; want 0, false", v, ok)
+	}
+	if v, ok := core.GetTyped[string](ctx, "missing"); ok || v != "" {
+		t.Errorf("missing key: got %q, %v; want empty, false", v, ok)
+	}
+	if _, ok := core.GetTyped[string](context.Background(), "tenant"); ok {
+		t.Error("no store: expected ok = false")
+	}
+}
+
+func TestStore_CloneCopiesRegisteredTypes(t *testing.T) {
+	ctx, s := core.WithStore(context.Background())
+	s.Set("cart", &cart{Items: []string{"a"}})
+	s.Set("counts", map[string]int{"a": 1})
+	s.Set("note", "original")
+
+	cloned := core.WithClone(ctx)
+	c := core.StoreFrom(cloned)
+	if c == s {
+		t.Fatal("WithClone should attach a new store")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		v, _ := c.Get("cart")
+		v.(*cart).Items[0] = "changed"
+		v.(*cart).Items = append(v.(*cart).Items, "b")
+		m, _ := c.Get("counts")
+		m.(map[string]int)["a"] = 99
+		c.Set("note", "clone")
+	}()
+	wg.Wait()
+
+	v, _ := s.Get("cart")
+	if items := v.(*cart).Items; len(items) != 1 || items[0] != "a" {
+		t.Errorf("original cart modified through clone: %v", items)
+	}
+	m, _ := s.Get("counts")
+	if m.(map[string]int)["a"] != 1 {
+		t.Errorf("original map modified through clone: %v", m)
+	}
+	if note, _ := s.Get("note"); note != "original" {
+		t.Errorf("original note = %v", note)
+	}
+}
+
+func TestWithStoreValues(t *testing.T) {
+	type otherKey struct{}
+	parent := context.WithValue(context.Background(), otherKey{}, "parent")
+	parent = context.WithValue(parent, "shadowed", "parent")
+
+	ctx, s := core.WithStore(parent)
+	s.Set("request_id", "r-1")
+	s.Set("shadowed", "store")
+	view := core.WithStoreValues(ctx)
+
+	if got := view.Value("request_id"); got != "r-1" {
+		t.Errorf(`Value("request_id") = %v, want r-1`, got)
+	}
+	if got := view.Value("shadowed"); got != "store" {
+		t.Errorf(`Value("shadowed") = %v, want the store value`, got)
+	}
+	if got := view.Value(otherKey{}); got != "parent" {
+		t.Errorf("Value(otherKey) = %v, want parent", got)
+	}
+	if core.StoreFrom(view) != s {
+		t.Error("StoreFrom should still find the store")
+	}
+
+	s.Set("late", 42)
+	if got := view.Value("late"); got != 42 {
+		t.Errorf(`Value("late") = %v, want a live read of 42`, got)
+	}
+	if got := view.Value("missing"); got != nil {
+		t.Errorf(`Value("missing") = %v, want nil`, got)
+	}
+}
+
+func TestWithStoreValues_NoStore(t *testing.T) {
+	ctx := context.Background()
+	if core.WithStoreValues(ctx) != ctx {
+		t.Error("without a store, the context should be returned unchanged")
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import "context"
+
+// SubscribeOptions tunes how a broker consumes one route, for topics whose
+// throughput or payload size differ from the rest. The Router passes them to
+// the broker's Subscribe through its context; brokers read them with
+// SubscribeOptionsFrom and ignore fields they have no equivalent for. Zero
+// fields keep the broker's own setting.
+type SubscribeOptions struct {
+	// Prefetch is how many unacknowledged messages the broker may deliver
+	// to the subscription at once (RabbitMQ's per-consumer QoS).
+	Prefetch int
+
+	// FetchMinBytes and FetchMaxBytes bound the size of each fetch
+	// (Kafka's reader MinBytes and MaxBytes).
+	FetchMinBytes int
+	FetchMaxBytes int
+
+	// Group is the consumer group to consume as (Kafka's group ID,
+	// JetStream's durable consumer name). See WithGroup.
+	Group string
+}
+
+// WithPrefetch sets the route's SubscribeOptions.Prefetch.
+func WithPrefetch(n int) RouteOption {
+	return func(c *routeConfig) { c.subscribe.Prefetch = n }
+}
+
+// WithFetchBytes sets the route's SubscribeOptions.FetchMinBytes and
+// FetchMaxBytes.
+func WithFetchBytes(min, max int) RouteOption {
+	return func(c *routeConfig) {
+		c.subscribe.FetchMinBytes = min
+		c.subscribe.FetchMaxBytes = max
+	}
+}
+
+type subscribeOptionsKey struct{}
+
+// SubscribeOptionsFrom returns the SubscribeOptions of the route being
+// subscribed. It reports false if the route set none.
+func SubscribeOptionsFrom(ctx context.Context) (SubscribeOptions, bool) {
+	o, ok := ctx.Value(subscribeOptionsKey{}).(SubscribeOptions)
+	return o, ok
+}
+
+// WithSubscribeOptions returns a copy of ctx carrying o, for calling a
+// broker's Subscribe directly.
+func WithSubscribeOptions(ctx context.Context, o SubscribeOptions) context.Context {
+	return context.WithValue(ctx, subscribeOptionsKey{}, o)
+}
@@ -0,0 +1,54 @@
+package core_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// optsBroker records the SubscribeOptions each Subscribe call receives.
+type optsBroker struct {
+	*mock.Broker
+	mu   sync.Mutex
+	opts map[string]core.SubscribeOptions
+}
+
+func (b *optsBroker) Subscribe(ctx context.Context, topic string, h core.Handler) error {
+	if o, ok := core.SubscribeOptionsFrom(ctx); ok {
+		b.mu.Lock()
+		b.opts[topic] = o
+		b.mu.Unlock()
+	}
+	return b.Broker.Subscribe(ctx, topic, h)
+}
+
+func TestRouter_SubscribeOptions(t *testing.T) {
+	b := &optsBroker{Broker: mock.NewBroker(), opts: make(map[string]core.SubscribeOptions)}
+	r := core.New(b)
+	noop := func(context.Context, core.Message) error { return nil }
+	r.Handle("clicks", noop, core.WithPrefetch(500), core.WithFetchBytes(64<<10, 4<<20))
+	r.Handle("reports", noop, core.WithPrefetch(1), core.WithFetchBytes(1, 50<<20))
+	r.Handle("orders", noop, core.WithIdleTimeout(time.Hour))
+
+	cancel := startRouter(t, r)
+	defer cancel()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	want := map[string]core.SubscribeOptions{
+		"clicks":  {Prefetch: 500, FetchMinBytes: 64 << 10, FetchMaxBytes: 4 << 20},
+		"reports": {Prefetch: 1, FetchMinBytes: 1, FetchMaxBytes: 50 << 20},
+	}
+	for topic, o := range want {
+		if b.opts[topic] != o {
+			t.Errorf("%s: SubscribeOptions = %+v, want %+v", topic, b.opts[topic], o)
+		}
+	}
+	if o, ok := b.opts["orders"]; ok {
+		t.Errorf("orders: got SubscribeOptions %+v, want none", o)
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TopicValidator checks a topic before the Router publishes to it. It
+// should return an error wrapping ErrInvalidTopic for topics the broker
+// would reject or misinterpret.
+type TopicValidator func(topic string) error
+
+// ValidateTopic is the default TopicValidator. It rejects empty topics,
+// wildcard characters ("*", "#" and NATS's ">"), whitespace and control
+// characters, which are almost always a programming error on publish.
+func ValidateTopic(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("%w: empty topic", ErrInvalidTopic)
+	}
+	if i := strings.IndexAny(topic, "*#>"); i >= 0 {
+		return fmt.Errorf("%w %q: wildcard %q is not allowed when publishing", ErrInvalidTopic, topic, topic[i])
+	}
+	for _, c := range topic {
+		if unicode.IsSpace(c) || unicode.IsControl(c) {
+			return fmt.Errorf("%w %q: contains whitespace or control character %q", ErrInvalidTopic, topic, c)
+		}
+	}
+	return nil
+}
+
+// checkAllowlist returns an error wrapping ErrTopicNotAllowed that names
+// every route pattern not allowed by allowlist. An empty allowlist allows
+// everything.
+func checkAllowlist(routes map[string]Handler, allowlist []string) error {
+	if len(allowlist) == 0 {
+		return nil
+	}
+	var denied []string
+	for pattern := range routes {
+		if !allowed(pattern, allowlist) {
+			denied = append(denied, fmt.Sprintf("%q", pattern))
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	sort.Strings(denied)
+	return fmt.Errorf("%w: %s", ErrTopicNotAllowed, strings.Join(denied, ", "))
+}
+
+// allowed reports whether pattern is allowed by allowlist, as described by
+// WithTopicAllowlist.
+func allowed(pattern string, allowlist []string) bool {
+	for _, a := range allowlist {
+		if prefix, ok := strings.CutSuffix(a, "#"); ok {
+			if strings.HasPrefix(pattern, prefix) {
+				return true
+			}
+		} else if pattern == a {
+			return true
+		}
+	}
+	return false
+}
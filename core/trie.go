@@ -0,0 +1,110 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// patternTrie indexes route patterns level by level so Dispatch can find
+// the most specific pattern matching a topic. Levels are compared from the
+// left; at each level a literal segment is preferred over "*" or a ":name"
+// capture, which is preferred over "#". Patterns that differ only in the
+// names of their captures share a node, and the lexically first one wins.
+type patternTrie struct {
+	literal  map[string]*patternTrie
+	one      *patternTrie // "*" and ":name"
+	multi    *patternTrie // "#"
+	patterns []string     // patterns ending at this node, sorted
+}
+
+// insert adds pattern to the trie. Inserting a pattern twice is a no-op.
+func (t *patternTrie) insert(pattern string) {
+	n := t
+	for _, seg := range strings.Split(pattern, ".") {
+		n = n.child(seg)
+	}
+	i := sort.SearchStrings(n.patterns, pattern)
+	if i < len(n.patterns) && n.patterns[i] == pattern {
+		return
+	}
+	n.patterns = append(n.patterns, "")
+	copy(n.patterns[i+1:], n.patterns[i:])
+	n.patterns[i] = pattern
+}
+
+// child returns the node for seg below n, creating it if needed.
+func (n *patternTrie) child(seg string) *patternTrie {
+	switch {
+	case seg == "#":
+		if n.multi == nil {
+			n.multi = &patternTrie{}
+		}
+		return n.multi
+	case seg == "*" || isCapture(seg):
+		if n.one == nil {
+			n.one = &patternTrie{}
+		}
+		return n.one
+	default:
+		if n.literal == nil {
+			n.literal = make(map[string]*patternTrie)
+		}
+		c, ok := n.literal[seg]
+		if !ok {
+			c = &patternTrie{}
+			n.literal[seg] = c
+		}
+		return c
+	}
+}
+
+// lookup returns the most specific pattern matching topic, with the same
+// semantics as DefaultMatcher.
+func (t *patternTrie) lookup(topic string) (string, bool) {
+	return t.find(strings.Split(topic, "."), 0)
+}
+
+// find matches segs[i:] against the patterns below n.
+func (n *patternTrie) find(segs []string, i int) (string, bool) {
+	if i == len(segs) {
+		if len(n.patterns) > 0 {
+			return n.patterns[0], true
+		}
+		return "", false
+	}
+	return n.descend(segs, i)
+}
+
+// descend tries n's children against segs[i], most specific first, and
+// backtracks to broader ones when the rest of the topic does not match.
+func (n *patternTrie) descend(segs []string, i int) (string, bool) {
+	if c, ok := n.literal[segs[i]]; ok {
+		if p, ok := c.find(segs, i+1); ok {
+			return p, true
+		}
+	}
+	if n.one != nil {
+		if p, ok := n.one.find(segs, i+1); ok {
+			return p, true
+		}
+	}
+	if n.multi != nil {
+		return n.multi.findMulti(segs, i)
+	}
+	return "", false
+}
+
+// findMulti matches segs[i:] at a "#" node. A trailing "#" consumes all
+// remaining levels, at least one; a "#" followed by more levels may consume
+// any number, including none, and the fewest are tried first.
+func (n *patternTrie) findMulti(segs []string, i int) (string, bool) {
+	for j := i; j < len(segs); j++ {
+		if p, ok := n.descend(segs, j); ok {
+			return p, true
+		}
+	}
+	if i < len(segs) && len(n.patterns) > 0 {
+		return n.patterns[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package core
+
+import "testing"
+
+func TestPatternTrie_MostSpecific(t *testing.T) {
+	var trie patternTrie
+	for _, p := range []string{
+		"#",
+		"orders.#",
+		"orders.*",
+		"orders.created",
+		"orders.*.shipped",
+		"orders.created.#",
+		"tenant.:id.orders",
+		"tenant.*.orders",
+		"a.#.z",
+	} {
+		trie.insert(p)
+	}
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"orders.created", "orders.created"},
+		{"orders.updated", "orders.*"},
+		{"orders.us.east", "orders.#"},
+		{"orders.created.shipped", "orders.created.#"}, // leftmost level decides
+		{"orders.updated.shipped", "orders.*.shipped"},
+		{"orders.updated.cancelled", "orders.#"},
+		{"tenant.acme.orders", "tenant.*.orders"}, // same node, lexically first
+		{"a.z", "a.#.z"},
+		{"a.b.c.z", "a.#.z"},
+		{"payments", "#"},
+		{"orders", "#"},
+	}
+	for _, tt := range tests {
+		got, ok := trie.lookup(tt.topic)
+		if !ok || got != tt.want {
+			t.Errorf("lookup(%q) = %q, %v; want %q", tt.topic, got, ok, tt.want)
+		}
+	}
+}
+
+func TestPatternTrie_AgreesWithDefaultMatcher(t *testing.T) {
+	patterns := []string{
+		"orders.created", "orders.*", "*.created", "orders.#", "#",
+		"a.#.z", "a.*.#", "#.z", "tenant.:id.#", "a.#.#",
+	}
+	topics := []string{
+		"orders", "orders.created", "orders.us.created", "payments.created",
+		"a", "a.z", "a.b.z", "a.b.c", "z", "tenant.acme", "tenant.acme.x",
+	}
+	for _, p := range patterns {
+		var trie patternTrie
+		trie.insert(p)
+		for _, topic := range topics {
+			_, got := trie.lookup(topic)
+			if want := (DefaultMatcher{}).Match(p, topic); got != want {
+				t.Errorf("pattern %q, topic %q: trie %v, DefaultMatcher %v", p, topic, got, want)
+			}
+		}
+	}
+}
+
+func TestPatternTrie_InsertTwice(t *testing.T) {
+	var trie patternTrie
+	trie.insert("orders.*")
+	trie.insert("orders.*")
+	if n := len(trie.literal["orders"].one.patterns); n != 1 {
+		t.Errorf("patterns = %d, want 1", n)
+	}
+}
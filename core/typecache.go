@@ -0,0 +1,29 @@
+package core
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TypeCache memoizes per-type metadata, such as field layouts computed by
+// reflection, for Binder implementations that decode the same types
+// repeatedly. It is safe for concurrent use; build may run more than once
+// for a type under contention, and one result wins.
+type TypeCache[T any] struct {
+	build func(reflect.Type) T
+	m     sync.Map // reflect.Type -> T
+}
+
+// NewTypeCache returns a TypeCache that computes missing entries with build.
+func NewTypeCache[T any](build func(reflect.Type) T) *TypeCache[T] {
+	return &TypeCache[T]{build: build}
+}
+
+// Get returns the metadata for t, computing and caching it on first use.
+func (c *TypeCache[T]) Get(t reflect.Type) T {
+	if v, ok := c.m.Load(t); ok {
+		return v.(T)
+	}
+	v, _ := c.m.LoadOrStore(t, c.build(t))
+	return v.(T)
+}
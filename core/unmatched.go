@@ -0,0 +1,80 @@
+package core
+
+import (
+	"log"
+	"maps"
+	"sync"
+)
+
+// UnmatchedOverflow is the UnmatchedTopics key that counts messages on
+// topics first seen after the limit set with WithUnmatchedTopicLimit.
+const UnmatchedOverflow = "(other)"
+
+// defaultUnmatchedLimit is the number of distinct unmatched topics tracked
+// by default.
+const defaultUnmatchedLimit = 100
+
+// WithUnmatchedLog logs the first message Dispatch receives on each topic
+// that no route pattern matches, whether or not the Default handler takes
+// it, so operators notice topics nobody handles. Each topic is logged once,
+// up to the limit set with WithUnmatchedTopicLimit.
+func WithUnmatchedLog() Option {
+	return func(r *Router) { r.logUnmatched = true }
+}
+
+// WithUnmatchedTopicLimit sets how many distinct topics UnmatchedTopics
+// tracks, keeping metric labels derived from it bounded. Messages on topics
+// beyond the limit are counted under UnmatchedOverflow. The default is 100.
+func WithUnmatchedTopicLimit(n int) Option {
+	return func(r *Router) { r.unmatchedLimit = n }
+}
+
+// UnmatchedTopics returns how many messages Dispatch has received on each
+// topic that no route pattern matched, including those the Default handler
+// took. Stats.Unmatched is the total.
+func (r *Router) UnmatchedTopics() map[string]int64 {
+	r.unmatched.mu.Lock()
+	defer r.unmatched.mu.Unlock()
+	return maps.Clone(r.unmatched.counts)
+}
+
+// unmatchedTopics counts unmatched messages per topic.
+type unmatchedTopics struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// recordUnmatched counts a message on topic that matched no route.
+func (r *Router) recordUnmatched(topic string) {
+	r.unmatchedCount.Add(1)
+
+	u := &r.unmatched
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.counts[topic]; ok {
+		u.counts[topic]++
+		return
+	}
+	limit := r.unmatchedLimit
+	if limit <= 0 {
+		limit = defaultUnmatchedLimit
+	}
+	if u.counts == nil {
+		u.counts = make(map[string]int64)
+	}
+	tracked := len(u.counts)
+	if _, ok := u.counts[UnmatchedOverflow]; ok {
+		tracked--
+	}
+	if tracked >= limit {
+		if u.counts[UnmatchedOverflow] == 0 && r.logUnmatched {
+			log.Printf("[EventMux] more than %d unmatched topics; counting further ones as %q", limit, UnmatchedOverflow)
+		}
+		u.counts[UnmatchedOverflow]++
+		return
+	}
+	u.counts[topic] = 1
+	if r.logUnmatched {
+		log.Printf("[EventMux] no route matches topic %q", topic)
+	}
+}
@@ -0,0 +1,118 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestRouter_UnmatchedCounter(t *testing.T) {
+	r := core.New(mock.NewBroker())
+	r.Handle("orders.*", func(context.Context, core.Message) error { return nil })
+
+	if err := r.Dispatch(context.Background(), "orders.created", &mock.Message{}); err != nil {
+		t.Fatalf("matched dispatch: %v", err)
+	}
+	for range 2 {
+		if err := r.Dispatch(context.Background(), "payments.settled", &mock.Message{}); !errors.Is(err, core.ErrNoHandler) {
+			t.Fatalf("unmatched dispatch = %v, want ErrNoHandler", err)
+		}
+	}
+
+	if got := r.Stats().Unmatched; got != 2 {
+		t.Errorf("Stats().Unmatched = %d, want 2", got)
+	}
+	topics := r.UnmatchedTopics()
+	if len(topics) != 1 || topics["payments.settled"] != 2 {
+		t.Errorf("UnmatchedTopics = %v, want payments.settled: 2", topics)
+	}
+}
+
+func TestRouter_UnmatchedCountedWithDefault(t *testing.T) {
+	r := core.New(mock.NewBroker(), core.WithDispatchMode(core.DispatchAllMatching))
+	r.Handle("orders.*", func(context.Context, core.Message) error { return nil })
+	var defaulted int
+	r.Default(func(context.Context, core.Message) error {
+		defaulted++
+		return nil
+	})
+
+	if err := r.Dispatch(context.Background(), "audit.login", &mock.Message{}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if defaulted != 1 {
+		t.Errorf("default handler ran %d times, want 1", defaulted)
+	}
+	if got := r.UnmatchedTopics()["audit.login"]; got != 1 {
+		t.Errorf("unmatched count for audit.login = %d, want 1", got)
+	}
+}
+
+func TestRouter_UnmatchedTopicLimit(t *testing.T) {
+	var mu sync.Mutex
+	var logged []string
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		logged = append(logged, string(p))
+		return len(p), nil
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	r := core.New(mock.NewBroker(), core.WithUnmatchedLog(), core.WithUnmatchedTopicLimit(2))
+	r.Handle("orders", func(context.Context, core.Message) error { return nil })
+	for _, topic := range []string{"a", "b", "a", "c", "d", "b", "c"} {
+		_ = r.Dispatch(context.Background(), topic, &mock.Message{})
+	}
+
+	want := map[string]int64{"a": 2, "b": 2, core.UnmatchedOverflow: 3}
+	got := r.UnmatchedTopics()
+	if len(got) != len(want) {
+		t.Fatalf("UnmatchedTopics = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("UnmatchedTopics[%q] = %d, want %d", k, got[k], v)
+		}
+	}
+	if s := r.Stats().Unmatched; s != 7 {
+		t.Errorf("Stats().Unmatched = %d, want 7", s)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logged) != 3 {
+		t.Fatalf("logged %d lines, want one per tracked topic and one for the overflow: %q", len(logged), logged)
+	}
+	for i, want := range []string{`no route matches topic "a"`, `no route matches topic "b"`, fmt.Sprintf("more than 2 unmatched topics; counting further ones as %q", core.UnmatchedOverflow)} {
+		if !strings.Contains(logged[i], want) {
+			t.Errorf("log line %d = %q, want %q", i, logged[i], want)
+		}
+	}
+}
+
+func TestRouter_UnmatchedNotLoggedByDefault(t *testing.T) {
+	var logged int
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		logged++
+		return len(p), nil
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	r := core.New(mock.NewBroker())
+	_ = r.Dispatch(context.Background(), "nobody.home", &mock.Message{})
+	if logged != 0 {
+		t.Errorf("logged %d lines without WithUnmatchedLog", logged)
+	}
+	if r.UnmatchedTopics()["nobody.home"] != 1 {
+		t.Error("unmatched topics are counted even without logging")
+	}
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// XMLBinder decodes XML payloads with encoding/xml, for producers that do
+// not speak JSON. Select it with WithBinder or SetBinder.
+//
+// Namespaces follow encoding/xml: a field or XMLName tagged
+// `xml:"urn:example:orders order"` only matches elements in that namespace,
+// while an untagged name matches the local name in any namespace.
+type XMLBinder struct {
+	// AllowEmpty makes Bind a no-op for empty payloads, leaving v at its
+	// current value. By default empty payloads return ErrEmptyPayload.
+	AllowEmpty bool
+}
+
+// Bind implements Binder. A document whose root element does not match v's
+// XMLName, by local name or namespace, fails with ErrUnexpectedElement.
+func (b XMLBinder) Bind(msg Message, v any) error {
+	if isEmptyPayload(msg) {
+		if b.AllowEmpty {
+			return nil
+		}
+		return ErrEmptyPayload
+	}
+	err := xml.Unmarshal(msg.Value(), v)
+	if err == nil {
+		return nil
+	}
+	var uerr xml.UnmarshalError
+	if errors.As(err, &uerr) && strings.HasPrefix(string(uerr), "expected element") {
+		return fmt.Errorf("eventmux: bind: %w: %s (check the XMLName of %T, including its namespace)",
+			ErrUnexpectedElement, strings.TrimPrefix(string(uerr), "expected "), v)
+	}
+	return fmt.Errorf("eventmux: bind: %w", err)
+}
@@ -0,0 +1,70 @@
+package core_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+type xmlOrder struct {
+	XMLName xml.Name `xml:"urn:example:orders order"`
+	ID      string   `xml:"id,attr"`
+	Items   []string `xml:"items>item"`
+	Total   float64  `xml:"total"`
+}
+
+func TestXMLBinder(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<o:order xmlns:o="urn:example:orders" id="42">
+  <o:items><o:item>book</o:item><o:item>pen</o:item></o:items>
+  <o:total>12.5</o:total>
+</o:order>`
+
+	var o xmlOrder
+	if err := (core.XMLBinder{}).Bind(&mock.Message{V: []byte(doc)}, &o); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if o.ID != "42" || o.Total != 12.5 || strings.Join(o.Items, ",") != "book,pen" {
+		t.Errorf("bound %+v", o)
+	}
+}
+
+func TestXMLBinder_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantIs  error
+		wantMsg string
+	}{
+		{"malformed", `<order xmlns="urn:example:orders"><total>1</order>`, nil, "syntax error"},
+		{"wrong element", `<invoice xmlns="urn:example:orders"/>`, core.ErrUnexpectedElement, "<order>"},
+		{"wrong namespace", `<order xmlns="urn:example:invoices"/>`, core.ErrUnexpectedElement, "urn:example:orders"},
+		{"empty", "  ", core.ErrEmptyPayload, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o xmlOrder
+			err := (core.XMLBinder{}).Bind(&mock.Message{V: []byte(tt.doc)}, &o)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error %v is not %v", err, tt.wantIs)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestXMLBinder_AllowEmpty(t *testing.T) {
+	o := xmlOrder{ID: "keep"}
+	if err := (core.XMLBinder{AllowEmpty: true}).Bind(&mock.Message{}, &o); err != nil || o.ID != "keep" {
+		t.Errorf("Bind(empty) = %v, %+v", err, o)
+	}
+}
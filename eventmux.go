@@ -7,7 +7,10 @@
 package eventmux
 
 import (
+	"context"
+
 	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/core/middleware"
 )
 
 // Re-export core types at the package level for ergonomic usage.
@@ -17,9 +20,57 @@ type (
 	Middleware = core.Middleware
 	Broker     = core.Broker
 	Router     = core.Router
+	Option     = core.Option
+	Result     = core.Result
+
+	PublishInterceptor = core.PublishInterceptor
+	ReconnectEvent     = core.ReconnectEvent
+	RouteOption        = core.RouteOption
 )
 
 // New creates a new Router bound to the given Broker.
-func New(b Broker) *Router {
-	return core.New(b)
+func New(b Broker, opts ...Option) *Router {
+	return core.New(b, opts...)
+}
+
+// NewWithDefaults creates a Router like New with middleware.Logging and
+// middleware.Recovery already installed. Recovery runs inside Logging, so a
+// panicking handler is recovered and logged as a failure instead of
+// crashing the process. Use New to choose the middleware yourself.
+func NewWithDefaults(b Broker, opts ...Option) *Router {
+	r := core.New(b, opts...)
+	r.Use(middleware.Logging())
+	r.Use(middleware.Recovery())
+	return r
+}
+
+// AckResult tells the Router to acknowledge the message.
+func AckResult() error { return core.AckResult() }
+
+// NackResult tells the Router to negatively acknowledge the message.
+func NackResult() error { return core.NackResult() }
+
+// DLQResult tells the Router to dead-letter the message with the given reason.
+func DLQResult(reason string) error { return core.DLQResult(reason) }
+
+// Bytes adapts a payload-only function into a Handler that acks on nil and
+// nacks on error.
+func Bytes(fn func(ctx context.Context, value []byte) error) Handler { return core.Bytes(fn) }
+
+// DeadLetter republishes msg to the Router's dead-letter topic and acks it.
+func DeadLetter(ctx context.Context, msg Message, reason string) error {
+	return core.DeadLetter(ctx, msg, reason)
 }
+
+// Ready releases routes registered with HandleAfter on the current route.
+func Ready(ctx context.Context) { core.Ready(ctx) }
+
+// GetTyped returns the value stored under key in the context's Store as a
+// T, with ok false if it is missing or of another type.
+func GetTyped[T any](ctx context.Context, key string) (T, bool) { return core.GetTyped[T](ctx, key) }
+
+// Param returns the topic segment captured by ":name" in the route pattern.
+func Param(ctx context.Context, name string) string { return core.Param(ctx, name) }
+
+// Bind decodes msg into v using the Router's Binder.
+func Bind(ctx context.Context, msg Message, v any) error { return core.Bind(ctx, msg, v) }
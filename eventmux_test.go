@@ -0,0 +1,78 @@
+package eventmux_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestNewWithDefaults_RecoversAndLogs(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(nil)
+
+	r := eventmux.NewWithDefaults(mock.NewBroker())
+	r.Handle("orders.created", func(ctx context.Context, msg eventmux.Message) error {
+		panic("nil order")
+	})
+
+	err := r.Dispatch(context.Background(), "orders.created", &mock.Message{K: []byte("o-1")})
+	if err == nil || !strings.Contains(err.Error(), "nil order") {
+		t.Fatalf("expected the panic as an error, got %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "PANIC recovered: nil order") {
+		t.Errorf("panic was not logged by Recovery:\n%s", out)
+	}
+	if !strings.Contains(out, "ERROR key=o-1") {
+		t.Errorf("failure was not logged by Logging:\n%s", out)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	r := eventmux.New(mock.NewBroker())
+	var got []string
+	r.Handle("orders.created", eventmux.Bytes(func(ctx context.Context, value []byte) error {
+		got = append(got, string(value))
+		if string(value) == "bad" {
+			return errors.New("invalid order")
+		}
+		return nil
+	}))
+
+	ok := &mock.Message{V: []byte("good")}
+	if err := r.Dispatch(context.Background(), "orders.created", ok); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if !ok.Acked || ok.Nacked {
+		t.Errorf("success: acked=%v nacked=%v, want acked", ok.Acked, ok.Nacked)
+	}
+
+	bad := &mock.Message{V: []byte("bad")}
+	if err := r.Dispatch(context.Background(), "orders.created", bad); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if bad.Acked || !bad.Nacked {
+		t.Errorf("failure: acked=%v nacked=%v, want nacked", bad.Acked, bad.Nacked)
+	}
+	if len(got) != 2 || got[0] != "good" || got[1] != "bad" {
+		t.Errorf("payloads = %v", got)
+	}
+}
+
+func TestBytes_ErrorVisibleToMiddleware(t *testing.T) {
+	h := eventmux.Bytes(func(ctx context.Context, value []byte) error {
+		return errors.New("invalid order")
+	})
+	err := h(context.Background(), &mock.Message{})
+	if err == nil || !strings.Contains(err.Error(), "invalid order") {
+		t.Errorf("handler error = %v, want the cause", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package brokertest is the contract every core.Broker implementation must
+// satisfy. Each plugin runs it from its tests, with a real server where the
+// broker cannot be built without one.
+package brokertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Factory returns a new, open broker. It is called once per contract case.
+type Factory func(t *testing.T) core.Broker
+
+// message is a minimal core.Message for publishing.
+type message struct{}
+
+func (message) Key() []byte                { return []byte("k") }
+func (message) Value() []byte              { return []byte("v") }
+func (message) Headers() map[string]string { return nil }
+func (message) Ack() error                 { return nil }
+func (message) Nack() error                { return nil }
+
+// RunClose checks the Close contract:
+//   - Close is idempotent: closing twice returns nil the second time.
+//   - Publish after Close returns core.ErrBrokerClosed.
+//   - Subscribe after Close returns core.ErrBrokerClosed without blocking.
+//
+// topic is a topic or subject valid for the broker.
+func RunClose(t *testing.T, topic string, newBroker Factory) {
+	tests := []struct {
+		name  string
+		check func(t *testing.T, b core.Broker)
+	}{
+		{"DoubleClose", func(t *testing.T, b core.Broker) {
+			if err := b.Close(); err != nil {
+				t.Errorf("second Close = %v, want nil", err)
+			}
+		}},
+		{"PublishAfterClose", func(t *testing.T, b core.Broker) {
+			err := b.Publish(context.Background(), topic, message{})
+			if !errors.Is(err, core.ErrBrokerClosed) {
+				t.Errorf("Publish after Close = %v, want ErrBrokerClosed", err)
+			}
+		}},
+		{"SubscribeAfterClose", func(t *testing.T, b core.Broker) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			noop := func(context.Context, core.Message) error { return nil }
+			err := b.Subscribe(ctx, topic, noop)
+			if !errors.Is(err, core.ErrBrokerClosed) {
+				t.Errorf("Subscribe after Close = %v, want ErrBrokerClosed", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBroker(t)
+			if err := b.Close(); err != nil {
+				t.Fatalf("Close = %v", err)
+			}
+			tt.check(t, b)
+		})
+	}
+}
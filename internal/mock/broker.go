@@ -1,7 +1,9 @@
 package mock
 
 import (
+	"bytes"
 	"context"
+	"maps"
 	"sync"
 
 	"github.com/miladsoleymani/eventmux/core"
@@ -12,35 +14,62 @@ type Broker struct {
 	mu           sync.Mutex
 	published    []PublishedMessage
 	handlers     map[string]core.Handler
+	delivered    map[string][]core.Message
 	SubscribeErr error
 	PublishErr   error
 	closed       bool
+	reconnects   chan core.ReconnectEvent
 }
 
-// PublishedMessage records a message sent through Publish.
+// PublishedMessage records a message sent through Publish. Key, Value and
+// Headers are copies taken at publish time, so assertions are not affected
+// by later changes to the original message.
 type PublishedMessage struct {
 	Topic   string
 	Message core.Message
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Header returns the value of the named header as published.
+func (p PublishedMessage) Header(key string) string {
+	return p.Headers[key]
 }
 
 func NewBroker() *Broker {
 	return &Broker{
-		handlers: make(map[string]core.Handler),
+		handlers:   make(map[string]core.Handler),
+		delivered:  make(map[string][]core.Message),
+		reconnects: make(chan core.ReconnectEvent, 16),
 	}
 }
 
 func (b *Broker) Publish(_ context.Context, topic string, msg core.Message) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	if b.closed {
+		return core.ErrBrokerClosed
+	}
 	if b.PublishErr != nil {
 		return b.PublishErr
 	}
-	b.published = append(b.published, PublishedMessage{Topic: topic, Message: msg})
+	b.published = append(b.published, PublishedMessage{
+		Topic:   topic,
+		Message: msg,
+		Key:     bytes.Clone(msg.Key()),
+		Value:   bytes.Clone(msg.Value()),
+		Headers: maps.Clone(msg.Headers()),
+	})
 	return nil
 }
 
 func (b *Broker) Subscribe(ctx context.Context, topic string, handler core.Handler) error {
 	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
 	if b.SubscribeErr != nil {
 		err := b.SubscribeErr
 		b.mu.Unlock()
@@ -61,10 +90,16 @@ func (b *Broker) Close() error {
 	return nil
 }
 
-// Deliver simulates an incoming message to a registered handler.
+// Deliver simulates an incoming message to a registered handler. The
+// message is recorded under its key (see DeliveredByKey) before the handler
+// runs.
 func (b *Broker) Deliver(ctx context.Context, topic string, msg core.Message) error {
 	b.mu.Lock()
 	h, ok := b.handlers[topic]
+	if ok {
+		key := string(msg.Key())
+		b.delivered[key] = append(b.delivered[key], msg)
+	}
 	b.mu.Unlock()
 	if !ok {
 		return core.ErrNoHandler
@@ -72,6 +107,28 @@ func (b *Broker) Deliver(ctx context.Context, topic string, msg core.Message) er
 	return h(ctx, msg)
 }
 
+// DeliverMany delivers msgs to topic one at a time in the order given,
+// waiting for each handler to return, as a single-partition broker would.
+// It stops at the first error and returns it.
+func (b *Broker) DeliverMany(ctx context.Context, topic string, msgs ...core.Message) error {
+	for _, msg := range msgs {
+		if err := b.Deliver(ctx, topic, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliveredByKey returns the messages delivered with the given key, in
+// delivery order.
+func (b *Broker) DeliveredByKey(key string) []core.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]core.Message, len(b.delivered[key]))
+	copy(out, b.delivered[key])
+	return out
+}
+
 // Published returns all messages sent via Publish.
 func (b *Broker) Published() []PublishedMessage {
 	b.mu.Lock()
@@ -81,9 +138,32 @@ func (b *Broker) Published() []PublishedMessage {
 	return out
 }
 
+// PublishedTo returns the messages sent via Publish to topic, in order.
+func (b *Broker) PublishedTo(topic string) []PublishedMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []PublishedMessage
+	for _, p := range b.published {
+		if p.Topic == topic {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 // IsClosed reports whether Close was called.
 func (b *Broker) IsClosed() bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	return b.closed
 }
+
+// Reconnects implements core.Reconnecter.
+func (b *Broker) Reconnects() <-chan core.ReconnectEvent {
+	return b.reconnects
+}
+
+// EmitReconnect simulates the broker reporting a reconnect.
+func (b *Broker) EmitReconnect(ev core.ReconnectEvent) {
+	b.reconnects <- ev
+}
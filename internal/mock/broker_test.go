@@ -0,0 +1,123 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/brokertest"
+)
+
+// subscribe registers h for topic and returns once it is in place.
+func subscribe(t *testing.T, b *Broker, topic string, h core.Handler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Subscribe(ctx, topic, h)
+	for {
+		b.mu.Lock()
+		_, ok := b.handlers[topic]
+		b.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBroker_DeliverManyPreservesKeyOrder(t *testing.T) {
+	b := NewBroker()
+	var seen []string
+	subscribe(t, b, "orders", func(ctx context.Context, msg core.Message) error {
+		seen = append(seen, string(msg.Key())+":"+string(msg.Value()))
+		return nil
+	})
+
+	msgs := []core.Message{
+		&Message{K: []byte("a"), V: []byte("1")},
+		&Message{K: []byte("b"), V: []byte("1")},
+		&Message{K: []byte("a"), V: []byte("2")},
+		&Message{K: []byte("b"), V: []byte("2")},
+		&Message{K: []byte("a"), V: []byte("3")},
+	}
+	if err := b.DeliverMany(context.Background(), "orders", msgs...); err != nil {
+		t.Fatalf("DeliverMany: %v", err)
+	}
+
+	want := []string{"a:1", "b:1", "a:2", "b:2", "a:3"}
+	if len(seen) != len(want) {
+		t.Fatalf("handler saw %v, want %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("delivery %d = %s, want %s", i, seen[i], want[i])
+		}
+	}
+
+	for key, values := range map[string][]string{"a": {"1", "2", "3"}, "b": {"1", "2"}} {
+		got := b.DeliveredByKey(key)
+		if len(got) != len(values) {
+			t.Fatalf("key %q: %d deliveries, want %d", key, len(got), len(values))
+		}
+		for i, v := range values {
+			if string(got[i].Value()) != v {
+				t.Errorf("key %q delivery %d = %s, want %s", key, i, got[i].Value(), v)
+			}
+		}
+	}
+}
+
+func TestBroker_DeliverManyStopsOnError(t *testing.T) {
+	b := NewBroker()
+	boom := errors.New("boom")
+	subscribe(t, b, "orders", func(ctx context.Context, msg core.Message) error {
+		if string(msg.Value()) == "2" {
+			return boom
+		}
+		return nil
+	})
+
+	err := b.DeliverMany(context.Background(), "orders",
+		&Message{K: []byte("a"), V: []byte("1")},
+		&Message{K: []byte("a"), V: []byte("2")},
+		&Message{K: []byte("a"), V: []byte("3")},
+	)
+	if !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if got := len(b.DeliveredByKey("a")); got != 2 {
+		t.Errorf("expected delivery to stop after the failure, got %d deliveries", got)
+	}
+}
+
+func TestBroker_PublishedSnapshot(t *testing.T) {
+	b := NewBroker()
+	msg := &Message{K: []byte("k"), V: []byte("v1"), H: map[string]string{"trace-id": "abc"}}
+	if err := b.Publish(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	b.Publish(context.Background(), "payments", &Message{V: []byte("p")})
+
+	msg.V[1] = '2'
+	msg.H["trace-id"] = "changed"
+
+	pubs := b.PublishedTo("orders")
+	if len(pubs) != 1 {
+		t.Fatalf("PublishedTo(orders) = %d messages, want 1", len(pubs))
+	}
+	if string(pubs[0].Key) != "k" || string(pubs[0].Value) != "v1" {
+		t.Errorf("key/value = %q/%q, want k/v1", pubs[0].Key, pubs[0].Value)
+	}
+	if got := pubs[0].Header("trace-id"); got != "abc" {
+		t.Errorf("trace-id = %q, want abc as published", got)
+	}
+	if got := b.PublishedTo("missing"); len(got) != 0 {
+		t.Errorf("PublishedTo(missing) = %v", got)
+	}
+}
+
+func TestBroker_CloseContract(t *testing.T) {
+	brokertest.RunClose(t, "orders", func(t *testing.T) core.Broker { return NewBroker() })
+}
@@ -1,19 +1,26 @@
 package mock
 
+import "time"
+
 // Message is a simple core.Message implementation for testing.
 type Message struct {
 	K       []byte
 	V       []byte
 	H       map[string]string
+	T       string
 	Acked   bool
 	Nacked  bool
 	AckErr  error
 	NackErr error
+
+	// NackDelay records the delay passed to NackWithDelay.
+	NackDelay time.Duration
 }
 
 func (m *Message) Key() []byte              { return m.K }
 func (m *Message) Value() []byte            { return m.V }
 func (m *Message) Headers() map[string]string { return m.H }
+func (m *Message) Topic() string              { return m.T }
 
 func (m *Message) Ack() error {
 	m.Acked = true
@@ -24,3 +31,9 @@ func (m *Message) Nack() error {
 	m.Nacked = true
 	return m.NackErr
 }
+
+// NackWithDelay implements core.DelayedNacker.
+func (m *Message) NackWithDelay(d time.Duration) error {
+	m.NackDelay = d
+	return m.Nack()
+}
@@ -0,0 +1,56 @@
+// Package otelbaggage propagates OpenTelemetry baggage, such as a tenant or
+// feature flags, through the W3C baggage header, so values a producer set
+// reach the handler's context and every message it publishes in turn.
+// Baggage travels independently of trace context; see oteltrace for that.
+package otelbaggage
+
+import (
+	"context"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Header is the W3C header that carries baggage.
+const Header = "baggage"
+
+// Propagator is a core.ContextPropagator for W3C baggage. Register it with
+// core.WithPropagator; handlers then read it with baggage.FromContext, and
+// messages they publish carry it on.
+type Propagator struct{}
+
+var _ core.ContextPropagator = Propagator{}
+
+// Extract implements core.ContextPropagator. Baggage already in ctx is
+// replaced by the message's, as in otel's HTTP instrumentation; messages
+// without a baggage header leave ctx unchanged.
+func (Propagator) Extract(ctx context.Context, msg core.Message) context.Context {
+	return propagation.Baggage{}.Extract(ctx, carrier{msg: msg})
+}
+
+// Inject implements core.ContextPropagator. Messages are returned unchanged
+// when ctx carries no baggage.
+func (Propagator) Inject(ctx context.Context, msg core.Message) core.Message {
+	if baggage.FromContext(ctx).Len() == 0 {
+		return msg
+	}
+	h := make(map[string]string, 1)
+	propagation.Baggage{}.Inject(ctx, propagation.MapCarrier(h))
+	return core.MergeHeaders(msg, h)
+}
+
+// carrier reads headers from an inbound message.
+type carrier struct {
+	msg core.Message
+}
+
+func (c carrier) Get(key string) string { return core.Header(c.msg, key) }
+func (c carrier) Set(string, string)    {}
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers()))
+	for k := range c.msg.Headers() {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,70 @@
+package otelbaggage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestPropagator_ExtractAndReinject(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithPropagator(Propagator{}))
+
+	var tenant, flags string
+	r.Handle("orders", func(ctx context.Context, msg core.Message) error {
+		bag := baggage.FromContext(ctx)
+		tenant = bag.Member("tenant").Value()
+		flags = bag.Member("flags").Value()
+		return r.Publish(ctx, "invoices", &mock.Message{V: []byte("inv")})
+	})
+
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{Header: "tenant=acme,flags=beta"}}
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if tenant != "acme" || flags != "beta" {
+		t.Errorf("handler baggage tenant=%q flags=%q", tenant, flags)
+	}
+
+	pubs := mb.PublishedTo("invoices")
+	if len(pubs) != 1 {
+		t.Fatalf("published %d messages, want 1", len(pubs))
+	}
+	got, err := baggage.Parse(pubs[0].Header(Header))
+	if err != nil {
+		t.Fatalf("republished baggage %q: %v", pubs[0].Header(Header), err)
+	}
+	if got.Member("tenant").Value() != "acme" || got.Member("flags").Value() != "beta" {
+		t.Errorf("republished baggage = %q", got)
+	}
+}
+
+func TestPropagator_NoBaggage(t *testing.T) {
+	ctx := context.Background()
+	msg := &mock.Message{H: map[string]string{"other": "x"}}
+
+	if got := (Propagator{}).Extract(ctx, msg); baggage.FromContext(got).Len() != 0 {
+		t.Errorf("extract without header = %v", baggage.FromContext(got))
+	}
+	if got := (Propagator{}).Inject(ctx, msg); got != core.Message(msg) {
+		t.Error("inject without baggage should return msg unchanged")
+	}
+}
+
+func TestPropagator_InjectFromContext(t *testing.T) {
+	m, _ := baggage.NewMember("tenant", "acme")
+	bag, _ := baggage.New(m)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	msg := &mock.Message{H: map[string]string{"id": "1"}}
+	out := (Propagator{}).Inject(ctx, msg)
+	if got := core.Header(out, Header); got != "tenant=acme" {
+		t.Errorf("baggage header = %q", got)
+	}
+	if core.Header(out, "id") != "1" || len(msg.H) != 1 {
+		t.Error("inject should keep existing headers without modifying msg")
+	}
+}
@@ -0,0 +1,87 @@
+// Package oteltrace propagates OpenTelemetry trace context through message
+// headers, so spans a handler starts continue the producer's trace. The
+// header format is configurable: W3C TraceContext by default, or B3, Jaeger
+// or any other propagation.TextMapPropagator. Only the context is carried;
+// starting and ending spans is left to the handler's own tracer.
+package oteltrace
+
+import (
+	"context"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Propagator is a core.ContextPropagator for trace context. Register it with
+// core.WithPropagator; handlers then start spans from the context they are
+// given, and messages they publish carry the trace on.
+type Propagator struct {
+	format propagation.TextMapPropagator
+}
+
+var _ core.ContextPropagator = Propagator{}
+
+// Option configures a Propagator.
+type Option func(*Propagator)
+
+// WithPropagator sets the header format trace context is extracted from and
+// injected into, e.g. b3.New() or jaeger.Jaeger{} from
+// go.opentelemetry.io/contrib/propagators. Pass a composite propagator to
+// accept several formats. The default is propagation.TraceContext.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(tp *Propagator) { tp.format = p }
+}
+
+// New returns a Propagator configured by opts.
+func New(opts ...Option) Propagator {
+	var p Propagator
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// Extract implements core.ContextPropagator. The message's trace becomes the
+// remote parent of spans started from the returned context; messages without
+// trace headers leave ctx unchanged.
+func (p Propagator) Extract(ctx context.Context, msg core.Message) context.Context {
+	return p.textMap().Extract(ctx, carrier{msg: msg})
+}
+
+// Inject implements core.ContextPropagator. Messages are returned unchanged
+// when ctx carries no valid span context.
+func (p Propagator) Inject(ctx context.Context, msg core.Message) core.Message {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return msg
+	}
+	h := make(map[string]string, 2)
+	p.textMap().Inject(ctx, propagation.MapCarrier(h))
+	if len(h) == 0 {
+		return msg
+	}
+	return core.MergeHeaders(msg, h)
+}
+
+// textMap returns the configured format, or TraceContext if none is set.
+func (p Propagator) textMap() propagation.TextMapPropagator {
+	if p.format == nil {
+		return propagation.TraceContext{}
+	}
+	return p.format
+}
+
+// carrier reads headers from an inbound message.
+type carrier struct {
+	msg core.Message
+}
+
+func (c carrier) Get(key string) string { return core.Header(c.msg, key) }
+func (c carrier) Set(string, string)    {}
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers()))
+	for k := range c.msg.Headers() {
+		keys = append(keys, k)
+	}
+	return keys
+}
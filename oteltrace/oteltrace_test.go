@@ -0,0 +1,110 @@
+package oteltrace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID  = "00f067aa0ba902b7"
+)
+
+// handledSpan dispatches msg on a router using p and returns the span
+// context of a span the handler starts, and the republished headers.
+func handledSpan(t *testing.T, p Propagator, msg *mock.Message) (trace.SpanContext, map[string]string) {
+	t.Helper()
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithPropagator(p))
+
+	var sc trace.SpanContext
+	r.Handle("orders", func(ctx context.Context, _ core.Message) error {
+		ctx, span := noop.NewTracerProvider().Tracer("test").Start(ctx, "handle")
+		defer span.End()
+		sc = span.SpanContext()
+		return r.Publish(ctx, "invoices", &mock.Message{V: []byte("inv")})
+	})
+	if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	pubs := mb.PublishedTo("invoices")
+	if len(pubs) != 1 {
+		t.Fatalf("published %d messages, want 1", len(pubs))
+	}
+	return sc, pubs[0].Headers
+}
+
+func TestPropagator_B3(t *testing.T) {
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{
+		"x-b3-traceid": traceID,
+		"x-b3-spanid":  spanID,
+		"x-b3-sampled": "1",
+	}}
+	sc, h := handledSpan(t, New(WithPropagator(b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))), msg)
+
+	if got := sc.TraceID().String(); got != traceID {
+		t.Errorf("span trace ID = %s, want %s", got, traceID)
+	}
+	if got := h["x-b3-traceid"]; got != traceID {
+		t.Errorf("republished x-b3-traceid = %q, want %s", got, traceID)
+	}
+	if _, ok := h["traceparent"]; ok {
+		t.Error("B3 propagator should not write traceparent")
+	}
+}
+
+func TestPropagator_Jaeger(t *testing.T) {
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{
+		"uber-trace-id": traceID + ":" + spanID + ":0:1",
+	}}
+	sc, _ := handledSpan(t, New(WithPropagator(jaeger.Jaeger{})), msg)
+
+	if got := sc.TraceID().String(); got != traceID {
+		t.Errorf("span trace ID = %s, want %s", got, traceID)
+	}
+}
+
+func TestPropagator_DefaultsToTraceContext(t *testing.T) {
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{
+		"traceparent":  "00-" + traceID + "-" + spanID + "-01",
+		"x-b3-traceid": "80f198ee56343ba864fe8b2a57d3eff7",
+		"x-b3-spanid":  "e457b5a2e4d86bd1",
+	}}
+	for name, p := range map[string]Propagator{"New": New(), "zero": {}} {
+		sc, h := handledSpan(t, p, msg)
+		if got := sc.TraceID().String(); got != traceID {
+			t.Errorf("%s: span trace ID = %s, want %s from traceparent", name, got, traceID)
+		}
+		if h["traceparent"] == "" {
+			t.Errorf("%s: republished message has no traceparent", name)
+		}
+	}
+}
+
+func TestPropagator_Composite(t *testing.T) {
+	p := New(WithPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, b3.New())))
+	msg := &mock.Message{V: []byte("o"), H: map[string]string{"b3": traceID + "-" + spanID + "-1"}}
+	sc, _ := handledSpan(t, p, msg)
+	if got := sc.TraceID().String(); got != traceID {
+		t.Errorf("span trace ID = %s, want %s", got, traceID)
+	}
+}
+
+func TestPropagator_NoTraceLeavesMessageUnchanged(t *testing.T) {
+	msg := &mock.Message{V: []byte("o")}
+	if got := New().Inject(context.Background(), msg); got != core.Message(msg) {
+		t.Error("Inject without a span context should return the message unchanged")
+	}
+	ctx := New().Extract(context.Background(), msg)
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("Extract without trace headers should not set a span context")
+	}
+}
@@ -0,0 +1,90 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Config is the strongly-typed alternative to broker.Config's Extra map.
+// Zero fields keep the defaults of New. Options are applied after the
+// fields, so they can set anything Config does not cover (loggers,
+// balancer, rebalance callbacks).
+type Config struct {
+	Brokers []string
+	Group   string
+
+	// Writer
+	Async       bool
+	BatchSize   int
+	Compression kafka.Compression
+
+	// Reader
+	MaxBytes             int
+	MaxWait              time.Duration
+	StartOffset          int64
+	GroupStartOffset     int64
+	PartitionConcurrency bool
+	KeyedWorkers         int
+	KeyHasher            core.Hasher
+
+	// Provisioning
+	TopicPartitions   int
+	ReplicationFactor int
+
+	Dialer  *kafka.Dialer
+	Options []Option
+}
+
+// NewFromConfig creates a Kafka Broker from a typed Config. It is also
+// registered with broker.RegisterTyped under "kafka".
+func NewFromConfig(cfg Config) (*Broker, error) {
+	return New(cfg.Brokers, cfg.Group, cfg.options()...)
+}
+
+// options converts the set fields of c into Options.
+func (c Config) options() []Option {
+	var opts []Option
+	if c.Async {
+		opts = append(opts, WithAsync(true))
+	}
+	if c.BatchSize != 0 {
+		opts = append(opts, WithBatchSize(c.BatchSize))
+	}
+	if c.Compression != 0 {
+		opts = append(opts, WithCompression(c.Compression))
+	}
+	if c.MaxBytes != 0 {
+		opts = append(opts, WithMaxBytes(c.MaxBytes))
+	}
+	if c.MaxWait != 0 {
+		opts = append(opts, WithMaxWait(c.MaxWait))
+	}
+	if c.StartOffset != 0 {
+		opts = append(opts, WithStartOffset(c.StartOffset))
+	}
+	if c.GroupStartOffset != 0 {
+		opts = append(opts, WithGroupStartOffset(c.GroupStartOffset))
+	}
+	if c.PartitionConcurrency {
+		opts = append(opts, WithPartitionConcurrency(true))
+	}
+	if c.KeyedWorkers != 0 {
+		opts = append(opts, WithKeyedWorkers(c.KeyedWorkers))
+	}
+	if c.KeyHasher != nil {
+		opts = append(opts, WithKeyHasher(c.KeyHasher))
+	}
+	if c.TopicPartitions != 0 {
+		opts = append(opts, WithTopicPartitions(c.TopicPartitions))
+	}
+	if c.ReplicationFactor != 0 {
+		opts = append(opts, WithReplicationFactor(c.ReplicationFactor))
+	}
+	if c.Dialer != nil {
+		opts = append(opts, WithDialer(c.Dialer))
+	}
+	return append(opts, c.Options...)
+}
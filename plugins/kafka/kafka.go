@@ -2,7 +2,9 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 
 	"github.com/segmentio/kafka-go"
@@ -16,6 +18,9 @@ func init() {
 		opts := optsFromConfig(cfg)
 		return New(cfg.Brokers, cfg.Group, opts...)
 	})
+	broker.RegisterTyped("kafka", func(cfg Config) (core.Broker, error) {
+		return NewFromConfig(cfg)
+	})
 }
 
 // Broker implements core.Broker for Apache Kafka using segmentio/kafka-go.
@@ -23,6 +28,12 @@ func init() {
 // Design decisions:
 //   - One kafka.Writer shared across all Publish calls (thread-safe by library).
 //   - One kafka.Reader per Subscribe call, each running in its own goroutine.
+//     With rebalance callbacks, Subscribe drives a kafka.ConsumerGroup itself
+//     and runs one reader per assigned partition instead.
+//   - WithKeyedWorkers fans a reader out to a fixed worker pool by key and
+//     commits through a per-partition watermark.
+//   - WithOffsetStore resumes partitions from, and acks into, an external
+//     store rather than the group's committed offsets.
 //   - Manual offset commit via Ack(); not committing (Nack) causes redelivery.
 //   - Graceful shutdown: context cancellation breaks the fetch loop, Close()
 //     flushes the writer and closes all readers.
@@ -33,6 +44,7 @@ type Broker struct {
 
 	writer  *kafka.Writer
 	readers []*kafka.Reader
+	groups  []*kafka.ConsumerGroup
 	mu      sync.Mutex
 	closed  bool
 }
@@ -47,13 +59,15 @@ func New(brokers []string, group string, fns ...Option) (*Broker, error) {
 	for _, fn := range fns {
 		fn(&opts)
 	}
-
 	w := &kafka.Writer{
 		Addr:         kafka.TCP(brokers...),
 		Balancer:     opts.balancer,
+		Compression:  opts.compression,
 		BatchSize:    opts.batchSize,
 		Async:        opts.async,
 		RequiredAcks: kafka.RequireAll,
+		Logger:       opts.logger,
+		ErrorLogger:  opts.errorLogger,
 	}
 	if opts.dialer != nil {
 		w.Transport = &kafka.Transport{
@@ -79,37 +93,26 @@ func (b *Broker) Publish(ctx context.Context, topic string, msg core.Message) er
 	}
 	b.mu.Unlock()
 
-	km := kafka.Message{
-		Topic:   topic,
-		Key:     msg.Key(),
-		Value:   msg.Value(),
-		Headers: toHeaders(msg.Headers()),
-	}
-	if err := b.writer.WriteMessages(ctx, km); err != nil {
+	if err := b.writer.WriteMessages(ctx, toKafkaMessage(topic, msg)); err != nil {
 		return fmt.Errorf("eventmux/kafka: publish to %q: %w", topic, err)
 	}
 	return nil
 }
 
 // Subscribe creates a consumer for the topic and blocks, delivering messages
-// to the handler until the context is cancelled.
+// to the handler until the context is cancelled. Fetch sizes set for the
+// route with core.WithFetchBytes override WithMinBytes and WithMaxBytes, and
+// a group set with core.WithGroup overrides the broker's.
 func (b *Broker) Subscribe(ctx context.Context, topic string, handler core.Handler) error {
-	cfg := kafka.ReaderConfig{
-		Brokers:  b.brokers,
-		Topic:    topic,
-		GroupID:  b.group,
-		MinBytes: b.opts.minBytes,
-		MaxBytes: b.opts.maxBytes,
-		MaxWait:  b.opts.maxWait,
+	so, _ := core.SubscribeOptionsFrom(ctx)
+	group := b.groupID(so)
+	if b.opts.offsetStore != nil && group == "" {
+		return fmt.Errorf("eventmux/kafka: WithOffsetStore requires a consumer group for %q", topic)
 	}
-	if b.opts.dialer != nil {
-		cfg.Dialer = b.opts.dialer
+	if b.managesGenerations(group) {
+		return b.consumeGroup(ctx, topic, group, so, handler)
 	}
-	if b.group == "" {
-		cfg.StartOffset = b.opts.startOffset
-	}
-
-	r := kafka.NewReader(cfg)
+	r := kafka.NewReader(withFetchBytes(b.readerConfig(topic, group), so))
 
 	b.mu.Lock()
 	if b.closed {
@@ -120,11 +123,80 @@ func (b *Broker) Subscribe(ctx context.Context, topic string, handler core.Handl
 	b.readers = append(b.readers, r)
 	b.mu.Unlock()
 
+	if b.opts.keyedWorkers > 0 {
+		return b.consumeKeyed(ctx, r, handler, b.opts.keyedWorkers, b.opts.keyedWindow)
+	}
+	if b.opts.partitionConcurrency {
+		return b.consumePartitioned(ctx, r, handler)
+	}
 	return b.consumeLoop(ctx, r, handler)
 }
 
+// logError reports a consumer failure through WithErrorLogger, or the
+// standard logger if none is set.
+func (b *Broker) logError(format string, args ...any) {
+	if b.opts.errorLogger != nil {
+		b.opts.errorLogger.Printf(format, args...)
+		return
+	}
+	log.Printf("[EventMux] "+format, args...)
+}
+
+// Group implements core.GroupReader.
+func (b *Broker) Group() string { return b.group }
+
+// groupID returns the consumer group for a route: its core.WithGroup
+// override, or else the broker's group.
+func (b *Broker) groupID(so core.SubscribeOptions) string {
+	if so.Group != "" {
+		return so.Group
+	}
+	return b.group
+}
+
+// reader is the subset of *kafka.Reader used for consuming.
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// readerConfig builds the reader configuration for topic, consumed by group
+// or, if group is empty, without one.
+func (b *Broker) readerConfig(topic, group string) kafka.ReaderConfig {
+	cfg := kafka.ReaderConfig{
+		Brokers:     b.brokers,
+		Topic:       topic,
+		GroupID:     group,
+		MinBytes:    b.opts.minBytes,
+		MaxBytes:    b.opts.maxBytes,
+		MaxWait:     b.opts.maxWait,
+		Logger:      b.opts.logger,
+		ErrorLogger: b.opts.errorLogger,
+	}
+	if b.opts.dialer != nil {
+		cfg.Dialer = b.opts.dialer
+	}
+	if group == "" {
+		cfg.StartOffset = b.opts.startOffset
+	} else if b.opts.groupStart != 0 {
+		cfg.StartOffset = b.opts.groupStart
+	}
+	return cfg
+}
+
+// withFetchBytes applies a route's fetch sizes to cfg.
+func withFetchBytes(cfg kafka.ReaderConfig, so core.SubscribeOptions) kafka.ReaderConfig {
+	if so.FetchMinBytes > 0 {
+		cfg.MinBytes = so.FetchMinBytes
+	}
+	if so.FetchMaxBytes > 0 {
+		cfg.MaxBytes = so.FetchMaxBytes
+	}
+	return cfg
+}
+
 // consumeLoop fetches messages and dispatches them to the handler.
-func (b *Broker) consumeLoop(ctx context.Context, r *kafka.Reader, handler core.Handler) error {
+func (b *Broker) consumeLoop(ctx context.Context, r reader, handler core.Handler) error {
 	for {
 		raw, err := r.FetchMessage(ctx)
 		if err != nil {
@@ -143,6 +215,59 @@ func (b *Broker) consumeLoop(ctx context.Context, r *kafka.Reader, handler core.
 	}
 }
 
+// partitionQueueSize bounds how many fetched messages may wait for a busy
+// partition worker before fetching blocks.
+const partitionQueueSize = 16
+
+// consumePartitioned fetches messages and hands each to a worker goroutine
+// owned by its partition. Messages within a partition are processed (and
+// committed) serially in offset order; different partitions run in parallel.
+// Once ctx is cancelled, messages still queued are left uncommitted for
+// redelivery instead of reaching the handler with a cancelled context.
+func (b *Broker) consumePartitioned(ctx context.Context, r reader, handler core.Handler) error {
+	var wg sync.WaitGroup
+	workers := make(map[int]chan kafka.Message)
+	defer func() {
+		for _, ch := range workers {
+			close(ch)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		raw, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil // graceful shutdown
+			}
+			return fmt.Errorf("eventmux/kafka: fetch: %w", err)
+		}
+
+		ch, ok := workers[raw.Partition]
+		if !ok {
+			ch = make(chan kafka.Message, partitionQueueSize)
+			workers[raw.Partition] = ch
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for raw := range ch {
+					if ctx.Err() != nil {
+						continue // shutting down: leave the rest uncommitted
+					}
+					// Errors leave the offset uncommitted, as in consumeLoop.
+					_ = handler(ctx, &message{raw: raw, reader: r, ctx: ctx})
+				}
+			}()
+		}
+
+		select {
+		case ch <- raw:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
 // Close flushes the writer and closes all readers.
 func (b *Broker) Close() error {
 	b.mu.Lock()
@@ -161,19 +286,38 @@ func (b *Broker) Close() error {
 			errs = append(errs, fmt.Errorf("eventmux/kafka: close reader: %w", err))
 		}
 	}
+	for _, g := range b.groups {
+		if err := g.Close(); err != nil && !errors.Is(err, kafka.ErrGroupClosed) {
+			errs = append(errs, fmt.Errorf("eventmux/kafka: close consumer group: %w", err))
+		}
+	}
 	if len(errs) > 0 {
 		return errs[0]
 	}
 	return nil
 }
 
-// toHeaders converts a string map to Kafka headers.
+// toKafkaMessage converts a core.Message for publishing to topic.
+func toKafkaMessage(topic string, msg core.Message) kafka.Message {
+	return kafka.Message{
+		Topic:   topic,
+		Key:     msg.Key(),
+		Value:   msg.Value(),
+		Headers: toHeaders(msg.Headers()),
+		Time:    timestampOf(msg),
+	}
+}
+
+// toHeaders converts a string map to Kafka headers, dropping HeaderTimestamp.
 func toHeaders(h map[string]string) []kafka.Header {
 	if len(h) == 0 {
 		return nil
 	}
 	headers := make([]kafka.Header, 0, len(h))
 	for k, v := range h {
+		if k == HeaderTimestamp {
+			continue
+		}
 		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
 	}
 	return headers
@@ -194,5 +338,36 @@ func optsFromConfig(cfg broker.Config) []Option {
 	if v, ok := cfg.Extra["max_bytes"].(int); ok {
 		opts = append(opts, WithMaxBytes(v))
 	}
+	if v, ok := cfg.Extra["keyed_workers"].(int); ok {
+		opts = append(opts, WithKeyedWorkers(v))
+	}
+	if v, ok := cfg.Extra["compression"].(string); ok {
+		var codec kafka.Compression
+		if err := codec.UnmarshalText([]byte(v)); err == nil {
+			opts = append(opts, WithCompression(codec))
+		}
+	}
+	if v, ok := cfg.Extra["key_hasher"].(string); ok {
+		switch v {
+		case "fnv1a":
+			opts = append(opts, WithKeyHasher(core.FNV1a{}))
+		case "murmur2":
+			opts = append(opts, WithKeyHasher(core.Murmur2{}))
+		}
+	}
+	if v, ok := cfg.Extra["group_start_offset"].(string); ok {
+		switch v {
+		case "earliest":
+			opts = append(opts, WithGroupStartOffset(kafka.FirstOffset))
+		case "latest":
+			opts = append(opts, WithGroupStartOffset(kafka.LastOffset))
+		}
+	}
+	if v, ok := cfg.Extra["topic_partitions"].(int); ok {
+		opts = append(opts, WithTopicPartitions(v))
+	}
+	if v, ok := cfg.Extra["replication_factor"].(int); ok {
+		opts = append(opts, WithReplicationFactor(v))
+	}
 	return opts
 }
@@ -0,0 +1,341 @@
+//go:build integration
+
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/core/middleware"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// kafkaAddr returns the broker address used by integration tests.
+func kafkaAddr() string {
+	if addr := os.Getenv("KAFKA_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:9092"
+}
+
+func TestIntegration_Timestamp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "eventmux-timestamp-" + time.Now().Format("20060102150405")
+	b, err := New([]string{kafkaAddr()}, "", WithStartOffset(kafka.FirstOffset))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := Timestamped(&mock.Message{V: []byte("v")}, want)
+	if err := b.Publish(ctx, topic, msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	got := make(chan time.Time, 1)
+	subCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go b.Subscribe(subCtx, topic, func(ctx context.Context, m core.Message) error {
+		got <- m.(Timestamper).Timestamp()
+		stop()
+		return m.Ack()
+	})
+
+	select {
+	case ts := <-got:
+		if !ts.Equal(want) {
+			t.Errorf("consumed Timestamp = %v, want %v", ts, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestIntegration_ReplayFrom(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "eventmux-replay-" + time.Now().Format("20060102150405")
+	b, err := New([]string{kafkaAddr()}, "")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i, v := range []string{"old", "new-1", "new-2"} {
+		msg := Timestamped(&mock.Message{V: []byte(v)}, base.Add(time.Duration(i)*time.Minute))
+		if err := b.Publish(ctx, topic, msg); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	var got []string
+	err = b.ReplayFrom(ctx, topic, base.Add(30*time.Second), func(ctx context.Context, m core.Message) error {
+		got = append(got, string(m.Value()))
+		return m.Ack()
+	})
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+	if len(got) != 2 || got[0] != "new-1" || got[1] != "new-2" {
+		t.Errorf("replayed %v, want [new-1 new-2]", got)
+	}
+}
+
+func TestIntegration_RebalanceCallbacks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	topic := "eventmux-rebalance-" + time.Now().Format("20060102150405")
+	conn, err := kafka.DialContext(ctx, "tcp", kafkaAddr())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	err = conn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 2, ReplicationFactor: 1})
+	conn.Close()
+	if err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+
+	type event struct {
+		member     string
+		assigned   bool
+		partitions []int
+	}
+	events := make(chan event, 16)
+	member := func(name string) *Broker {
+		b, err := New([]string{kafkaAddr()}, topic+"-group",
+			OnPartitionsAssigned(func(_ string, p []int) { events <- event{name, true, p} }),
+			OnPartitionsRevoked(func(_ string, p []int) { events <- event{name, false, p} }),
+		)
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		return b
+	}
+	next := func() event {
+		select {
+		case ev := <-events:
+			return ev
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for rebalance callback")
+			return event{}
+		}
+	}
+	noop := func(ctx context.Context, m core.Message) error { return m.Ack() }
+
+	first := member("first")
+	defer first.Close()
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	go first.Subscribe(firstCtx, topic, noop)
+
+	if ev := next(); ev.member != "first" || !ev.assigned || len(ev.partitions) != 2 {
+		t.Fatalf("expected first to be assigned both partitions, got %+v", ev)
+	}
+
+	second := member("second")
+	defer second.Close()
+	go second.Subscribe(ctx, topic, noop)
+
+	if ev := next(); ev.member != "first" || ev.assigned || len(ev.partitions) != 2 {
+		t.Fatalf("expected first to have both partitions revoked, got %+v", ev)
+	}
+
+	// After the rebalance each member owns one partition.
+	owned := map[string]int{}
+	for len(owned) < 2 {
+		if ev := next(); ev.assigned {
+			owned[ev.member] = len(ev.partitions)
+		}
+	}
+	if owned["first"] != 1 || owned["second"] != 1 {
+		t.Errorf("partitions after rebalance = %v, want one each", owned)
+	}
+
+	// Shutting down reports the remaining partition as revoked.
+	stopFirst()
+	for {
+		if ev := next(); ev.member == "first" && !ev.assigned {
+			if len(ev.partitions) != 1 {
+				t.Errorf("revoked on shutdown %v, want one partition", ev.partitions)
+			}
+			break
+		}
+	}
+}
+
+func TestIntegration_Provision(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "eventmux-provision-" + time.Now().Format("20060102150405")
+	b, err := New([]string{kafkaAddr()}, "", WithTopicPartitions(3))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	topics := []string{topic, "eventmux-provision.*"}
+	for i := range 2 { // the second run must be a no-op
+		if err := b.Provision(ctx, topics); err != nil {
+			t.Fatalf("provision run %d: %v", i+1, err)
+		}
+	}
+
+	parts, err := kafka.DefaultDialer.LookupPartitions(ctx, "tcp", kafkaAddr(), topic)
+	if err != nil {
+		t.Fatalf("lookup partitions: %v", err)
+	}
+	if len(parts) != 3 {
+		t.Errorf("topic has %d partitions, want 3", len(parts))
+	}
+}
+
+func TestIntegration_AppGzipUnderTransportCompression(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "eventmux-compression-" + time.Now().Format("20060102150405")
+	b, err := New([]string{kafkaAddr()}, "", WithStartOffset(kafka.FirstOffset), WithCompression(kafka.Gzip))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	body := []byte(`{"order_id":"o-1"}`)
+	var app bytes.Buffer
+	zw := gzip.NewWriter(&app)
+	zw.Write(body)
+	zw.Close()
+	msg := &mock.Message{V: app.Bytes(), H: map[string]string{middleware.HeaderContentEncoding: "gzip"}}
+	if err := b.Publish(ctx, topic, msg); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	got := make(chan []byte, 1)
+	subCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go b.Subscribe(subCtx, topic, middleware.Decompress()(func(ctx context.Context, m core.Message) error {
+		got <- m.Value()
+		stop()
+		return m.Ack()
+	}))
+
+	select {
+	case v := <-got:
+		if !bytes.Equal(v, body) {
+			t.Errorf("consumed %q, want %q", v, body)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestIntegration_OffsetStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	topic := "eventmux-offsetstore-" + time.Now().Format("20060102150405")
+	conn, err := kafka.DialContext(ctx, "tcp", kafkaAddr())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	err = conn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1})
+	conn.Close()
+	if err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+
+	// The store says offsets 0 and 1 were already processed.
+	store := &memOffsetStore{next: map[string]int64{offsetKey(topic, 0): 2}}
+	b, err := New([]string{kafkaAddr()}, topic+"-group",
+		WithGroupStartOffset(kafka.FirstOffset), WithOffsetStore(store))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		if err := b.Publish(ctx, topic, &mock.Message{V: []byte(v)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	got := make(chan string, 4)
+	subCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go b.Subscribe(subCtx, topic, func(ctx context.Context, m core.Message) error {
+		err := m.Ack()
+		got <- string(m.Value())
+		return err
+	})
+
+	for _, want := range []string{"c", "d"} {
+		select {
+		case v := <-got:
+			if v != want {
+				t.Fatalf("consumed %q, want %q", v, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for message")
+		}
+	}
+	if next, _, _ := store.Load(ctx, topic, 0); next != 4 {
+		t.Errorf("stored offset = %d, want 4", next)
+	}
+}
+
+func TestIntegration_OffsetStoreKeyedWorkersFail(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	topic := "eventmux-offsetstore-keyed-" + time.Now().Format("20060102150405")
+	conn, err := kafka.DialContext(ctx, "tcp", kafkaAddr())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	err = conn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1})
+	conn.Close()
+	if err != nil {
+		t.Fatalf("create topic: %v", err)
+	}
+
+	store := &memOffsetStore{next: map[string]int64{}}
+	b, err := New([]string{kafkaAddr()}, topic+"-group", WithGroupStartOffset(kafka.FirstOffset),
+		WithOffsetStore(store), WithKeyedWorkers(2), WithKeyedWindow(1))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	for _, v := range []string{"poison", "b", "c"} {
+		if err := b.Publish(ctx, topic, &mock.Message{K: []byte(v), V: []byte(v)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	// The failed head holds the one-message window, so the keyed workers
+	// fail the partition and Subscribe reports it.
+	err = b.Subscribe(ctx, topic, func(ctx context.Context, m core.Message) error {
+		if string(m.Value()) == "poison" {
+			return errors.New("poison")
+		}
+		return m.Ack()
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Subscribe = %v, want the partition's failure", err)
+	}
+}
@@ -0,0 +1,644 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// fakeReader serves a fixed list of messages, then blocks until cancelled.
+type fakeReader struct {
+	mu      sync.Mutex
+	msgs    []kafka.Message
+	commits map[int][]int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		m := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return m, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.commits[m.Partition] = append(r.commits[m.Partition], m.Offset)
+	}
+	return nil
+}
+
+func TestConsumePartitioned(t *testing.T) {
+	const partitions, perPartition = 3, 5
+
+	r := &fakeReader{commits: make(map[int][]int64)}
+	for off := int64(0); off < perPartition; off++ {
+		for p := 0; p < partitions; p++ {
+			r.msgs = append(r.msgs, kafka.Message{Partition: p, Offset: off})
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		active     = make(map[int]int)
+		total      int
+		maxTotal   int
+		overlapped bool
+		handled    int
+	)
+	done := make(chan struct{})
+	handler := func(ctx context.Context, msg core.Message) error {
+		p := msg.(*message).raw.Partition
+		mu.Lock()
+		active[p]++
+		total++
+		if active[p] > 1 {
+			overlapped = true
+		}
+		if total > maxTotal {
+			maxTotal = total
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		active[p]--
+		total--
+		handled++
+		if handled == partitions*perPartition {
+			close(done)
+		}
+		mu.Unlock()
+		return msg.Ack()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	b := &Broker{}
+	go func() { errCh <- b.consumePartitioned(ctx, r, handler) }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("consumePartitioned: %v", err)
+	}
+
+	if overlapped {
+		t.Error("messages from the same partition were processed concurrently")
+	}
+	if maxTotal < 2 {
+		t.Error("different partitions were never processed in parallel")
+	}
+	for p := 0; p < partitions; p++ {
+		offs := r.commits[p]
+		if len(offs) != perPartition {
+			t.Fatalf("partition %d: %d commits, want %d", p, len(offs), perPartition)
+		}
+		for i, off := range offs {
+			if off != int64(i) {
+				t.Errorf("partition %d: commit %d has offset %d, want in-order %d", p, i, off, i)
+			}
+		}
+	}
+}
+
+func TestReplayLoopStopsAtEndOffset(t *testing.T) {
+	// Offsets 8 and 9 were written after the replay started, moving the
+	// high watermark on.
+	r := &fakeReader{commits: make(map[int][]int64)}
+	for off := int64(5); off < 10; off++ {
+		r.msgs = append(r.msgs, kafka.Message{Topic: "orders", Offset: off, HighWaterMark: off + 1})
+	}
+
+	var offsets []int64
+	err := replayLoop(context.Background(), r, func(ctx context.Context, msg core.Message) error {
+		offsets = append(offsets, msg.(*message).raw.Offset)
+		return nil
+	}, 8)
+	if err != nil {
+		t.Fatalf("replayLoop: %v", err)
+	}
+	if len(offsets) != 3 || offsets[0] != 5 || offsets[2] != 7 {
+		t.Errorf("replayed offsets %v, want [5 6 7]", offsets)
+	}
+}
+
+func TestReplayLoopEndsWithoutMessageAtEnd(t *testing.T) {
+	defer func(d time.Duration) { replayIdleTimeout = d }(replayIdleTimeout)
+	replayIdleTimeout = 20 * time.Millisecond
+
+	// Offsets 7 to 9 are transaction markers, so no message reaches end-1.
+	r := &fakeReader{commits: make(map[int][]int64)}
+	r.msgs = []kafka.Message{{Offset: 5}, {Offset: 6}}
+
+	var calls int
+	err := replayLoop(context.Background(), r, func(ctx context.Context, msg core.Message) error {
+		calls++
+		return nil
+	}, 10)
+	if err != nil {
+		t.Fatalf("replayLoop: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestReplayLoopStopsOnHandlerError(t *testing.T) {
+	r := &fakeReader{commits: make(map[int][]int64)}
+	r.msgs = []kafka.Message{{Offset: 0}, {Offset: 1}}
+
+	boom := errors.New("boom")
+	var calls int
+	err := replayLoop(context.Background(), r, func(ctx context.Context, msg core.Message) error {
+		calls++
+		return boom
+	}, 2)
+	if !errors.Is(err, boom) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestConsumeKeyed(t *testing.T) {
+	const partitions, perPartition, failPartition, failOffset = 2, 6, 0, 2
+
+	r := &fakeReader{commits: make(map[int][]int64)}
+	for off := int64(0); off < perPartition; off++ {
+		for p := 0; p < partitions; p++ {
+			key := []byte{byte('a' + off%3)}
+			r.msgs = append(r.msgs, kafka.Message{Partition: p, Offset: off, Key: key})
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		handled = make(map[int][]int64)
+		byKey   = make(map[string][]int64)
+		count   int
+	)
+	done := make(chan struct{})
+	handler := func(ctx context.Context, msg core.Message) error {
+		raw := msg.(*message).raw
+		time.Sleep(time.Duration(raw.Offset%3) * time.Millisecond)
+
+		mu.Lock()
+		handled[raw.Partition] = append(handled[raw.Partition], raw.Offset)
+		k := string(raw.Key) + string(rune('0'+raw.Partition))
+		byKey[k] = append(byKey[k], raw.Offset)
+		if count++; count == partitions*perPartition {
+			defer close(done)
+		}
+		mu.Unlock()
+
+		if raw.Partition == failPartition && raw.Offset == failOffset {
+			return errors.New("boom")
+		}
+		return msg.Ack()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	b := &Broker{}
+	go func() { errCh <- b.consumeKeyed(ctx, r, handler, 3, 0) }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("consumeKeyed: %v", err)
+	}
+
+	for p := 0; p < partitions; p++ {
+		if len(handled[p]) != perPartition {
+			t.Errorf("partition %d: handled %v, want every offset", p, handled[p])
+		}
+		offs := r.commits[p]
+		for i := 1; i < len(offs); i++ {
+			if offs[i] <= offs[i-1] {
+				t.Errorf("partition %d: commits %v are not increasing", p, offs)
+			}
+		}
+		last := int64(-1)
+		if len(offs) > 0 {
+			last = offs[len(offs)-1]
+		}
+		want := int64(perPartition - 1)
+		if p == failPartition {
+			want = failOffset - 1
+		}
+		if last != want {
+			t.Errorf("partition %d: committed up to %d, want %d (commits %v)", p, last, want, offs)
+		}
+	}
+	for k, offs := range byKey {
+		for i := 1; i < len(offs); i++ {
+			if offs[i] < offs[i-1] {
+				t.Errorf("key %s handled out of order: %v", k, offs)
+			}
+		}
+	}
+}
+
+func TestWorkerFor_Murmur2MatchesBalancer(t *testing.T) {
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	for _, key := range []string{"21", "foobar", "abc", "order-42", "a-little-bit-longer-string"} {
+		raw := kafka.Message{Key: []byte(key)}
+		want := (kafka.Murmur2Balancer{}).Balance(raw, partitions...)
+		if got := workerFor(raw, len(partitions), core.Murmur2{}); got != want {
+			t.Errorf("key %q: worker %d, want partition %d", key, got, want)
+		}
+	}
+	if got := workerFor(kafka.Message{Partition: 5}, 4, core.Murmur2{}); got != 1 {
+		t.Errorf("keyless message: worker %d, want partition 5 %% 4", got)
+	}
+}
+
+func TestWatermarkCommitsContiguously(t *testing.T) {
+	r := &fakeReader{commits: make(map[int][]int64)}
+	wm := newWatermark(r, defaultKeyedWindow)
+	msgs := make([]kafka.Message, 4)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Offset: int64(i)}
+		if err := wm.track(context.Background(), msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ack := func(i int) {
+		if err := wm.CommitMessages(context.Background(), msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ack(2)
+	ack(1)
+	if len(r.commits[0]) != 0 {
+		t.Fatalf("committed %v before offset 0 was acked", r.commits[0])
+	}
+	ack(0)
+	ack(3)
+	if got := r.commits[0]; len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("commits = %v, want [2 3]", got)
+	}
+}
+
+func (r *fakeReader) remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.msgs)
+}
+
+func TestConsumers_SkipQueuedMessagesOnCancel(t *testing.T) {
+	consumers := map[string]func(*Broker, context.Context, reader, core.Handler) error{
+		"partitioned": (*Broker).consumePartitioned,
+		"keyed": func(b *Broker, ctx context.Context, r reader, h core.Handler) error {
+			return b.consumeKeyed(ctx, r, h, 1, 0)
+		},
+	}
+	for name, consume := range consumers {
+		t.Run(name, func(t *testing.T) {
+			r := &fakeReader{commits: make(map[int][]int64)}
+			for off := int64(0); off < 5; off++ {
+				r.msgs = append(r.msgs, kafka.Message{Offset: off})
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			started := make(chan struct{})
+			var calls atomic.Int32
+			handler := func(ctx context.Context, msg core.Message) error {
+				if calls.Add(1) == 1 {
+					close(started)
+					<-ctx.Done()
+				}
+				return msg.Ack()
+			}
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- consume(&Broker{}, ctx, r, handler) }()
+			<-started
+			for r.remaining() > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			if err := <-errCh; err != nil {
+				t.Fatalf("consume: %v", err)
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("handler ran %d times, want queued messages skipped after cancel", n)
+			}
+		})
+	}
+}
+
+func TestConsumeKeyed_FailedMessageFillsWindow(t *testing.T) {
+	failures := map[string]func(core.Message) error{
+		"handler error": func(core.Message) error { return errors.New("poison") },
+		"nack":          func(msg core.Message) error { return msg.Nack() },
+		"no ack":        func(core.Message) error { return nil },
+	}
+	for name, fail := range failures {
+		t.Run(name, func(t *testing.T) {
+			r := &fakeReader{commits: make(map[int][]int64)}
+			for off := int64(0); off < 20; off++ {
+				r.msgs = append(r.msgs, kafka.Message{Key: []byte{byte(off)}, Offset: off})
+			}
+			handler := func(_ context.Context, msg core.Message) error {
+				if msg.(*message).raw.Offset == 0 {
+					return fail(msg)
+				}
+				return msg.Ack()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := (&Broker{}).consumeKeyed(ctx, r, handler, 3, 4)
+			if err == nil || !strings.Contains(err.Error(), "offset 0 failed") {
+				t.Fatalf("consumeKeyed = %v, want the failed offset reported", err)
+			}
+			if n := r.remaining(); n < 20-5 {
+				t.Errorf("fetched %d messages, want at most the window and the one waiting for it", 20-n)
+			}
+			if c := r.commits[0]; len(c) != 0 {
+				t.Errorf("committed %v past the failed offset", c)
+			}
+		})
+	}
+}
+
+func TestConsumeKeyed_LateAckWithinWindow(t *testing.T) {
+	r := &fakeReader{commits: make(map[int][]int64)}
+	for off := int64(0); off < 3; off++ {
+		r.msgs = append(r.msgs, kafka.Message{Key: []byte{byte(off)}, Offset: off})
+	}
+	held := make(chan core.Message, 1)
+	var acked atomic.Int64
+	handler := func(_ context.Context, msg core.Message) error {
+		if msg.(*message).raw.Offset == 0 {
+			held <- msg // acked later, as by a debouncer
+			return nil
+		}
+		acked.Add(1)
+		return msg.Ack()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- (&Broker{}).consumeKeyed(ctx, r, handler, 3, 4) }()
+
+	if err := (<-held).Ack(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for acked.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("consumeKeyed: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.commits[0]; len(c) == 0 || c[len(c)-1] != 2 {
+		t.Errorf("commits = %v, want up to offset 2 once the held message is acked", c)
+	}
+}
+
+func TestConsumeKeyed_WindowPausesFetching(t *testing.T) {
+	r := &fakeReader{commits: make(map[int][]int64)}
+	for off := int64(0); off < 20; off++ {
+		r.msgs = append(r.msgs, kafka.Message{Key: []byte{byte(off)}, Offset: off})
+	}
+	release := make(chan struct{})
+	var acked atomic.Int64
+	handler := func(_ context.Context, msg core.Message) error {
+		if msg.(*message).raw.Offset == 0 {
+			<-release // a slow head holds the window
+		}
+		acked.Add(1)
+		return msg.Ack()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- (&Broker{}).consumeKeyed(ctx, r, handler, 3, 4) }()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := r.remaining(); n < 20-5 {
+		t.Errorf("fetched %d messages behind a slow head, want at most 5", 20-n)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for acked.Load() < 20 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("consumeKeyed: %v", err)
+	}
+	if c := r.commits[0]; len(c) == 0 || c[len(c)-1] != 19 {
+		t.Errorf("commits = %v, want up to offset 19", c)
+	}
+}
+
+// memOffsetStore is an in-memory OffsetStore.
+type memOffsetStore struct {
+	mu   sync.Mutex
+	next map[string]int64 // "topic/partition" -> next offset
+}
+
+func offsetKey(topic string, partition int) string {
+	return topic + "/" + strconv.Itoa(partition)
+}
+
+func (s *memOffsetStore) Load(_ context.Context, topic string, partition int) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.next[offsetKey(topic, partition)]
+	return next, ok, nil
+}
+
+func (s *memOffsetStore) Save(_ context.Context, topic string, partition int, next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next[offsetKey(topic, partition)] = next
+	return nil
+}
+
+func TestOffsetStore_ResumeFromStoredOffset(t *testing.T) {
+	store := &memOffsetStore{next: make(map[string]int64)}
+	b, err := New([]string{"localhost:9092"}, "billing", WithOffsetStore(store))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	var log []kafka.Message
+	for off := int64(0); off < 5; off++ {
+		log = append(log, kafka.Message{Topic: "orders", Partition: 2, Offset: off})
+	}
+	assignment := kafka.PartitionAssignment{ID: 2, Offset: 0} // group commit never moves
+
+	// consume runs one session of the partition from its start offset up to
+	// and including offset last, acking each message.
+	consume := func(last int64) []int64 {
+		t.Helper()
+		start, err := b.startOffset(context.Background(), "orders", assignment)
+		if err != nil {
+			t.Fatalf("startOffset: %v", err)
+		}
+		fr := &fakeReader{commits: make(map[int][]int64)}
+		for _, m := range log {
+			if m.Offset >= start && m.Offset <= last {
+				fr.msgs = append(fr.msgs, m)
+			}
+		}
+
+		var seen []int64
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := &storeReader{reader: fr, store: store}
+		err = b.consumeLoop(ctx, r, func(ctx context.Context, msg core.Message) error {
+			off := msg.(core.OffsetReader).Offset()
+			seen = append(seen, off)
+			if off == last {
+				cancel()
+			}
+			return msg.Ack()
+		})
+		if err != nil {
+			t.Fatalf("consumeLoop: %v", err)
+		}
+		if len(fr.commits) != 0 {
+			t.Errorf("offsets committed to the group: %v", fr.commits)
+		}
+		return seen
+	}
+
+	if got := consume(2); len(got) != 3 || got[0] != 0 {
+		t.Fatalf("first session handled %v, want [0 1 2]", got)
+	}
+	if next, ok, _ := store.Load(context.Background(), "orders", 2); !ok || next != 3 {
+		t.Fatalf("stored offset = %d (%v), want 3", next, ok)
+	}
+	if got := consume(4); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("resumed session handled %v, want [3 4]", got)
+	}
+}
+
+// flakyOffsetStore fails the first failures loads, or every load if
+// failures is negative.
+type flakyOffsetStore struct {
+	memOffsetStore
+	failures int
+	loads    atomic.Int32
+}
+
+func (s *flakyOffsetStore) Load(ctx context.Context, topic string, partition int) (int64, bool, error) {
+	if n := int(s.loads.Add(1)); s.failures < 0 || n <= s.failures {
+		return 0, false, errors.New("store unavailable")
+	}
+	return s.memOffsetStore.Load(ctx, topic, partition)
+}
+
+func TestOffsetStore_LoadRetried(t *testing.T) {
+	defer func(orig core.BackoffStrategy) { loadBackoff = orig }(loadBackoff)
+	loadBackoff = func(int) time.Duration { return time.Millisecond }
+
+	var logged atomic.Int32
+	logger := kafka.LoggerFunc(func(string, ...any) { logged.Add(1) })
+	assignment := kafka.PartitionAssignment{ID: 2, Offset: 0}
+
+	t.Run("recovers", func(t *testing.T) {
+		store := &flakyOffsetStore{memOffsetStore: memOffsetStore{next: map[string]int64{offsetKey("orders", 2): 7}}, failures: 3}
+		b, err := New([]string{"localhost:9092"}, "billing", WithOffsetStore(store), WithErrorLogger(logger))
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer b.Close()
+
+		offset, ok := b.awaitStartOffset(context.Background(), "orders", assignment)
+		if !ok || offset != 7 {
+			t.Errorf("start offset = %d (%v), want the stored 7", offset, ok)
+		}
+		if n := store.loads.Load(); n != 4 {
+			t.Errorf("loaded %d times, want 4", n)
+		}
+		if n := logged.Load(); n != 3 {
+			t.Errorf("logged %d failures, want 3", n)
+		}
+	})
+
+	t.Run("generation ends", func(t *testing.T) {
+		store := &flakyOffsetStore{memOffsetStore: memOffsetStore{next: map[string]int64{}}, failures: -1}
+		b, err := New([]string{"localhost:9092"}, "billing", WithOffsetStore(store), WithErrorLogger(logger))
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		defer b.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, ok := b.awaitStartOffset(ctx, "orders", assignment); ok {
+			t.Error("awaitStartOffset succeeded against a failing store")
+		}
+		if n := store.loads.Load(); n < 2 {
+			t.Errorf("loaded %d times before the generation ended, want retries", n)
+		}
+	})
+}
+
+func TestOffsetStore_FallsBackToGroupOffset(t *testing.T) {
+	b, err := New([]string{"localhost:9092"}, "billing", WithOffsetStore(&memOffsetStore{next: make(map[string]int64)}))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	got, err := b.startOffset(context.Background(), "orders", kafka.PartitionAssignment{ID: 0, Offset: 42})
+	if err != nil || got != 42 {
+		t.Errorf("startOffset = %d, %v; want the group's 42", got, err)
+	}
+	if !b.managesGenerations(b.group) {
+		t.Error("an OffsetStore must consume through group generations")
+	}
+}
+
+func TestOffsetStore_RequiresGroup(t *testing.T) {
+	b, err := New([]string{"localhost:9092"}, "", WithOffsetStore(&memOffsetStore{}))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	if err := b.Subscribe(context.Background(), "orders", nil); err == nil || !strings.Contains(err.Error(), "requires a consumer group") {
+		t.Errorf("Subscribe without a group = %v, want an error", err)
+	}
+	if !b.managesGenerations(b.groupID(core.SubscribeOptions{Group: "billing"})) {
+		t.Error("a route group must let an OffsetStore consume through group generations")
+	}
+}
@@ -0,0 +1,196 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// consumeKeyed fetches messages from every assigned partition and hands each
+// to one of a fixed set of workers chosen by hashing its key, so messages
+// with the same key are handled in order while different keys run in
+// parallel. Keys are hashed with the WithKeyHasher hasher, FNV-1a by
+// default. Messages without a key are routed by partition. Because a
+// partition's messages complete out of order, acks go through a watermark
+// that commits only the highest offset below which everything is acked.
+// At most window messages per partition are held; zero or less uses
+// defaultKeyedWindow.
+func (b *Broker) consumeKeyed(ctx context.Context, r reader, handler core.Handler, workers, window int) error {
+	if window <= 0 {
+		window = defaultKeyedWindow
+	}
+	wm := newWatermark(r, window)
+	hasher := b.opts.keyHasher
+	if hasher == nil {
+		hasher = core.FNV1a{}
+	}
+	var wg sync.WaitGroup
+	queues := make([]chan kafka.Message, workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, partitionQueueSize)
+		wg.Add(1)
+		go func(ch <-chan kafka.Message) {
+			defer wg.Done()
+			for raw := range ch {
+				if ctx.Err() != nil {
+					continue // shutting down: leave the rest uncommitted
+				}
+				// A message the handler failed, or returned from without
+				// acking, holds the watermark. It is recorded as failed so
+				// the window cannot fill behind it unnoticed; acking it
+				// later still lets the window move.
+				msg := &message{raw: raw, reader: wm, ctx: ctx}
+				if err := handler(ctx, msg); err != nil || !msg.acked.Load() {
+					wm.fail(raw)
+				}
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, ch := range queues {
+			close(ch)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		raw, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil // graceful shutdown
+			}
+			return fmt.Errorf("eventmux/kafka: fetch: %w", err)
+		}
+
+		if err := wm.track(ctx, raw); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case queues[workerFor(raw, workers, hasher)] <- raw:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// workerFor picks the worker for raw from the hash of its key, or its
+// partition when it has none.
+func workerFor(raw kafka.Message, workers int, h core.Hasher) int {
+	if len(raw.Key) == 0 {
+		return raw.Partition % workers
+	}
+	return core.Partition(h, raw.Key, workers)
+}
+
+// defaultKeyedWindow is the default for WithKeyedWindow.
+const defaultKeyedWindow = 1024
+
+// watermark is a reader whose commits are held back until they are
+// contiguous within each partition.
+type watermark struct {
+	reader
+	window int
+
+	mu      sync.Mutex
+	pending map[int]*partitionWatermark
+	changed chan struct{} // closed and replaced when a window moves or a message fails
+}
+
+func newWatermark(r reader, window int) *watermark {
+	return &watermark{reader: r, window: window, pending: make(map[int]*partitionWatermark), changed: make(chan struct{})}
+}
+
+// partitionWatermark holds the fetched, not yet committed messages of one
+// partition in offset order.
+type partitionWatermark struct {
+	msgs   []kafka.Message
+	acked  map[int64]bool
+	failed map[int64]bool
+}
+
+// track records raw as fetched, waiting while its partition already holds
+// window messages. It fails if the oldest of them failed, as the window
+// can then only move once consumption restarts. Messages must be tracked
+// in fetch order.
+func (w *watermark) track(ctx context.Context, raw kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p := w.pending[raw.Partition]
+	if p == nil {
+		p = &partitionWatermark{acked: make(map[int64]bool), failed: make(map[int64]bool)}
+		w.pending[raw.Partition] = p
+	}
+	for len(p.msgs) >= w.window {
+		if head := p.msgs[0]; p.failed[head.Offset] {
+			return fmt.Errorf("eventmux/kafka: partition %d: offset %d failed with %d later messages waiting to be committed behind it",
+				raw.Partition, head.Offset, len(p.msgs)-1)
+		}
+		changed := w.changed
+		w.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			w.mu.Lock()
+			return ctx.Err()
+		}
+		w.mu.Lock()
+	}
+	p.msgs = append(p.msgs, raw)
+	return nil
+}
+
+// fail records that raw was handled without being acked.
+func (w *watermark) fail(raw kafka.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if p := w.pending[raw.Partition]; p != nil && !p.acked[raw.Offset] {
+		p.failed[raw.Offset] = true
+		w.notify()
+	}
+}
+
+// notify wakes track calls waiting for a window to move. w.mu must be held.
+func (w *watermark) notify() {
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// CommitMessages marks msgs as acked and commits, per partition, the last
+// message of the acked prefix, if it advanced. A message that is never
+// acked keeps every later offset of its partition uncommitted, so it is
+// redelivered along with them rather than skipped.
+func (w *watermark) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var commit []kafka.Message
+	for _, m := range msgs {
+		if p := w.pending[m.Partition]; p != nil {
+			p.acked[m.Offset] = true
+			delete(p.failed, m.Offset)
+		}
+	}
+	for _, p := range w.pending {
+		n := 0
+		for n < len(p.msgs) && p.acked[p.msgs[n].Offset] {
+			delete(p.acked, p.msgs[n].Offset)
+			n++
+		}
+		if n > 0 {
+			commit = append(commit, p.msgs[n-1])
+			p.msgs = p.msgs[n:]
+		}
+	}
+	if len(commit) == 0 {
+		return nil
+	}
+	w.notify()
+	// Committed under the lock so offsets reach Kafka in order.
+	return w.reader.CommitMessages(ctx, commit...)
+}
@@ -3,6 +3,9 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -11,31 +14,71 @@ import (
 // It holds a reference to the reader for offset management.
 type message struct {
 	raw    kafka.Message
-	reader *kafka.Reader
+	reader reader
 	ctx    context.Context
+
+	headersOnce sync.Once
+	headers     map[string]string // built on the first Headers call
+
+	acked atomic.Bool // set once Ack has committed
 }
 
 func (m *message) Key() []byte   { return m.raw.Key }
 func (m *message) Value() []byte { return m.raw.Value }
+func (m *message) Topic() string { return m.raw.Topic }
 
+// Partition and Offset implement core.OffsetReader.
+func (m *message) Partition() int { return m.raw.Partition }
+func (m *message) Offset() int64  { return m.raw.Offset }
+
+// Headers returns the message headers, with the Kafka time in
+// HeaderTimestamp. The map is built once per message and shared between
+// calls, so callers must not modify it.
 func (m *message) Headers() map[string]string {
-	h := make(map[string]string, len(m.raw.Headers))
-	for _, kh := range m.raw.Headers {
-		h[kh.Key] = string(kh.Value)
+	m.headersOnce.Do(func() {
+		m.headers = make(map[string]string, len(m.raw.Headers)+1)
+		for _, kh := range m.raw.Headers {
+			m.headers[kh.Key] = string(kh.Value)
+		}
+		if !m.raw.Time.IsZero() {
+			m.headers[HeaderTimestamp] = formatTimestamp(m.raw.Time)
+		}
+	})
+	return m.headers
+}
+
+// Header looks up a single header without building the full map.
+// When a key repeats, the last value wins, matching Headers.
+func (m *message) Header(key string) (string, bool) {
+	if key == HeaderTimestamp && !m.raw.Time.IsZero() {
+		return formatTimestamp(m.raw.Time), true
+	}
+	for i := len(m.raw.Headers) - 1; i >= 0; i-- {
+		if m.raw.Headers[i].Key == key {
+			return string(m.raw.Headers[i].Value), true
+		}
 	}
-	return h
+	return "", false
 }
 
+// Timestamp returns the time Kafka recorded for the message.
+func (m *message) Timestamp() time.Time { return m.raw.Time }
+
 // Ack commits the offset for this message.
 func (m *message) Ack() error {
 	if err := m.reader.CommitMessages(m.ctx, m.raw); err != nil {
 		return fmt.Errorf("eventmux/kafka: commit offset: %w", err)
 	}
+	m.acked.Store(true)
 	return nil
 }
 
 // Nack is a no-op for Kafka. Not committing the offset causes the message
-// to be redelivered on the next consumer group rebalance or restart.
+// to be redelivered on the next consumer group rebalance or restart. Under
+// WithKeyedWorkers the message is recorded as failed, see WithKeyedWindow.
 func (m *message) Nack() error {
+	if wm, ok := m.reader.(*watermark); ok {
+		wm.fail(m.raw)
+	}
 	return nil
 }
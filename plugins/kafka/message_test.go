@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/core/middleware"
+)
+
+func testMessage() *message {
+	return &message{raw: kafka.Message{
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/json")},
+			{Key: "trace-id", Value: []byte("abc")},
+			{Key: "trace-id", Value: []byte("def")},
+		},
+	}}
+}
+
+func TestMessage_Headers(t *testing.T) {
+	m := testMessage()
+
+	h := m.Headers()
+	if got := h["content-type"]; got != "application/json" {
+		t.Errorf("content-type = %q, want %q", got, "application/json")
+	}
+	if got := h["trace-id"]; got != "def" {
+		t.Errorf("trace-id = %q, want last value %q", got, "def")
+	}
+	if len(h) != 2 {
+		t.Errorf("expected 2 headers, got %d", len(h))
+	}
+}
+
+func TestMessage_Header(t *testing.T) {
+	for _, built := range []bool{false, true} {
+		m := testMessage()
+		if built {
+			m.Headers()
+		}
+
+		if v, ok := m.Header("trace-id"); !ok || v != "def" {
+			t.Errorf("built=%v: Header(trace-id) = %q, %v; want %q, true", built, v, ok, "def")
+		}
+		if _, ok := m.Header("missing"); ok {
+			t.Errorf("built=%v: Header(missing) reported present", built)
+		}
+	}
+}
+
+// TestMessage_ConcurrentHeaders reads one message from several goroutines,
+// as EmitAsync, the best-effort publisher and Tap do; run it with -race.
+func TestMessage_ConcurrentHeaders(t *testing.T) {
+	m := testMessage()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := m.Headers()["trace-id"]; got != "def" {
+				t.Errorf("Headers()[trace-id] = %q, want def", got)
+			}
+			if v, _ := m.Header("content-type"); v != "application/json" {
+				t.Errorf("Header(content-type) = %q", v)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkMessage_Headers(b *testing.B) {
+	m := testMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = m.Headers()["trace-id"]
+	}
+}
+
+func BenchmarkMessage_Header(b *testing.B) {
+	m := testMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Header("trace-id")
+	}
+}
+
+func TestMessage_OffsetDedupKey(t *testing.T) {
+	m := &message{raw: kafka.Message{Topic: "orders", Partition: 2, Offset: 99}}
+	if got := middleware.OffsetDedupKey(m); got != "offset:orders/2/99" {
+		t.Errorf("OffsetDedupKey = %q, want %q", got, "offset:orders/2/99")
+	}
+}
+
+func TestMessage_Decompress(t *testing.T) {
+	body := []byte(`{"order_id":"o-1"}`)
+	var app bytes.Buffer
+	zw := gzip.NewWriter(&app)
+	zw.Write(body)
+	zw.Close()
+
+	msg := &message{raw: kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    99,
+		Value:     app.Bytes(),
+		Headers:   []kafka.Header{{Key: middleware.HeaderContentEncoding, Value: []byte("gzip")}},
+	}}
+	var got core.Message
+	h := middleware.Decompress()(func(ctx context.Context, m core.Message) error {
+		got = m
+		return nil
+	})
+	if err := h(context.Background(), msg); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if !bytes.Equal(got.Value(), body) {
+		t.Errorf("handler payload = %q, want %q", got.Value(), body)
+	}
+	if key := middleware.OffsetDedupKey(got); key != "offset:orders/2/99" {
+		t.Errorf("OffsetDedupKey = %q, want the record's offset", key)
+	}
+}
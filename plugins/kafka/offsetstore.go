@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// loadBackoff spaces retries of a failing OffsetStore.Load.
+var loadBackoff = core.ExponentialBackoff(100*time.Millisecond, 5*time.Second)
+
+// OffsetStore keeps consumer positions outside Kafka, typically in the
+// database the handler writes to. Ack saves the offset after the handler has
+// written its output, so a crash between the two replays the message:
+// delivery is at-least-once, and Save must accept an offset it already holds.
+type OffsetStore interface {
+	// Load returns the offset of the next message to read from partition of
+	// topic. It reports false if nothing is stored, in which case consuming
+	// starts from the group's committed offset.
+	Load(ctx context.Context, topic string, partition int) (next int64, ok bool, err error)
+
+	// Save records next as the offset to resume partition of topic from.
+	Save(ctx context.Context, topic string, partition int, next int64) error
+}
+
+// startOffset returns the offset to read partition a of topic from: the
+// stored one if the broker has an OffsetStore holding it, else the group's.
+func (b *Broker) startOffset(ctx context.Context, topic string, a kafka.PartitionAssignment) (int64, error) {
+	if b.opts.offsetStore == nil {
+		return a.Offset, nil
+	}
+	next, ok, err := b.opts.offsetStore.Load(ctx, topic, a.ID)
+	if err != nil {
+		return 0, fmt.Errorf("eventmux/kafka: load offset for %s/%d: %w", topic, a.ID, err)
+	}
+	if !ok {
+		return a.Offset, nil
+	}
+	return next, nil
+}
+
+// awaitStartOffset is startOffset, retried with backoff while the
+// OffsetStore fails, so a store outage delays the partition instead of
+// leaving it unread for the rest of the generation. It reports false once
+// ctx ends first.
+func (b *Broker) awaitStartOffset(ctx context.Context, topic string, a kafka.PartitionAssignment) (int64, bool) {
+	for attempt := 1; ; attempt++ {
+		offset, err := b.startOffset(ctx, topic, a)
+		if err == nil {
+			return offset, true
+		}
+		if ctx.Err() != nil {
+			return 0, false
+		}
+		d := loadBackoff(attempt)
+		b.logError("%v; retrying in %v", err, d)
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return 0, false
+		case <-t.C:
+		}
+	}
+}
+
+// storeReader saves offsets to an OffsetStore instead of committing them to
+// the consumer group.
+type storeReader struct {
+	reader
+	store OffsetStore
+}
+
+func (r *storeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		if err := r.store.Save(ctx, m.Topic, m.Partition, m.Offset+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
 )
 
 // Option configures the Kafka broker.
@@ -11,19 +13,35 @@ type Option func(*options)
 
 type options struct {
 	// Writer
-	balancer  kafka.Balancer
-	batchSize int
-	async     bool
+	balancer    kafka.Balancer
+	compression kafka.Compression
+	batchSize   int
+	async       bool
 
 	// Reader
 	minBytes     int
 	maxBytes     int
 	maxWait      time.Duration
 	startOffset  int64
+	groupStart   int64
 	commitPeriod time.Duration
 
+	partitionConcurrency bool
+	keyedWorkers         int
+	keyedWindow          int
+	keyHasher            core.Hasher
+	onAssigned           PartitionsFunc
+	onRevoked            PartitionsFunc
+	offsetStore          OffsetStore
+
+	// Provisioning
+	topicPartitions   int
+	replicationFactor int
+
 	// General
-	dialer *kafka.Dialer
+	dialer      *kafka.Dialer
+	logger      kafka.Logger
+	errorLogger kafka.Logger
 }
 
 func defaults() options {
@@ -35,6 +53,9 @@ func defaults() options {
 		maxWait:      500 * time.Millisecond,
 		startOffset:  kafka.LastOffset,
 		commitPeriod: 0, // manual commit by default
+
+		topicPartitions:   1,
+		replicationFactor: 1,
 	}
 }
 
@@ -43,6 +64,15 @@ func WithBalancer(b kafka.Balancer) Option {
 	return func(o *options) { o.balancer = b }
 }
 
+// WithCompression compresses the record batches the writer produces with
+// codec. This is transport compression: consumers' clients decompress the
+// batches before handlers run, whatever the message headers say. Payloads
+// the application compresses itself are a separate layer, declared with a
+// content-encoding header and decoded by middleware.Decompress.
+func WithCompression(codec kafka.Compression) Option {
+	return func(o *options) { o.compression = codec }
+}
+
 // WithBatchSize sets the maximum batch size for writes.
 func WithBatchSize(n int) Option {
 	return func(o *options) { o.batchSize = n }
@@ -68,7 +98,112 @@ func WithStartOffset(offset int64) Option {
 	return func(o *options) { o.startOffset = offset }
 }
 
+// WithTopicPartitions sets the partition count of topics created by
+// Provision. The default is 1.
+func WithTopicPartitions(n int) Option {
+	return func(o *options) { o.topicPartitions = n }
+}
+
+// WithReplicationFactor sets the replication factor of topics created by
+// Provision. The default is 1.
+func WithReplicationFactor(n int) Option {
+	return func(o *options) { o.replicationFactor = n }
+}
+
+// WithGroupStartOffset sets where a consumer group with no committed
+// offsets begins reading: kafka.FirstOffset to consume the existing history
+// or kafka.LastOffset for new messages only. It only affects groups that
+// have never committed; once a group has, it always resumes from its
+// commits. WithStartOffset, by contrast, applies only without a group.
+func WithGroupStartOffset(offset int64) Option {
+	return func(o *options) { o.groupStart = offset }
+}
+
 // WithDialer sets a custom dialer for TLS/SASL connections.
 func WithDialer(d *kafka.Dialer) Option {
 	return func(o *options) { o.dialer = d }
 }
+
+// WithLogger routes kafka-go's informational reader and writer logs to l.
+// Use kafka.LoggerFunc to adapt a function such as a slog method.
+func WithLogger(l kafka.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithErrorLogger routes kafka-go's reader and writer error logs to l.
+func WithErrorLogger(l kafka.Logger) Option {
+	return func(o *options) { o.errorLogger = l }
+}
+
+// WithPartitionConcurrency processes each partition on its own goroutine.
+// Ordering and offset commits stay serial within a partition, while
+// different partitions are handled in parallel. The handler must therefore
+// be safe for concurrent use.
+func WithPartitionConcurrency(enabled bool) Option {
+	return func(o *options) { o.partitionConcurrency = enabled }
+}
+
+// OnPartitionsAssigned registers fn to be called with the partitions this
+// consumer owns each time the group assigns them, before any of their
+// messages are handled. Use it to load per-partition state. Rebalance
+// callbacks require a consumer group; when set, each assigned partition is
+// processed on its own goroutine, as with WithPartitionConcurrency.
+func OnPartitionsAssigned(fn PartitionsFunc) Option {
+	return func(o *options) { o.onAssigned = fn }
+}
+
+// OnPartitionsRevoked registers fn to be called with the partitions this
+// consumer is giving up, after their handlers have returned and before the
+// next assignment. Use it to flush per-partition state. It is also called
+// on shutdown.
+func OnPartitionsRevoked(fn PartitionsFunc) Option {
+	return func(o *options) { o.onRevoked = fn }
+}
+
+// WithOffsetStore resumes each assigned partition from the offset in store,
+// falling back to the group's committed offset when store has none, and
+// makes Ack save the next offset to store instead of committing it to
+// Kafka. Delivery is at-least-once: a message whose output was written but
+// whose next offset (Offset()+1, see core.OffsetReader) was not yet saved is
+// handled again, so the handler's writes should be idempotent and Save must
+// accept an offset it has already stored.
+// The group still assigns partitions, so Subscribe fails for a route with
+// neither the broker's group nor one set with core.WithGroup. As with
+// rebalance callbacks, each assigned partition is processed on its own
+// goroutine.
+func WithOffsetStore(store OffsetStore) Option {
+	return func(o *options) { o.offsetStore = store }
+}
+
+// WithKeyedWorkers processes messages from all assigned partitions on a
+// fixed pool of n workers, routing each message by a hash of its key.
+// Messages sharing a key are handled in order; others run in parallel, so
+// the handler must be safe for concurrent use. Offsets are committed only
+// up to the first message of each partition that has not been acked, so a
+// failure part-way through a batch never lets later commits skip it. It
+// takes precedence over WithPartitionConcurrency. With rebalance callbacks
+// or an OffsetStore, partitions are consumed one per goroutine, and each
+// gets a pool of n workers of its own.
+func WithKeyedWorkers(n int) Option {
+	return func(o *options) { o.keyedWorkers = n }
+}
+
+// WithKeyedWindow bounds how many fetched messages of one partition
+// WithKeyedWorkers holds while they wait to be committed behind an unacked
+// one. Fetching pauses while a partition's window is full. If the message
+// holding it back failed, i.e. its handler returned an error, it was
+// nacked, or the handler returned without acking it, Subscribe returns an
+// error instead, since the message is only redelivered once consumption
+// restarts from the last commit. Acking a message after its handler has
+// returned only helps while its window has room. The default is 1024.
+func WithKeyedWindow(n int) Option {
+	return func(o *options) { o.keyedWindow = n }
+}
+
+// WithKeyHasher sets how WithKeyedWorkers hashes keys to pick a worker. The
+// default is core.FNV1a; core.Murmur2 matches Kafka's default partitioner,
+// so pair it with kafka.Murmur2Balancer to give a key the same worker index
+// as its partition when the worker and partition counts are equal.
+func WithKeyHasher(h core.Hasher) Option {
+	return func(o *options) { o.keyHasher = h }
+}
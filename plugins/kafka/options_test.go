@@ -0,0 +1,218 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/broker"
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/brokertest"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestWithLoggers(t *testing.T) {
+	var logged, errored []string
+	logger := kafka.LoggerFunc(func(msg string, args ...any) { logged = append(logged, msg) })
+	errLogger := kafka.LoggerFunc(func(msg string, args ...any) { errored = append(errored, msg) })
+
+	b, err := New([]string{"localhost:9092"}, "group", WithLogger(logger), WithErrorLogger(errLogger))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	cfg := b.readerConfig("orders", b.group)
+	for name, l := range map[string]kafka.Logger{
+		"writer.Logger":      b.writer.Logger,
+		"writer.ErrorLogger": b.writer.ErrorLogger,
+		"reader.Logger":      cfg.Logger,
+		"reader.ErrorLogger": cfg.ErrorLogger,
+	} {
+		if l == nil {
+			t.Errorf("%s not set", name)
+			continue
+		}
+		l.Printf(name)
+	}
+
+	for _, msg := range logged {
+		if msg != "writer.Logger" && msg != "reader.Logger" {
+			t.Errorf("Logger received %q", msg)
+		}
+	}
+	for _, msg := range errored {
+		if msg != "writer.ErrorLogger" && msg != "reader.ErrorLogger" {
+			t.Errorf("ErrorLogger received %q", msg)
+		}
+	}
+}
+
+func TestWithGroupStartOffset(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+		opts  []Option
+		want  int64
+	}{
+		{"group, earliest", "billing", []Option{WithGroupStartOffset(kafka.FirstOffset)}, kafka.FirstOffset},
+		{"group, latest", "billing", []Option{WithGroupStartOffset(kafka.LastOffset)}, kafka.LastOffset},
+		{"group, unset", "billing", nil, 0},
+		{"group ignores WithStartOffset", "billing", []Option{WithStartOffset(kafka.FirstOffset)}, 0},
+		{"no group uses WithStartOffset", "", []Option{WithStartOffset(kafka.FirstOffset), WithGroupStartOffset(kafka.LastOffset)}, kafka.FirstOffset},
+	}
+	routeGroups := []struct {
+		name        string
+		broker, sub string
+		want        int64
+	}{
+		{"route group without broker group", "", "billing", kafka.FirstOffset},
+		{"broker group", "billing", "", kafka.FirstOffset},
+		{"no group", "", "", kafka.LastOffset},
+	}
+	for _, tt := range routeGroups {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New([]string{"localhost:9092"}, tt.broker, WithStartOffset(kafka.LastOffset), WithGroupStartOffset(kafka.FirstOffset))
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+			defer b.Close()
+			if got := b.readerConfig("orders", b.groupID(core.SubscribeOptions{Group: tt.sub})).StartOffset; got != tt.want {
+				t.Errorf("StartOffset = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New([]string{"localhost:9092"}, tt.group, tt.opts...)
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+			defer b.Close()
+			if got := b.readerConfig("orders", tt.group).StartOffset; got != tt.want {
+				t.Errorf("StartOffset = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptsFromConfig_GroupStartOffset(t *testing.T) {
+	var o options
+	for _, fn := range optsFromConfig(broker.Config{Extra: map[string]any{"group_start_offset": "earliest"}}) {
+		fn(&o)
+	}
+	if o.groupStart != kafka.FirstOffset {
+		t.Errorf("groupStart = %d, want FirstOffset", o.groupStart)
+	}
+}
+
+func TestOptsFromConfig_KeyHasher(t *testing.T) {
+	var o options
+	for _, fn := range optsFromConfig(broker.Config{Extra: map[string]any{"key_hasher": "murmur2"}}) {
+		fn(&o)
+	}
+	if _, ok := o.keyHasher.(core.Murmur2); !ok {
+		t.Errorf("keyHasher = %T, want core.Murmur2", o.keyHasher)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	b, err := NewFromConfig(Config{
+		Brokers:          []string{"localhost:9092"},
+		Group:            "billing",
+		BatchSize:        10,
+		MaxBytes:         1 << 20,
+		GroupStartOffset: kafka.FirstOffset,
+		KeyedWorkers:     4,
+		TopicPartitions:  6,
+		Options:          []Option{WithReplicationFactor(3)},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	if b.group != "billing" {
+		t.Errorf("group = %q, want billing", b.group)
+	}
+	if b.opts.batchSize != 10 || b.writer.BatchSize != 10 {
+		t.Errorf("batch size = %d (writer %d), want 10", b.opts.batchSize, b.writer.BatchSize)
+	}
+	if b.opts.maxBytes != 1<<20 {
+		t.Errorf("maxBytes = %d, want %d", b.opts.maxBytes, 1<<20)
+	}
+	if got := b.readerConfig("orders", b.group).StartOffset; got != kafka.FirstOffset {
+		t.Errorf("StartOffset = %d, want FirstOffset", got)
+	}
+	if b.opts.keyedWorkers != 4 || b.opts.topicPartitions != 6 || b.opts.replicationFactor != 3 {
+		t.Errorf("keyedWorkers/topicPartitions/replicationFactor = %d/%d/%d, want 4/6/3",
+			b.opts.keyedWorkers, b.opts.topicPartitions, b.opts.replicationFactor)
+	}
+	if b.opts.maxWait != defaults().maxWait {
+		t.Errorf("unset MaxWait changed default to %v", b.opts.maxWait)
+	}
+}
+
+func TestCreateTyped(t *testing.T) {
+	b, err := broker.CreateTyped("kafka", Config{Brokers: []string{"localhost:9092"}, BatchSize: 7})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer b.Close()
+	if got := b.(*Broker).opts.batchSize; got != 7 {
+		t.Errorf("batchSize = %d, want 7", got)
+	}
+
+	if _, err := broker.CreateTyped("kafka", broker.Config{}); err == nil {
+		t.Error("expected error for a foreign config type")
+	}
+}
+
+func TestBroker_CloseContract(t *testing.T) {
+	brokertest.RunClose(t, "orders", func(t *testing.T) core.Broker {
+		b, err := New([]string{"localhost:9092"}, "")
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		return b
+	})
+}
+
+func TestWithFetchBytes_PerRoute(t *testing.T) {
+	b, err := New([]string{"localhost:9092"}, "group", WithMaxBytes(1<<20))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	small := withFetchBytes(b.readerConfig("clicks", ""), core.SubscribeOptions{FetchMinBytes: 1, FetchMaxBytes: 64 << 10})
+	large := withFetchBytes(b.readerConfig("reports", ""), core.SubscribeOptions{FetchMinBytes: 1 << 20, FetchMaxBytes: 50 << 20})
+	unset := withFetchBytes(b.readerConfig("orders", ""), core.SubscribeOptions{Prefetch: 5})
+
+	if small.MinBytes != 1 || small.MaxBytes != 64<<10 {
+		t.Errorf("clicks: MinBytes/MaxBytes = %d/%d, want 1/%d", small.MinBytes, small.MaxBytes, 64<<10)
+	}
+	if large.MinBytes != 1<<20 || large.MaxBytes != 50<<20 {
+		t.Errorf("reports: MinBytes/MaxBytes = %d/%d, want %d/%d", large.MinBytes, large.MaxBytes, 1<<20, 50<<20)
+	}
+	if unset.MinBytes != 1 || unset.MaxBytes != 1<<20 {
+		t.Errorf("orders: MinBytes/MaxBytes = %d/%d, want broker defaults 1/%d", unset.MinBytes, unset.MaxBytes, 1<<20)
+	}
+}
+
+func TestGroupID_PerRoute(t *testing.T) {
+	b, err := New([]string{"localhost:9092"}, "billing")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	if got := b.Group(); got != "billing" {
+		t.Errorf("Group() = %q, want %q", got, "billing")
+	}
+	if got := b.groupID(core.SubscribeOptions{}); got != "billing" {
+		t.Errorf("groupID without override = %q, want %q", got, "billing")
+	}
+	if got := b.groupID(core.SubscribeOptions{Group: "billing-slow"}); got != "billing-slow" {
+		t.Errorf("groupID with override = %q, want %q", got, "billing-slow")
+	}
+}
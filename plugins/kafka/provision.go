@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Provision implements core.Provisioner by creating each topic on the
+// cluster controller with the partitions and replication factor set by
+// WithTopicPartitions and WithReplicationFactor. Topics that already exist
+// are left unchanged. Patterns with wildcards name no single topic and are
+// skipped.
+func (b *Broker) Provision(ctx context.Context, topics []string) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
+	b.mu.Unlock()
+
+	var configs []kafka.TopicConfig
+	for _, t := range topics {
+		if strings.ContainsAny(t, "*>#") {
+			continue
+		}
+		configs = append(configs, kafka.TopicConfig{
+			Topic:             t,
+			NumPartitions:     b.opts.topicPartitions,
+			ReplicationFactor: b.opts.replicationFactor,
+		})
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	dialer := b.opts.dialer
+	if dialer == nil {
+		dialer = kafka.DefaultDialer
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", b.brokers[0])
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: dial %q: %w", b.brokers[0], err)
+	}
+	defer conn.Close()
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: find controller: %w", err)
+	}
+	cc, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: dial controller: %w", err)
+	}
+	defer cc.Close()
+
+	if err := cc.CreateTopics(configs...); err != nil {
+		return fmt.Errorf("eventmux/kafka: create topics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// PartitionsFunc receives the partitions of topic affected by a rebalance.
+type PartitionsFunc func(topic string, partitions []int)
+
+// managesGenerations reports whether Subscribe must manage the generations
+// of a route's consumer group itself, for rebalance callbacks or an
+// OffsetStore.
+func (b *Broker) managesGenerations(group string) bool {
+	return group != "" && (b.opts.onAssigned != nil || b.opts.onRevoked != nil || b.opts.offsetStore != nil)
+}
+
+// consumeGroup consumes topic through a kafka.ConsumerGroup so partition
+// assignment and revocation can be reported. Each generation reads every
+// assigned partition on its own goroutine, committing offsets through the
+// generation; revocation is reported once all of them have stopped, before
+// the next generation starts. A partition that fails closes the group, and
+// its error is returned.
+func (b *Broker) consumeGroup(ctx context.Context, topic, id string, so core.SubscribeOptions, handler core.Handler) error {
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:          id,
+		Brokers:     b.brokers,
+		Dialer:      b.opts.dialer,
+		Topics:      []string{topic},
+		StartOffset: b.opts.groupStart,
+		Logger:      b.opts.logger,
+		ErrorLogger: b.opts.errorLogger,
+	})
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: consumer group %q: %w", id, err)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		group.Close()
+		return core.ErrBrokerClosed
+	}
+	b.groups = append(b.groups, group)
+	b.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { group.Close() })
+	defer stop()
+
+	failed := make(chan error, 1)
+	fail := func(err error) {
+		b.logError("%v", err)
+		select {
+		case failed <- err:
+		default:
+		}
+		group.Close()
+	}
+	for {
+		gen, err := group.Next(ctx)
+		if err != nil {
+			select {
+			case err := <-failed:
+				return err
+			default:
+			}
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return nil // graceful shutdown
+			}
+			return fmt.Errorf("eventmux/kafka: join group %q: %w", id, err)
+		}
+		b.runGeneration(gen, topic, so, handler, fail)
+	}
+}
+
+// runGeneration starts the partition readers for gen and the goroutine that
+// reports revocation when gen ends. Partition errors are passed to fail.
+func (b *Broker) runGeneration(gen *kafka.Generation, topic string, so core.SubscribeOptions, handler core.Handler, fail func(error)) {
+	assignments := gen.Assignments[topic]
+	partitions := make([]int, len(assignments))
+	for i, a := range assignments {
+		partitions[i] = a.ID
+	}
+	sort.Ints(partitions)
+
+	if b.opts.onAssigned != nil {
+		b.opts.onAssigned(topic, partitions)
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(len(assignments))
+	for _, a := range assignments {
+		gen.Start(func(ctx context.Context) {
+			defer readers.Done()
+			if err := b.consumeAssignment(ctx, gen, topic, a, so, handler); err != nil {
+				fail(err)
+			}
+		})
+	}
+	gen.Start(func(ctx context.Context) {
+		<-ctx.Done()
+		readers.Wait()
+		if b.opts.onRevoked != nil {
+			b.opts.onRevoked(topic, partitions)
+		}
+	})
+}
+
+// consumeAssignment reads one assigned partition from its committed (or
+// stored) offset until the generation ends, on a pool of its own with
+// WithKeyedWorkers.
+func (b *Broker) consumeAssignment(ctx context.Context, gen *kafka.Generation, topic string, a kafka.PartitionAssignment, so core.SubscribeOptions, handler core.Handler) error {
+	offset, ok := b.awaitStartOffset(ctx, topic, a)
+	if !ok {
+		return nil
+	}
+
+	cfg := withFetchBytes(b.readerConfig(topic, ""), so)
+	cfg.Partition = a.ID
+	r := kafka.NewReader(cfg)
+	defer r.Close()
+
+	if err := r.SetOffset(offset); err != nil {
+		return fmt.Errorf("eventmux/kafka: seek %s/%d to offset %d: %w", topic, a.ID, offset, err)
+	}
+	var rd reader = &generationReader{Reader: r, gen: gen}
+	if b.opts.offsetStore != nil {
+		rd = &storeReader{reader: r, store: b.opts.offsetStore}
+	}
+	var err error
+	if b.opts.keyedWorkers > 0 {
+		err = b.consumeKeyed(ctx, rd, handler, b.opts.keyedWorkers, b.opts.keyedWindow)
+	} else {
+		err = b.consumeLoop(ctx, rd, handler)
+	}
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: partition %s/%d: %w", topic, a.ID, err)
+	}
+	return nil
+}
+
+// generationReader commits offsets through the consumer group generation
+// that owns the partition.
+type generationReader struct {
+	*kafka.Reader
+	gen *kafka.Generation
+}
+
+func (r *generationReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	offsets := make(map[string]map[int]int64)
+	for _, m := range msgs {
+		if offsets[m.Topic] == nil {
+			offsets[m.Topic] = make(map[int]int64)
+		}
+		offsets[m.Topic][m.Partition] = m.Offset + 1
+	}
+	return r.gen.CommitOffsets(offsets)
+}
@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// ReplayFrom implements core.Replayer. Each partition of topic is read from
+// the first offset at or after since (looked up by timestamp) up to the end
+// offset it had when ReplayFrom was called, one partition at a time. Replay readers have no consumer group,
+// so committed offsets are untouched and Ack is a no-op.
+func (b *Broker) ReplayFrom(ctx context.Context, topic string, since time.Time, handler core.Handler) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
+	b.mu.Unlock()
+
+	dialer := b.opts.dialer
+	if dialer == nil {
+		dialer = kafka.DefaultDialer
+	}
+	parts, err := dialer.LookupPartitions(ctx, "tcp", b.brokers[0], topic)
+	if err != nil {
+		return fmt.Errorf("eventmux/kafka: lookup partitions for %q: %w", topic, err)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].ID < parts[j].ID })
+
+	for _, p := range parts {
+		if err := b.replayPartition(ctx, topic, p.ID, since, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayIdleTimeout bounds the wait for a message below the end offset. The
+// last offsets of a partition may hold no message, e.g. transaction markers
+// or compacted records, so a fetch that stays empty this long ends the
+// partition's replay.
+var replayIdleTimeout = 10 * time.Second
+
+// replayPartition replays a single partition from since to the end offset
+// it had when the replay started.
+func (b *Broker) replayPartition(ctx context.Context, topic string, partition int, since time.Time, handler core.Handler) error {
+	end, err := b.lastOffset(ctx, topic, partition)
+	if err != nil {
+		return err
+	}
+
+	cfg := b.readerConfig(topic, "")
+	cfg.Partition = partition
+	r := kafka.NewReader(cfg)
+	defer r.Close()
+
+	if err := r.SetOffsetAt(ctx, since); err != nil {
+		return fmt.Errorf("eventmux/kafka: seek %q/%d to %s: %w", topic, partition, since.Format(time.RFC3339), err)
+	}
+	if r.Offset() >= end {
+		return nil
+	}
+	return replayLoop(ctx, replayReader{r}, handler, end)
+}
+
+// lastOffset returns the offset the next message written to partition of
+// topic will get.
+func (b *Broker) lastOffset(ctx context.Context, topic string, partition int) (int64, error) {
+	dialer := b.opts.dialer
+	if dialer == nil {
+		dialer = kafka.DefaultDialer
+	}
+	conn, err := dialer.DialLeader(ctx, "tcp", b.brokers[0], topic, partition)
+	if err != nil {
+		return 0, fmt.Errorf("eventmux/kafka: dial leader of %q/%d: %w", topic, partition, err)
+	}
+	defer conn.Close()
+	end, err := conn.ReadLastOffset()
+	if err != nil {
+		return 0, fmt.Errorf("eventmux/kafka: read last offset of %q/%d: %w", topic, partition, err)
+	}
+	return end, nil
+}
+
+// replayReader is a group-less reader whose commits are no-ops.
+type replayReader struct {
+	*kafka.Reader
+}
+
+func (replayReader) CommitMessages(context.Context, ...kafka.Message) error { return nil }
+
+// replayLoop delivers messages below end, the partition's end offset when
+// the replay started. Messages written since are left alone. It also stops
+// once no message arrives for replayIdleTimeout, as the offsets before end
+// need not all hold messages. A handler error stops the replay.
+func replayLoop(ctx context.Context, r reader, handler core.Handler, end int64) error {
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, replayIdleTimeout)
+		raw, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if fetchCtx.Err() != nil {
+				return nil // nothing left below end
+			}
+			return fmt.Errorf("eventmux/kafka: replay fetch: %w", err)
+		}
+		if raw.Offset >= end {
+			return nil
+		}
+		if err := handler(ctx, &message{raw: raw, reader: r, ctx: ctx}); err != nil {
+			return fmt.Errorf("eventmux/kafka: replay %q/%d at offset %d: %w", raw.Topic, raw.Partition, raw.Offset, err)
+		}
+		if raw.Offset+1 >= end {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// HeaderTimestamp carries a message's event time, formatted as RFC 3339, and
+// sets the Kafka message time on publish. Timestamped and consumed Kafka
+// messages report it, so it survives wrappers such as core.MergeHeaders that
+// keep the headers of the message they wrap. The header itself is not
+// forwarded to Kafka.
+const HeaderTimestamp = "eventmux-timestamp"
+
+// Timestamper is implemented by consumed Kafka messages, which carry the time
+// Kafka recorded for them. Republishing one preserves that time.
+type Timestamper interface {
+	Timestamp() time.Time
+}
+
+// Timestamped returns msg with t as its event time, carried in
+// HeaderTimestamp. Publishing the result sets kafka.Message.Time instead of
+// letting the writer use the current time.
+func Timestamped(msg core.Message, t time.Time) core.Message {
+	return core.MergeHeaders(msg, map[string]string{HeaderTimestamp: formatTimestamp(t)})
+}
+
+func formatTimestamp(t time.Time) string { return t.Format(time.RFC3339Nano) }
+
+// timestampOf returns the event time in msg's HeaderTimestamp, or the zero
+// time if none was set.
+func timestampOf(msg core.Message) time.Time {
+	if v := core.Header(msg, HeaderTimestamp); v != "" {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
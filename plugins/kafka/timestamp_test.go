@@ -0,0 +1,54 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func TestToKafkaMessage_Timestamp(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		msg  core.Message
+	}{
+		{"timestamped", Timestamped(&mock.Message{V: []byte("v")}, want)},
+		{"header", &mock.Message{V: []byte("v"), H: map[string]string{
+			HeaderTimestamp: want.Format(time.RFC3339Nano),
+		}}},
+		{"merged headers", core.MergeHeaders(
+			Timestamped(&mock.Message{V: []byte("v")}, want),
+			map[string]string{"trace-id": "abc"},
+		)},
+		{"consumed", core.MergeHeaders(
+			&message{raw: kafka.Message{Value: []byte("v"), Time: want}},
+			map[string]string{core.HeaderDeadLetterReason: "poison"},
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := toKafkaMessage("orders", tt.msg)
+			if !km.Time.Equal(want) {
+				t.Errorf("Time = %v, want %v", km.Time, want)
+			}
+			for _, h := range km.Headers {
+				if h.Key == HeaderTimestamp {
+					t.Errorf("%s header must not be forwarded", HeaderTimestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestToKafkaMessage_NoTimestamp(t *testing.T) {
+	km := toKafkaMessage("orders", &mock.Message{V: []byte("v")})
+	if !km.Time.IsZero() {
+		t.Errorf("Time = %v, want zero so the writer stamps it", km.Time)
+	}
+}
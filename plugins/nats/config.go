@@ -0,0 +1,127 @@
+package nats
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// Config is the strongly-typed alternative to broker.Config's Extra map.
+// Zero fields keep the defaults of New. Options are applied after the
+// fields.
+type Config struct {
+	URL   string
+	Group string
+
+	// Stream
+	MaxMessages int64
+	MaxBytes    int64
+	MaxAge      time.Duration
+	Replicas    int
+	Retention   jetstream.RetentionPolicy
+	Storage     jetstream.StorageType
+
+	// Consumer
+	AckWait         time.Duration
+	MaxDeliver      int
+	BackoffSchedule []time.Duration
+	FetchMaxBytes   int
+
+	RecreateOnConflict bool
+
+	// Producer
+	DedupID func(msg core.Message) string
+
+	// Connection
+	TLSConfig       *tls.Config
+	RootCAs         []string
+	CertFile        string
+	KeyFile         string
+	CredentialsFile string
+	Token           string
+
+	MaxReconnects      int
+	ReconnectWait      time.Duration
+	MaxReconnectBuffer int
+
+	Options []Option
+}
+
+// NewFromConfig creates a NATS JetStream Broker from a typed Config. It is
+// also registered with broker.RegisterTyped under "nats".
+func NewFromConfig(cfg Config) (*Broker, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("eventmux/nats: a broker URL is required")
+	}
+	return New(cfg.URL, cfg.Group, cfg.options()...)
+}
+
+// options converts the set fields of c into Options.
+func (c Config) options() []Option {
+	var opts []Option
+	if c.MaxMessages != 0 {
+		opts = append(opts, WithMaxMessages(c.MaxMessages))
+	}
+	if c.MaxBytes != 0 {
+		opts = append(opts, WithMaxBytes(c.MaxBytes))
+	}
+	if c.MaxAge != 0 {
+		opts = append(opts, WithMaxAge(c.MaxAge))
+	}
+	if c.Replicas != 0 {
+		opts = append(opts, WithReplicas(c.Replicas))
+	}
+	if c.Retention != 0 {
+		opts = append(opts, WithRetention(c.Retention))
+	}
+	if c.Storage != 0 {
+		opts = append(opts, WithStorage(c.Storage))
+	}
+	if c.AckWait != 0 {
+		opts = append(opts, WithAckWait(c.AckWait))
+	}
+	if c.MaxDeliver != 0 {
+		opts = append(opts, WithMaxDeliver(c.MaxDeliver))
+	}
+	if len(c.BackoffSchedule) > 0 {
+		opts = append(opts, WithBackoffSchedule(c.BackoffSchedule))
+	}
+	if c.FetchMaxBytes != 0 {
+		opts = append(opts, WithFetchMaxBytes(c.FetchMaxBytes))
+	}
+	if c.RecreateOnConflict {
+		opts = append(opts, WithRecreateOnConflict(true))
+	}
+	if c.DedupID != nil {
+		opts = append(opts, WithDedupID(c.DedupID))
+	}
+	if c.TLSConfig != nil {
+		opts = append(opts, WithTLSConfig(c.TLSConfig))
+	}
+	if len(c.RootCAs) > 0 {
+		opts = append(opts, WithRootCAs(c.RootCAs...))
+	}
+	if c.CertFile != "" {
+		opts = append(opts, WithClientCert(c.CertFile, c.KeyFile))
+	}
+	if c.CredentialsFile != "" {
+		opts = append(opts, WithUserCredentials(c.CredentialsFile))
+	}
+	if c.Token != "" {
+		opts = append(opts, WithToken(c.Token))
+	}
+	if c.MaxReconnects != 0 {
+		opts = append(opts, WithMaxReconnects(c.MaxReconnects))
+	}
+	if c.ReconnectWait != 0 {
+		opts = append(opts, WithReconnectWait(c.ReconnectWait))
+	}
+	if c.MaxReconnectBuffer != 0 {
+		opts = append(opts, WithMaxReconnectBuffer(c.MaxReconnectBuffer))
+	}
+	return append(opts, c.Options...)
+}
@@ -0,0 +1,44 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestConfig_Options(t *testing.T) {
+	cfg := Config{
+		URL:             "nats://localhost:4222",
+		Replicas:        3,
+		Storage:         jetstream.MemoryStorage,
+		MaxDeliver:      4,
+		BackoffSchedule: []time.Duration{time.Second},
+		FetchMaxBytes:   1 << 20,
+		Options:         []Option{WithAckWait(time.Minute)},
+	}
+
+	o := defaults()
+	for _, fn := range cfg.options() {
+		fn(&o)
+	}
+
+	if o.replicas != 3 || o.storage != jetstream.MemoryStorage || o.maxDeliver != 4 {
+		t.Errorf("replicas/storage/maxDeliver = %d/%v/%d, want 3/memory/4", o.replicas, o.storage, o.maxDeliver)
+	}
+	if len(o.backoff) != 1 || o.fetchMaxBytes != 1<<20 || o.ackWait != time.Minute {
+		t.Errorf("backoff/fetchMaxBytes/ackWait = %v/%d/%v", o.backoff, o.fetchMaxBytes, o.ackWait)
+	}
+	if d := defaults(); o.maxMsgs != d.maxMsgs || o.retention != d.retention {
+		t.Errorf("unset fields changed defaults: maxMsgs %d, retention %v", o.maxMsgs, o.retention)
+	}
+	if err := o.validate(); err != nil {
+		t.Errorf("validate: %v", err)
+	}
+}
+
+func TestNewFromConfig_RequiresURL(t *testing.T) {
+	if _, err := NewFromConfig(Config{}); err == nil {
+		t.Error("expected error without URL")
+	}
+}
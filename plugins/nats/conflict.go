@@ -0,0 +1,115 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConflictError reports that a stream or durable consumer already exists
+// with settings JetStream cannot change in place, such as the storage type.
+// The existing one must be deleted and recreated, either by hand or with
+// WithRecreateOnConflict.
+type ConflictError struct {
+	Kind   string   // "stream" or "consumer"
+	Name   string   // stream or consumer name
+	Fields []string // the conflicting settings, existing value first
+	Err    error    // the error JetStream returned for the update
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("eventmux/nats: %s %q exists with an incompatible configuration (%s); "+
+		"delete and recreate it, or enable WithRecreateOnConflict: %v",
+		e.Kind, e.Name, strings.Join(e.Fields, "; "), e.Err)
+}
+
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// streamConflicts lists the settings of want that JetStream refuses to
+// apply to an existing stream configured as have.
+func streamConflicts(have, want jetstream.StreamConfig) []string {
+	var fields []string
+	if have.Storage != want.Storage {
+		fields = append(fields, fmt.Sprintf("storage is %s, want %s", have.Storage, want.Storage))
+	}
+	if have.Retention != want.Retention &&
+		(have.Retention == jetstream.WorkQueuePolicy || want.Retention == jetstream.WorkQueuePolicy) {
+		fields = append(fields, fmt.Sprintf("retention is %s, want %s", have.Retention, want.Retention))
+	}
+	return fields
+}
+
+// consumerConflicts lists the settings of want that JetStream refuses to
+// apply to an existing consumer configured as have.
+func consumerConflicts(have, want jetstream.ConsumerConfig) []string {
+	var fields []string
+	if have.AckPolicy != want.AckPolicy {
+		fields = append(fields, fmt.Sprintf("ack policy is %s, want %s", have.AckPolicy, want.AckPolicy))
+	}
+	if have.DeliverPolicy != want.DeliverPolicy {
+		fields = append(fields, fmt.Sprintf("deliver policy is %s, want %s", have.DeliverPolicy, want.DeliverPolicy))
+	}
+	return fields
+}
+
+// ensureStream creates or updates the stream described by cfg. If an update
+// fails because the existing stream conflicts with cfg, it returns a
+// ConflictError, or deletes and recreates the stream with
+// WithRecreateOnConflict.
+func (b *Broker) ensureStream(ctx context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	stream, err := b.js.CreateOrUpdateStream(ctx, cfg)
+	if err == nil {
+		return stream, nil
+	}
+	existing, infoErr := b.js.Stream(ctx, cfg.Name)
+	if infoErr != nil {
+		return nil, fmt.Errorf("eventmux/nats: create stream %q: %w", cfg.Name, err)
+	}
+	fields := streamConflicts(existing.CachedInfo().Config, cfg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("eventmux/nats: create stream %q: %w", cfg.Name, err)
+	}
+	if !b.opts.recreateOnConflict {
+		return nil, &ConflictError{Kind: "stream", Name: cfg.Name, Fields: fields, Err: err}
+	}
+
+	if err := b.js.DeleteStream(ctx, cfg.Name); err != nil {
+		return nil, fmt.Errorf("eventmux/nats: recreate stream %q: delete: %w", cfg.Name, err)
+	}
+	stream, err = b.js.CreateStream(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/nats: recreate stream %q: %w", cfg.Name, err)
+	}
+	return stream, nil
+}
+
+// ensureDurable creates or updates the durable consumer described by cfg on
+// stream, handling conflicts as ensureStream does.
+func (b *Broker) ensureDurable(ctx context.Context, stream jetstream.Stream, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	cons, err := stream.CreateOrUpdateConsumer(ctx, cfg)
+	if err == nil {
+		return cons, nil
+	}
+	existing, infoErr := stream.Consumer(ctx, cfg.Durable)
+	if infoErr != nil {
+		return nil, fmt.Errorf("eventmux/nats: create consumer %q: %w", cfg.Durable, err)
+	}
+	fields := consumerConflicts(existing.CachedInfo().Config, cfg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("eventmux/nats: create consumer %q: %w", cfg.Durable, err)
+	}
+	if !b.opts.recreateOnConflict {
+		return nil, &ConflictError{Kind: "consumer", Name: cfg.Durable, Fields: fields, Err: err}
+	}
+
+	if err := stream.DeleteConsumer(ctx, cfg.Durable); err != nil {
+		return nil, fmt.Errorf("eventmux/nats: recreate consumer %q: delete: %w", cfg.Durable, err)
+	}
+	cons, err = stream.CreateConsumer(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("eventmux/nats: recreate consumer %q: %w", cfg.Durable, err)
+	}
+	return cons, nil
+}
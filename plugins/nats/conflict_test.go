@@ -0,0 +1,239 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var errUpdate = errors.New("stream configuration update can not change storage type")
+
+// fakeJetStream records publishes and serves one existing stream whose
+// config cannot be updated to a different storage type or ack policy.
+type fakeJetStream struct {
+	jetstream.JetStream
+	stream     *fakeStream
+	deleted    []string
+	published  []*nats.Msg
+	publishErr error
+}
+
+func (f *fakeJetStream) PublishMsg(_ context.Context, msg *nats.Msg, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	if f.publishErr != nil {
+		return nil, f.publishErr
+	}
+	f.published = append(f.published, msg)
+	return &jetstream.PubAck{}, nil
+}
+
+func (f *fakeJetStream) CreateOrUpdateStream(_ context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	if f.stream != nil && f.stream.cfg.Storage != cfg.Storage {
+		return nil, errUpdate
+	}
+	return f.CreateStream(context.Background(), cfg)
+}
+
+func (f *fakeJetStream) CreateStream(_ context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	if f.stream == nil {
+		f.stream = &fakeStream{}
+	}
+	f.stream.cfg = cfg
+	return f.stream, nil
+}
+
+func (f *fakeJetStream) Stream(context.Context, string) (jetstream.Stream, error) {
+	if f.stream == nil {
+		return nil, jetstream.ErrStreamNotFound
+	}
+	return f.stream, nil
+}
+
+func (f *fakeJetStream) DeleteStream(_ context.Context, name string) error {
+	f.deleted = append(f.deleted, "stream "+name)
+	f.stream = nil
+	return nil
+}
+
+type fakeStream struct {
+	jetstream.Stream
+	cfg      jetstream.StreamConfig
+	consumer *fakeConsumer
+	deleted  []string
+	onStart  func() // passed to consumers created on the stream
+}
+
+func (s *fakeStream) CachedInfo() *jetstream.StreamInfo {
+	return &jetstream.StreamInfo{Config: s.cfg}
+}
+
+func (s *fakeStream) CreateOrUpdateConsumer(_ context.Context, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	if s.consumer != nil && s.consumer.cfg.AckPolicy != cfg.AckPolicy {
+		return nil, errors.New("ack policy can not be updated")
+	}
+	return s.CreateConsumer(context.Background(), cfg)
+}
+
+func (s *fakeStream) CreateConsumer(_ context.Context, cfg jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	s.consumer = &fakeConsumer{cfg: cfg, onStart: s.onStart}
+	return s.consumer, nil
+}
+
+func (s *fakeStream) Consumer(context.Context, string) (jetstream.Consumer, error) {
+	if s.consumer == nil {
+		return nil, jetstream.ErrConsumerNotFound
+	}
+	return s.consumer, nil
+}
+
+func (s *fakeStream) DeleteConsumer(_ context.Context, name string) error {
+	s.deleted = append(s.deleted, "consumer "+name)
+	s.consumer = nil
+	return nil
+}
+
+type fakeConsumer struct {
+	jetstream.Consumer
+	cfg     jetstream.ConsumerConfig
+	onStart func()
+	cc      *fakeConsumeContext
+}
+
+func (c *fakeConsumer) Consume(jetstream.MessageHandler, ...jetstream.PullConsumeOpt) (jetstream.ConsumeContext, error) {
+	if c.onStart != nil {
+		c.onStart()
+	}
+	c.cc = &fakeConsumeContext{}
+	return c.cc, nil
+}
+
+type fakeConsumeContext struct {
+	jetstream.ConsumeContext
+	stopped bool
+}
+
+func (c *fakeConsumeContext) Stop() { c.stopped = true }
+
+func (c *fakeConsumer) CachedInfo() *jetstream.ConsumerInfo {
+	return &jetstream.ConsumerInfo{Config: c.cfg}
+}
+
+func newConflictBroker(js *fakeJetStream, fns ...Option) *Broker {
+	opts := defaults()
+	WithStorage(jetstream.MemoryStorage)(&opts)
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	return &Broker{js: js, group: "billing", opts: opts}
+}
+
+func TestEnsureConsumer_StreamConflict(t *testing.T) {
+	js := &fakeJetStream{stream: &fakeStream{cfg: jetstream.StreamConfig{Name: "orders", Storage: jetstream.FileStorage}}}
+	b := newConflictBroker(js)
+
+	_, _, err := b.ensureConsumer(context.Background(), "orders")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if conflict.Kind != "stream" || conflict.Name != "orders" {
+		t.Errorf("conflict = %s %q", conflict.Kind, conflict.Name)
+	}
+	if !strings.Contains(err.Error(), "storage is File, want Memory") || !strings.Contains(err.Error(), "WithRecreateOnConflict") {
+		t.Errorf("error should name the field and the fix: %v", err)
+	}
+	if !errors.Is(err, errUpdate) {
+		t.Error("ConflictError should wrap the JetStream error")
+	}
+	if len(js.deleted) != 0 {
+		t.Errorf("nothing should be deleted by default, deleted %v", js.deleted)
+	}
+}
+
+func TestEnsureConsumer_RecreateStream(t *testing.T) {
+	js := &fakeJetStream{stream: &fakeStream{cfg: jetstream.StreamConfig{Name: "orders", Storage: jetstream.FileStorage}}}
+	b := newConflictBroker(js, WithRecreateOnConflict(true))
+
+	if _, _, err := b.ensureConsumer(context.Background(), "orders"); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if len(js.deleted) != 1 || js.deleted[0] != "stream orders" {
+		t.Errorf("deleted = %v, want [stream orders]", js.deleted)
+	}
+	if js.stream.cfg.Storage != jetstream.MemoryStorage {
+		t.Errorf("recreated storage = %s, want Memory", js.stream.cfg.Storage)
+	}
+}
+
+func TestEnsureConsumer_ConsumerConflict(t *testing.T) {
+	existing := &fakeConsumer{cfg: jetstream.ConsumerConfig{Durable: "billing", AckPolicy: jetstream.AckNonePolicy}}
+	js := &fakeJetStream{stream: &fakeStream{
+		cfg:      jetstream.StreamConfig{Name: "orders", Storage: jetstream.MemoryStorage},
+		consumer: existing,
+	}}
+
+	_, _, err := newConflictBroker(js).ensureConsumer(context.Background(), "orders")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Kind != "consumer" || conflict.Name != "billing" {
+		t.Fatalf("expected consumer ConflictError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "ack policy is AckNone, want AckExplicit") {
+		t.Errorf("error should name the ack policy: %v", err)
+	}
+
+	b := newConflictBroker(js, WithRecreateOnConflict(true))
+	if _, _, err := b.ensureConsumer(context.Background(), "orders"); err != nil {
+		t.Fatalf("ensure with recreate: %v", err)
+	}
+	if got := js.stream.deleted; len(got) != 1 || got[0] != "consumer billing" {
+		t.Errorf("deleted = %v, want [consumer billing]", got)
+	}
+	if js.stream.consumer.cfg.AckPolicy != jetstream.AckExplicitPolicy {
+		t.Errorf("recreated ack policy = %s", js.stream.consumer.cfg.AckPolicy)
+	}
+}
+
+func TestEnsureConsumer_OtherErrorsPassThrough(t *testing.T) {
+	js := &fakeJetStream{}
+	b := newConflictBroker(js)
+	b.js = &failingJetStream{fakeJetStream: js}
+
+	_, _, err := b.ensureConsumer(context.Background(), "orders")
+	var conflict *ConflictError
+	if err == nil || errors.As(err, &conflict) {
+		t.Fatalf("expected a plain error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `create stream "orders"`) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// failingJetStream fails every update without an existing stream to blame.
+type failingJetStream struct {
+	*fakeJetStream
+}
+
+func (f *failingJetStream) CreateOrUpdateStream(context.Context, jetstream.StreamConfig) (jetstream.Stream, error) {
+	return nil, errors.New("insufficient resources")
+}
+
+func TestEnsureConsumer_GroupOverride(t *testing.T) {
+	js := &fakeJetStream{}
+	b := newConflictBroker(js)
+
+	ctx := core.WithSubscribeOptions(context.Background(), core.SubscribeOptions{Group: "billing-slow"})
+	_, name, err := b.ensureConsumer(ctx, "reports")
+	if err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if name != "billing-slow" || js.stream.consumer.cfg.Durable != "billing-slow" {
+		t.Errorf("durable = %q (%q), want the route group %q", js.stream.consumer.cfg.Durable, name, "billing-slow")
+	}
+	if b.Group() != "billing" {
+		t.Errorf("Group() = %q, want the broker group %q", b.Group(), "billing")
+	}
+}
@@ -2,27 +2,69 @@ package nats
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
 )
 
 // message adapts a JetStream message to core.Message.
 type message struct {
-	msg jetstream.Msg
+	msg     jetstream.Msg
+	backoff []time.Duration
+
+	headersOnce sync.Once
+	headers     map[string]string // built on the first Headers call
 }
 
 func (m *message) Key() []byte   { return []byte(m.msg.Subject()) }
 func (m *message) Value() []byte { return m.msg.Data() }
+func (m *message) Topic() string { return m.msg.Subject() }
 
+// Headers returns the first value of each message header. The map is built
+// once per message and shared between calls, so callers must not modify it.
 func (m *message) Headers() map[string]string {
-	raw := m.msg.Headers()
-	h := make(map[string]string, len(raw))
-	for k, v := range raw {
-		if len(v) > 0 {
-			h[k] = v[0]
+	m.headersOnce.Do(func() {
+		raw := m.msg.Headers()
+		m.headers = make(map[string]string, len(raw))
+		for k, v := range raw {
+			if len(v) > 0 {
+				m.headers[k] = v[0]
+			}
 		}
+	})
+	return m.headers
+}
+
+// Header looks up a single header without building the full map.
+func (m *message) Header(key string) (string, bool) {
+	if v := m.msg.Headers()[key]; len(v) > 0 {
+		return v[0], true
 	}
-	return h
+	return "", false
+}
+
+// Attempt implements core.AttemptReader. A republished HeaderAttempt wins;
+// otherwise JetStream's delivery count is used.
+func (m *message) Attempt() int {
+	if n, ok := core.AttemptFromHeader(m); ok {
+		return n
+	}
+	if md, err := m.msg.Metadata(); err == nil && md.NumDelivered > 0 {
+		return int(md.NumDelivered)
+	}
+	return 1
+}
+
+// StreamSequence implements core.SequenceReader.
+func (m *message) StreamSequence() (string, uint64, bool) {
+	md, err := m.msg.Metadata()
+	if err != nil {
+		return "", 0, false
+	}
+	return md.Stream, md.Sequence.Stream, true
 }
 
 // Ack acknowledges the message, marking it as processed.
@@ -34,10 +76,54 @@ func (m *message) Ack() error {
 }
 
 // Nack signals that the message could not be processed.
-// The server will redeliver it according to the consumer's MaxDeliver setting.
+// The server will redeliver it according to the consumer's MaxDeliver setting,
+// after the delay given by the backoff schedule if one is configured.
 func (m *message) Nack() error {
-	if err := m.msg.Nak(); err != nil {
+	var err error
+	if delay := m.nakDelay(); delay > 0 {
+		err = m.msg.NakWithDelay(delay)
+	} else {
+		err = m.msg.Nak()
+	}
+	if err != nil {
+		return fmt.Errorf("eventmux/nats: nack: %w", err)
+	}
+	return nil
+}
+
+// NackWithDelay implements core.DelayedNacker, asking the server to
+// redeliver the message after d instead of the backoff schedule's delay.
+func (m *message) NackWithDelay(d time.Duration) error {
+	if err := m.msg.NakWithDelay(d); err != nil {
 		return fmt.Errorf("eventmux/nats: nack: %w", err)
 	}
 	return nil
 }
+
+// nakDelay returns the backoff for the current delivery attempt.
+func (m *message) nakDelay() time.Duration {
+	if len(m.backoff) == 0 {
+		return 0
+	}
+	md, err := m.msg.Metadata()
+	if err != nil {
+		return m.backoff[0]
+	}
+	return backoffFor(m.backoff, md.NumDelivered)
+}
+
+// backoffFor returns the delay after the given delivery attempt (1-based).
+// Attempts past the end of the schedule reuse its last entry.
+func backoffFor(schedule []time.Duration, delivered uint64) time.Duration {
+	if len(schedule) == 0 {
+		return 0
+	}
+	i := int(delivered) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(schedule) {
+		i = len(schedule) - 1
+	}
+	return schedule[i]
+}
@@ -2,8 +2,11 @@ package nats
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
@@ -20,6 +23,9 @@ func init() {
 		}
 		return New(cfg.Brokers[0], cfg.Group, opts...)
 	})
+	broker.RegisterTyped("nats", func(cfg Config) (core.Broker, error) {
+		return NewFromConfig(cfg)
+	})
 }
 
 // Broker implements core.Broker for NATS JetStream.
@@ -30,6 +36,8 @@ func init() {
 //   - Each Subscribe call creates (or updates) a stream and a durable consumer.
 //   - Manual ack via Ack(); Nack() triggers server-side redelivery.
 //   - Configurable stream retention, storage type, and consumer ack policy.
+//   - Settings an existing stream or consumer cannot take in place fail with
+//     a ConflictError naming them, unless WithRecreateOnConflict is set.
 //   - Graceful shutdown: context cancellation stops consumers, Close() drains
 //     the connection.
 type Broker struct {
@@ -38,9 +46,11 @@ type Broker struct {
 	group string
 	opts  options
 
-	mu     sync.Mutex
-	closed bool
-	subs   []jetstream.ConsumeContext
+	mu         sync.Mutex
+	closed     bool
+	subs       []jetstream.ConsumeContext
+	reconnects chan core.ReconnectEvent
+	attempts   int
 }
 
 // New creates a NATS JetStream Broker. url is a standard NATS URL (nats://host:port).
@@ -49,8 +59,18 @@ func New(url, group string, fns ...Option) (*Broker, error) {
 	for _, fn := range fns {
 		fn(&opts)
 	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		group:      group,
+		opts:       opts,
+		reconnects: make(chan core.ReconnectEvent, 16),
+	}
 
-	nc, err := nats.Connect(url)
+	connectOpts := append([]nats.Option{nats.ReconnectHandler(b.onReconnect)}, opts.connectOptions()...)
+	nc, err := nats.Connect(url, connectOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("eventmux/nats: connect to %q: %w", url, err)
 	}
@@ -61,12 +81,28 @@ func New(url, group string, fns ...Option) (*Broker, error) {
 		return nil, fmt.Errorf("eventmux/nats: init jetstream: %w", err)
 	}
 
-	return &Broker{
-		conn:  nc,
-		js:    js,
-		group: group,
-		opts:  opts,
-	}, nil
+	b.conn = nc
+	b.js = js
+	return b, nil
+}
+
+// Reconnects implements core.Reconnecter. Events are dropped if the channel
+// is full, so a slow reader never stalls the NATS client.
+func (b *Broker) Reconnects() <-chan core.ReconnectEvent {
+	return b.reconnects
+}
+
+func (b *Broker) onReconnect(*nats.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.attempts++
+	select {
+	case b.reconnects <- core.ReconnectEvent{Broker: "nats", Time: time.Now(), Attempt: b.attempts}:
+	default:
+	}
 }
 
 // Publish sends a message to the specified subject via JetStream.
@@ -82,6 +118,11 @@ func (b *Broker) Publish(ctx context.Context, topic string, msg core.Message) er
 	for k, v := range msg.Headers() {
 		headers.Set(k, v)
 	}
+	if b.opts.dedupID != nil {
+		if id := b.opts.dedupID(msg); id != "" {
+			headers.Set(jetstream.MsgIDHeader, id)
+		}
+	}
 
 	nm := &nats.Msg{
 		Subject: topic,
@@ -89,6 +130,9 @@ func (b *Broker) Publish(ctx context.Context, topic string, msg core.Message) er
 		Header:  headers,
 	}
 	if _, err := b.js.PublishMsg(ctx, nm); err != nil {
+		if errors.Is(err, nats.ErrConnectionClosed) {
+			return core.ErrBrokerClosed // closed while publishing
+		}
 		return fmt.Errorf("eventmux/nats: publish to %q: %w", topic, err)
 	}
 	return nil
@@ -104,8 +148,44 @@ func (b *Broker) Subscribe(ctx context.Context, topic string, handler core.Handl
 	}
 	b.mu.Unlock()
 
+	cons, consumerName, err := b.ensureConsumer(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	cc, err := cons.Consume(func(jsMsg jetstream.Msg) {
+		msg := &message{msg: jsMsg, backoff: b.opts.backoff}
+		if err := handler(ctx, msg); err != nil {
+			_ = msg.Nack()
+		}
+	}, b.consumeOpts()...)
+	if err != nil {
+		return fmt.Errorf("eventmux/nats: start consume on %q: %w", consumerName, err)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		// Close ran while the consumer was being set up and did not see it.
+		b.mu.Unlock()
+		cc.Stop()
+		return core.ErrBrokerClosed
+	}
+	b.subs = append(b.subs, cc)
+	b.mu.Unlock()
+
+	// Block until context is cancelled
+	<-ctx.Done()
+	cc.Stop()
+	return nil
+}
+
+// ensureConsumer creates or updates the stream for topic and its durable
+// consumer, returning the consumer and its name. The consumer is named
+// after the route's core.WithGroup if ctx carries one, else the broker's
+// group.
+func (b *Broker) ensureConsumer(ctx context.Context, topic string) (jetstream.Consumer, string, error) {
 	streamName := sanitizeStreamName(topic)
-	stream, err := b.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+	stream, err := b.ensureStream(ctx, jetstream.StreamConfig{
 		Name:      streamName,
 		Subjects:  []string{topic},
 		MaxMsgs:   b.opts.maxMsgs,
@@ -116,41 +196,80 @@ func (b *Broker) Subscribe(ctx context.Context, topic string, handler core.Handl
 		Storage:   b.opts.storage,
 	})
 	if err != nil {
-		return fmt.Errorf("eventmux/nats: create stream %q: %w", streamName, err)
+		return nil, "", err
 	}
 
 	consumerName := b.group
+	if so, _ := core.SubscribeOptionsFrom(ctx); so.Group != "" {
+		consumerName = so.Group // route override, see core.WithGroup
+	}
 	if consumerName == "" {
 		consumerName = "eventmux-" + streamName
 	}
 
-	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+	cons, err := b.ensureDurable(ctx, stream, jetstream.ConsumerConfig{
 		Durable:    consumerName,
 		AckPolicy:  jetstream.AckExplicitPolicy,
 		AckWait:    b.opts.ackWait,
 		MaxDeliver: b.opts.maxDeliver,
+		BackOff:    b.opts.backoff,
 	})
 	if err != nil {
-		return fmt.Errorf("eventmux/nats: create consumer %q: %w", consumerName, err)
+		return nil, "", err
 	}
+	return cons, consumerName, nil
+}
 
-	cc, err := cons.Consume(func(jsMsg jetstream.Msg) {
-		msg := &message{msg: jsMsg}
-		if err := handler(ctx, msg); err != nil {
-			_ = jsMsg.Nak()
+// Provision implements core.Provisioner by creating or updating the stream
+// and durable consumer for each topic, as Subscribe does.
+func (b *Broker) Provision(ctx context.Context, topics []string) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
+	b.mu.Unlock()
+
+	for _, topic := range topics {
+		if _, _, err := b.ensureConsumer(ctx, topic); err != nil {
+			return err
 		}
-	})
-	if err != nil {
-		return fmt.Errorf("eventmux/nats: start consume on %q: %w", consumerName, err)
 	}
+	return nil
+}
+
+// consumeOpts returns the pull options for Consume.
+func (b *Broker) consumeOpts() []jetstream.PullConsumeOpt {
+	var opts []jetstream.PullConsumeOpt
+	if b.opts.fetchMaxBytes > 0 {
+		opts = append(opts, jetstream.PullMaxBytes(b.opts.fetchMaxBytes))
+	}
+	return opts
+}
 
+// Group implements core.GroupReader. It is the name of the durable
+// consumers Subscribe creates, or "" if each is named after its stream.
+func (b *Broker) Group() string { return b.group }
+
+// Flush implements core.Flusher. It waits until the server has received
+// everything written to the connection, including publishes buffered while
+// reconnecting (see WithMaxReconnectBuffer).
+func (b *Broker) Flush(ctx context.Context) error {
 	b.mu.Lock()
-	b.subs = append(b.subs, cc)
+	closed := b.closed
 	b.mu.Unlock()
-
-	// Block until context is cancelled
-	<-ctx.Done()
-	cc.Stop()
+	if closed {
+		return core.ErrBrokerClosed
+	}
+	var err error
+	if _, ok := ctx.Deadline(); ok {
+		err = b.conn.FlushWithContext(ctx)
+	} else {
+		err = b.conn.Flush() // FlushWithContext requires a deadline
+	}
+	if err != nil {
+		return fmt.Errorf("eventmux/nats: flush: %w", err)
+	}
 	return nil
 }
 
@@ -167,6 +286,7 @@ func (b *Broker) Close() error {
 		s.Stop()
 	}
 	b.conn.Close()
+	close(b.reconnects)
 	return nil
 }
 
@@ -197,5 +317,39 @@ func optsFromConfig(cfg broker.Config) []Option {
 	if v, ok := cfg.Extra["replicas"].(int); ok {
 		opts = append(opts, WithReplicas(v))
 	}
+	if v, ok := cfg.Extra["fetch_max_bytes"].(int); ok {
+		opts = append(opts, WithFetchMaxBytes(v))
+	}
+	if v, ok := cfg.Extra["tls_insecure_skip_verify"].(bool); ok && v {
+		opts = append(opts, WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	}
+	if v, ok := cfg.Extra["tls_ca_file"].(string); ok {
+		opts = append(opts, WithRootCAs(v))
+	}
+	cert, _ := cfg.Extra["tls_cert_file"].(string)
+	key, _ := cfg.Extra["tls_key_file"].(string)
+	if cert != "" {
+		opts = append(opts, WithClientCert(cert, key))
+	}
+	if v, ok := cfg.Extra["credentials_file"].(string); ok {
+		opts = append(opts, WithUserCredentials(v))
+	}
+	if v, ok := cfg.Extra["token"].(string); ok {
+		opts = append(opts, WithToken(v))
+	}
+	if v, ok := cfg.Extra["recreate_on_conflict"].(bool); ok {
+		opts = append(opts, WithRecreateOnConflict(v))
+	}
+	if v, ok := cfg.Extra["max_reconnects"].(int); ok {
+		opts = append(opts, WithMaxReconnects(v))
+	}
+	if v, ok := cfg.Extra["reconnect_wait"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			opts = append(opts, WithReconnectWait(d))
+		}
+	}
+	if v, ok := cfg.Extra["reconnect_buffer_bytes"].(int); ok {
+		opts = append(opts, WithMaxReconnectBuffer(v))
+	}
 	return opts
 }
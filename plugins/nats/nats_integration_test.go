@@ -0,0 +1,246 @@
+//go:build integration
+
+package nats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// natsURL returns the server URL used by integration tests.
+func natsURL() string {
+	if url := os.Getenv("NATS_URL"); url != "" {
+		return url
+	}
+	return "nats://localhost:4222"
+}
+
+func TestIntegration_BackoffSchedule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	schedule := []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond}
+	b, err := New(natsURL(), "", WithBackoffSchedule(schedule), WithMaxDeliver(3), WithStorage(0))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	subject := "eventmux.backoff." + time.Now().Format("20060102150405")
+
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	done := make(chan struct{})
+	go b.Subscribe(ctx, subject, func(ctx context.Context, msg core.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) == 3 {
+			close(done)
+			return msg.Ack()
+		}
+		return errors.New("retry")
+	})
+	time.Sleep(500 * time.Millisecond)
+
+	if err := b.Publish(ctx, subject, &mock.Message{V: []byte("v")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for redeliveries")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, want := range schedule {
+		if gap := times[i+1].Sub(times[i]); gap < want {
+			t.Errorf("redelivery %d after %v, want at least %v", i+1, gap, want)
+		}
+	}
+}
+
+func TestIntegration_ReplayFrom(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	b, err := New(natsURL(), "")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	subject := "eventmux.replay." + time.Now().Format("20060102150405")
+	if _, err := b.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     sanitizeStreamName(subject),
+		Subjects: []string{subject},
+		Storage:  jetstream.MemoryStorage,
+	}); err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+
+	if err := b.Publish(ctx, subject, &mock.Message{V: []byte("old")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	since := time.Now()
+	for _, v := range []string{"new-1", "new-2"} {
+		if err := b.Publish(ctx, subject, &mock.Message{V: []byte(v)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	var got []string
+	err = b.ReplayFrom(ctx, subject, since, func(ctx context.Context, m core.Message) error {
+		got = append(got, string(m.Value()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+	if len(got) != 2 || got[0] != "new-1" || got[1] != "new-2" {
+		t.Errorf("replayed %v, want [new-1 new-2]", got)
+	}
+}
+
+func TestIntegration_FetchMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const (
+		payload  = 1024
+		limit    = 4 * payload
+		messages = 20
+	)
+	b, err := New(natsURL(), "", WithFetchMaxBytes(limit), WithAckWait(time.Minute), WithStorage(jetstream.MemoryStorage))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	subject := "eventmux.fetchbytes." + time.Now().Format("20060102150405")
+	release := make(chan struct{})
+	defer close(release)
+	go b.Subscribe(ctx, subject, func(ctx context.Context, msg core.Message) error {
+		<-release // hold the first message so nothing else is pulled
+		return msg.Ack()
+	})
+	time.Sleep(500 * time.Millisecond)
+
+	for i := 0; i < messages; i++ {
+		if err := b.Publish(ctx, subject, &mock.Message{V: make([]byte, payload)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	time.Sleep(time.Second)
+
+	stream := sanitizeStreamName(subject)
+	cons, err := b.js.Consumer(ctx, stream, "eventmux-"+stream)
+	if err != nil {
+		t.Fatalf("consumer: %v", err)
+	}
+	info, err := cons.Info(ctx)
+	if err != nil {
+		t.Fatalf("consumer info: %v", err)
+	}
+	// Allow one refill pull on top of the first, but nowhere near all.
+	if got := info.NumAckPending; got == 0 || got*payload > 2*limit {
+		t.Errorf("%d messages pulled (%d bytes), want at most %d bytes", got, got*payload, 2*limit)
+	}
+}
+
+func TestIntegration_Provision(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	b, err := New(natsURL(), "", WithStorage(jetstream.MemoryStorage))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	subject := "eventmux.provision." + time.Now().Format("20060102150405")
+	for i := range 2 { // the second run must be a no-op
+		if err := b.Provision(ctx, []string{subject}); err != nil {
+			t.Fatalf("provision run %d: %v", i+1, err)
+		}
+	}
+
+	stream, err := b.js.Stream(ctx, sanitizeStreamName(subject))
+	if err != nil {
+		t.Fatalf("stream not created: %v", err)
+	}
+	if subj := stream.CachedInfo().Config.Subjects; len(subj) != 1 || subj[0] != subject {
+		t.Errorf("stream subjects = %v, want [%s]", subj, subject)
+	}
+	if _, err := stream.Consumer(ctx, "eventmux-"+sanitizeStreamName(subject)); err != nil {
+		t.Errorf("consumer not created: %v", err)
+	}
+}
+
+func TestIntegration_DedupID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	b, err := New(natsURL(), "", WithStorage(jetstream.MemoryStorage),
+		WithDedupID(func(msg core.Message) string { return string(msg.Key()) }))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer b.Close()
+
+	subject := "eventmux.dedup." + time.Now().Format("20060102150405")
+	if err := b.Provision(ctx, []string{subject}); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.Publish(ctx, subject, &mock.Message{K: []byte("order-42"), V: []byte("v")}); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	stream, err := b.js.Stream(ctx, sanitizeStreamName(subject))
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("stored %d messages, want 1", info.State.Msgs)
+	}
+}
+
+func TestIntegration_Flush(t *testing.T) {
+	b, err := New(natsURL(), "")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.Flush(ctx); err != nil {
+		t.Errorf("Flush with deadline: %v", err)
+	}
+	if err := b.Flush(context.Background()); err != nil {
+		t.Errorf("Flush without deadline: %v", err)
+	}
+	b.Close()
+	if err := b.Flush(ctx); !errors.Is(err, core.ErrBrokerClosed) {
+		t.Errorf("Flush after Close = %v, want ErrBrokerClosed", err)
+	}
+}
@@ -0,0 +1,101 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/brokertest"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// newFakeBroker returns a Broker backed by fakeJetStream, without a
+// connection.
+func newFakeBroker(js *fakeJetStream) *Broker {
+	return &Broker{js: js, opts: defaults(), reconnects: make(chan core.ReconnectEvent, 1)}
+}
+
+// fakeMsg is a JetStream message carrying only headers.
+type fakeMsg struct {
+	jetstream.Msg
+	header nats.Header
+}
+
+func (m *fakeMsg) Headers() nats.Header { return m.header }
+
+// TestMessage_ConcurrentHeaders reads one message from several goroutines,
+// as EmitAsync, the best-effort publisher and Tap do; run it with -race.
+func TestMessage_ConcurrentHeaders(t *testing.T) {
+	m := &message{msg: &fakeMsg{header: nats.Header{"trace-id": {"abc", "def"}, "tenant": {"acme"}}}}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := m.Headers()["trace-id"]; got != "abc" {
+				t.Errorf("Headers()[trace-id] = %q, want the first value abc", got)
+			}
+			if v, _ := m.Header("tenant"); v != "acme" {
+				t.Errorf("Header(tenant) = %q", v)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBroker_CloseContract(t *testing.T) {
+	brokertest.RunClose(t, "orders", func(t *testing.T) core.Broker {
+		return newFakeBroker(&fakeJetStream{})
+	})
+}
+
+func TestPublish_ClosedWhilePublishing(t *testing.T) {
+	b := newFakeBroker(&fakeJetStream{publishErr: nats.ErrConnectionClosed})
+	if err := b.Publish(context.Background(), "orders", &mock.Message{}); !errors.Is(err, core.ErrBrokerClosed) {
+		t.Errorf("Publish = %v, want ErrBrokerClosed", err)
+	}
+}
+
+func TestSubscribe_ClosedDuringSetup(t *testing.T) {
+	js := &fakeJetStream{}
+	b := newFakeBroker(js)
+	WithStorage(jetstream.MemoryStorage)(&b.opts)
+	js.stream = &fakeStream{cfg: jetstream.StreamConfig{Storage: jetstream.MemoryStorage}, onStart: func() { b.Close() }}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := b.Subscribe(ctx, "orders", func(context.Context, core.Message) error { return nil })
+	if !errors.Is(err, core.ErrBrokerClosed) {
+		t.Errorf("Subscribe = %v, want ErrBrokerClosed", err)
+	}
+	if cc := js.stream.consumer; cc == nil || cc.cc == nil || !cc.cc.stopped {
+		t.Error("a consumer started after Close must be stopped")
+	}
+}
+
+func TestPublish_DedupID(t *testing.T) {
+	js := &fakeJetStream{}
+	b := &Broker{js: js, opts: defaults()}
+	WithDedupID(func(msg core.Message) string { return msg.Headers()["event-id"] })(&b.opts)
+
+	ctx := context.Background()
+	if err := b.Publish(ctx, "orders", &mock.Message{H: map[string]string{"event-id": "evt-1"}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := b.Publish(ctx, "orders", &mock.Message{}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if got := js.published[0].Header.Get(jetstream.MsgIDHeader); got != "evt-1" {
+		t.Errorf("%s = %q, want evt-1", jetstream.MsgIDHeader, got)
+	}
+	if _, ok := js.published[1].Header[jetstream.MsgIDHeader]; ok {
+		t.Error("an empty ID must not set the header")
+	}
+}
@@ -1,9 +1,14 @@
 package nats
 
 import (
+	"crypto/tls"
+	"fmt"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
 )
 
 // Option configures the NATS broker.
@@ -22,6 +27,25 @@ type options struct {
 	ackWait     time.Duration
 	maxDeliver  int
 	filterSubj  string
+	backoff     []time.Duration
+	fetchMaxBytes int
+
+	recreateOnConflict bool
+
+	// Producer
+	dedupID func(core.Message) string
+
+	// Connection
+	tlsConfig *tls.Config
+	rootCAs   []string
+	certFile  string
+	keyFile   string
+	credsFile string
+	token     string
+
+	maxReconnects int
+	reconnectWait time.Duration
+	reconnectBuf  int
 }
 
 func defaults() options {
@@ -37,6 +61,18 @@ func defaults() options {
 	}
 }
 
+// validate reports option combinations JetStream would reject.
+func (o options) validate() error {
+	if o.fetchMaxBytes < 0 {
+		return fmt.Errorf("eventmux/nats: fetch max bytes must not be negative, got %d", o.fetchMaxBytes)
+	}
+	if len(o.backoff) > 0 && o.maxDeliver > 0 && o.maxDeliver <= len(o.backoff) {
+		return fmt.Errorf("eventmux/nats: max deliver (%d) must be greater than backoff schedule length (%d)",
+			o.maxDeliver, len(o.backoff))
+	}
+	return nil
+}
+
 // WithMaxMessages sets the maximum number of messages per stream.
 func WithMaxMessages(n int64) Option {
 	return func(o *options) { o.maxMsgs = n }
@@ -76,3 +112,123 @@ func WithAckWait(d time.Duration) Option {
 func WithMaxDeliver(n int) Option {
 	return func(o *options) { o.maxDeliver = n }
 }
+
+// WithBackoffSchedule sets the consumer's redelivery backoff. The i-th entry
+// is the delay before delivery attempt i+2; the last entry repeats for any
+// remaining attempts. Nack honors the schedule instead of redelivering
+// immediately. MaxDeliver must be greater than the schedule length.
+func WithBackoffSchedule(schedule []time.Duration) Option {
+	return func(o *options) { o.backoff = schedule }
+}
+
+// WithFetchMaxBytes bounds the total payload bytes requested by each pull
+// the consumer issues (JetStream's max_bytes), so a burst of large messages
+// cannot be buffered in memory all at once. Zero leaves pulls bounded by
+// message count only.
+func WithFetchMaxBytes(n int) Option {
+	return func(o *options) { o.fetchMaxBytes = n }
+}
+
+// WithRecreateOnConflict deletes and recreates a stream or durable consumer
+// whose existing configuration conflicts with the requested one in a way
+// JetStream cannot update, such as a different storage type. This is
+// destructive: recreating a stream discards every message it holds, and
+// recreating a consumer resets its position. Off by default, in which case
+// the conflict is returned as a ConflictError.
+func WithRecreateOnConflict(enabled bool) Option {
+	return func(o *options) { o.recreateOnConflict = enabled }
+}
+
+// WithDedupID stamps each published message with the Nats-Msg-Id header
+// returned by fn, e.g. an event ID carried in the payload or headers.
+// JetStream drops a publish whose ID it has already stored within the
+// stream's duplicate window (two minutes by default), so retried publishes
+// are stored once. An empty ID leaves the message without the header.
+func WithDedupID(fn func(msg core.Message) string) Option {
+	return func(o *options) { o.dedupID = fn }
+}
+
+// WithTLSConfig connects over TLS using cfg. Combine it with WithRootCAs or
+// WithClientCert to load certificates from files.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) { o.tlsConfig = cfg }
+}
+
+// WithRootCAs connects over TLS, verifying the server against the PEM CA
+// bundles in files instead of the system pool.
+func WithRootCAs(files ...string) Option {
+	return func(o *options) { o.rootCAs = files }
+}
+
+// WithClientCert connects over TLS, presenting the certificate and key in
+// the given PEM files to servers that require mutual TLS.
+func WithClientCert(certFile, keyFile string) Option {
+	return func(o *options) { o.certFile, o.keyFile = certFile, keyFile }
+}
+
+// WithUserCredentials authenticates with the JWT and NKey seed in a NATS
+// .creds file.
+func WithUserCredentials(path string) Option {
+	return func(o *options) { o.credsFile = path }
+}
+
+// WithToken authenticates with a token.
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithMaxReconnects sets how many times the client tries to reconnect after
+// losing the server before giving up and closing the broker. Negative values
+// retry forever; zero keeps the client default of 60.
+func WithMaxReconnects(n int) Option {
+	return func(o *options) { o.maxReconnects = n }
+}
+
+// WithReconnectWait sets the delay between reconnect attempts to the same
+// server. Zero keeps the client default of two seconds.
+func WithReconnectWait(d time.Duration) Option {
+	return func(o *options) { o.reconnectWait = d }
+}
+
+// WithMaxReconnectBuffer sets how many bytes of outgoing data the client
+// buffers while reconnecting, so publishes during a brief outage are sent
+// once the connection is back instead of failing. A JetStream publish still
+// waits for the server's ack, so give its context a deadline longer than
+// the outages it should ride out. Negative values disable buffering, making
+// publishes fail as soon as the connection drops; zero keeps the client
+// default of 8MB.
+func WithMaxReconnectBuffer(bytes int) Option {
+	return func(o *options) { o.reconnectBuf = bytes }
+}
+
+// connectOptions returns the nats.Connect options for the configured TLS
+// and credentials. Certificate and credential files are read at connect
+// time, so a missing file surfaces as a connect error.
+func (o options) connectOptions() []nats.Option {
+	var opts []nats.Option
+	if o.tlsConfig != nil {
+		opts = append(opts, nats.Secure(o.tlsConfig))
+	}
+	if len(o.rootCAs) > 0 {
+		opts = append(opts, nats.RootCAs(o.rootCAs...))
+	}
+	if o.certFile != "" {
+		opts = append(opts, nats.ClientCert(o.certFile, o.keyFile))
+	}
+	if o.credsFile != "" {
+		opts = append(opts, nats.UserCredentials(o.credsFile))
+	}
+	if o.token != "" {
+		opts = append(opts, nats.Token(o.token))
+	}
+	if o.maxReconnects != 0 {
+		opts = append(opts, nats.MaxReconnects(o.maxReconnects))
+	}
+	if o.reconnectWait != 0 {
+		opts = append(opts, nats.ReconnectWait(o.reconnectWait))
+	}
+	if o.reconnectBuf != 0 {
+		opts = append(opts, nats.ReconnectBufSize(o.reconnectBuf))
+	}
+	return opts
+}
@@ -0,0 +1,188 @@
+package nats
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/miladsoleymani/eventmux/broker"
+)
+
+func TestOptions_ValidateBackoff(t *testing.T) {
+	schedule := []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+
+	tests := []struct {
+		name       string
+		maxDeliver int
+		wantErr    bool
+	}{
+		{"longer than schedule", 4, false},
+		{"equal to schedule", 3, true},
+		{"shorter than schedule", 2, true},
+		{"unlimited", -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaults()
+			WithBackoffSchedule(schedule)(&o)
+			WithMaxDeliver(tt.maxDeliver)(&o)
+			if err := o.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoffFor(t *testing.T) {
+	schedule := []time.Duration{time.Second, 5 * time.Second}
+
+	tests := []struct {
+		delivered uint64
+		want      time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 5 * time.Second},
+		{3, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoffFor(schedule, tt.delivered); got != tt.want {
+			t.Errorf("backoffFor(%d) = %v, want %v", tt.delivered, got, tt.want)
+		}
+	}
+	if got := backoffFor(nil, 1); got != 0 {
+		t.Errorf("backoffFor(nil) = %v, want 0", got)
+	}
+}
+
+func TestOptions_ValidateFetchMaxBytes(t *testing.T) {
+	o := defaults()
+	WithFetchMaxBytes(-1)(&o)
+	if err := o.validate(); err == nil {
+		t.Error("expected error for negative fetch max bytes")
+	}
+
+	o = defaults()
+	WithFetchMaxBytes(1 << 20)(&o)
+	if err := o.validate(); err != nil {
+		t.Errorf("validate() = %v", err)
+	}
+}
+
+// applyConnect applies o's connect options to a fresh nats.Options.
+func applyConnect(t *testing.T, o options) (nats.Options, error) {
+	t.Helper()
+	var no nats.Options
+	for _, fn := range o.connectOptions() {
+		if err := fn(&no); err != nil {
+			return no, err
+		}
+	}
+	return no, nil
+}
+
+func TestConnectOptions(t *testing.T) {
+	tlsCfg := &tls.Config{ServerName: "nats.internal"}
+	o := defaults()
+	WithTLSConfig(tlsCfg)(&o)
+	WithToken("s3cret")(&o)
+
+	no, err := applyConnect(t, o)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !no.Secure || no.TLSConfig != tlsCfg {
+		t.Errorf("Secure = %v, TLSConfig = %p, want %p", no.Secure, no.TLSConfig, tlsCfg)
+	}
+	if no.Token != "s3cret" {
+		t.Errorf("Token = %q", no.Token)
+	}
+}
+
+func TestConnectOptions_Reconnect(t *testing.T) {
+	o := defaults()
+	WithMaxReconnects(-1)(&o)
+	WithReconnectWait(500 * time.Millisecond)(&o)
+	WithMaxReconnectBuffer(32 << 20)(&o)
+
+	no, err := applyConnect(t, o)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if no.MaxReconnect != -1 || no.ReconnectWait != 500*time.Millisecond || no.ReconnectBufSize != 32<<20 {
+		t.Errorf("MaxReconnect/ReconnectWait/ReconnectBufSize = %d/%v/%d, want -1/500ms/%d",
+			no.MaxReconnect, no.ReconnectWait, no.ReconnectBufSize, 32<<20)
+	}
+}
+
+func TestOptsFromConfig_Reconnect(t *testing.T) {
+	o := defaults()
+	for _, fn := range optsFromConfig(broker.Config{Extra: map[string]any{
+		"max_reconnects":         10,
+		"reconnect_wait":         "250ms",
+		"reconnect_buffer_bytes": -1,
+	}}) {
+		fn(&o)
+	}
+	if o.maxReconnects != 10 || o.reconnectWait != 250*time.Millisecond || o.reconnectBuf != -1 {
+		t.Errorf("maxReconnects/reconnectWait/reconnectBuf = %d/%v/%d", o.maxReconnects, o.reconnectWait, o.reconnectBuf)
+	}
+}
+
+func TestConnectOptions_Default(t *testing.T) {
+	if opts := defaults().connectOptions(); len(opts) != 0 {
+		t.Errorf("expected no connect options by default, got %d", len(opts))
+	}
+}
+
+func TestConnectOptions_MissingFiles(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"CA bundle", WithRootCAs(missing)},
+		{"client cert", WithClientCert(missing, missing)},
+		{"credentials", WithUserCredentials(missing)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaults()
+			tt.opt(&o)
+			if _, err := applyConnect(t, o); err == nil {
+				t.Error("expected error for a missing file")
+			}
+		})
+	}
+}
+
+func TestOptsFromConfig_TLSAndCredentials(t *testing.T) {
+	o := defaults()
+	for _, fn := range optsFromConfig(broker.Config{Extra: map[string]any{
+		"tls_insecure_skip_verify": true,
+		"tls_ca_file":              "/etc/nats/ca.pem",
+		"tls_cert_file":            "/etc/nats/client.pem",
+		"tls_key_file":             "/etc/nats/client-key.pem",
+		"credentials_file":         "/etc/nats/app.creds",
+		"token":                    "s3cret",
+	}}) {
+		fn(&o)
+	}
+
+	if o.tlsConfig == nil || !o.tlsConfig.InsecureSkipVerify {
+		t.Error("tls_insecure_skip_verify not applied")
+	}
+	if len(o.rootCAs) != 1 || o.rootCAs[0] != "/etc/nats/ca.pem" {
+		t.Errorf("rootCAs = %v", o.rootCAs)
+	}
+	if o.certFile != "/etc/nats/client.pem" || o.keyFile != "/etc/nats/client-key.pem" {
+		t.Errorf("client cert = %q, %q", o.certFile, o.keyFile)
+	}
+	if o.credsFile != "/etc/nats/app.creds" || o.token != "s3cret" {
+		t.Errorf("creds = %q, token = %q", o.credsFile, o.token)
+	}
+}
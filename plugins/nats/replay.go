@@ -0,0 +1,72 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// replayFetchWait bounds each pull during replay so cancellation is noticed.
+const replayFetchWait = time.Second
+
+// ReplayFrom implements core.Replayer using a temporary pull consumer with
+// DeliverByStartTime on the stream that holds topic. The consumer does not
+// require acks and is deleted when the replay ends, leaving durable
+// consumers untouched. Replay returns once the messages pending at creation
+// have been handled.
+func (b *Broker) ReplayFrom(ctx context.Context, topic string, since time.Time, handler core.Handler) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
+	b.mu.Unlock()
+
+	streamName, err := b.js.StreamNameBySubject(ctx, topic)
+	if err != nil {
+		return fmt.Errorf("eventmux/nats: find stream for %q: %w", topic, err)
+	}
+	cons, err := b.js.CreateConsumer(ctx, streamName, jetstream.ConsumerConfig{
+		DeliverPolicy:     jetstream.DeliverByStartTimePolicy,
+		OptStartTime:      &since,
+		FilterSubject:     topic,
+		AckPolicy:         jetstream.AckNonePolicy,
+		InactiveThreshold: time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("eventmux/nats: create replay consumer on %q: %w", streamName, err)
+	}
+	name := cons.CachedInfo().Name
+	defer func() {
+		_ = b.js.DeleteConsumer(context.WithoutCancel(ctx), streamName, name)
+	}()
+
+	pending := cons.CachedInfo().NumPending
+	for pending > 0 {
+		jsMsg, err := cons.Next(jetstream.FetchMaxWait(replayFetchWait))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, nats.ErrTimeout) || errors.Is(err, jetstream.ErrNoMessages) {
+				continue
+			}
+			return fmt.Errorf("eventmux/nats: replay fetch on %q: %w", topic, err)
+		}
+		md, err := jsMsg.Metadata()
+		if err != nil {
+			return fmt.Errorf("eventmux/nats: replay metadata: %w", err)
+		}
+		if err := handler(ctx, &message{msg: jsMsg}); err != nil {
+			return fmt.Errorf("eventmux/nats: replay %q at sequence %d: %w", topic, md.Sequence.Stream, err)
+		}
+		pending = md.NumPending
+	}
+	return nil
+}
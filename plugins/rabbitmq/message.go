@@ -1,34 +1,146 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/miladsoleymani/eventmux/core"
 )
 
 // message adapts an amqp.Delivery to core.Message.
 type message struct {
 	delivery amqp.Delivery
 	requeue  bool
+
+	// Set for consumed messages so NackWithDelay can use a retry queue.
+	broker *Broker
+	queue  string
+	ctx    context.Context
+
+	headersOnce sync.Once
+	headers     map[string]string // built on the first Headers call
 }
 
-func (m *message) Key() []byte   { return []byte(m.delivery.RoutingKey) }
+func (m *message) Key() []byte   { return []byte(m.routingKey()) }
 func (m *message) Value() []byte { return m.delivery.Body }
+func (m *message) Topic() string { return m.routingKey() }
+
+// routingKey returns the key the message was originally published with. A
+// message returning from a retry queue was dead-lettered to its queue under
+// the queue's name, so the original travels in headerRoutingKey.
+func (m *message) routingKey() string {
+	if k, ok := m.delivery.Headers[headerRoutingKey].(string); ok && k != "" {
+		return k
+	}
+	return m.delivery.RoutingKey
+}
 
+// Headers returns the delivery headers as strings. The map is built once per
+// message and shared between calls, so callers must not modify it.
 func (m *message) Headers() map[string]string {
-	h := make(map[string]string, len(m.delivery.Headers))
-	for k, v := range m.delivery.Headers {
-		if s, ok := v.(string); ok {
-			h[k] = s
-		} else {
-			h[k] = fmt.Sprintf("%v", v)
+	m.headersOnce.Do(func() {
+		m.headers = make(map[string]string, len(m.delivery.Headers))
+		for k, v := range m.delivery.Headers {
+			m.headers[k] = headerString(v)
+		}
+	})
+	return m.headers
+}
+
+// Header looks up a single header without building the full map.
+func (m *message) Header(key string) (string, bool) {
+	v, ok := m.delivery.Headers[key]
+	if !ok {
+		return "", false
+	}
+	return headerString(v), true
+}
+
+// headerString renders an AMQP table value as a string.
+func headerString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Attempt implements core.AttemptReader. A republished HeaderAttempt wins;
+// otherwise the quorum queue delivery count is used, then the dead-letter
+// counts in x-death, falling back to the delivery's redelivered flag.
+func (m *message) Attempt() int {
+	if n, ok := core.AttemptFromHeader(m); ok {
+		return n
+	}
+	if n, ok := deliveryCount(m.delivery); ok {
+		return int(n) + 1
+	}
+	if deaths, ok := m.delivery.Headers["x-death"].([]any); ok {
+		total := int64(0)
+		for _, d := range deaths {
+			if t, ok := d.(amqp.Table); ok {
+				if c, ok := t["count"].(int64); ok {
+					total += c
+				}
+			}
+		}
+		if total > 0 {
+			return int(total) + 1
 		}
 	}
-	return h
+	if m.delivery.Redelivered {
+		return 2
+	}
+	return 1
+}
+
+// CountsDeliveries implements core.DeliveryCounter. Only quorum queues
+// count deliveries, in x-delivery-count; a requeued delivery from a classic
+// queue carries just the redelivered flag.
+func (m *message) CountsDeliveries() bool {
+	_, ok := deliveryCount(m.delivery)
+	return ok
+}
+
+// deliveryCount returns the number of earlier deliveries a quorum queue
+// recorded in x-delivery-count.
+func deliveryCount(d amqp.Delivery) (int64, bool) {
+	switch n := d.Headers["x-delivery-count"].(type) {
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
 }
 
-// Ack acknowledges the message, removing it from the queue.
+// StreamSequence implements core.SequenceReader with the stream's name and
+// the message's offset in it. It reports false for messages not consumed
+// from a stream.
+func (m *message) StreamSequence() (string, uint64, bool) {
+	off, ok := deliveryOffset(m.delivery)
+	if !ok || off < 0 {
+		return "", 0, false
+	}
+	return m.queue, uint64(off), true
+}
+
+// Ack acknowledges the message, removing it from the queue. For streams,
+// which keep their messages, the next offset is saved to the OffsetStore
+// first, if one is configured.
 func (m *message) Ack() error {
+	if m.broker != nil && m.broker.opts.offsetStore != nil {
+		if off, ok := deliveryOffset(m.delivery); ok {
+			if err := m.broker.opts.offsetStore.Save(m.ctx, m.queue, off+1); err != nil {
+				return fmt.Errorf("eventmux/rabbitmq: save offset for stream %q: %w", m.queue, err)
+			}
+		}
+	}
 	if err := m.delivery.Ack(false); err != nil {
 		return fmt.Errorf("eventmux/rabbitmq: ack: %w", err)
 	}
@@ -43,3 +155,13 @@ func (m *message) Nack() error {
 	}
 	return nil
 }
+
+// NackWithDelay implements core.DelayedNacker by moving the message to a
+// retry queue that returns it to its queue after d. Messages not consumed
+// through a Broker are nacked as usual.
+func (m *message) NackWithDelay(d time.Duration) error {
+	if m.broker == nil || d <= 0 {
+		return m.Nack()
+	}
+	return m.broker.retryLater(m.ctx, m, d)
+}
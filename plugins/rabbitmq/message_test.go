@@ -0,0 +1,154 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+func testMessage() *message {
+	return &message{delivery: amqp.Delivery{
+		Headers: amqp.Table{
+			"content-type": "application/json",
+			"x-attempt":    int32(3),
+		},
+	}}
+}
+
+func TestMessage_Headers(t *testing.T) {
+	h := testMessage().Headers()
+	if got := h["content-type"]; got != "application/json" {
+		t.Errorf("content-type = %q, want %q", got, "application/json")
+	}
+	if got := h["x-attempt"]; got != "3" {
+		t.Errorf("x-attempt = %q, want %q", got, "3")
+	}
+}
+
+func TestMessage_Header(t *testing.T) {
+	for _, built := range []bool{false, true} {
+		m := testMessage()
+		if built {
+			m.Headers()
+		}
+
+		if v, ok := m.Header("x-attempt"); !ok || v != "3" {
+			t.Errorf("built=%v: Header(x-attempt) = %q, %v; want %q, true", built, v, ok, "3")
+		}
+		if _, ok := m.Header("missing"); ok {
+			t.Errorf("built=%v: Header(missing) reported present", built)
+		}
+	}
+}
+
+func BenchmarkMessage_Header(b *testing.B) {
+	m := testMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Header("content-type")
+	}
+}
+
+// TestMessage_ConcurrentHeaders reads one message from several goroutines,
+// as EmitAsync, the best-effort publisher and Tap do; run it with -race.
+func TestMessage_ConcurrentHeaders(t *testing.T) {
+	m := testMessage()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := m.Headers()["x-attempt"]; got != "3" {
+				t.Errorf("Headers()[x-attempt] = %q, want 3", got)
+			}
+			if v, _ := m.Header("content-type"); v != "application/json" {
+				t.Errorf("Header(content-type) = %q", v)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMessage_Attempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     int
+	}{
+		{"first delivery", amqp.Delivery{}, 1},
+		{"redelivered", amqp.Delivery{Redelivered: true}, 2},
+		{"x-death", amqp.Delivery{Headers: amqp.Table{
+			"x-death": []any{amqp.Table{"count": int64(2)}, amqp.Table{"count": int64(1)}},
+		}}, 4},
+		{"quorum delivery count", amqp.Delivery{Redelivered: true, Headers: amqp.Table{
+			"x-delivery-count": int64(3),
+		}}, 4},
+		{"header wins", amqp.Delivery{Headers: amqp.Table{
+			"x-eventmux-attempt": "5",
+			"x-death":            []any{amqp.Table{"count": int64(2)}},
+		}}, 5},
+	}
+	for _, tt := range tests {
+		m := &message{delivery: tt.delivery}
+		if got := m.Attempt(); got != tt.want {
+			t.Errorf("%s: Attempt = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_CountsDeliveries(t *testing.T) {
+	classic := &message{delivery: amqp.Delivery{Redelivered: true}}
+	quorum := &message{delivery: amqp.Delivery{Redelivered: true, Headers: amqp.Table{"x-delivery-count": int64(1)}}}
+	if classic.CountsDeliveries() {
+		t.Error("a requeued classic delivery has no delivery count")
+	}
+	if !quorum.CountsDeliveries() {
+		t.Error("a quorum delivery with x-delivery-count is counted")
+	}
+}
+
+// TestMaxRedeliveries_ClassicRequeue redelivers a poison message the way a
+// classic queue with requeueOnNack does: every delivery after the first only
+// has the redelivered flag, so the Router must count attempts itself.
+func TestMaxRedeliveries_ClassicRequeue(t *testing.T) {
+	mb := mock.NewBroker()
+	r := core.New(mb, core.WithMaxRedeliveries(2, "orders.dlq"))
+	r.Handle("orders", func(context.Context, core.Message) error { return errors.New("poison") })
+
+	headers := amqp.Table{}
+	deliveries := 0
+	for ; deliveries < 10 && len(mb.PublishedTo("orders.dlq")) == 0; deliveries++ {
+		ack := &fakeAcknowledger{}
+		msg := &message{delivery: amqp.Delivery{
+			Acknowledger: ack,
+			RoutingKey:   "orders",
+			Redelivered:  deliveries > 0,
+			Headers:      headers,
+		}}
+		if err := r.Dispatch(context.Background(), "orders", msg); err != nil {
+			t.Fatalf("delivery %d: %v", deliveries+1, err)
+		}
+		if !ack.acked {
+			t.Fatalf("delivery %d was not acked", deliveries+1)
+		}
+		if pubs := mb.PublishedTo("orders"); len(pubs) > deliveries {
+			headers = amqp.Table{}
+			for k, v := range pubs[deliveries].Headers {
+				headers[k] = v
+			}
+		}
+	}
+
+	if deliveries != 3 {
+		t.Errorf("dead-lettered after %d deliveries, want 3 (1 + 2 redeliveries)", deliveries)
+	}
+	if len(mb.PublishedTo("orders.dlq")) != 1 {
+		t.Error("poison message was never dead-lettered")
+	}
+}
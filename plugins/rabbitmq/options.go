@@ -1,5 +1,11 @@
 package rabbitmq
 
+import (
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
 // Option configures the RabbitMQ broker.
 type Option func(*options)
 
@@ -13,10 +19,17 @@ type options struct {
 	durable    bool
 	autoDelete bool
 	exclusive  bool
+	queueArgs  amqp.Table
 
 	// Consumer settings
 	prefetchCount int
 	requeueOnNack bool
+	consumerTag   string
+
+	// Stream settings
+	stream       bool
+	streamOffset StreamOffset
+	offsetStore  OffsetStore
 }
 
 func defaults() options {
@@ -29,6 +42,17 @@ func defaults() options {
 	}
 }
 
+// validate reports option combinations RabbitMQ would reject.
+func (o options) validate() error {
+	if o.stream && (!o.durable || o.autoDelete || o.exclusive) {
+		return fmt.Errorf("eventmux/rabbitmq: stream queues must be durable, non-exclusive and not auto-delete")
+	}
+	if o.offsetStore != nil && !o.stream {
+		return fmt.Errorf("eventmux/rabbitmq: an offset store requires WithStream")
+	}
+	return nil
+}
+
 // WithExchange sets the exchange name and type.
 func WithExchange(name, kind string) Option {
 	return func(o *options) {
@@ -61,3 +85,34 @@ func WithRequeueOnNack(requeue bool) Option {
 func WithAutoDelete(d bool) Option {
 	return func(o *options) { o.autoDelete = d }
 }
+
+// WithQueueArgs sets the x-arguments passed to QueueDeclare, e.g.
+// x-queue-type, x-max-length, x-message-ttl or x-dead-letter-exchange.
+func WithQueueArgs(args amqp.Table) Option {
+	return func(o *options) { o.queueArgs = args }
+}
+
+// WithConsumerTag sets the consumer tag prefix. Each subscription uses
+// "<tag>-<queue>", making consumers identifiable in the management UI. The
+// default prefix is "eventmux-<hostname>-<pid>".
+func WithConsumerTag(tag string) Option {
+	return func(o *options) { o.consumerTag = tag }
+}
+
+// WithStream declares every queue as a RabbitMQ stream (x-queue-type=stream)
+// and starts consumers at start. Streams are append-only logs: consuming does
+// not remove messages, so they can be replayed from any offset. Nacked
+// messages are not redelivered; use a dead-letter topic or NackWithDelay to
+// retry them.
+func WithStream(start StreamOffset) Option {
+	return func(o *options) {
+		o.stream = true
+		o.streamOffset = start
+	}
+}
+
+// WithOffsetStore saves each acked stream message's offset to store and
+// resumes consumers from it. It requires WithStream.
+func WithOffsetStore(store OffsetStore) Option {
+	return func(o *options) { o.offsetStore = store }
+}
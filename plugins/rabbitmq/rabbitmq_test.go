@@ -0,0 +1,258 @@
+package rabbitmq
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/miladsoleymani/eventmux/broker"
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// fakeChannel records calls made by Broker.
+type fakeChannel struct {
+	mu          sync.Mutex
+	declareArgs amqp.Table
+	consumerTag string
+	consumeArgs amqp.Table
+	cancelled   string
+	deliveries  chan amqp.Delivery
+	declared    string
+	published   []fakePublish
+	qos         int            // current per-consumer prefetch
+	prefetch    map[string]int // consumer tag -> prefetch when it started
+}
+
+type fakePublish struct {
+	exchange, key string
+	msg           amqp.Publishing
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{deliveries: make(chan amqp.Delivery)}
+}
+
+func (c *fakeChannel) PublishWithContext(_ context.Context, exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, fakePublish{exchange: exchange, key: key, msg: msg})
+	return nil
+}
+
+func (c *fakeChannel) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.declareArgs = args
+	c.declared = name
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *fakeChannel) QueueBind(string, string, string, bool, amqp.Table) error { return nil }
+
+func (c *fakeChannel) Consume(_, consumer string, _, _, _, _ bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumerTag = consumer
+	c.consumeArgs = args
+	if c.prefetch == nil {
+		c.prefetch = make(map[string]int)
+	}
+	c.prefetch[consumer] = c.qos
+	return c.deliveries, nil
+}
+
+func (c *fakeChannel) Qos(prefetchCount, _ int, _ bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.qos = prefetchCount
+	return nil
+}
+
+func (c *fakeChannel) Cancel(consumer string, _ bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelled = consumer
+	return nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+// subscribeBriefly runs Subscribe until it has declared its queue, then
+// cancels it.
+func subscribeBriefly(t *testing.T, b *Broker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := b.Subscribe(ctx, "orders", func(context.Context, core.Message) error { return nil })
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+}
+
+func TestSubscribe_QueueArgs(t *testing.T) {
+	ch := newFakeChannel()
+	opts := defaults()
+	WithQueueArgs(amqp.Table{"x-queue-type": "quorum", "x-dead-letter-exchange": "dlx"})(&opts)
+	b := &Broker{ch: ch, opts: opts}
+
+	subscribeBriefly(t, b)
+
+	if ch.declareArgs["x-queue-type"] != "quorum" || ch.declareArgs["x-dead-letter-exchange"] != "dlx" {
+		t.Errorf("QueueDeclare args = %v", ch.declareArgs)
+	}
+}
+
+func TestOptsFromConfig_QueueArgs(t *testing.T) {
+	cfg := broker.Config{Extra: map[string]any{
+		"queue_args":  map[string]any{"x-overflow": "reject-publish", "x-queue-type": "classic"},
+		"queue_type":  "quorum",
+		"max_length":  1000,
+		"message_ttl": 60000,
+	}}
+	opts := defaults()
+	for _, fn := range optsFromConfig(cfg) {
+		fn(&opts)
+	}
+
+	want := amqp.Table{
+		"x-overflow":    "reject-publish",
+		"x-queue-type":  "quorum",
+		"x-max-length":  int64(1000),
+		"x-message-ttl": int64(60000),
+	}
+	if len(opts.queueArgs) != len(want) {
+		t.Fatalf("queueArgs = %v, want %v", opts.queueArgs, want)
+	}
+	for k, v := range want {
+		if opts.queueArgs[k] != v {
+			t.Errorf("queueArgs[%q] = %v, want %v", k, opts.queueArgs[k], v)
+		}
+	}
+}
+
+func TestSubscribe_ConsumerTag(t *testing.T) {
+	ch := newFakeChannel()
+	opts := defaults()
+	WithConsumerTag("billing")(&opts)
+	b := &Broker{ch: ch, opts: opts}
+
+	subscribeBriefly(t, b)
+
+	if ch.consumerTag != "billing-orders" {
+		t.Errorf("consumer tag = %q, want %q", ch.consumerTag, "billing-orders")
+	}
+	if ch.cancelled != ch.consumerTag {
+		t.Errorf("cancelled %q on shutdown, want %q", ch.cancelled, ch.consumerTag)
+	}
+}
+
+func TestConsumerTag_Default(t *testing.T) {
+	b := &Broker{opts: defaults()}
+	tag := b.consumerTag("orders")
+	if !strings.HasPrefix(tag, "eventmux-") || !strings.HasSuffix(tag, "-orders") {
+		t.Errorf("default tag = %q, want eventmux-<host>-<pid>-orders", tag)
+	}
+}
+
+// fakeAcknowledger records how a delivery was settled.
+type fakeAcknowledger struct {
+	acked, nacked bool
+}
+
+func (a *fakeAcknowledger) Ack(uint64, bool) error        { a.acked = true; return nil }
+func (a *fakeAcknowledger) Nack(uint64, bool, bool) error { a.nacked = true; return nil }
+func (a *fakeAcknowledger) Reject(uint64, bool) error     { a.nacked = true; return nil }
+
+func TestMessage_NackWithDelay(t *testing.T) {
+	ch := newFakeChannel()
+	b := &Broker{ch: ch, opts: defaults()}
+	ack := &fakeAcknowledger{}
+	m := &message{
+		delivery: amqp.Delivery{
+			Acknowledger:  ack,
+			RoutingKey:    "orders.created",
+			ContentType:   "application/json",
+			MessageId:     "m-1",
+			CorrelationId: "c-1",
+			Timestamp:     time.Unix(1700000000, 0),
+			Expiration:    "60000",
+			Body:          []byte("payload"),
+			Headers:       amqp.Table{"trace": "t1", core.HeaderAttempt: "2"},
+		},
+		broker: b,
+		queue:  "orders",
+		ctx:    context.Background(),
+	}
+
+	if err := core.NackWithDelay(m, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if ch.declared != "orders.retry.1500ms" {
+		t.Errorf("declared %q, want orders.retry.1500ms", ch.declared)
+	}
+	if ch.declareArgs["x-message-ttl"] != int64(1500) || ch.declareArgs["x-dead-letter-routing-key"] != "orders" {
+		t.Errorf("retry queue args = %v", ch.declareArgs)
+	}
+	if len(ch.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(ch.published))
+	}
+	p := ch.published[0]
+	if p.exchange != "" || p.key != "orders.retry.1500ms" || string(p.msg.Body) != "payload" {
+		t.Errorf("published %+v", p)
+	}
+	if p.msg.Headers["trace"] != "t1" || p.msg.Headers[core.HeaderAttempt] != "3" {
+		t.Errorf("headers = %v, want trace kept and attempt 3", p.msg.Headers)
+	}
+	if p.msg.ContentType != "application/json" || p.msg.MessageId != "m-1" || p.msg.CorrelationId != "c-1" ||
+		!p.msg.Timestamp.Equal(time.Unix(1700000000, 0)) || p.msg.Expiration != "" {
+		t.Errorf("properties = %+v, want the delivery's without its expiration", p.msg)
+	}
+	if !ack.acked || ack.nacked {
+		t.Errorf("original acked=%v nacked=%v, want acked", ack.acked, ack.nacked)
+	}
+
+	// The retry queue dead-letters the copy to "orders" under the queue's
+	// name; the original routing key must survive.
+	back := &message{delivery: amqp.Delivery{RoutingKey: "orders", Headers: p.msg.Headers}}
+	if back.Topic() != "orders.created" || string(back.Key()) != "orders.created" {
+		t.Errorf("returned copy Topic/Key = %q/%q, want orders.created", back.Topic(), back.Key())
+	}
+}
+
+func TestSubscribe_RoutePrefetch(t *testing.T) {
+	ch := newFakeChannel()
+	opts := defaults()
+	WithConsumerTag("billing")(&opts)
+	ch.qos = opts.prefetchCount // set by New
+	b := &Broker{ch: ch, opts: opts}
+
+	run := func(ctx context.Context, queue string) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if err := b.Subscribe(ctx, queue, func(context.Context, core.Message) error { return nil }); err != nil {
+			t.Fatalf("subscribe %s: %v", queue, err)
+		}
+	}
+	run(core.WithSubscribeOptions(context.Background(), core.SubscribeOptions{Prefetch: 200}), "events")
+	run(context.Background(), "orders")
+	run(core.WithSubscribeOptions(context.Background(), core.SubscribeOptions{Prefetch: 1}), "reports")
+
+	want := map[string]int{"billing-events": 200, "billing-orders": 10, "billing-reports": 1}
+	for tag, n := range want {
+		if got := ch.prefetch[tag]; got != n {
+			t.Errorf("prefetch for %s = %d, want %d", tag, got, n)
+		}
+	}
+	if ch.qos != opts.prefetchCount {
+		t.Errorf("channel prefetch left at %d, want default %d", ch.qos, opts.prefetchCount)
+	}
+}
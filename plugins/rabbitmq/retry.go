@@ -0,0 +1,74 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+)
+
+// headerRoutingKey carries a retried message's original routing key, which
+// dead-lettering back from the retry queue replaces.
+const headerRoutingKey = "x-eventmux-routing-key"
+
+// retryQueueName returns the queue that holds messages for queue until d
+// has passed. There is one retry queue per queue and delay, because
+// RabbitMQ only expires messages at the head of a queue.
+func retryQueueName(queue string, d time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queue, d.Milliseconds())
+}
+
+// retryLater republishes m to a retry queue whose messages expire after d
+// and are dead-lettered back to m's queue through the default exchange,
+// then acks the original. The copy keeps the delivery's properties and
+// routing key, and carries the next core.HeaderAttempt.
+func (b *Broker) retryLater(ctx context.Context, m *message, d time.Duration) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return core.ErrBrokerClosed
+	}
+	ch := b.ch
+	b.mu.Unlock()
+
+	name := retryQueueName(m.queue, d)
+	if _, err := ch.QueueDeclare(name, b.opts.durable, false, false, false, amqp.Table{
+		"x-message-ttl":             d.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": m.queue,
+	}); err != nil {
+		return fmt.Errorf("eventmux/rabbitmq: declare retry queue %q: %w", name, err)
+	}
+
+	headers := amqp.Table{}
+	for k, v := range m.delivery.Headers {
+		headers[k] = v
+	}
+	headers[core.HeaderAttempt] = strconv.Itoa(m.Attempt() + 1)
+	headers[headerRoutingKey] = m.routingKey()
+	orig := m.delivery
+	if err := ch.PublishWithContext(ctx, "", name, false, false, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     orig.ContentType,
+		ContentEncoding: orig.ContentEncoding,
+		DeliveryMode:    orig.DeliveryMode,
+		Priority:        orig.Priority,
+		CorrelationId:   orig.CorrelationId,
+		ReplyTo:         orig.ReplyTo,
+		MessageId:       orig.MessageId,
+		Timestamp:       orig.Timestamp,
+		Type:            orig.Type,
+		AppId:           orig.AppId,
+		Body:            orig.Body,
+		// Expiration is left out so the message does not expire while it
+		// waits, and UserId because the broker checks it against the
+		// connection's user.
+	}); err != nil {
+		return fmt.Errorf("eventmux/rabbitmq: publish to retry queue %q: %w", name, err)
+	}
+	return m.Ack()
+}
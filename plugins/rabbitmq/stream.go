@@ -0,0 +1,79 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// StreamOffset selects where a consumer starts reading a RabbitMQ stream.
+// Streams keep messages after they are consumed, so any consumer can replay
+// them from an earlier point.
+type StreamOffset struct {
+	arg any // x-stream-offset value; nil leaves the server default ("next")
+}
+
+var (
+	// OffsetFirst starts at the first message still held by the stream.
+	OffsetFirst = StreamOffset{arg: "first"}
+	// OffsetLast starts at the last chunk of messages written to the stream.
+	OffsetLast = StreamOffset{arg: "last"}
+	// OffsetNext starts with the next message published. It is the default.
+	OffsetNext = StreamOffset{arg: "next"}
+)
+
+// OffsetAt starts at the message with offset n.
+func OffsetAt(n int64) StreamOffset { return StreamOffset{arg: n} }
+
+// OffsetAtTime starts at the first chunk of messages published at or after
+// t. Timestamps have one-second precision.
+func OffsetAtTime(t time.Time) StreamOffset { return StreamOffset{arg: t} }
+
+// OffsetStore tracks how far each stream has been consumed, so a restarted
+// consumer resumes where it left off instead of at the WithStream offset.
+// AMQP consumers cannot store offsets on the server, so Ack saves them here;
+// keep them in the database the handler writes to for effectively-once
+// processing.
+type OffsetStore interface {
+	// Load returns the offset of the next message to read from stream. It
+	// reports false if nothing is stored, in which case consuming starts at
+	// the WithStream offset.
+	Load(ctx context.Context, stream string) (next int64, ok bool, err error)
+
+	// Save records next as the offset to resume stream from.
+	Save(ctx context.Context, stream string, next int64) error
+}
+
+// streamOffsetHeader is the header RabbitMQ sets on stream deliveries, and
+// the consumer argument that selects the starting offset.
+const streamOffsetHeader = "x-stream-offset"
+
+// consumeArgs returns the Consume arguments for queue: for streams, the
+// offset to start from, taken from the OffsetStore when it holds one.
+func (b *Broker) consumeArgs(ctx context.Context, queue string) (amqp.Table, error) {
+	if !b.opts.stream {
+		return nil, nil
+	}
+	start := b.opts.streamOffset.arg
+	if b.opts.offsetStore != nil {
+		next, ok, err := b.opts.offsetStore.Load(ctx, queue)
+		if err != nil {
+			return nil, fmt.Errorf("eventmux/rabbitmq: load offset for stream %q: %w", queue, err)
+		}
+		if ok {
+			start = next
+		}
+	}
+	if start == nil {
+		return nil, nil
+	}
+	return amqp.Table{streamOffsetHeader: start}, nil
+}
+
+// deliveryOffset returns the stream offset RabbitMQ stamped on d.
+func deliveryOffset(d amqp.Delivery) (int64, bool) {
+	off, ok := d.Headers[streamOffsetHeader].(int64)
+	return off, ok
+}
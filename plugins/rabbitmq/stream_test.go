@@ -0,0 +1,200 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/core/middleware"
+)
+
+// memOffsetStore is an in-memory OffsetStore.
+type memOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]int64
+	saveErr error
+}
+
+func (s *memOffsetStore) Load(_ context.Context, stream string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.offsets[stream]
+	return next, ok, nil
+}
+
+func (s *memOffsetStore) Save(_ context.Context, stream string, next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	if s.offsets == nil {
+		s.offsets = make(map[string]int64)
+	}
+	s.offsets[stream] = next
+	return nil
+}
+
+func newStreamBroker(ch *fakeChannel, fns ...Option) *Broker {
+	opts := defaults()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	return &Broker{ch: ch, opts: opts}
+}
+
+func TestSubscribe_Stream(t *testing.T) {
+	ch := newFakeChannel()
+	b := newStreamBroker(ch, WithQueueArgs(amqp.Table{"x-max-age": "7D"}), WithStream(OffsetFirst))
+
+	subscribeBriefly(t, b)
+
+	if ch.declareArgs["x-queue-type"] != "stream" || ch.declareArgs["x-max-age"] != "7D" {
+		t.Errorf("QueueDeclare args = %v, want stream type and x-max-age kept", ch.declareArgs)
+	}
+	if ch.consumeArgs["x-stream-offset"] != "first" {
+		t.Errorf("Consume args = %v, want x-stream-offset first", ch.consumeArgs)
+	}
+	if _, ok := b.opts.queueArgs["x-queue-type"]; ok {
+		t.Error("WithQueueArgs table should not be modified")
+	}
+}
+
+func TestSubscribe_StreamOffsets(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		start StreamOffset
+		want  any
+	}{
+		{"last", OffsetLast, "last"},
+		{"next", OffsetNext, "next"},
+		{"offset", OffsetAt(42), int64(42)},
+		{"time", OffsetAtTime(at), at},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := newFakeChannel()
+			subscribeBriefly(t, newStreamBroker(ch, WithStream(tc.start)))
+			if got := ch.consumeArgs["x-stream-offset"]; got != tc.want {
+				t.Errorf("x-stream-offset = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	ch := newFakeChannel()
+	subscribeBriefly(t, newStreamBroker(ch, WithStream(StreamOffset{})))
+	if ch.consumeArgs != nil {
+		t.Errorf("zero StreamOffset should leave the server default, got %v", ch.consumeArgs)
+	}
+}
+
+func TestSubscribe_StreamResumesFromStore(t *testing.T) {
+	store := &memOffsetStore{offsets: map[string]int64{"orders": 17}}
+	ch := newFakeChannel()
+	subscribeBriefly(t, newStreamBroker(ch, WithStream(OffsetFirst), WithOffsetStore(store)))
+
+	if got := ch.consumeArgs["x-stream-offset"]; got != int64(17) {
+		t.Errorf("x-stream-offset = %v, want the stored 17", got)
+	}
+}
+
+func TestSubscribe_StreamAckSavesOffset(t *testing.T) {
+	store := &memOffsetStore{}
+	ch := newFakeChannel()
+	b := newStreamBroker(ch, WithStream(OffsetFirst), WithOffsetStore(store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	handled := make(chan core.Message, 1)
+	go func() {
+		done <- b.Subscribe(ctx, "orders", func(_ context.Context, msg core.Message) error {
+			handled <- msg
+			return msg.Ack()
+		})
+	}()
+
+	ack := &fakeAcknowledger{}
+	ch.deliveries <- amqp.Delivery{Acknowledger: ack, Headers: amqp.Table{"x-stream-offset": int64(7)}}
+	msg := <-handled
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if stream, seq, ok := msg.(core.SequenceReader).StreamSequence(); !ok || stream != "orders" || seq != 7 {
+		t.Errorf("StreamSequence() = %q, %d, %v, want orders, 7", stream, seq, ok)
+	}
+	if !ack.acked {
+		t.Error("delivery should be acked")
+	}
+	if next, ok, _ := store.Load(context.Background(), "orders"); !ok || next != 8 {
+		t.Errorf("stored offset = %d, %v, want 8", next, ok)
+	}
+}
+
+func TestMessage_StreamSequence(t *testing.T) {
+	stream := &message{queue: "orders", delivery: amqp.Delivery{Headers: amqp.Table{"x-stream-offset": int64(42)}}}
+	if got := middleware.OffsetDedupKey(stream); got != "seq:orders/42" {
+		t.Errorf("OffsetDedupKey = %q, want seq:orders/42", got)
+	}
+	classic := &message{queue: "orders", delivery: amqp.Delivery{Body: []byte("v")}}
+	if _, _, ok := classic.StreamSequence(); ok {
+		t.Error("classic queue message should report no stream sequence")
+	}
+}
+
+func TestMessage_StreamAckSaveError(t *testing.T) {
+	errDown := errors.New("db down")
+	b := newStreamBroker(newFakeChannel(), WithStream(OffsetFirst), WithOffsetStore(&memOffsetStore{saveErr: errDown}))
+	ack := &fakeAcknowledger{}
+	m := &message{
+		delivery: amqp.Delivery{Acknowledger: ack, Headers: amqp.Table{"x-stream-offset": int64(3)}},
+		broker:   b,
+		queue:    "orders",
+		ctx:      context.Background(),
+	}
+
+	if err := m.Ack(); !errors.Is(err, errDown) {
+		t.Fatalf("Ack = %v, want the store error", err)
+	}
+	if ack.acked {
+		t.Error("delivery should not be acked when the offset was not saved")
+	}
+}
+
+func TestMessage_StreamNackDoesNotRequeue(t *testing.T) {
+	b := newStreamBroker(newFakeChannel(), WithStream(OffsetFirst))
+	if b.requeue() {
+		t.Error("stream consumers cannot requeue")
+	}
+}
+
+func TestOptions_StreamValidate(t *testing.T) {
+	cases := map[string][]Option{
+		"not durable": {WithStream(OffsetFirst), WithDurable(false)},
+		"auto-delete": {WithStream(OffsetFirst), WithAutoDelete(true)},
+		"no stream":   {WithOffsetStore(&memOffsetStore{})},
+	}
+	for name, fns := range cases {
+		opts := defaults()
+		for _, fn := range fns {
+			fn(&opts)
+		}
+		if err := opts.validate(); err == nil {
+			t.Errorf("%s: validate should fail", name)
+		}
+	}
+
+	opts := defaults()
+	WithStream(OffsetAt(5))(&opts)
+	WithOffsetStore(&memOffsetStore{})(&opts)
+	if err := opts.validate(); err != nil {
+		t.Errorf("stream with store: %v", err)
+	}
+}
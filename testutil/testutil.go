@@ -0,0 +1,216 @@
+// Package testutil helps test handlers and middleware, including your own,
+// without a broker. A Harness runs messages through a real Router backed by
+// an in-memory broker, so Results are settled exactly as in production:
+// AckResult acks, NackResult nacks and DLQResult republishes to the
+// dead-letter topic. Order records which middleware ran, and in what order,
+// to check ordering and short-circuiting.
+package testutil
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/internal/mock"
+)
+
+// Message is a core.Message that records how it was settled. It is safe for
+// concurrent use, so middleware may settle it from another goroutine.
+type Message struct {
+	key     []byte
+	value   []byte
+	headers map[string]string
+	topic   string
+
+	mu        sync.Mutex
+	acks      int
+	nacks     int
+	nackDelay time.Duration
+}
+
+// NewMessage returns a message with the given payload and headers. The
+// headers map is copied.
+func NewMessage(value []byte, headers map[string]string) *Message {
+	return &Message{value: value, headers: maps.Clone(headers)}
+}
+
+// WithKey sets the message key and returns m.
+func (m *Message) WithKey(key []byte) *Message {
+	m.key = key
+	return m
+}
+
+func (m *Message) Key() []byte                { return m.key }
+func (m *Message) Value() []byte              { return m.value }
+func (m *Message) Headers() map[string]string { return m.headers }
+
+// Topic returns the topic the message was last run on.
+func (m *Message) Topic() string { return m.topic }
+
+func (m *Message) Ack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acks++
+	return nil
+}
+
+func (m *Message) Nack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nacks++
+	return nil
+}
+
+// NackWithDelay implements core.DelayedNacker.
+func (m *Message) NackWithDelay(d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nacks++
+	m.nackDelay = d
+	return nil
+}
+
+// Acked reports whether the message was acked.
+func (m *Message) Acked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acks > 0
+}
+
+// Nacked reports whether the message was nacked.
+func (m *Message) Nacked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nacks > 0
+}
+
+// Settled reports whether the message was acked or nacked.
+func (m *Message) Settled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acks+m.nacks > 0
+}
+
+// NackDelay returns the delay passed to NackWithDelay, if any.
+func (m *Message) NackDelay() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nackDelay
+}
+
+// Published is a message published while a Harness ran. Key, Value and
+// Headers are copies taken at publish time.
+type Published struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Harness runs a handler behind middleware on a Router whose broker is kept
+// in memory.
+type Harness struct {
+	router *core.Router
+	broker *mock.Broker
+}
+
+// New returns a Harness that runs h for every topic. opts configure the
+// Router, e.g. core.WithDeadLetterTopic for DLQResult.
+func New(h core.Handler, opts ...core.Option) *Harness {
+	b := mock.NewBroker()
+	r := core.New(b, opts...)
+	r.Default(h)
+	return &Harness{router: r, broker: b}
+}
+
+// Use adds middleware in the same order as Router.Use: the first registered
+// runs outermost.
+func (h *Harness) Use(mws ...core.Middleware) *Harness {
+	for _, mw := range mws {
+		h.router.Use(mw)
+	}
+	return h
+}
+
+// Publisher returns the Publisher behind the Harness, for middleware such as
+// middleware.Tap that publishes. Its messages appear in Published.
+func (h *Harness) Publisher() core.Publisher { return h.router }
+
+// Run delivers msg on topic through the middleware and handler and returns
+// the error the broker would see once the Router has settled it.
+func (h *Harness) Run(ctx context.Context, topic string, msg *Message) error {
+	msg.topic = topic
+	return h.router.Dispatch(ctx, topic, msg)
+}
+
+// Published returns every message published so far, in order.
+func (h *Harness) Published() []Published {
+	return published(h.broker.Published())
+}
+
+// PublishedTo returns the messages published to topic so far, in order.
+func (h *Harness) PublishedTo(topic string) []Published {
+	return published(h.broker.PublishedTo(topic))
+}
+
+func published(pms []mock.PublishedMessage) []Published {
+	out := make([]Published, len(pms))
+	for i, pm := range pms {
+		out[i] = Published{Topic: pm.Topic, Key: pm.Key, Value: pm.Value, Headers: pm.Headers}
+	}
+	return out
+}
+
+// Order records the order in which middleware and handlers are entered.
+// The zero value is ready to use.
+type Order struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+// Middleware wraps mw so that name is recorded each time mw is entered.
+func (o *Order) Middleware(name string, mw core.Middleware) core.Middleware {
+	return func(next core.Handler) core.Handler {
+		inner := mw(next)
+		return func(ctx context.Context, msg core.Message) error {
+			o.record(name)
+			return inner(ctx, msg)
+		}
+	}
+}
+
+// Mark returns pass-through middleware that records name when entered.
+func (o *Order) Mark(name string) core.Middleware {
+	return o.Middleware(name, func(next core.Handler) core.Handler { return next })
+}
+
+// Handler wraps h so that name is recorded each time h runs.
+func (o *Order) Handler(name string, h core.Handler) core.Handler {
+	return func(ctx context.Context, msg core.Message) error {
+		o.record(name)
+		return h(ctx, msg)
+	}
+}
+
+// Steps returns the recorded names in order. A middleware that
+// short-circuits leaves every later name out.
+func (o *Order) Steps() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.steps...)
+}
+
+// Reset clears the recorded names.
+func (o *Order) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.steps = nil
+}
+
+func (o *Order) record(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.steps = append(o.steps, name)
+}
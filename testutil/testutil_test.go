@@ -0,0 +1,127 @@
+package testutil_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miladsoleymani/eventmux/core"
+	"github.com/miladsoleymani/eventmux/core/middleware"
+	"github.com/miladsoleymani/eventmux/testutil"
+)
+
+func TestHarness_Results(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     error
+		wantAck    bool
+		wantNack   bool
+		wantDLQ    bool
+		wantErr    bool
+		wantSettle bool
+	}{
+		{"ack", core.AckResult(), true, false, false, false, true},
+		{"nack", core.NackResult(), false, true, false, false, true},
+		{"dlq", core.DLQResult("bad payload"), true, false, true, false, true},
+		{"plain error", errors.New("boom"), false, false, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testutil.New(func(context.Context, core.Message) error { return tt.result },
+				core.WithDeadLetterTopic("orders.dlq"))
+			msg := testutil.NewMessage([]byte(`{}`), map[string]string{"tenant": "acme"})
+
+			err := h.Run(context.Background(), "orders", msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run = %v, wantErr %v", err, tt.wantErr)
+			}
+			if msg.Acked() != tt.wantAck || msg.Nacked() != tt.wantNack || msg.Settled() != tt.wantSettle {
+				t.Errorf("acked/nacked/settled = %v/%v/%v, want %v/%v/%v",
+					msg.Acked(), msg.Nacked(), msg.Settled(), tt.wantAck, tt.wantNack, tt.wantSettle)
+			}
+			dlq := h.PublishedTo("orders.dlq")
+			if got := len(dlq) == 1; got != tt.wantDLQ {
+				t.Fatalf("dead-lettered %d messages, want DLQ %v", len(dlq), tt.wantDLQ)
+			}
+			if tt.wantDLQ {
+				if dlq[0].Headers[core.HeaderDeadLetterReason] != "bad payload" || dlq[0].Headers["tenant"] != "acme" {
+					t.Errorf("dead-letter headers = %v", dlq[0].Headers)
+				}
+			}
+		})
+	}
+}
+
+func TestHarness_NackWithDelay(t *testing.T) {
+	h := testutil.New(func(ctx context.Context, msg core.Message) error {
+		return msg.(core.DelayedNacker).NackWithDelay(time.Second)
+	})
+	msg := testutil.NewMessage(nil, nil)
+	if err := h.Run(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !msg.Nacked() || msg.NackDelay() != time.Second {
+		t.Errorf("nacked = %v, delay = %v; want true, 1s", msg.Nacked(), msg.NackDelay())
+	}
+}
+
+func TestHarness_PublisherRecordsRepublish(t *testing.T) {
+	h := testutil.New(func(context.Context, core.Message) error { return core.AckResult() })
+	h.Use(middleware.Tap(h.Publisher(), "orders.tap", 1))
+
+	msg := testutil.NewMessage([]byte("v"), nil).WithKey([]byte("k"))
+	if err := h.Run(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	pub := h.Published()
+	if len(pub) != 1 || pub[0].Topic != "orders.tap" || string(pub[0].Key) != "k" || string(pub[0].Value) != "v" {
+		t.Errorf("published %+v, want one copy on orders.tap", pub)
+	}
+	if msg.Topic() != "orders" {
+		t.Errorf("Topic = %q, want orders", msg.Topic())
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var order testutil.Order
+	h := testutil.New(order.Handler("handler", func(context.Context, core.Message) error {
+		return core.AckResult()
+	}))
+	h.Use(order.Mark("first"), order.Mark("second"), order.Mark("third"))
+
+	if err := h.Run(context.Background(), "orders", testutil.NewMessage(nil, nil)); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []string{"first", "second", "third", "handler"}; !slices.Equal(order.Steps(), want) {
+		t.Errorf("steps = %v, want %v", order.Steps(), want)
+	}
+}
+
+func TestOrder_ShortCircuit(t *testing.T) {
+	var order testutil.Order
+	reject := func(core.Handler) core.Handler {
+		return func(context.Context, core.Message) error { return core.NackResult() }
+	}
+	h := testutil.New(order.Handler("handler", func(context.Context, core.Message) error {
+		return core.AckResult()
+	}))
+	h.Use(order.Mark("first"), order.Middleware("reject", reject), order.Mark("after"))
+
+	msg := testutil.NewMessage(nil, nil)
+	if err := h.Run(context.Background(), "orders", msg); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []string{"first", "reject"}; !slices.Equal(order.Steps(), want) {
+		t.Errorf("steps = %v, want %v", order.Steps(), want)
+	}
+	if !msg.Nacked() || msg.Acked() {
+		t.Error("short-circuited message should be nacked only")
+	}
+
+	order.Reset()
+	if len(order.Steps()) != 0 {
+		t.Errorf("steps after Reset = %v", order.Steps())
+	}
+}